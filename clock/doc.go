@@ -0,0 +1,2 @@
+// Package clock provides a fake core.Clock for deterministic tests and simulations.
+package clock
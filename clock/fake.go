@@ -0,0 +1,134 @@
+package clock
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// FakeClock implements a core.Clock that only moves when told to.  Timers created from the clock fire when the clock
+// is advanced past their deadline.  It is safe for concurrent use.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock will create a new FakeClock set to the given start time.
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a new Timer that fires once the clock has been advanced by at least d.  A timer with d <= 0 fires
+// immediately.
+func (c *FakeClock) NewTimer(d time.Duration) core.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{
+		clock:    c,
+		c:        make(chan time.Time, 1),
+		deadline: c.now.Add(d),
+	}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d and fires every timer whose deadline has been reached, in deadline order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(c.now.Add(d))
+}
+
+// Set moves the clock to t and fires every timer whose deadline has been reached.  Moving the clock backwards does
+// not fire any timer.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setLocked(t)
+}
+
+func (c *FakeClock) setLocked(t time.Time) {
+	c.now = t
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	remaining := c.timers[:0]
+	for _, timer := range c.timers {
+		if timer.deadline.After(t) {
+			remaining = append(remaining, timer)
+			continue
+		}
+		timer.c <- t
+	}
+	for i := len(remaining); i < len(c.timers); i++ {
+		c.timers[i] = nil
+	}
+	c.timers = remaining
+	c.cond.Broadcast()
+}
+
+// PendingTimers returns the number of timers that have not fired or been stopped.
+func (c *FakeClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers are pending, i.e. to wait until a goroutine under test is blocked on a
+// timer before advancing the clock.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *FakeClock) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return fmt.Sprintf("FakeClock{now=%v, pendingTimers=%d}", c.now, len(c.timers))
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Advance", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		start := time.Unix(100, 0)
+		c := NewFakeClock(start)
+		asrt.Equal(start, c.Now())
+		c.Advance(time.Second)
+		asrt.Equal(start.Add(time.Second), c.Now())
+		c.Set(start)
+		asrt.Equal(start, c.Now())
+	})
+
+	t.Run("Timers", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		c := NewFakeClock(time.Unix(0, 0))
+		short := c.NewTimer(time.Second)
+		long := c.NewTimer(time.Minute)
+		stopped := c.NewTimer(time.Second)
+		asrt.Equal(3, c.PendingTimers())
+
+		asrt.True(stopped.Stop())
+		asrt.False(stopped.Stop())
+
+		c.Advance(999 * time.Millisecond)
+		select {
+		case <-short.C():
+			asrt.Fail("timer fired early")
+		default:
+		}
+
+		c.Advance(time.Millisecond)
+		asrt.Equal(time.Unix(1, 0), <-short.C())
+		asrt.False(short.Stop())
+		asrt.Equal(1, c.PendingTimers())
+
+		c.Advance(time.Hour)
+		<-long.C()
+		asrt.Equal(0, c.PendingTimers())
+		select {
+		case <-stopped.C():
+			asrt.Fail("stopped timer fired")
+		default:
+		}
+
+		// non-positive durations fire immediately
+		<-c.NewTimer(0).C()
+	})
+
+	t.Run("BlockUntil", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		c := NewFakeClock(time.Unix(0, 0))
+		fired := make(chan time.Time)
+		go func() {
+			fired <- <-c.NewTimer(time.Second).C()
+		}()
+		c.BlockUntil(1)
+		c.Advance(time.Second)
+		asrt.Equal(time.Unix(1, 0), <-fired)
+	})
+}
@@ -0,0 +1,99 @@
+// Command limitreplay replays a trace recorded with recording.Limit through one or more limit algorithms and writes
+// the limit after each sample as CSV or JSON, so that algorithms and their parameters can be compared against the
+// same production traffic.
+//
+// Example:
+//
+//	limitreplay -trace=samples.trace -limit=vegas,gradient2 -initial-limit=20 -format=csv > out.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/recording"
+	"github.com/platinummonkey/go-concurrency-limits/sim"
+)
+
+type options struct {
+	trace        string
+	limits       string
+	initialLimit int
+	maxLimit     int
+	format       string
+	out          string
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.trace, "trace", "", "recorded trace file")
+	flag.StringVar(&opts.limits, "limit", "vegas",
+		"comma separated limit algorithms to replay: "+strings.Join(sim.LimitNames, ", "))
+	flag.IntVar(&opts.initialLimit, "initial-limit", 20, "initial limit")
+	flag.IntVar(&opts.maxLimit, "max-limit", 1000, "maximum limit for algorithms that support one")
+	flag.StringVar(&opts.format, "format", "csv", "output format: csv or json")
+	flag.StringVar(&opts.out, "out", "", "output file, defaults to stdout")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintf(os.Stderr, "limitreplay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	if opts.trace == "" {
+		return fmt.Errorf("trace must be provided")
+	}
+	f, err := os.Open(opts.trace)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader, err := recording.NewReader(f)
+	if err != nil {
+		return err
+	}
+	samples, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+
+	names := strings.Split(opts.limits, ",")
+	limits := make([]core.Limit, 0, len(names))
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		l, err := sim.NewLimitByName(names[i], opts.initialLimit, opts.maxLimit)
+		if err != nil {
+			return err
+		}
+		limits = append(limits, l)
+	}
+	result, err := recording.NewResult(samples, names, limits)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if opts.out != "" {
+		out, err := os.Create(opts.out)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		w = out
+	}
+
+	switch opts.format {
+	case "csv":
+		return result.WriteCSV(w)
+	case "json":
+		return result.WriteJSON(w)
+	default:
+		return fmt.Errorf("unknown format %q", opts.format)
+	}
+}
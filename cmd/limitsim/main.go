@@ -0,0 +1,168 @@
+// Command limitsim simulates a limit algorithm and strategy against a modeled backend in virtual time and writes the
+// resulting time series of the limit, in-flight count, latency and rejections as CSV or JSON.
+//
+// Example:
+//
+//	limitsim -limit=gradient2 -rate=2000 -service-time=exp:10ms -capacity=30 -duration=5m -format=csv > out.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/sim"
+	"github.com/platinummonkey/go-concurrency-limits/strategy"
+)
+
+type options struct {
+	limit        string
+	strategy     string
+	initialLimit int
+	maxLimit     int
+	duration     time.Duration
+	interval     time.Duration
+	arrivals     string
+	rate         float64
+	serviceTime  string
+	capacity     int
+	degradation  float64
+	failureRate  float64
+	timeout      time.Duration
+	outageStart  time.Duration
+	outageEnd    time.Duration
+	outageRate   float64
+	outageFactor float64
+	seed         int64
+	format       string
+	out          string
+}
+
+func main() {
+	opts := options{}
+	flag.StringVar(&opts.limit, "limit", "vegas", "limit algorithm: "+strings.Join(sim.LimitNames, ", "))
+	flag.StringVar(&opts.strategy, "strategy", "simple", "enforcement strategy: simple or precise")
+	flag.IntVar(&opts.initialLimit, "initial-limit", 20, "initial limit")
+	flag.IntVar(&opts.maxLimit, "max-limit", 1000, "maximum limit for algorithms that support one")
+	flag.DurationVar(&opts.duration, "duration", time.Minute, "virtual time to simulate")
+	flag.DurationVar(&opts.interval, "interval", time.Second, "width of each output point")
+	flag.StringVar(&opts.arrivals, "arrivals", "poisson", "arrival process: poisson or constant")
+	flag.Float64Var(&opts.rate, "rate", 100, "arrival rate in requests per second")
+	flag.StringVar(&opts.serviceTime, "service-time", "exp:10ms",
+		"service time distribution: const:D, uniform:MIN,MAX, exp:MEAN, normal:MEAN,STDDEV or lognormal:MEDIAN,SIGMA")
+	flag.IntVar(&opts.capacity, "capacity", 0, "backend concurrency knee, 0 disables")
+	flag.Float64Var(&opts.degradation, "degradation", 1, "service time growth past the knee")
+	flag.Float64Var(&opts.failureRate, "failure-rate", 0, "probability of a request failing")
+	flag.DurationVar(&opts.timeout, "timeout", 0, "request timeout, 0 disables")
+	flag.DurationVar(&opts.outageStart, "outage-start", 0, "start of an injected outage")
+	flag.DurationVar(&opts.outageEnd, "outage-end", 0, "end of an injected outage, 0 disables the outage")
+	flag.Float64Var(&opts.outageRate, "outage-failure-rate", 0, "probability of a request failing during the outage")
+	flag.Float64Var(&opts.outageFactor, "outage-latency", 0, "service time multiplier during the outage")
+	flag.Int64Var(&opts.seed, "seed", 1, "random seed")
+	flag.StringVar(&opts.format, "format", "csv", "output format: csv or json")
+	flag.StringVar(&opts.out, "out", "", "output file, defaults to stdout")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintf(os.Stderr, "limitsim: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	config, err := buildConfig(opts)
+	if err != nil {
+		return err
+	}
+	l, err := sim.NewLimitByName(opts.limit, opts.initialLimit, opts.maxLimit)
+	if err != nil {
+		return err
+	}
+	s, err := buildStrategy(opts)
+	if err != nil {
+		return err
+	}
+
+	result, err := sim.Run(config, sim.NewDefaultLimiterFactory(l, s))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if opts.out != "" {
+		f, err := os.Create(opts.out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch opts.format {
+	case "csv":
+		return result.WriteCSV(w)
+	case "json":
+		return result.WriteJSON(w)
+	default:
+		return fmt.Errorf("unknown format %q", opts.format)
+	}
+}
+
+func buildConfig(opts options) (sim.Config, error) {
+	if opts.rate <= 0 {
+		return sim.Config{}, fmt.Errorf("rate must be > 0")
+	}
+	var arrivals sim.ArrivalProcess
+	switch opts.arrivals {
+	case "poisson":
+		arrivals = sim.PoissonArrivals{Rate: opts.rate}
+	case "constant":
+		arrivals = sim.ConstantArrivals{Rate: opts.rate}
+	default:
+		return sim.Config{}, fmt.Errorf("unknown arrival process %q", opts.arrivals)
+	}
+
+	serviceTime, err := sim.ParseDistribution(opts.serviceTime)
+	if err != nil {
+		return sim.Config{}, err
+	}
+
+	backend := sim.Backend{
+		ServiceTime: serviceTime,
+		Capacity:    opts.capacity,
+		Degradation: opts.degradation,
+		FailureRate: opts.failureRate,
+		Timeout:     opts.timeout,
+	}
+	if opts.outageEnd > 0 {
+		backend.Failures = append(backend.Failures, sim.FailureWindow{
+			Start:             opts.outageStart,
+			End:               opts.outageEnd,
+			FailureRate:       opts.outageRate,
+			LatencyMultiplier: opts.outageFactor,
+		})
+	}
+
+	return sim.Config{
+		Duration: opts.duration,
+		Interval: opts.interval,
+		Arrivals: arrivals,
+		Backend:  backend,
+		Seed:     opts.seed,
+	}, nil
+}
+
+func buildStrategy(opts options) (core.Strategy, error) {
+	switch opts.strategy {
+	case "simple":
+		return strategy.NewSimpleStrategy(opts.initialLimit), nil
+	case "precise":
+		return strategy.NewPreciseStrategy(opts.initialLimit), nil
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", opts.strategy)
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Clock is the source of time used by limiters and limits.  Inject a fake clock, i.e. clock.FakeClock, to drive
+// them deterministically in tests and simulations.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// NewTimer creates a new Timer that will send the current time on its channel after at least duration d.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single event timer created by a Clock.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+	// Stop prevents the Timer from firing.  Returns false if the timer already fired or was stopped.
+	Stop() bool
+}
+
+// SystemClock implements a Clock using the time package.
+type SystemClock struct{}
+
+// SystemClockInstance is a singleton system clock instance.
+var SystemClockInstance = SystemClock{}
+
+// Now returns the current local time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a new time.Timer.
+func (SystemClock) NewTimer(d time.Duration) Timer {
+	return &systemTimer{timer: time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	timer *time.Timer
+}
+
+func (t *systemTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *systemTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// RandomSource is the source of randomness used by limits, i.e. for probe jitter.  A *rand.Rand satisfies this
+// interface, use NewSeededRandomSource for reproducible sequences.
+type RandomSource interface {
+	// Float64 returns a pseudo-random number in [0.0,1.0).
+	Float64() float64
+	// Intn returns a non-negative pseudo-random number in [0,n).  It panics if n <= 0.
+	Intn(n int) int
+}
+
+// SystemRandomSource implements a RandomSource using the global math/rand source, which is safe for concurrent use.
+type SystemRandomSource struct{}
+
+// SystemRandomSourceInstance is a singleton system random source instance.
+var SystemRandomSourceInstance = SystemRandomSource{}
+
+// Float64 returns a pseudo-random number in [0.0,1.0) from the global source.
+func (SystemRandomSource) Float64() float64 {
+	return rand.Float64()
+}
+
+// Intn returns a non-negative pseudo-random number in [0,n) from the global source.
+func (SystemRandomSource) Intn(n int) int {
+	return rand.Intn(n)
+}
+
+// NewSeededRandomSource returns a RandomSource that produces a reproducible sequence for the given seed.  The
+// returned source is not safe for concurrent use, limits only use it while holding their own lock.
+func NewSeededRandomSource(seed int64) RandomSource {
+	return rand.New(rand.NewSource(seed))
+}
@@ -25,6 +25,23 @@ const (
 	MetricQueueSize = "queue_size"
 	// MetricQueueLimit represents the name of the metric for the max size of a lifo queue
 	MetricQueueLimit = "queue_limit"
+	// MetricRegistryKeys represents the name of the metric for the number of keys held by a limiter registry
+	MetricRegistryKeys = "registry.keys"
+	// MetricRegistryEvicted represents the name of the metric for the number of keys evicted from a limiter registry
+	MetricRegistryEvicted = "registry.evicted"
+	// MetricDeliveryRate represents the name of the metric for the delivery rate, in requests per second, of a sample
+	MetricDeliveryRate = "delivery_rate"
+	// MetricThroughput represents the name of the metric for the throughput, in completed requests per second
+	MetricThroughput = "throughput"
+	// MetricPercentileRTT represents the name of the metric for the estimated percentile of the Round Trip Time
+	MetricPercentileRTT = "rtt.percentile"
+	// MetricErrorRate represents the name of the metric for the ratio of dropped requests
+	MetricErrorRate = "error_rate"
+	// MetricSuppressed represents the name of the metric for the number of suppressed or clipped limit changes, tagged
+	// with the reason
+	MetricSuppressed = "limit.suppressed"
+	// MetricRejected represents the name of the metric for the number of rejected acquisitions
+	MetricRejected = "rejected"
 )
 
 // PrefixMetricWithName will prefix a given name with the metric name in the form "<name>.<metric>"
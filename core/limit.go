@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"time"
 )
 
 // MeasurementInterface defines the contract for tracking a measurement such as a minimum or average of a sample set.
@@ -58,6 +59,46 @@ type Limit interface {
 	OnSample(startTime int64, rtt int64, inFlight int, didDrop bool)
 }
 
+// ThroughputLimit is a Limit that also uses the throughput of the resource.  Limiters that aggregate samples into
+// windows call OnThroughput with the number of requests released during each window before calling OnSample.
+type ThroughputLimit interface {
+	Limit
+
+	// OnThroughput records that completed requests were released over duration.
+	OnThroughput(completed int, duration time.Duration)
+}
+
+// RTTObservingLimit is a Limit that uses the RTT of every request rather than one sample per window, i.e. to track a
+// latency percentile.  Limiters that aggregate samples into windows call ObserveRTT for every successful request.
+type RTTObservingLimit interface {
+	Limit
+
+	// ObserveRTT records the RTT of a single request in nanoseconds, it is called on the request path and must be
+	// cheap.
+	ObserveRTT(rtt int64)
+}
+
+// DropRateLimit is a Limit that uses the ratio of dropped requests rather than whether any request was dropped.
+// Limiters that aggregate samples into windows call OnDropRate with the number of dropped requests and the number of
+// requests of each window before calling OnSample.
+type DropRateLimit interface {
+	Limit
+
+	// OnDropRate records that dropped out of total requests were dropped.
+	OnDropRate(dropped int, total int)
+}
+
+// IdleLimit is a Limit that adjusts itself once it stops receiving samples, i.e. decays a limit learned during a burst
+// back toward its initial limit.  Limiters that aggregate samples into windows call CheckIdle from the request path at
+// most once per window, so that the limit is adjusted before a new burst is admitted.
+type IdleLimit interface {
+	Limit
+
+	// CheckIdle applies the idle policy of the limit if it has been idle long enough, listeners are notified if the
+	// limit changed.
+	CheckIdle()
+}
+
 // Listener implements token listener for callback to the limiter when and how it should be released.
 type Listener interface {
 	// OnSuccess is called as a notification that the operation succeeded and internally measured latency should be
@@ -83,3 +124,29 @@ type Limiter interface {
 	// context - Context for the request. The context is used by advanced strategies such as LookupPartitionStrategy.
 	Acquire(ctx context.Context) (listener Listener, ok bool)
 }
+
+// WeightedLimiter is a Limiter that supports acquiring more than a single unit of concurrency per request.
+type WeightedLimiter interface {
+	Limiter
+
+	// AcquireN acquires a token worth weight units of concurrency from the limiter.  A weight < 1 is treated as 1.
+	// Returns a nil listener if the limit has been exceeded.  If acquired the caller must call one of the Listener
+	// methods when the operation has been completed to release all of the acquired weight.
+	//
+	// context - Context for the request. The context is used by advanced strategies such as LookupPartitionStrategy.
+	AcquireN(ctx context.Context, weight int) (listener Listener, ok bool)
+}
+
+// DrainableLimiter is a Limiter that can be shut down gracefully, i.e. from http.Server.RegisterOnShutdown or before
+// grpc.Server.GracefulStop.
+type DrainableLimiter interface {
+	Limiter
+
+	// Close the limiter.  Every new acquisition is rejected immediately and every caller waiting for a token is woken
+	// and rejected.  Tokens that were already acquired are unaffected.  Calling Close more than once is a no-op.
+	Close()
+
+	// Drain will Close the limiter and then block until every outstanding listener has been released.  Returns the
+	// context error if ctx is done first.
+	Drain(ctx context.Context) error
+}
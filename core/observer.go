@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// RejectReason describes why a limiter rejected an acquisition attempt.
+type RejectReason string
+
+const (
+	// RejectReasonLimitExceeded is used when the concurrency limit has been reached.
+	RejectReasonLimitExceeded RejectReason = "limit_exceeded"
+	// RejectReasonQueueFull is used when the limit has been reached and the backlog is at capacity.
+	RejectReasonQueueFull RejectReason = "queue_full"
+	// RejectReasonQueueTimeout is used when a caller waited in the backlog for longer than the allowed timeout.
+	RejectReasonQueueTimeout RejectReason = "queue_timeout"
+	// RejectReasonQueueDropped is used when a caller was dropped from the head of the backlog to shorten a standing
+	// queue.
+	RejectReasonQueueDropped RejectReason = "queue_dropped"
+	// RejectReasonDeadlineExceeded is used when the limiter deadline passed before a token could be acquired.
+	RejectReasonDeadlineExceeded RejectReason = "deadline_exceeded"
+	// RejectReasonContextDone is used when the request context was cancelled or expired before a token could be
+	// acquired.
+	RejectReasonContextDone RejectReason = "context_done"
+	// RejectReasonClosed is used when the limiter has been closed.
+	RejectReasonClosed RejectReason = "closed"
+	// RejectReasonUnsupported is used when the limiter can never acquire the request, i.e. a weight other than 1 with a
+	// delegate or strategy that only accounts for single units.  Waiting does not help, so it is never retried.
+	RejectReasonUnsupported RejectReason = "unsupported"
+)
+
+// ReleaseOutcome describes which Listener method released an acquired token.
+type ReleaseOutcome string
+
+const (
+	// ReleaseOutcomeSuccess is used when the token was released with Listener.OnSuccess.
+	ReleaseOutcomeSuccess ReleaseOutcome = "success"
+	// ReleaseOutcomeIgnore is used when the token was released with Listener.OnIgnore.
+	ReleaseOutcomeIgnore ReleaseOutcome = "ignore"
+	// ReleaseOutcomeDropped is used when the token was released with Listener.OnDropped.
+	ReleaseOutcomeDropped ReleaseOutcome = "dropped"
+)
+
+// LimiterObserver receives a callback for every decision a limiter makes about a single request, i.e. to feed an
+// audit log or a tracing system.  Callbacks are made synchronously on the request path and must not block.
+//
+// Embed NoopLimiterObserver to only implement the callbacks of interest.
+type LimiterObserver interface {
+	// OnAcquireAttempt is called when Acquire is called, before any decision is made.
+	OnAcquireAttempt(ctx context.Context)
+	// OnAcquired is called when a token was acquired.  inFlight is the in flight count observed at acquisition, or 0
+	// if the limiter is unable to tell.
+	OnAcquired(ctx context.Context, inFlight int)
+	// OnRejected is called when the acquisition attempt was rejected.
+	OnRejected(ctx context.Context, reason RejectReason)
+	// OnQueued is called when the caller starts waiting for a token.  queueDepth includes the caller.
+	OnQueued(ctx context.Context, queueDepth int)
+	// OnDequeued is called when the caller stops waiting, whether or not a token was acquired.
+	OnDequeued(ctx context.Context, waited time.Duration)
+	// OnReleased is called when an acquired token is released with the round trip time since it was acquired.
+	OnReleased(ctx context.Context, outcome ReleaseOutcome, rtt time.Duration)
+}
+
+// ObservableLimiter is implemented by limiters that accept a LimiterObserver.
+type ObservableLimiter interface {
+	// AddObserver will register an observer to receive the lifecycle callbacks of every request.
+	AddObserver(observer LimiterObserver)
+}
+
+// NoopLimiterObserver implements a LimiterObserver that ignores everything.
+type NoopLimiterObserver struct{}
+
+// OnAcquireAttempt is called when Acquire is called.
+func (NoopLimiterObserver) OnAcquireAttempt(ctx context.Context) {}
+
+// OnAcquired is called when a token was acquired.
+func (NoopLimiterObserver) OnAcquired(ctx context.Context, inFlight int) {}
+
+// OnRejected is called when the acquisition attempt was rejected.
+func (NoopLimiterObserver) OnRejected(ctx context.Context, reason RejectReason) {}
+
+// OnQueued is called when the caller starts waiting for a token.
+func (NoopLimiterObserver) OnQueued(ctx context.Context, queueDepth int) {}
+
+// OnDequeued is called when the caller stops waiting.
+func (NoopLimiterObserver) OnDequeued(ctx context.Context, waited time.Duration) {}
+
+// OnReleased is called when an acquired token is released.
+func (NoopLimiterObserver) OnReleased(ctx context.Context, outcome ReleaseOutcome, rtt time.Duration) {
+}
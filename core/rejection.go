@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// RejectReasonPartitionExceeded is used when the limit has been reached and the partition of the request is over its
+// share of the limit.
+const RejectReasonPartitionExceeded RejectReason = "partition_exceeded"
+
+// RejectReasonTagName represents the metric tag used for the rejection reason.
+const RejectReasonTagName = "reason"
+
+// RejectionError is returned by ReasonLimiter when a token was not acquired.  Use errors.Is with one of the Err
+// sentinels to test for a reason regardless of the partition.
+type RejectionError struct {
+	// Reason the acquisition was rejected.
+	Reason RejectReason
+	// Partition is the name of the partition that exceeded its limit, only set for RejectReasonPartitionExceeded.
+	Partition string
+}
+
+// Sentinel rejection errors for use with errors.Is.
+var (
+	ErrLimitExceeded     = &RejectionError{Reason: RejectReasonLimitExceeded}
+	ErrPartitionExceeded = &RejectionError{Reason: RejectReasonPartitionExceeded}
+	ErrQueueFull         = &RejectionError{Reason: RejectReasonQueueFull}
+	ErrQueueTimeout      = &RejectionError{Reason: RejectReasonQueueTimeout}
+	ErrQueueDropped      = &RejectionError{Reason: RejectReasonQueueDropped}
+	ErrDeadlineExceeded  = &RejectionError{Reason: RejectReasonDeadlineExceeded}
+	ErrContextDone       = &RejectionError{Reason: RejectReasonContextDone}
+	ErrLimiterClosed     = &RejectionError{Reason: RejectReasonClosed}
+	ErrUnsupported       = &RejectionError{Reason: RejectReasonUnsupported}
+)
+
+// NewRejectionError will create a RejectionError for the given reason.
+func NewRejectionError(reason RejectReason) *RejectionError {
+	return &RejectionError{Reason: reason}
+}
+
+// NewPartitionRejectionError will create a RejectionError for a partition that exceeded its limit.
+func NewPartitionRejectionError(partition string) *RejectionError {
+	return &RejectionError{Reason: RejectReasonPartitionExceeded, Partition: partition}
+}
+
+func (e *RejectionError) Error() string {
+	if e.Partition != "" {
+		return fmt.Sprintf("concurrency limit rejected: %s partition=%s", e.Reason, e.Partition)
+	}
+	return fmt.Sprintf("concurrency limit rejected: %s", e.Reason)
+}
+
+// Is reports whether target is a RejectionError with the same reason, and the same partition if target names one.
+func (e *RejectionError) Is(target error) bool {
+	t, ok := target.(*RejectionError)
+	if !ok {
+		return false
+	}
+	return e.Reason == t.Reason && (t.Partition == "" || e.Partition == t.Partition)
+}
+
+// ReasonLimiter is a Limiter that can explain why an acquisition was rejected.
+type ReasonLimiter interface {
+	Limiter
+
+	// AcquireWithReason will acquire a token like Acquire, returning a *RejectionError when it is rejected.
+	AcquireWithReason(ctx context.Context) (Listener, error)
+}
+
+// WeightedReasonLimiter is a WeightedLimiter that can explain why an acquisition was rejected.
+type WeightedReasonLimiter interface {
+	WeightedLimiter
+
+	// AcquireNWithReason will acquire a token like AcquireN, returning a *RejectionError when it is rejected.
+	AcquireNWithReason(ctx context.Context, weight int) (Listener, error)
+}
+
+// AcquireWithReason will acquire a token from any limiter, returning a *RejectionError when it is rejected.  Limiters
+// that do not implement ReasonLimiter report RejectReasonContextDone if ctx is done and RejectReasonLimitExceeded
+// otherwise.
+func AcquireWithReason(ctx context.Context, limiter Limiter) (Listener, error) {
+	if l, ok := limiter.(ReasonLimiter); ok {
+		return l.AcquireWithReason(ctx)
+	}
+	listener, ok := limiter.Acquire(ctx)
+	if ok && listener != nil {
+		return listener, nil
+	}
+	if ctx.Err() != nil {
+		return nil, NewRejectionError(RejectReasonContextDone)
+	}
+	return nil, NewRejectionError(RejectReasonLimitExceeded)
+}
+
+// RejectingStrategyToken is implemented by strategy tokens that describe why they were not acquired.
+type RejectingStrategyToken interface {
+	StrategyToken
+
+	// Rejection returns why the token was not acquired, or nil if the strategy did not say.
+	Rejection() *RejectionError
+}
+
+// RejectionCounter counts rejections in a MetricRegistry, tagged with the reason and partition.
+type RejectionCounter struct {
+	registry MetricRegistry
+	id       string
+	tags     []string
+	counters sync.Map
+}
+
+// NewRejectionCounter will create a RejectionCounter registering the "<name>.rejected" count.
+func NewRejectionCounter(registry MetricRegistry, name string, tags ...string) *RejectionCounter {
+	if registry == nil {
+		registry = EmptyMetricRegistryInstance
+	}
+	return &RejectionCounter{
+		registry: registry,
+		id:       PrefixMetricWithName(MetricRejected, name),
+		tags:     tags,
+	}
+}
+
+// Add will count a rejection.  Errors that are not a *RejectionError are counted as RejectReasonLimitExceeded.
+func (c *RejectionCounter) Add(err error) {
+	rejection, ok := err.(*RejectionError)
+	if !ok || rejection == nil {
+		rejection = ErrLimitExceeded
+	}
+	key := *rejection
+	counter, ok := c.counters.Load(key)
+	if !ok {
+		tags := append(append([]string(nil), c.tags...), fmt.Sprintf("%s:%s", RejectReasonTagName, key.Reason))
+		if key.Partition != "" {
+			tags = append(tags, fmt.Sprintf("partition:%s", key.Partition))
+		}
+		counter, _ = c.counters.LoadOrStore(key, c.registry.RegisterCount(c.id, tags...))
+	}
+	counter.(MetricSampleListener).AddSample(1)
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"time"
+)
+
+// RetryAfterEstimator is implemented by limiters that can suggest how long a rejected caller should wait before
+// retrying, estimated from the queue depth, the observed release rate and the RTT.
+type RetryAfterEstimator interface {
+	// RetryAfter returns the suggested delay before retrying, or 0 if there is not enough data for an estimate.
+	RetryAfter() time.Duration
+}
+
+// RetryAfter returns the suggested retry delay of the limiter, or 0 if it is not a RetryAfterEstimator or has no
+// estimate.
+func RetryAfter(limiter Limiter) time.Duration {
+	if e, ok := limiter.(RetryAfterEstimator); ok {
+		if d := e.RetryAfter(); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// RetryableRejection returns true if a retry after a rejection with the given reason is useful, that is the caller did
+// not give up, the deadline has not passed and the limiter supports the request.
+func RetryableRejection(reason RejectReason) bool {
+	switch reason {
+	case RejectReasonContextDone, RejectReasonDeadlineExceeded, RejectReasonUnsupported:
+		return false
+	default:
+		return true
+	}
+}
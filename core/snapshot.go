@@ -0,0 +1,106 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Snapshot is a structured, point-in-time view of a Limiter, Limit or Strategy.  Decorators include the snapshot of
+// the component they wrap so that a full limiter chain can be walked and rendered.
+//
+// Fields that do not apply to a given component are left at their zero value.
+type Snapshot struct {
+	// Type is the name of the component type, i.e. "DefaultLimiter" or "VegasLimit".
+	Type string `json:"type"`
+	// Name is the component name if it has one, i.e. a partition name.
+	Name string `json:"name,omitempty"`
+	// Limit is the current concurrency limit.
+	Limit int `json:"limit"`
+	// InFlight is the current number of acquired and unreleased tokens.
+	InFlight int `json:"inFlight"`
+	// QueueDepth is the number of callers currently waiting for a token.
+	QueueDepth int `json:"queueDepth,omitempty"`
+	// CandidateRTTNanoseconds is the candidate (traditionally the minimum) RTT of the current sample window.
+	CandidateRTTNanoseconds int64 `json:"candidateRttNs,omitempty"`
+	// Dropped is the total number of released tokens that were reported as dropped.
+	Dropped uint64 `json:"dropped,omitempty"`
+	// Rejected is the total number of acquisition attempts that were rejected.
+	Rejected uint64 `json:"rejected,omitempty"`
+	// Attributes holds additional type specific values.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// Algorithm is the snapshot of the Limit algorithm used by a limiter.
+	Algorithm *Snapshot `json:"algorithm,omitempty"`
+	// Strategy is the snapshot of the Strategy used by a limiter.
+	Strategy *Snapshot `json:"strategy,omitempty"`
+	// Delegate is the snapshot of the wrapped component for decorators.
+	Delegate *Snapshot `json:"delegate,omitempty"`
+	// Partitions are the snapshots of the partitions of a partitioned Strategy.
+	Partitions []*Snapshot `json:"partitions,omitempty"`
+	// Members are the snapshots of the algorithms combined by a composite Limit.
+	Members []*Snapshot `json:"members,omitempty"`
+}
+
+// Inspectable is implemented by components that can report a structured Snapshot of their current state.
+type Inspectable interface {
+	// Snapshot returns the current state.
+	Snapshot() Snapshot
+}
+
+// SnapshotOf will return the snapshot of the given component if it is Inspectable, otherwise nil.
+func SnapshotOf(component interface{}) *Snapshot {
+	if i, ok := component.(Inspectable); ok {
+		s := i.Snapshot()
+		return &s
+	}
+	return nil
+}
+
+// Walk will call fn for this snapshot and every nested snapshot in depth first order.  The depth of the root
+// snapshot is 0.
+func (s *Snapshot) Walk(fn func(depth int, snapshot *Snapshot)) {
+	s.walk(0, fn)
+}
+
+func (s *Snapshot) walk(depth int, fn func(depth int, snapshot *Snapshot)) {
+	if s == nil {
+		return
+	}
+	fn(depth, s)
+	s.Algorithm.walk(depth+1, fn)
+	s.Strategy.walk(depth+1, fn)
+	for _, p := range s.Partitions {
+		p.walk(depth+1, fn)
+	}
+	for _, m := range s.Members {
+		m.walk(depth+1, fn)
+	}
+	s.Delegate.walk(depth+1, fn)
+}
+
+// Render returns a human readable, indented tree of the snapshot chain.
+func (s *Snapshot) Render() string {
+	var b strings.Builder
+	s.Walk(func(depth int, snapshot *Snapshot) {
+		b.WriteString(strings.Repeat("  ", depth))
+		b.WriteString(snapshot.Type)
+		if snapshot.Name != "" {
+			fmt.Fprintf(&b, "(%s)", snapshot.Name)
+		}
+		fmt.Fprintf(&b, " limit=%d inFlight=%d", snapshot.Limit, snapshot.InFlight)
+		if snapshot.QueueDepth > 0 {
+			fmt.Fprintf(&b, " queueDepth=%d", snapshot.QueueDepth)
+		}
+		if snapshot.CandidateRTTNanoseconds > 0 {
+			fmt.Fprintf(&b, " candidateRTT=%dns", snapshot.CandidateRTTNanoseconds)
+		}
+		if snapshot.Dropped > 0 {
+			fmt.Fprintf(&b, " dropped=%d", snapshot.Dropped)
+		}
+		if snapshot.Rejected > 0 {
+			fmt.Fprintf(&b, " rejected=%d", snapshot.Rejected)
+		}
+		b.WriteString("\n")
+	})
+	return b.String()
+}
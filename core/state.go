@@ -0,0 +1,57 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LimitStateVersion is the version of the LimitState format written by this package.  States of a newer version are
+// rejected on import.
+const LimitStateVersion = 1
+
+// ErrStateNotSupported is returned when exporting or importing the state of a limit that does not learn any state,
+// i.e. a decorator around a limit that is not a StatefulLimit.
+var ErrStateNotSupported = errors.New("limit state not supported")
+
+// LimitState is the versioned, serializable learned state of a limit algorithm.  It is exported before a restart and
+// imported into a new instance so that it does not have to relearn the limit from its initial value.
+type LimitState struct {
+	// Version is the format version, LimitStateVersion when exported by this package.
+	Version int `json:"version"`
+	// Type is the type of the limit that exported the state, i.e. "VegasLimit".  State is only imported into a limit
+	// of the same type.
+	Type string `json:"type"`
+	// Limit is the estimated limit.
+	Limit float64 `json:"limit"`
+	// Values holds additional type specific learned values, i.e. "rttNoLoad" in nanoseconds.
+	Values map[string]float64 `json:"values,omitempty"`
+	// Members holds the states of the algorithms combined by a composite Limit, in order.  Algorithms that are not a
+	// StatefulLimit have an empty state.
+	Members []LimitState `json:"members,omitempty"`
+}
+
+// Check will return an error if the state can not be imported into a limit of the given type.
+func (s LimitState) Check(limitType string) error {
+	if s.Version < 1 || s.Version > LimitStateVersion {
+		return fmt.Errorf("unsupported limit state version %d", s.Version)
+	}
+	if s.Type != limitType {
+		return fmt.Errorf("limit state of type %s can not be imported into %s", s.Type, limitType)
+	}
+	if s.Limit < 1 {
+		return fmt.Errorf("limit state limit must be >= 1")
+	}
+	return nil
+}
+
+// StatefulLimit is a Limit whose learned state can be exported and restored.
+type StatefulLimit interface {
+	Limit
+
+	// ExportState returns the current learned state.
+	ExportState() (LimitState, error)
+
+	// ImportState replaces the learned state, the limit is clamped to the bounds of the limit and listeners are
+	// notified of the new limit.
+	ImportState(state LimitState) error
+}
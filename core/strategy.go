@@ -19,6 +19,7 @@ type StaticStrategyToken struct {
 	acquired      bool
 	inFlightCount int
 	releaseFunc   func()
+	rejection     *RejectionError
 }
 
 // IsAcquired will return true if the token is acquired
@@ -38,6 +39,11 @@ func (t *StaticStrategyToken) Release() {
 	}
 }
 
+// Rejection returns why the token was not acquired, or nil if unknown.
+func (t *StaticStrategyToken) Rejection() *RejectionError {
+	return t.rejection
+}
+
 // NewNotAcquiredStrategyToken will create a new un-acquired strategy token.
 func NewNotAcquiredStrategyToken(inFlightCount int) StrategyToken {
 	return &StaticStrategyToken{
@@ -47,6 +53,16 @@ func NewNotAcquiredStrategyToken(inFlightCount int) StrategyToken {
 	}
 }
 
+// NewRejectedStrategyToken will create a new un-acquired strategy token that reports why it was rejected.
+func NewRejectedStrategyToken(inFlightCount int, rejection *RejectionError) StrategyToken {
+	return &StaticStrategyToken{
+		acquired:      false,
+		inFlightCount: inFlightCount,
+		releaseFunc:   func() {},
+		rejection:     rejection,
+	}
+}
+
 // NewAcquiredStrategyToken will create a new acquired strategy token.
 func NewAcquiredStrategyToken(inFlightCount int, releaseFunc func()) StrategyToken {
 	return &StaticStrategyToken{
@@ -67,6 +83,18 @@ type Strategy interface {
 	SetLimit(limit int)
 }
 
+// WeightedStrategy is a Strategy that can acquire more than a single unit of concurrency with one token, allowing
+// requests to express their cost, i.e. a large upload can consume more of the limit than a health check.
+type WeightedStrategy interface {
+	Strategy
+
+	// TryAcquireN will try to acquire a token worth weight units of concurrency from the limiter.  A weight < 1 is
+	// treated as 1.  The token reports the weighted in-flight count and releases all weight units at once.
+	// context Context of the request for partitioned limits.
+	// returns not ok if limit is exceeded, or a StrategyToken that must be released when the operation completes.
+	TryAcquireN(ctx context.Context, weight int) (token StrategyToken, ok bool)
+}
+
 // StrategyFactory is a function that creates a Strategy initialized with the given limit.
 // Use StrategyFactory with NewDefaultLimiterWithFactory to ensure the strategy's initial
 // limit is always derived from the Limit algorithm, preventing mismatches between the two.
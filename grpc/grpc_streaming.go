@@ -2,7 +2,8 @@ package grpc
 
 import (
 	golangGrpc "google.golang.org/grpc"
-	"google.golang.org/grpc/status"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
 )
 
 type ssRecvWrapper struct {
@@ -14,12 +15,14 @@ type ssRecvWrapper struct {
 // RecvMsg wrapps the underlying StreamServer RecvMsg with the limiter.
 func (s *ssRecvWrapper) RecvMsg(m interface{}) error {
 	ctx := s.Context()
-	token, ok := s.cfg.recvLimiter.Acquire(ctx)
-	if !ok {
+	token, err := core.AcquireWithReason(ctx, s.cfg.recvLimiter)
+	if err != nil {
+		s.cfg.recvRejections.Add(err)
+		ctx = withRejection(ctx, err)
 		_, errCode, err := s.cfg.recvLimitExceededResponseClassifier(ctx, s.info.FullMethod, m, s.cfg.recvLimiter)
-		return status.Error(errCode, err.Error())
+		return rejectionStatus(ctx, s.cfg.recvLimiter, errCode, err)
 	}
-	err := s.ServerStream.RecvMsg(m)
+	err = s.ServerStream.RecvMsg(m)
 	if err != nil {
 		respType := s.cfg.serverResponseClassifer(ctx, m, s.info, err)
 		switch respType {
@@ -39,12 +42,14 @@ func (s *ssRecvWrapper) RecvMsg(m interface{}) error {
 // SendMsg wrapps the underlying StreamServer SendMsg with the limiter.
 func (s *ssRecvWrapper) SendMsg(m interface{}) error {
 	ctx := s.Context()
-	token, ok := s.cfg.recvLimiter.Acquire(ctx)
-	if !ok {
-		_, errCode, err := s.cfg.sendLimitExceededResponseClassifier(ctx, s.info.FullMethod, m, s.cfg.recvLimiter)
-		return status.Error(errCode, err.Error())
+	token, err := core.AcquireWithReason(ctx, s.cfg.sendLimiter)
+	if err != nil {
+		s.cfg.sendRejections.Add(err)
+		ctx = withRejection(ctx, err)
+		_, errCode, err := s.cfg.sendLimitExceededResponseClassifier(ctx, s.info.FullMethod, m, s.cfg.sendLimiter)
+		return rejectionStatus(ctx, s.cfg.sendLimiter, errCode, err)
 	}
-	err := s.ServerStream.SendMsg(m)
+	err = s.ServerStream.SendMsg(m)
 	if err != nil {
 		respType := s.cfg.clientResponseClassifer(ctx, m, s.info, err)
 		switch respType {
@@ -68,6 +73,8 @@ func StreamServerInterceptor(opts ...StreamInterceptorOption) golangGrpc.StreamS
 	for _, fn := range opts {
 		fn(cfg)
 	}
+	cfg.recvRejections = cfg.newRejectionCounter(cfg.recvName, "default-recv")
+	cfg.sendRejections = cfg.newRejectionCounter(cfg.sendName, "default-send")
 	return func(srv interface{}, ss golangGrpc.ServerStream, info *golangGrpc.StreamServerInfo, handler golangGrpc.StreamHandler) error {
 		wrappedSs := &ssRecvWrapper{
 			ServerStream: ss,
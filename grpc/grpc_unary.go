@@ -4,7 +4,8 @@ import (
 	"context"
 
 	golangGrpc "google.golang.org/grpc"
-	"google.golang.org/grpc/status"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
 )
 
 // UnaryServerInterceptor will trace requests to the given grpc server.
@@ -14,11 +15,14 @@ func UnaryServerInterceptor(opts ...InterceptorOption) golangGrpc.UnaryServerInt
 	for _, fn := range opts {
 		fn(cfg)
 	}
+	rejections := cfg.newRejectionCounter()
 	return func(ctx context.Context, req interface{}, info *golangGrpc.UnaryServerInfo, handler golangGrpc.UnaryHandler) (interface{}, error) {
-		token, ok := cfg.limiter.Acquire(ctx)
-		if !ok {
+		token, err := core.AcquireWithReason(ctx, cfg.limiter)
+		if err != nil {
+			rejections.Add(err)
+			ctx = withRejection(ctx, err)
 			errResp, errCode, err := cfg.limitExceededResponseClassifier(ctx, info.FullMethod, req, cfg.limiter)
-			return errResp, status.Error(errCode, err.Error())
+			return errResp, rejectionStatus(ctx, cfg.limiter, errCode, err)
 		}
 		resp, err := handler(ctx, req)
 		respType := cfg.serverResponseClassifer(ctx, req, info, resp, err)
@@ -41,13 +45,16 @@ func UnaryClientInterceptor(opts ...InterceptorOption) golangGrpc.UnaryClientInt
 	for _, fn := range opts {
 		fn(cfg)
 	}
+	rejections := cfg.newRejectionCounter()
 	return func(ctx context.Context, method string, req, reply interface{}, cc *golangGrpc.ClientConn, invoker golangGrpc.UnaryInvoker, opts ...golangGrpc.CallOption) error {
-		token, ok := cfg.limiter.Acquire(ctx)
-		if !ok {
+		token, err := core.AcquireWithReason(ctx, cfg.limiter)
+		if err != nil {
+			rejections.Add(err)
+			ctx = withRejection(ctx, err)
 			_, errCode, err := cfg.limitExceededResponseClassifier(ctx, method, req, cfg.limiter)
-			return status.Error(errCode, err.Error())
+			return rejectionStatus(ctx, cfg.limiter, errCode, err)
 		}
-		err := invoker(ctx, method, req, reply, cc, opts...)
+		err = invoker(ctx, method, req, reply, cc, opts...)
 		respType := cfg.clientResponseClassifer(ctx, method, req, reply, err)
 		switch respType {
 		case ResponseTypeSuccess:
@@ -55,6 +55,9 @@ type streamInterceptorConfig struct {
 	sendLimitExceededResponseClassifier LimitExceededResponseClassifier
 	serverResponseClassifer             StreamServerResponseClassifier
 	clientResponseClassifer             StreamClientResponseClassifier
+	metricRegistry                      core.MetricRegistry
+	recvRejections                      *core.RejectionCounter
+	sendRejections                      *core.RejectionCounter
 }
 
 // StreamInterceptorOption represents an option that can be passed to the stream
@@ -94,6 +97,14 @@ func streamDefaults(cfg *streamInterceptorConfig) {
 	cfg.serverResponseClassifer = defaultStreamServerResponseClassifier
 }
 
+// newRejectionCounter creates a rejection counter for the given limiter name once every option has been applied.
+func (cfg *streamInterceptorConfig) newRejectionCounter(name string, defaultName string) *core.RejectionCounter {
+	if name == "" {
+		name = defaultName
+	}
+	return core.NewRejectionCounter(cfg.metricRegistry, name, cfg.tags...)
+}
+
 // WithStreamSendName sets the default SendMsg limiter name if the default limiter is used, otherwise unused.
 func WithStreamSendName(name string) StreamInterceptorOption {
 	return func(cfg *streamInterceptorConfig) {
@@ -156,3 +167,11 @@ func WithStreamServerResponseTypeClassifier(classifier StreamServerResponseClass
 		cfg.serverResponseClassifer = classifier
 	}
 }
+
+// WithStreamMetricRegistry sets the registry used to count rejected messages by reason as "<name>.rejected", using the
+// RecvMsg and SendMsg limiter names.
+func WithStreamMetricRegistry(registry core.MetricRegistry) StreamInterceptorOption {
+	return func(cfg *streamInterceptorConfig) {
+		cfg.metricRegistry = registry
+	}
+}
@@ -26,7 +26,8 @@ const (
 )
 
 // LimitExceededResponseClassifier is a method definition for defining the error response type when the limit is exceeded
-// and a token is not able to be acquired. By default the RESOURCE_EXHUASTED type is returend.
+// and a token is not able to be acquired. By default the code is chosen by RejectionCode, the reason is available with
+// RejectionFromContext.
 type LimitExceededResponseClassifier func(ctx context.Context, method string, req interface{}, l core.Limiter) (interface{}, codes.Code, error)
 
 // ClientResponseClassifier is a method definition for defining custom response types to the limiter algorithm to
@@ -45,7 +46,11 @@ func defaultLimitExceededResponseClassifier(
 	req interface{},
 	l core.Limiter,
 ) (interface{}, codes.Code, error) {
-	return nil, codes.ResourceExhausted, fmt.Errorf("limit exceeded for limiter=%v", l)
+	rejection := RejectionFromContext(ctx)
+	if rejection == nil {
+		return nil, codes.ResourceExhausted, fmt.Errorf("limit exceeded for limiter=%v", l)
+	}
+	return nil, RejectionCode(ctx, rejection), fmt.Errorf("limit exceeded for limiter=%v: %w", l, rejection)
 }
 
 func defaultClientResponseClassifier(
@@ -81,6 +86,7 @@ type interceptorConfig struct {
 	limitExceededResponseClassifier LimitExceededResponseClassifier
 	serverResponseClassifer         ServerResponseClassifier
 	clientResponseClassifer         ClientResponseClassifier
+	metricRegistry                  core.MetricRegistry
 }
 
 // InterceptorOption represents an option that can be passed to the grpc unary
@@ -108,6 +114,15 @@ func defaults(cfg *interceptorConfig) {
 	cfg.serverResponseClassifer = defaultServerResponseClassifier
 }
 
+// newRejectionCounter creates the rejection counter once every option has been applied.
+func (cfg *interceptorConfig) newRejectionCounter() *core.RejectionCounter {
+	name := cfg.name
+	if name == "" {
+		name = "default"
+	}
+	return core.NewRejectionCounter(cfg.metricRegistry, name, cfg.tags...)
+}
+
 // WithName sets the default limiter name if the default limiter is used, otherwise unused.
 func WithName(name string) InterceptorOption {
 	return func(cfg *interceptorConfig) {
@@ -129,6 +144,13 @@ func WithLimiter(limiter core.Limiter) InterceptorOption {
 	}
 }
 
+// WithMetricRegistry sets the registry used to count rejected calls by reason as "<name>.rejected".
+func WithMetricRegistry(registry core.MetricRegistry) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.metricRegistry = registry
+	}
+}
+
 // WithLimitExceededResponseClassifier sets the response classifier for the intercepted client
 func WithLimitExceededResponseClassifier(classifier LimitExceededResponseClassifier) InterceptorOption {
 	return func(cfg *interceptorConfig) {
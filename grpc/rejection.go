@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// rejectionKey is the context key of the rejection of a call.
+type rejectionKey struct{}
+
+// withRejection stores why the call was rejected in the context passed to the LimitExceededResponseClassifier.
+func withRejection(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, rejectionKey{}, rejectionOf(err))
+}
+
+// RejectionFromContext retrieves why the call was rejected, or returns nil if it was not rejected.  It is intended to
+// be used by a LimitExceededResponseClassifier.
+func RejectionFromContext(ctx context.Context) *core.RejectionError {
+	rejection, _ := ctx.Value(rejectionKey{}).(*core.RejectionError)
+	return rejection
+}
+
+func rejectionOf(err error) *core.RejectionError {
+	var rejection *core.RejectionError
+	if errors.As(err, &rejection) {
+		return rejection
+	}
+	return core.NewRejectionError(core.RejectReasonLimitExceeded)
+}
+
+// RejectionCode returns the status code for a rejection: DEADLINE_EXCEEDED or CANCELLED when the caller gave up,
+// UNAVAILABLE when the limiter is closed and RESOURCE_EXHAUSTED otherwise.
+func RejectionCode(ctx context.Context, rejection *core.RejectionError) codes.Code {
+	if rejection == nil {
+		return codes.ResourceExhausted
+	}
+	switch rejection.Reason {
+	case core.RejectReasonDeadlineExceeded:
+		return codes.DeadlineExceeded
+	case core.RejectReasonContextDone:
+		if err := ctx.Err(); err != nil {
+			return status.FromContextError(err).Code()
+		}
+		return codes.Canceled
+	case core.RejectReasonClosed:
+		return codes.Unavailable
+	default:
+		return codes.ResourceExhausted
+	}
+}
+
+// rejectionStatus builds the status error returned for a rejected call.  A RetryInfo detail with the retry delay
+// estimated by the limiter is attached when the code tells the caller to back off and retry.
+func rejectionStatus(ctx context.Context, l core.Limiter, code codes.Code, err error) error {
+	st := status.New(code, err.Error())
+	if code != codes.ResourceExhausted && code != codes.Unavailable {
+		return st.Err()
+	}
+	if rejection := RejectionFromContext(ctx); rejection != nil && !core.RetryableRejection(rejection.Reason) {
+		return st.Err()
+	}
+	delay := core.RetryAfter(l)
+	if delay <= 0 {
+		return st.Err()
+	}
+	withDetails, detailsErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(delay)})
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// RetryDelayFromError returns the retry delay of the RetryInfo detail of a status error, i.e. to back off on a client
+// after the server rejected a call.
+func RetryDelayFromError(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
@@ -12,7 +12,9 @@ import (
 // LimitExceededHandler is called instead of forwarding to the next handler.
 //
 // The middleware stores the Limiter in the request context so that downstream
-// handlers and custom classifiers can access it via LimiterFromContext.
+// handlers and custom classifiers can access it via LimiterFromContext. When a
+// request is rejected the reason is available to the LimitExceededHandler via
+// RejectionFromContext.
 //
 // Example:
 //
@@ -26,13 +28,17 @@ func NewServerMiddleware(opts ...InterceptorOption) func(http.Handler) http.Hand
 		o(cfg)
 	}
 
+	rejections := cfg.newRejectionCounter()
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := withLimiter(r.Context(), cfg.limiter)
 			r = r.WithContext(ctx)
 
-			token, ok := cfg.limiter.Acquire(r.Context())
-			if !ok {
+			token, err := core.AcquireWithReason(r.Context(), cfg.limiter)
+			if err != nil {
+				rejections.Add(err)
+				r = r.WithContext(withRejection(r.Context(), err))
 				cfg.limitExceededHandler(w, r, cfg.limiter)
 				return
 			}
@@ -69,7 +75,7 @@ func NewClientRoundTripper(opts ...InterceptorOption) http.RoundTripper {
 	for _, o := range opts {
 		o(cfg)
 	}
-	return &clientRoundTripper{cfg: cfg, base: http.DefaultTransport}
+	return &clientRoundTripper{cfg: cfg, base: http.DefaultTransport, rejections: cfg.newRejectionCounter()}
 }
 
 // NewClientRoundTripperWithBase is like NewClientRoundTripper but uses base as
@@ -81,18 +87,20 @@ func NewClientRoundTripperWithBase(base http.RoundTripper, opts ...InterceptorOp
 	for _, o := range opts {
 		o(cfg)
 	}
-	return &clientRoundTripper{cfg: cfg, base: base}
+	return &clientRoundTripper{cfg: cfg, base: base, rejections: cfg.newRejectionCounter()}
 }
 
 type clientRoundTripper struct {
-	cfg  *interceptorConfig
-	base http.RoundTripper
+	cfg        *interceptorConfig
+	base       http.RoundTripper
+	rejections *core.RejectionCounter
 }
 
 func (c *clientRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	token, ok := c.cfg.limiter.Acquire(r.Context())
-	if !ok {
-		return nil, &LimitExceededError{Limiter: c.cfg.limiter}
+	token, err := core.AcquireWithReason(r.Context(), c.cfg.limiter)
+	if err != nil {
+		c.rejections.Add(err)
+		return nil, &LimitExceededError{Limiter: c.cfg.limiter, Rejection: rejectionOf(err)}
 	}
 
 	resp, err := c.base.RoundTrip(r)
@@ -111,11 +119,24 @@ func (c *clientRoundTripper) RoundTrip(r *http.Request) (*http.Response, error)
 }
 
 // LimitExceededError is returned by the client round-tripper when no
-// concurrency token is available.
+// concurrency token is available. It unwraps to the *core.RejectionError
+// describing why, so errors.Is can be used with the core.Err sentinels.
 type LimitExceededError struct {
-	Limiter core.Limiter
+	Limiter   core.Limiter
+	Rejection *core.RejectionError
 }
 
 func (e *LimitExceededError) Error() string {
+	if e.Rejection != nil {
+		return fmt.Sprintf("concurrency limit exceeded for limiter=%v: %s", e.Limiter, e.Rejection.Reason)
+	}
 	return fmt.Sprintf("concurrency limit exceeded for limiter=%v", e.Limiter)
 }
+
+// Unwrap returns the rejection, if known.
+func (e *LimitExceededError) Unwrap() error {
+	if e.Rejection == nil {
+		return nil
+	}
+	return e.Rejection
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,6 +54,50 @@ func exhaustLimiter(l core.Limiter, n int) func() {
 	}
 }
 
+// countingRegistry records the counts registered by the interceptors.
+type countingRegistry struct {
+	core.EmptyMetricRegistry
+	mu     sync.Mutex
+	counts map[string]*countingListener
+}
+
+type countingListener struct {
+	mu    sync.Mutex
+	total float64
+}
+
+func (c *countingListener) AddSample(value float64, tags ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total += value
+}
+
+func newCountingRegistry() *countingRegistry {
+	return &countingRegistry{counts: make(map[string]*countingListener)}
+}
+
+func (r *countingRegistry) RegisterCount(ID string, tags ...string) core.MetricSampleListener {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := ID + "|" + strings.Join(tags, ",")
+	if _, ok := r.counts[key]; !ok {
+		r.counts[key] = &countingListener{}
+	}
+	return r.counts[key]
+}
+
+func (r *countingRegistry) count(ID string, tags ...string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	listener, ok := r.counts[ID+"|"+strings.Join(tags, ",")]
+	if !ok {
+		return 0
+	}
+	listener.mu.Lock()
+	defer listener.mu.Unlock()
+	return listener.total
+}
+
 // ---- ResponseWriter tests ---------------------------------------------------
 
 func TestResponseWriter_DefaultStatusCode(t *testing.T) {
@@ -260,6 +307,102 @@ func TestServerMiddleware_CustomLimitExceededHandler(t *testing.T) {
 	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
 }
 
+func TestServerMiddleware_RejectionReasonHeaders(t *testing.T) {
+	t.Parallel()
+	l := newFixedLimiter("server-reason", 1)
+	release := exhaustLimiter(l, 1)
+	defer release()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	NewServerMiddleware(WithLimiter(l))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, string(core.RejectReasonLimitExceeded), rec.Header().Get(RejectReasonHeader))
+	assert.Empty(t, rec.Header().Get(RejectPartitionHeader))
+	assert.Contains(t, rec.Body.String(), string(core.RejectReasonLimitExceeded))
+}
+
+func TestServerMiddleware_RetryAfterHeader(t *testing.T) {
+	t.Parallel()
+	l := newFixedLimiter("server-retry-after", 1)
+	tok, ok := l.Acquire(context.TODO())
+	require.True(t, ok)
+	time.Sleep(time.Millisecond)
+	tok.OnSuccess()
+	release := exhaustLimiter(l, 1)
+	defer release()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	NewServerMiddleware(WithLimiter(l))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	t.Parallel()
+	rec := httptest.NewRecorder()
+	SetRetryAfter(rec, 0)
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+	SetRetryAfter(rec, 1500*time.Millisecond)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+}
+
+func TestServerMiddleware_DeadlineRejectionReason(t *testing.T) {
+	t.Parallel()
+	l := limiter.NewDeadlineLimiter(newFixedLimiter("server-deadline", 1), time.Now().Add(-time.Second), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	NewServerMiddleware(WithLimiter(l))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, string(core.RejectReasonDeadlineExceeded), rec.Header().Get(RejectReasonHeader))
+	assert.Empty(t, rec.Header().Get("Retry-After"))
+}
+
+func TestServerMiddleware_RejectionFromContext(t *testing.T) {
+	t.Parallel()
+	l := newFixedLimiter("server-rejection-ctx", 1)
+	release := exhaustLimiter(l, 1)
+	defer release()
+
+	var rejection *core.RejectionError
+	middleware := NewServerMiddleware(
+		WithLimiter(l),
+		WithLimitExceededHandler(func(w http.ResponseWriter, r *http.Request, _ core.Limiter) {
+			rejection = RejectionFromContext(r.Context())
+			w.WriteHeader(http.StatusTooManyRequests)
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+	require.NotNil(t, rejection)
+	assert.Equal(t, core.RejectReasonLimitExceeded, rejection.Reason)
+	assert.Nil(t, RejectionFromContext(context.Background()))
+}
+
+func TestServerMiddleware_CountsRejections(t *testing.T) {
+	t.Parallel()
+	l := newFixedLimiter("server-metrics", 1)
+	release := exhaustLimiter(l, 1)
+	defer release()
+
+	registry := newCountingRegistry()
+	middleware := NewServerMiddleware(WithLimiter(l), WithName("api"), WithMetricRegistry(registry))
+	for range 2 {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, 2.0, registry.count("api.rejected", "reason:limit_exceeded"))
+}
+
 func TestServerMiddleware_SuccessResponseReleasesToken(t *testing.T) {
 	t.Parallel()
 	l := newFixedLimiter("server-release", 1)
@@ -367,6 +510,20 @@ func TestClientRoundTripper_RejectsWhenLimitExceeded(t *testing.T) {
 	assert.True(t, errors.As(err, &limitErr), "expected LimitExceededError, got %T: %v", err, err)
 }
 
+func TestClientRoundTripper_RejectionReason(t *testing.T) {
+	t.Parallel()
+	l := newFixedLimiter("client-reason", 1)
+	release := exhaustLimiter(l, 1)
+	defer release()
+
+	rt := NewClientRoundTripperWithBase(&fakeRoundTripper{resp: fakeResponse(http.StatusOK)}, WithLimiter(l))
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	_, err := rt.RoundTrip(req)
+
+	assert.ErrorIs(t, err, core.ErrLimitExceeded)
+	assert.Contains(t, err.Error(), string(core.RejectReasonLimitExceeded))
+}
+
 func TestClientRoundTripper_ReleasesTokenAfterSuccess(t *testing.T) {
 	t.Parallel()
 	l := newFixedLimiter("client-release", 1)
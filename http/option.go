@@ -2,8 +2,12 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
 	"github.com/platinummonkey/go-concurrency-limits/limit"
@@ -40,11 +44,41 @@ type ServerResponseClassifier func(r *http.Request, w *ResponseWriter, err error
 // limiter can update its estimate appropriately.
 type ClientResponseClassifier func(resp *http.Response, err error) ResponseType
 
+// Response headers set by the default LimitExceededHandler to describe the rejection.
+const (
+	// RejectReasonHeader carries the core.RejectReason of a rejected request.
+	RejectReasonHeader = "X-Concurrency-Limit-Reason"
+	// RejectPartitionHeader carries the partition that exceeded its limit, if any.
+	RejectPartitionHeader = "X-Concurrency-Limit-Partition"
+)
+
+// defaultLimitExceededHandler writes HTTP 503 Service Unavailable for every
+// rejection, along with the rejection reason headers that tell them apart and
+// a Retry-After header when a retry is useful.
 func defaultLimitExceededHandler(w http.ResponseWriter, r *http.Request, l core.Limiter) {
-	http.Error(w,
-		fmt.Sprintf("concurrency limit exceeded for limiter=%v", l),
-		http.StatusServiceUnavailable,
-	)
+	message := fmt.Sprintf("concurrency limit exceeded for limiter=%v", l)
+	rejection := RejectionFromContext(r.Context())
+	if rejection != nil {
+		w.Header().Set(RejectReasonHeader, string(rejection.Reason))
+		if rejection.Partition != "" {
+			w.Header().Set(RejectPartitionHeader, rejection.Partition)
+		}
+		message = fmt.Sprintf("%s: %s", message, rejection.Reason)
+	}
+	if rejection == nil || core.RetryableRejection(rejection.Reason) {
+		SetRetryAfter(w, core.RetryAfter(l))
+	}
+	http.Error(w, message, http.StatusServiceUnavailable)
+}
+
+// SetRetryAfter sets the Retry-After header to the delay rounded up to whole
+// seconds, as required by the header format.  Nothing is set for a delay <= 0.
+func SetRetryAfter(w http.ResponseWriter, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	seconds := int64(math.Ceil(delay.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
 }
 
 // defaultServerResponseClassifier maps HTTP status codes to ResponseType:
@@ -84,12 +118,13 @@ func classifyStatusCode(code int) ResponseType {
 }
 
 type interceptorConfig struct {
-	name                    string
-	tags                    []string
-	limiter                 core.Limiter
-	limitExceededHandler    LimitExceededHandler
+	name                     string
+	tags                     []string
+	limiter                  core.Limiter
+	limitExceededHandler     LimitExceededHandler
 	serverResponseClassifier ServerResponseClassifier
 	clientResponseClassifier ClientResponseClassifier
+	metricRegistry           core.MetricRegistry
 }
 
 // InterceptorOption is a functional option for configuring server middleware
@@ -117,6 +152,16 @@ func applyDefaults(cfg *interceptorConfig) {
 	cfg.clientResponseClassifier = defaultClientResponseClassifier
 }
 
+// newRejectionCounter creates the rejection counter once every option has
+// been applied.
+func (cfg *interceptorConfig) newRejectionCounter() *core.RejectionCounter {
+	name := cfg.name
+	if name == "" {
+		name = "default"
+	}
+	return core.NewRejectionCounter(cfg.metricRegistry, name, cfg.tags...)
+}
+
 // WithName sets the limiter name used when the default limiter is created.
 // Has no effect when WithLimiter is also provided.
 func WithName(name string) InterceptorOption {
@@ -133,6 +178,14 @@ func WithTags(tags []string) InterceptorOption {
 	}
 }
 
+// WithMetricRegistry sets the registry used to count rejected requests by
+// reason as "<name>.rejected", tagged with the tags set by WithTags.
+func WithMetricRegistry(registry core.MetricRegistry) InterceptorOption {
+	return func(cfg *interceptorConfig) {
+		cfg.metricRegistry = registry
+	}
+}
+
 // WithLimiter sets a custom limiter, replacing the default one.
 func WithLimiter(l core.Limiter) InterceptorOption {
 	return func(cfg *interceptorConfig) {
@@ -203,6 +256,30 @@ func withLimiter(ctx context.Context, l core.Limiter) context.Context {
 	return context.WithValue(ctx, contextKey{}, l)
 }
 
+// rejectionKey is the context key of the rejection of a request.
+type rejectionKey struct{}
+
+// withRejection stores why the request was rejected in the request context.
+func withRejection(ctx context.Context, err error) context.Context {
+	return context.WithValue(ctx, rejectionKey{}, rejectionOf(err))
+}
+
+// RejectionFromContext retrieves why the request was rejected by the server
+// middleware, or returns nil if it was not rejected.  It is intended to be
+// used by a LimitExceededHandler.
+func RejectionFromContext(ctx context.Context) *core.RejectionError {
+	rejection, _ := ctx.Value(rejectionKey{}).(*core.RejectionError)
+	return rejection
+}
+
+func rejectionOf(err error) *core.RejectionError {
+	var rejection *core.RejectionError
+	if errors.As(err, &rejection) {
+		return rejection
+	}
+	return core.NewRejectionError(core.RejectReasonLimitExceeded)
+}
+
 // LimiterFromContext retrieves the Limiter stored in the context by the server
 // middleware, or returns nil if none is present.
 func LimiterFromContext(ctx context.Context) core.Limiter {
@@ -15,6 +15,9 @@ type AIMDLimit struct {
 	limit        int
 	increaseBy   int
 	backOffRatio float64
+	initialLimit int
+	tags         []string
+	idle         idleState
 
 	listeners     []core.LimitChangeListener
 	registry      core.MetricRegistry
@@ -53,6 +56,9 @@ func NewAIMDLimit(
 		limit:        initialLimit,
 		backOffRatio: backOffRatio,
 		increaseBy:   increaseBy,
+		initialLimit: initialLimit,
+		tags:         tags,
+		idle:         newIdleState(IdlePolicy{}),
 		listeners:    make([]core.LimitChangeListener, 0),
 		registry:     registry,
 	}
@@ -60,6 +66,90 @@ func NewAIMDLimit(
 	return l
 }
 
+// AIMDLimitConfig configures an AIMDLimit created with NewAIMDLimitFromConfig.  The zero value of every field selects
+// its default, any other invalid value is an error.
+type AIMDLimitConfig struct {
+	// InitialLimit is the initial limit used by the limiter, default 10.
+	InitialLimit int `json:"initialLimit"`
+	// BackOffRatio in (0, 1) multiplies the limit on a drop, default 0.9.
+	BackOffRatio float64 `json:"backOffRatio"`
+	// IncreaseBy is the amount the limit grows by while the limit is reached, default 1.
+	IncreaseBy int `json:"increaseBy"`
+	// Idle adjusts the limit once traffic stops, disabled by default.  AIMDLimit does not measure RTTs, so
+	// Idle.Rebaseline has no effect.
+	Idle IdlePolicy `json:"idle"`
+
+	MetricRegistry core.MetricRegistry `json:"-"`
+	Tags           []string            `json:"tags,omitempty"`
+}
+
+// ApplyDefaults will set the default of every field with a zero value.
+func (c *AIMDLimitConfig) ApplyDefaults() {
+	if c.InitialLimit == 0 {
+		c.InitialLimit = 10
+	}
+	if c.BackOffRatio == 0 {
+		c.BackOffRatio = 0.9
+	}
+	if c.IncreaseBy == 0 {
+		c.IncreaseBy = 1
+	}
+	c.Idle.applyDefaults()
+	if c.MetricRegistry == nil {
+		c.MetricRegistry = core.EmptyMetricRegistryInstance
+	}
+}
+
+// Validate will return an error describing every invalid field.
+func (c *AIMDLimitConfig) Validate() error {
+	v := &configValidator{limitType: "AIMDLimit"}
+	v.atLeastOne("initialLimit", c.InitialLimit)
+	v.backOffRatio(c.BackOffRatio)
+	v.atLeastOne("increaseBy", c.IncreaseBy)
+	v.check(c.Idle.validate())
+	return v.err()
+}
+
+// backOffRatio will validate that the back off ratio is in (0, 1), so that a drop always reduces the limit.
+func (v *configValidator) backOffRatio(backOffRatio float64) {
+	if backOffRatio <= 0 || backOffRatio >= 1 {
+		v.errorf("backOffRatio must be in (0, 1), got %g", backOffRatio)
+	}
+}
+
+// NewAIMDLimitFromConfig will create a new AIMDLimit, returning an error if any field of the config is invalid.  The
+// resolved config is available from AIMDLimit.Config.
+func NewAIMDLimitFromConfig(name string, config AIMDLimitConfig) (*AIMDLimit, error) {
+	config.ApplyDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	l := NewAIMDLimit(
+		name,
+		config.InitialLimit,
+		config.BackOffRatio,
+		config.IncreaseBy,
+		config.MetricRegistry,
+		config.Tags...,
+	)
+	l.idle = newIdleState(config.Idle)
+	return l, nil
+}
+
+// Config returns the configuration of the limit with every default resolved, including changes made by Update.
+func (l *AIMDLimit) Config() AIMDLimitConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return AIMDLimitConfig{
+		InitialLimit:   l.initialLimit,
+		BackOffRatio:   l.backOffRatio,
+		IncreaseBy:     l.increaseBy,
+		Idle:           l.idle.policy,
+		MetricRegistry: l.registry,
+		Tags:           l.tags,
+	}
+}
+
 // EstimatedLimit returns the current estimated limit.
 func (l *AIMDLimit) EstimatedLimit() int {
 	l.mu.RLock()
@@ -87,6 +177,10 @@ func (l *AIMDLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop b
 	defer l.mu.Unlock()
 
 	l.commonSampler.Sample(rtt, inFlight, didDrop)
+	l.checkIdle()
+	if didDrop || inFlight*2 >= l.limit {
+		l.idle.active()
+	}
 
 	if didDrop {
 		l.limit = int(math.Max(1, math.Min(float64(l.limit-1), float64(l.limit)*l.backOffRatio)))
@@ -97,6 +191,22 @@ func (l *AIMDLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop b
 	}
 }
 
+// CheckIdle will apply the idle policy if the limit has been idle for longer than its timeout.
+func (l *AIMDLimit) CheckIdle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checkIdle()
+}
+
+// checkIdle decays the limit as configured by the idle policy, it must be called with mu held.
+func (l *AIMDLimit) checkIdle() {
+	newLimit, _ := l.idle.check(float64(l.limit), float64(l.initialLimit))
+	if int(newLimit) != l.limit {
+		l.limit = int(math.Max(1, newLimit))
+		l.notifyListeners(l.limit)
+	}
+}
+
 // BackOffRatio return the current back-off-ratio for the AIMDLimit
 func (l *AIMDLimit) BackOffRatio() float64 {
 	l.mu.RLock()
@@ -104,6 +214,82 @@ func (l *AIMDLimit) BackOffRatio() float64 {
 	return l.backOffRatio
 }
 
+// AIMDLimitUpdate holds the parameters of an AIMDLimit that can be changed at runtime with Update, nil fields are
+// left unchanged.
+type AIMDLimitUpdate struct {
+	BackOffRatio *float64
+	IncreaseBy   *int
+	Idle         *IdlePolicy
+}
+
+// Update will atomically apply new parameters without resetting the limit.  Nothing is applied if any parameter is
+// invalid.
+func (l *AIMDLimit) Update(update AIMDLimitUpdate) error {
+	v := &configValidator{limitType: "AIMDLimit", update: true}
+	if update.BackOffRatio != nil {
+		v.backOffRatio(*update.BackOffRatio)
+	}
+	if update.IncreaseBy != nil {
+		v.atLeastOne("increaseBy", *update.IncreaseBy)
+	}
+	if update.Idle != nil {
+		v.check(update.Idle.validate())
+	}
+	if err := v.err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if update.BackOffRatio != nil {
+		l.backOffRatio = *update.BackOffRatio
+	}
+	if update.IncreaseBy != nil {
+		l.increaseBy = *update.IncreaseBy
+	}
+	if update.Idle != nil {
+		l.idle = newIdleState(*update.Idle)
+	}
+	return nil
+}
+
+// ExportState returns the current limit.
+func (l *AIMDLimit) ExportState() (core.LimitState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "AIMDLimit",
+		Limit:   float64(l.limit),
+	}, nil
+}
+
+// ImportState will restore the limit of a previously exported state.
+func (l *AIMDLimit) ImportState(state core.LimitState) error {
+	if err := state.Check("AIMDLimit"); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = int(state.Limit)
+	l.notifyListeners(l.limit)
+	return nil
+}
+
+// Snapshot returns the current state of the limit.
+func (l *AIMDLimit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "AIMDLimit",
+		Limit: l.limit,
+		Attributes: map[string]interface{}{
+			"backOffRatio": l.backOffRatio,
+			"increaseBy":   l.increaseBy,
+		},
+	}
+}
+
 func (l *AIMDLimit) String() string {
 	return fmt.Sprintf("AIMDLimit{limit=%d, backOffRatio=%0.4f}", l.EstimatedLimit(), l.BackOffRatio())
 }
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
 )
 
 func TestAIMDLimit(t *testing.T) {
@@ -50,4 +52,65 @@ func TestAIMDLimit(t *testing.T) {
 		l := NewAIMDLimit("test", 10, 0.9, 1, nil)
 		asrt.Equal("AIMDLimit{limit=10, backOffRatio=0.9000}", l.String())
 	})
+
+	t.Run("Update", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewAIMDLimit("test", 10, 0.9, 1, nil)
+		backOffRatio := 1.5
+		asrt.Error(l.Update(AIMDLimitUpdate{BackOffRatio: &backOffRatio}))
+		asrt.Equal(0.9, l.BackOffRatio())
+		// updates are validated like the config
+		backOffRatio = 0
+		increaseBy := 0
+		idle := IdlePolicy{Timeout: -time.Second}
+		asrt.EqualError(l.Update(AIMDLimitUpdate{BackOffRatio: &backOffRatio, IncreaseBy: &increaseBy, Idle: &idle}),
+			"invalid AIMDLimit update: backOffRatio must be in (0, 1), got 0; increaseBy must be >= 1, got 0; "+
+				"idle.timeout must be >= 0, got -1s")
+		asrt.Equal(0.9, l.BackOffRatio())
+
+		backOffRatio = 0.5
+		increaseBy = 5
+		asrt.NoError(l.Update(AIMDLimitUpdate{BackOffRatio: &backOffRatio, IncreaseBy: &increaseBy}))
+		asrt.Equal(0.5, l.BackOffRatio())
+		asrt.Equal(10, l.EstimatedLimit())
+		l.OnSample(-1, 1, 10, false)
+		asrt.Equal(15, l.EstimatedLimit())
+		l.OnSample(-1, 1, 1, true)
+		asrt.Equal(7, l.EstimatedLimit())
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewAIMDLimit("test", 10, 0.5, 1, nil)
+		l.OnSample(-1, 1, 1, true)
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal(core.LimitState{Version: core.LimitStateVersion, Type: "AIMDLimit", Limit: 5}, state)
+
+		restored := NewAIMDLimit("test", 10, 0.5, 1, nil)
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(5, restored.EstimatedLimit())
+	})
+
+	t.Run("FromConfig", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewAIMDLimitFromConfig("test", AIMDLimitConfig{IncreaseBy: 2})
+		asrt.NoError(err)
+		asrt.Equal(10, l.EstimatedLimit())
+		asrt.Equal(AIMDLimitConfig{
+			InitialLimit:   10,
+			BackOffRatio:   0.9,
+			IncreaseBy:     2,
+			Idle:           IdlePolicy{Clock: core.SystemClockInstance},
+			MetricRegistry: core.EmptyMetricRegistryInstance,
+		}, l.Config())
+
+		_, err = NewAIMDLimitFromConfig("test", AIMDLimitConfig{BackOffRatio: 1.5})
+		asrt.EqualError(err, "invalid AIMDLimit config: backOffRatio must be in (0, 1), got 1.5")
+		_, err = NewAIMDLimitFromConfig("test", AIMDLimitConfig{InitialLimit: -1, IncreaseBy: -1})
+		asrt.EqualError(err, "invalid AIMDLimit config: initialLimit must be >= 1, got -1; increaseBy must be >= 1, got -1")
+	})
 }
@@ -0,0 +1,427 @@
+package limit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// BBRMode is the phase of the BBRLimit state machine.
+type BBRMode string
+
+const (
+	// BBRModeStartup grows the limit exponentially until the delivery rate stops increasing.
+	BBRModeStartup BBRMode = "startup"
+	// BBRModeDrain shrinks the limit for the queue built during startup to drain.
+	BBRModeDrain BBRMode = "drain"
+	// BBRModeProbeBandwidth cycles the pacing gain around the estimated bandwidth delay product.
+	BBRModeProbeBandwidth BBRMode = "probe_bw"
+	// BBRModeProbeRTT drops the limit to the minimum for one sample to re-measure the minimum RTT.
+	BBRModeProbeRTT BBRMode = "probe_rtt"
+)
+
+const (
+	// bbrHighGain is the startup gain, 2/ln(2), which doubles the delivery rate every sample.
+	bbrHighGain = 2.885
+	// bbrFullRateGrowth is the delivery rate growth below which startup is considered to have stalled.
+	bbrFullRateGrowth = 1.25
+	// bbrFullRateSamples is the number of stalled samples after which the bottleneck is considered full.
+	bbrFullRateSamples = 3
+)
+
+// bbrPacingGains are the gains cycled through in BBRModeProbeBandwidth: probe for more bandwidth, drain the queue the
+// probe may have built and then cruise at the estimate.
+var bbrPacingGains = []float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+// BBRLimit implements a throughput based concurrency limit modelled on TCP BBR.  Every sample yields a delivery rate
+// and the limit is set to the bandwidth delay product of the maximum delivery rate over a window of samples and the
+// minimum RTT, multiplied by a pacing gain:
+//
+//	limit = gain * maxDeliveryRate * minRTT
+//
+// The delivery rate is the throughput passed to OnThroughput, which DefaultLimiter calls with the requests released in
+// every sample window.  When OnThroughput is never called the delivery rate is estimated as the in flight count divided
+// by the RTT of the sample.  Limiters that aggregate samples into windows report the maximum in flight count and the
+// minimum RTT of the window, so the estimate overstates the bandwidth of a queueing backend and grows with the limit
+// rather than stalling at the bottleneck.
+//
+// Unlike the latency ratio of VegasLimit and Gradient2Limit the bandwidth delay product does not shrink when the RTT
+// is noisy but throughput holds, so it does not underestimate the capacity of high variance backends.
+//
+// The limit moves through four modes
+//
+//  1. Startup: the gain is 2/ln(2) so the limit grows exponentially until the delivery rate grows less than 25% for
+//     three samples, or a sample is dropped.
+//  2. Drain: the inverse gain lets the queue built during startup drain until the in flight count is below the
+//     bandwidth delay product.
+//  3. Probe bandwidth: the gain cycles through 1.25, 0.75 and six samples of 1 to discover additional capacity and
+//     drain the queue a probe built.
+//  4. Probe RTT: when the minimum RTT has not been refreshed for the minimum RTT window the limit drops to the minimum
+//     limit for one sample so the RTT without queueing can be measured again.
+//
+// Samples that are app limited, with fewer requests in flight than the limit, only raise the delivery rate estimate so
+// that a quiet period does not shrink the limit.
+type BBRLimit struct {
+	estimatedLimit float64
+	minLimit       int
+	maxLimit       int
+
+	rates        []float64 // ring buffer of delivery rates in requests per nanosecond
+	rateIndex    int
+	rateCount    int
+	measuredRate float64 // last delivery rate passed to OnThroughput in requests per nanosecond
+	measured     bool
+	minRTT       int64
+	minRTTWindow int
+	minRTTAge    int
+
+	mode           BBRMode
+	cycleIndex     int
+	fullRate       float64
+	fullRateRounds int
+	filledPipe     bool
+	random         core.RandomSource
+
+	commonSampler              *core.CommonMetricSampler
+	minRTTSampleListener       core.MetricSampleListener
+	deliveryRateSampleListener core.MetricSampleListener
+
+	mu        sync.RWMutex
+	listeners []core.LimitChangeListener
+	logger    Logger
+	registry  core.MetricRegistry
+}
+
+// NewDefaultBBRLimit will create a default BBRLimit.
+func NewDefaultBBRLimit(
+	name string,
+	logger Logger,
+	registry core.MetricRegistry,
+	tags ...string,
+) *BBRLimit {
+	l, _ := NewBBRLimit(name, 20, 4, 1000, 10, 100, logger, registry, tags...)
+	return l
+}
+
+// NewBBRLimit will create a new BBRLimit.
+// @param initialLimit: Initial limit used by the limiter, default 20.
+// @param minLimit: Minimum concurrency limit allowed, also used while probing the RTT, default 4.
+// @param maxLimit: Maximum allowable concurrency, default 1000.
+// @param rateWindow: number of samples the maximum delivery rate is taken over, default 10.
+// @param minRTTWindow: number of samples after which an unchanged minimum RTT is re-probed, default 100.
+// @param registry: metric registry to publish metrics
+func NewBBRLimit(
+	name string,
+	initialLimit int,
+	minLimit int,
+	maxLimit int,
+	rateWindow int,
+	minRTTWindow int,
+	logger Logger,
+	registry core.MetricRegistry,
+	tags ...string,
+) (*BBRLimit, error) {
+	if initialLimit <= 0 {
+		initialLimit = 20
+	}
+	if minLimit <= 0 {
+		minLimit = 4
+	}
+	if maxLimit <= 0 {
+		maxLimit = 1000
+	}
+	if rateWindow <= 0 {
+		rateWindow = 10
+	}
+	if minRTTWindow <= 0 {
+		minRTTWindow = 100
+	}
+	if logger == nil {
+		logger = NoopLimitLogger{}
+	}
+	if registry == nil {
+		registry = core.EmptyMetricRegistryInstance
+	}
+	if minLimit > maxLimit {
+		return nil, fmt.Errorf("minLimit must be <= maxLimit")
+	}
+
+	l := &BBRLimit{
+		estimatedLimit:             math.Max(float64(minLimit), math.Min(float64(maxLimit), float64(initialLimit))),
+		minLimit:                   minLimit,
+		maxLimit:                   maxLimit,
+		rates:                      make([]float64, rateWindow),
+		minRTTWindow:               minRTTWindow,
+		mode:                       BBRModeStartup,
+		random:                     core.SystemRandomSourceInstance,
+		minRTTSampleListener:       registry.RegisterDistribution(core.PrefixMetricWithName(core.MetricMinRTT, name), tags...),
+		deliveryRateSampleListener: registry.RegisterDistribution(core.PrefixMetricWithName(core.MetricDeliveryRate, name), tags...),
+		listeners:                  make([]core.LimitChangeListener, 0),
+		logger:                     logger,
+		registry:                   registry,
+	}
+	l.commonSampler = core.NewCommonMetricSamplerOrNil(registry, l, name, tags...)
+	return l, nil
+}
+
+// SetRandom will replace the random source used to pick the pacing gain phase when entering
+// BBRModeProbeBandwidth, i.e. with core.NewSeededRandomSource for reproducible tests.
+func (l *BBRLimit) SetRandom(random core.RandomSource) {
+	if random == nil {
+		random = core.SystemRandomSourceInstance
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.random = random
+}
+
+// EstimatedLimit returns the current estimated limit.
+func (l *BBRLimit) EstimatedLimit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return int(l.estimatedLimit)
+}
+
+// Mode returns the current mode of the state machine.
+func (l *BBRLimit) Mode() BBRMode {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.mode
+}
+
+// MinRTT returns the minimum RTT in nanoseconds, or 0 before the first sample.
+func (l *BBRLimit) MinRTT() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.minRTT
+}
+
+// MaxDeliveryRate returns the maximum delivery rate over the rate window in requests per second.
+func (l *BBRLimit) MaxDeliveryRate() float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.maxRate() * 1e9
+}
+
+// NotifyOnChange will register a callback to receive notification whenever the limit is updated to a new value.
+func (l *BBRLimit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.mu.Lock()
+	l.listeners = append(l.listeners, consumer)
+	l.mu.Unlock()
+}
+
+// notifyListeners will call the callbacks on limit changes
+func (l *BBRLimit) notifyListeners(newLimit int) {
+	for _, listener := range l.listeners {
+		listener(newLimit)
+	}
+}
+
+// OnThroughput records that completed requests were released over duration, the delivery rate of the next sample.
+func (l *BBRLimit) OnThroughput(completed int, duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.measured = true
+	l.measuredRate = float64(completed) / float64(duration.Nanoseconds())
+}
+
+// OnSample the concurrency limit using a new rtt sample.
+func (l *BBRLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.commonSampler.Sample(rtt, inFlight, didDrop)
+	if rtt <= 0 {
+		return
+	}
+
+	if l.mode == BBRModeProbeRTT {
+		// the sample was taken with the limit at the minimum, so it is the RTT without queueing
+		l.minRTT = rtt
+		l.minRTTAge = 0
+		if l.filledPipe {
+			l.enterProbeBandwidth()
+		} else {
+			l.mode = BBRModeStartup
+		}
+	} else if l.minRTT == 0 || rtt <= l.minRTT {
+		l.minRTT = rtt
+		l.minRTTAge = 0
+	} else {
+		l.minRTTAge++
+	}
+	l.minRTTSampleListener.AddSample(float64(l.minRTT))
+
+	rate := float64(inFlight) / float64(rtt)
+	if l.measured {
+		rate = l.measuredRate
+	}
+	l.deliveryRateSampleListener.AddSample(rate * 1e9)
+	appLimited := float64(inFlight) < l.estimatedLimit
+	if !appLimited || rate > l.maxRate() {
+		l.addRate(rate)
+	}
+
+	bdp := l.maxRate() * float64(l.minRTT)
+	switch l.mode {
+	case BBRModeStartup:
+		if didDrop {
+			l.filledPipe = true
+		} else if !appLimited {
+			l.checkFullPipe()
+		}
+		if l.filledPipe {
+			l.mode = BBRModeDrain
+		}
+	case BBRModeDrain:
+		if float64(inFlight) <= bdp {
+			l.enterProbeBandwidth()
+		}
+	case BBRModeProbeBandwidth:
+		l.cycleIndex = (l.cycleIndex + 1) % len(bbrPacingGains)
+	}
+	if l.mode != BBRModeProbeRTT && l.minRTTAge > l.minRTTWindow {
+		l.mode = BBRModeProbeRTT
+	}
+
+	newLimit := l.estimatedLimit
+	switch {
+	case l.mode == BBRModeProbeRTT:
+		newLimit = float64(l.minLimit)
+	case bdp > 0:
+		newLimit = math.Round(l.pacingGain() * bdp)
+	}
+	newLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), newLimit))
+	if newLimit == l.estimatedLimit {
+		return
+	}
+	if l.logger.IsDebugEnabled() {
+		l.logger.Debugf("new limit=%0.2f, mode=%s, minRTT=%0.2f ms, maxDeliveryRate=%0.2f/s",
+			newLimit, l.mode, float64(l.minRTT)/1e6, l.maxRate()*1e9)
+	}
+	l.estimatedLimit = newLimit
+	l.notifyListeners(int(l.estimatedLimit))
+}
+
+// checkFullPipe ends startup once the delivery rate stopped growing for bbrFullRateSamples samples.
+func (l *BBRLimit) checkFullPipe() {
+	maxRate := l.maxRate()
+	if maxRate >= l.fullRate*bbrFullRateGrowth {
+		l.fullRate = maxRate
+		l.fullRateRounds = 0
+		return
+	}
+	l.fullRateRounds++
+	if l.fullRateRounds >= bbrFullRateSamples {
+		l.filledPipe = true
+	}
+}
+
+// enterProbeBandwidth starts the gain cycle at a random phase other than the draining one.
+func (l *BBRLimit) enterProbeBandwidth() {
+	l.mode = BBRModeProbeBandwidth
+	l.cycleIndex = l.random.Intn(len(bbrPacingGains) - 1)
+	if l.cycleIndex >= 1 {
+		l.cycleIndex++
+	}
+}
+
+func (l *BBRLimit) pacingGain() float64 {
+	switch l.mode {
+	case BBRModeStartup:
+		return bbrHighGain
+	case BBRModeDrain:
+		return 1 / bbrHighGain
+	case BBRModeProbeBandwidth:
+		return bbrPacingGains[l.cycleIndex]
+	default:
+		return 1
+	}
+}
+
+func (l *BBRLimit) addRate(rate float64) {
+	l.rates[l.rateIndex] = rate
+	l.rateIndex = (l.rateIndex + 1) % len(l.rates)
+	if l.rateCount < len(l.rates) {
+		l.rateCount++
+	}
+}
+
+func (l *BBRLimit) maxRate() float64 {
+	maxRate := 0.0
+	for i := 0; i < l.rateCount; i++ {
+		maxRate = math.Max(maxRate, l.rates[i])
+	}
+	return maxRate
+}
+
+// ExportState returns the estimated limit, the minimum RTT and the maximum delivery rate.
+func (l *BBRLimit) ExportState() (core.LimitState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "BBRLimit",
+		Limit:   l.estimatedLimit,
+		Values: map[string]float64{
+			"minRTT":          float64(l.minRTT),
+			"maxDeliveryRate": l.maxRate(),
+		},
+	}, nil
+}
+
+// ImportState will restore the estimated limit, minimum RTT and maximum delivery rate of a previously exported
+// state.  A restored delivery rate means the bottleneck was already found, so the limit continues probing bandwidth
+// rather than starting up again.
+func (l *BBRLimit) ImportState(state core.LimitState) error {
+	if err := state.Check("BBRLimit"); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.estimatedLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), state.Limit))
+	if minRTT := state.Values["minRTT"]; minRTT > 0 {
+		l.minRTT = int64(minRTT)
+		l.minRTTAge = 0
+	}
+	if rate := state.Values["maxDeliveryRate"]; rate > 0 {
+		l.rateCount = 0
+		l.rateIndex = 0
+		l.addRate(rate)
+		l.fullRate = rate
+		l.filledPipe = true
+		l.enterProbeBandwidth()
+	}
+	l.notifyListeners(int(l.estimatedLimit))
+	return nil
+}
+
+// Snapshot returns the current state of the limit.
+func (l *BBRLimit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "BBRLimit",
+		Limit: int(l.estimatedLimit),
+		Attributes: map[string]interface{}{
+			"mode":            string(l.mode),
+			"pacingGain":      l.pacingGain(),
+			"minRTT":          l.minRTT,
+			"maxDeliveryRate": l.maxRate() * 1e9,
+			"minLimit":        l.minLimit,
+			"maxLimit":        l.maxLimit,
+		},
+	}
+}
+
+func (l *BBRLimit) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return fmt.Sprintf("BBRLimit{limit=%d, mode=%s, minRTT=%d ms}", int(l.estimatedLimit), l.mode, l.minRTT/1e6)
+}
@@ -0,0 +1,174 @@
+package limit
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// bottleneckSample returns the RTT of a backend that serves capacity requests concurrently in baseRTT and queues the
+// rest, so the delivery rate is capped at capacity / baseRTT.
+func bottleneckSample(inFlight int, capacity int, baseRTT time.Duration) int64 {
+	return int64(float64(baseRTT) * math.Max(1, float64(inFlight)/float64(capacity)))
+}
+
+func createBBRLimit(minRTTWindow int) *BBRLimit {
+	l, _ := NewBBRLimit("test", 20, 4, 1000, 10, minRTTWindow, NoopLimitLogger{}, core.EmptyMetricRegistryInstance)
+	l.SetRandom(core.NewSeededRandomSource(42))
+	return l
+}
+
+func TestBBRLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewDefaultBBRLimit("test", nil, nil)
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal(BBRModeStartup, l.Mode())
+		asrt.Equal("BBRLimit{limit=20, mode=startup, minRTT=0 ms}", l.String())
+
+		_, err := NewBBRLimit("test", 20, 10, 5, 0, 0, nil, nil)
+		asrt.Error(err)
+	})
+
+	t.Run("ConvergesToBandwidthDelayProduct", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createBBRLimit(1000)
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+
+		// startup grows the limit exponentially
+		l.OnSample(0, bottleneckSample(20, 100, 10*time.Millisecond), 20, false)
+		asrt.Equal(58, l.EstimatedLimit())
+		asrt.Equal([]int{58}, listener.changes)
+		for i := 0; i < 10 && l.Mode() == BBRModeStartup; i++ {
+			inFlight := l.EstimatedLimit()
+			l.OnSample(0, bottleneckSample(inFlight, 100, 10*time.Millisecond), inFlight, false)
+		}
+		asrt.Equal(BBRModeDrain, l.Mode())
+		asrt.Equal(35, l.EstimatedLimit())
+
+		for i := 0; i < 32; i++ {
+			inFlight := l.EstimatedLimit()
+			l.OnSample(0, bottleneckSample(inFlight, 100, 10*time.Millisecond), inFlight, false)
+			asrt.Equal(BBRModeProbeBandwidth, l.Mode())
+			asrt.GreaterOrEqual(l.EstimatedLimit(), 75)
+			asrt.LessOrEqual(l.EstimatedLimit(), 125)
+		}
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), l.MinRTT())
+		asrt.InDelta(10000, l.MaxDeliveryRate(), 0.001)
+	})
+
+	t.Run("MeasuredThroughput", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		// every window reports the peak in flight count and the RTT of the first requests, which are served before the
+		// queue at a bottleneck of 100 concurrent requests in 10ms builds, while only 10000 requests/s complete
+		baseRTT, window := 10*time.Millisecond, 100*time.Millisecond
+		simulate := func(l *BBRLimit, measured bool) {
+			for i := 0; i < 64; i++ {
+				inFlight := l.EstimatedLimit()
+				if measured {
+					completed := int(math.Min(float64(inFlight), 100) * float64(window/baseRTT))
+					l.OnThroughput(completed, window)
+				}
+				l.OnSample(0, baseRTT.Nanoseconds(), inFlight, false)
+			}
+		}
+
+		estimated := createBBRLimit(1000)
+		simulate(estimated, false)
+		asrt.Greater(estimated.EstimatedLimit(), 500, "the in flight estimate overstates the bottleneck")
+
+		l := createBBRLimit(1000)
+		simulate(l, true)
+		asrt.Equal(BBRModeProbeBandwidth, l.Mode())
+		asrt.GreaterOrEqual(l.EstimatedLimit(), 75)
+		asrt.LessOrEqual(l.EstimatedLimit(), 125)
+		asrt.InDelta(10000, l.MaxDeliveryRate(), 0.001)
+	})
+
+	t.Run("AppLimitedSamplesDoNotShrinkTheLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createBBRLimit(1000)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(58, l.EstimatedLimit())
+		for i := 0; i < 20; i++ {
+			l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 2, false)
+		}
+		asrt.Equal(58, l.EstimatedLimit())
+	})
+
+	t.Run("ProbeRTT", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createBBRLimit(5)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		for i := 0; i < 5; i++ {
+			l.OnSample(0, (20 * time.Millisecond).Nanoseconds(), 20, false)
+			asrt.NotEqual(BBRModeProbeRTT, l.Mode())
+		}
+
+		// the minimum RTT was not refreshed within the window
+		l.OnSample(0, (20 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(BBRModeProbeRTT, l.Mode())
+		asrt.Equal(4, l.EstimatedLimit())
+
+		// the RTT measured at the minimum limit replaces the minimum RTT
+		l.OnSample(0, (15 * time.Millisecond).Nanoseconds(), 4, false)
+		asrt.Equal(BBRModeStartup, l.Mode())
+		asrt.Equal((15 * time.Millisecond).Nanoseconds(), l.MinRTT())
+		asrt.Greater(l.EstimatedLimit(), 4)
+	})
+
+	t.Run("DropEndsStartup", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createBBRLimit(1000)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, true)
+		asrt.Equal(BBRModeDrain, l.Mode())
+		asrt.Equal(7, l.EstimatedLimit())
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createBBRLimit(1000)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal("BBRLimit", state.Type)
+		asrt.Equal(58.0, state.Limit)
+		asrt.Equal(float64((10 * time.Millisecond).Nanoseconds()), state.Values["minRTT"])
+
+		restored := createBBRLimit(1000)
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(58, restored.EstimatedLimit())
+		asrt.Equal(BBRModeProbeBandwidth, restored.Mode())
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), restored.MinRTT())
+		asrt.InDelta(2000, restored.MaxDeliveryRate(), 0.001)
+
+		state.Type = "VegasLimit"
+		asrt.Error(restored.ImportState(state))
+	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createBBRLimit(1000)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		snapshot := l.Snapshot()
+		asrt.Equal("BBRLimit", snapshot.Type)
+		asrt.Equal(58, snapshot.Limit)
+		asrt.Equal("startup", snapshot.Attributes["mode"])
+		asrt.Equal(bbrHighGain, snapshot.Attributes["pacingGain"])
+	})
+}
@@ -0,0 +1,334 @@
+package limit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// CompositeMode defines how CompositeLimit combines the limits of its algorithms.
+type CompositeMode int
+
+// The available modes
+const (
+	// CompositeMin uses the lowest limit, so every algorithm bounds the others.
+	CompositeMin CompositeMode = iota
+	// CompositeMax uses the highest limit.
+	CompositeMax
+	// CompositeWeighted uses the weighted average of the limits.
+	CompositeWeighted
+)
+
+func (m CompositeMode) String() string {
+	switch m {
+	case CompositeMin:
+		return "min"
+	case CompositeMax:
+		return "max"
+	case CompositeWeighted:
+		return "weighted"
+	default:
+		return fmt.Sprintf("CompositeMode(%d)", int(m))
+	}
+}
+
+// CompositeLimit implements a core.Limit that passes every sample to several limit algorithms and combines their
+// limits, i.e. the fast reaction of AIMD to drops bounded by the latency view of Gradient2 with CompositeMin.  Every
+// algorithm keeps adjusting its own limit independently of the combined limit, so each should have its own bounds.
+// Listeners are notified whenever the combined limit changes.  The exported state holds the state of every algorithm
+// that is a core.StatefulLimit.
+type CompositeLimit struct {
+	mode    CompositeMode
+	members []core.Limit
+	weights []float64
+	limits  []int
+	limit   int
+
+	mu        sync.RWMutex
+	listeners []core.LimitChangeListener
+}
+
+// NewCompositeLimit will create a new CompositeLimit.
+// @param mode: how the limits of the algorithms are combined.
+// @param limits: the limit algorithms, at least one is required.
+// @param weights: weight of each of the limits for CompositeWeighted, ignored by the other modes.
+func NewCompositeLimit(
+	mode CompositeMode,
+	limits []core.Limit,
+	weights []float64,
+) (*CompositeLimit, error) {
+	if len(limits) == 0 {
+		return nil, fmt.Errorf("at least one limit must be specified")
+	}
+	for _, member := range limits {
+		if member == nil {
+			return nil, fmt.Errorf("limits must not be nil")
+		}
+	}
+	switch mode {
+	case CompositeMin, CompositeMax:
+		weights = nil
+	case CompositeWeighted:
+		if len(weights) != len(limits) {
+			return nil, fmt.Errorf("a weight must be specified for each limit")
+		}
+		total := 0.0
+		for _, weight := range weights {
+			if weight < 0 {
+				return nil, fmt.Errorf("weights must be >= 0")
+			}
+			total += weight
+		}
+		if total <= 0 {
+			return nil, fmt.Errorf("at least one weight must be > 0")
+		}
+		weights = append([]float64(nil), weights...)
+	default:
+		return nil, fmt.Errorf("unknown composite mode %d", int(mode))
+	}
+
+	l := &CompositeLimit{
+		mode:      mode,
+		members:   append([]core.Limit(nil), limits...),
+		weights:   weights,
+		limits:    make([]int, len(limits)),
+		listeners: make([]core.LimitChangeListener, 0),
+	}
+	for i, member := range l.members {
+		l.limits[i] = member.EstimatedLimit()
+		// the algorithms notify while holding their own locks, so the new limit is cached rather than read back
+		member.NotifyOnChange(func(newLimit int) {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.limits[i] = newLimit
+			l.update()
+		})
+	}
+	l.limit = l.combine()
+	return l, nil
+}
+
+// EstimatedLimit returns the combined limit of the algorithms.
+func (l *CompositeLimit) EstimatedLimit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.limit
+}
+
+// Limits returns the limit of each of the algorithms.
+func (l *CompositeLimit) Limits() []int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]int(nil), l.limits...)
+}
+
+// NotifyOnChange will register a callback to receive notification whenever the limit is updated to a new value.
+func (l *CompositeLimit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.mu.Lock()
+	l.listeners = append(l.listeners, consumer)
+	l.mu.Unlock()
+}
+
+// notifyListeners will call the callbacks on limit changes
+func (l *CompositeLimit) notifyListeners(newLimit int) {
+	for _, listener := range l.listeners {
+		listener(newLimit)
+	}
+}
+
+// combine returns the combined limit of the cached limits of the algorithms, it must be called with mu held.
+func (l *CompositeLimit) combine() int {
+	combined := l.limits[0]
+	switch l.mode {
+	case CompositeMin:
+		for _, limit := range l.limits[1:] {
+			if limit < combined {
+				combined = limit
+			}
+		}
+	case CompositeMax:
+		for _, limit := range l.limits[1:] {
+			if limit > combined {
+				combined = limit
+			}
+		}
+	case CompositeWeighted:
+		sum, total := 0.0, 0.0
+		for i, limit := range l.limits {
+			sum += l.weights[i] * float64(limit)
+			total += l.weights[i]
+		}
+		combined = int(math.Round(sum / total))
+	}
+	if combined < 1 {
+		combined = 1
+	}
+	return combined
+}
+
+// update will recompute the combined limit and notify listeners if it changed, it must be called with mu held.
+func (l *CompositeLimit) update() {
+	newLimit := l.combine()
+	if newLimit == l.limit {
+		return
+	}
+	l.limit = newLimit
+	l.notifyListeners(l.limit)
+}
+
+// OnSample will pass the sample to every algorithm and update the combined limit.
+func (l *CompositeLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	// the algorithms notify the combined limit of changes, so they are called without holding mu
+	for _, member := range l.members {
+		member.OnSample(startTime, rtt, inFlight, didDrop)
+	}
+
+	// algorithms that do not notify on every change are caught up after the sample
+	limits := make([]int, len(l.members))
+	for i, member := range l.members {
+		limits[i] = member.EstimatedLimit()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	copy(l.limits, limits)
+	l.update()
+}
+
+// OnThroughput will pass the throughput to every algorithm that is a core.ThroughputLimit.
+func (l *CompositeLimit) OnThroughput(completed int, duration time.Duration) {
+	for _, member := range l.members {
+		if throughputLimit, ok := member.(core.ThroughputLimit); ok {
+			throughputLimit.OnThroughput(completed, duration)
+		}
+	}
+}
+
+// ObserveRTT will pass the RTT to every algorithm that is a core.RTTObservingLimit.
+func (l *CompositeLimit) ObserveRTT(rtt int64) {
+	for _, member := range l.members {
+		if observer, ok := member.(core.RTTObservingLimit); ok {
+			observer.ObserveRTT(rtt)
+		}
+	}
+}
+
+// OnDropRate will pass the drop rate to every algorithm that is a core.DropRateLimit.
+func (l *CompositeLimit) OnDropRate(dropped int, total int) {
+	for _, member := range l.members {
+		if dropRateLimit, ok := member.(core.DropRateLimit); ok {
+			dropRateLimit.OnDropRate(dropped, total)
+		}
+	}
+}
+
+// CheckIdle will pass the idle check to every algorithm that is a core.IdleLimit.
+func (l *CompositeLimit) CheckIdle() {
+	for _, member := range l.members {
+		if idleLimit, ok := member.(core.IdleLimit); ok {
+			idleLimit.CheckIdle()
+		}
+	}
+}
+
+// ExportState returns the combined limit and the state of every algorithm.
+func (l *CompositeLimit) ExportState() (core.LimitState, error) {
+	// the algorithms notify while holding their own locks, so they are exported without holding mu
+	members := make([]core.LimitState, len(l.members))
+	for i, member := range l.members {
+		stateful, ok := member.(core.StatefulLimit)
+		if !ok {
+			continue
+		}
+		state, err := stateful.ExportState()
+		if errors.Is(err, core.ErrStateNotSupported) {
+			continue
+		}
+		if err != nil {
+			return core.LimitState{}, fmt.Errorf("exporting state of limit %d: %w", i, err)
+		}
+		members[i] = state
+	}
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "CompositeLimit",
+		Limit:   float64(l.EstimatedLimit()),
+		Members: members,
+	}, nil
+}
+
+// ImportState will restore the state of every algorithm from a previously exported state, the combined limit is
+// recomputed from the restored limits.  Nothing is imported unless the state holds one state for every algorithm of
+// the same type as the algorithm.
+func (l *CompositeLimit) ImportState(state core.LimitState) error {
+	if err := state.Check("CompositeLimit"); err != nil {
+		return err
+	}
+	if len(state.Members) != len(l.members) {
+		return fmt.Errorf("limit state has %d members for %d limits", len(state.Members), len(l.members))
+	}
+	for i, member := range l.members {
+		memberType := ""
+		if stateful, ok := member.(core.StatefulLimit); ok {
+			current, err := stateful.ExportState()
+			if err != nil && !errors.Is(err, core.ErrStateNotSupported) {
+				return fmt.Errorf("exporting state of limit %d: %w", i, err)
+			}
+			memberType = current.Type
+		}
+		if state.Members[i].Type != memberType {
+			return fmt.Errorf("limit state of type %q can not be imported into limit %d of type %q",
+				state.Members[i].Type, i, memberType)
+		}
+	}
+	for i, member := range l.members {
+		if state.Members[i].Type == "" {
+			continue
+		}
+		if err := member.(core.StatefulLimit).ImportState(state.Members[i]); err != nil {
+			return fmt.Errorf("importing state of limit %d: %w", i, err)
+		}
+	}
+
+	limits := make([]int, len(l.members))
+	for i, member := range l.members {
+		limits[i] = member.EstimatedLimit()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	copy(l.limits, limits)
+	l.update()
+	return nil
+}
+
+// Snapshot returns the current state of the limit, including the snapshots of the algorithms as its members.
+func (l *CompositeLimit) Snapshot() core.Snapshot {
+	// the algorithms notify while holding their own locks, so they are inspected without holding mu
+	snapshots := make([]*core.Snapshot, len(l.members))
+	for i, member := range l.members {
+		snapshots[i] = core.SnapshotOf(member)
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	attributes := map[string]interface{}{
+		"mode": l.mode.String(),
+	}
+	if l.weights != nil {
+		attributes["weights"] = append([]float64(nil), l.weights...)
+	}
+	return core.Snapshot{
+		Type:       "CompositeLimit",
+		Limit:      l.limit,
+		Attributes: attributes,
+		Members:    snapshots,
+	}
+}
+
+func (l *CompositeLimit) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return fmt.Sprintf("CompositeLimit{limit=%d, mode=%s, limits=%v}", l.limit, l.mode, l.limits)
+}
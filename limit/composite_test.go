@@ -0,0 +1,152 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+func TestCompositeLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Validation", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fixed := NewFixedLimit("test", 10, nil)
+		_, err := NewCompositeLimit(CompositeMin, nil, nil)
+		asrt.Error(err)
+		_, err = NewCompositeLimit(CompositeMin, []core.Limit{fixed, nil}, nil)
+		asrt.Error(err)
+		_, err = NewCompositeLimit(CompositeWeighted, []core.Limit{fixed, fixed}, []float64{1})
+		asrt.Error(err)
+		_, err = NewCompositeLimit(CompositeWeighted, []core.Limit{fixed, fixed}, []float64{1, -1})
+		asrt.Error(err)
+		_, err = NewCompositeLimit(CompositeWeighted, []core.Limit{fixed, fixed}, []float64{0, 0})
+		asrt.Error(err)
+		_, err = NewCompositeLimit(CompositeMode(10), []core.Limit{fixed}, nil)
+		asrt.Error(err)
+	})
+
+	t.Run("Modes", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		limits := []core.Limit{NewFixedLimit("test", 10, nil), NewFixedLimit("test", 30, nil)}
+		l, err := NewCompositeLimit(CompositeMin, limits, nil)
+		asrt.NoError(err)
+		asrt.Equal(10, l.EstimatedLimit())
+		asrt.Equal("CompositeLimit{limit=10, mode=min, limits=[10 30]}", l.String())
+		l, err = NewCompositeLimit(CompositeMax, limits, nil)
+		asrt.NoError(err)
+		asrt.Equal(30, l.EstimatedLimit())
+		l, err = NewCompositeLimit(CompositeWeighted, limits, []float64{3, 1})
+		asrt.NoError(err)
+		asrt.Equal(15, l.EstimatedLimit())
+	})
+
+	t.Run("OnSample", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		aimd := NewDefaultAIMDLimit("test", nil)
+		settable := NewSettableLimit("test", 12, nil)
+		l, err := NewCompositeLimit(CompositeMin, []core.Limit{aimd, settable}, nil)
+		asrt.NoError(err)
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+
+		// every algorithm receives the sample
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 10, false)
+		asrt.Equal(11, aimd.EstimatedLimit())
+		asrt.Equal(11, l.EstimatedLimit())
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 11, false)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 12, false)
+		asrt.Equal(13, aimd.EstimatedLimit())
+		asrt.Equal(12, l.EstimatedLimit())
+		asrt.Equal([]int{13, 12}, l.Limits())
+
+		// changes of an algorithm outside of a sample move the combined limit
+		settable.SetLimit(5)
+		asrt.Equal(5, l.EstimatedLimit())
+		asrt.Equal([]int{11, 12, 5}, listener.changes)
+	})
+
+	t.Run("ForwardsOptionalInterfaces", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		littlesLaw := NewDefaultLittlesLawLimit("test", nil, nil)
+		slo := createSLOLimit()
+		errorRate := createErrorRateLimit(NewFixedLimit("test", 20, nil))
+		l, err := NewCompositeLimit(CompositeMin, []core.Limit{littlesLaw, slo, errorRate}, nil)
+		asrt.NoError(err)
+		l.OnThroughput(1000, time.Second)
+		asrt.InDelta(1000, littlesLaw.Throughput(), 0.001)
+		l.ObserveRTT((10 * time.Millisecond).Nanoseconds())
+		l.OnDropRate(1, 4)
+		l.OnSample(0, (20 * time.Millisecond).Nanoseconds(), 20, true)
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), slo.PercentileRTT())
+		asrt.Equal(0.25, errorRate.ErrorRate())
+	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewCompositeLimit(CompositeWeighted,
+			[]core.Limit{NewFixedLimit("test", 10, nil), NewFixedLimit("test", 30, nil)}, []float64{1, 1})
+		asrt.NoError(err)
+		snapshot := l.Snapshot()
+		asrt.Equal("CompositeLimit", snapshot.Type)
+		asrt.Equal(20, snapshot.Limit)
+		asrt.Equal("weighted", snapshot.Attributes["mode"])
+		asrt.Equal([]float64{1, 1}, snapshot.Attributes["weights"])
+		asrt.Len(snapshot.Members, 2)
+		asrt.Equal(30, snapshot.Members[1].Limit)
+		asrt.Equal("CompositeLimit limit=20 inFlight=0\n  FixedLimit limit=10 inFlight=0\n  FixedLimit limit=30 inFlight=0\n",
+			snapshot.Render())
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		newComposite := func() (*CompositeLimit, *AIMDLimit, *SettableLimit) {
+			aimd := NewAIMDLimit("test", 10, 0.5, 1, nil)
+			settable := NewSettableLimit("test", 50, nil)
+			l, err := NewCompositeLimit(CompositeMin, []core.Limit{aimd, settable}, nil)
+			asrt.NoError(err)
+			return l, aimd, settable
+		}
+		l, aimd, _ := newComposite()
+		for i := 0; i < 10; i++ {
+			aimd.OnSample(0, 10, 20, false)
+		}
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal("CompositeLimit", state.Type)
+		asrt.Equal(20.0, state.Limit)
+		asrt.Len(state.Members, 2)
+		asrt.Equal("AIMDLimit", state.Members[0].Type)
+		asrt.Equal(20.0, state.Members[0].Limit)
+		asrt.Equal(core.LimitState{}, state.Members[1])
+
+		restored, restoredAIMD, _ := newComposite()
+		listener := testNotifyListener{}
+		restored.NotifyOnChange(listener.updater())
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(20, restoredAIMD.EstimatedLimit())
+		asrt.Equal(20, restored.EstimatedLimit())
+		asrt.Equal([]int{20}, listener.changes)
+
+		// nothing is imported unless every member matches
+		mismatched := state
+		mismatched.Members = []core.LimitState{state.Members[0]}
+		asrt.Error(restored.ImportState(mismatched))
+		mismatched.Members = []core.LimitState{{Version: core.LimitStateVersion, Type: "VegasLimit", Limit: 5}, {}}
+		asrt.Error(restored.ImportState(mismatched))
+		mismatched.Members = []core.LimitState{state.Members[0], state.Members[0]}
+		asrt.Error(restored.ImportState(mismatched))
+		asrt.Equal(20, restored.EstimatedLimit())
+		state.Type = "AIMDLimit"
+		asrt.Error(restored.ImportState(state))
+	})
+}
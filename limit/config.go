@@ -0,0 +1,75 @@
+package limit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// configValidator collects every invalid field of a limit configuration, so that they are all reported at once.  The
+// same checks validate the parameters of an Update, which set update.
+type configValidator struct {
+	limitType string
+	update    bool
+	messages  []string
+}
+
+func (v *configValidator) errorf(format string, args ...interface{}) {
+	v.messages = append(v.messages, fmt.Sprintf(format, args...))
+}
+
+// limits will validate that minLimit <= initialLimit <= maxLimit, all of which must be >= 1.
+func (v *configValidator) limits(initialLimit int, minLimit int, maxLimit int) {
+	if initialLimit < 1 {
+		v.errorf("initialLimit must be >= 1, got %d", initialLimit)
+	}
+	if minLimit < 1 {
+		v.errorf("minLimit must be >= 1, got %d", minLimit)
+	}
+	if maxLimit < 1 {
+		v.errorf("maxLimit must be >= 1, got %d", maxLimit)
+	}
+	if minLimit > maxLimit {
+		v.errorf("minLimit %d must be <= maxLimit %d", minLimit, maxLimit)
+	} else if initialLimit >= 1 && (initialLimit < minLimit || initialLimit > maxLimit) {
+		v.errorf("initialLimit %d must be within [minLimit %d, maxLimit %d]", initialLimit, minLimit, maxLimit)
+	}
+}
+
+// atLeastOne will validate that a count parameter is >= 1.
+func (v *configValidator) atLeastOne(name string, value int) {
+	if value < 1 {
+		v.errorf("%s must be >= 1, got %d", name, value)
+	}
+}
+
+// smoothing will validate that smoothing is in (0, 1], a smoothing of 0 would never move the limit.
+func (v *configValidator) smoothing(smoothing float64) {
+	if smoothing <= 0 || smoothing > 1 {
+		v.errorf("smoothing must be in (0, 1], got %g", smoothing)
+	}
+}
+
+// rttTolerance will validate that the tolerance of the RTT exceeding its baseline is >= 1.
+func (v *configValidator) rttTolerance(rttTolerance float64) {
+	if rttTolerance < 1 {
+		v.errorf("rttTolerance must be >= 1, got %g", rttTolerance)
+	}
+}
+
+// check will collect the error of a nested validation, i.e. of an IdlePolicy.
+func (v *configValidator) check(err error) {
+	if err != nil {
+		v.errorf("%s", err)
+	}
+}
+
+func (v *configValidator) err() error {
+	if len(v.messages) == 0 {
+		return nil
+	}
+	kind := "config"
+	if v.update {
+		kind = "update"
+	}
+	return fmt.Errorf("invalid %s %s: %s", v.limitType, kind, strings.Join(v.messages, "; "))
+}
@@ -0,0 +1,304 @@
+package limit
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// errorRateRecovery is the growth of the ceiling on every sample once the error rate is below every threshold.
+const errorRateRecovery = 1.1
+
+// ErrorRateThreshold is a graduated response of ErrorRateLimit to an error rate.
+type ErrorRateThreshold struct {
+	// ErrorRate in [0, 1] at or above which the threshold applies.
+	ErrorRate float64
+	// BackOff in (0, 1] multiplies the limit on every sample while the threshold applies, 1 freezes the limit.
+	BackOff float64
+}
+
+// DefaultErrorRateThresholds freeze the limit at a 5% error rate and back off harder as the error rate grows.
+var DefaultErrorRateThresholds = []ErrorRateThreshold{
+	{ErrorRate: 0.05, BackOff: 1},
+	{ErrorRate: 0.1, BackOff: 0.9},
+	{ErrorRate: 0.25, BackOff: 0.75},
+	{ErrorRate: 0.5, BackOff: 0.5},
+}
+
+// ErrorRateLimit implements a core.Limit decorator that reacts to the ratio of dropped requests.  The wrapped limit
+// only learns whether a sample window had a drop, so a backend failing 20% of requests looks much like one with a
+// single timeout, and a backend that fails fast can even look faster.  ErrorRateLimit tracks the drop ratio over a
+// sliding window of samples and, while it is at or above one of the thresholds, caps the limit of the wrapped limit
+// with a ceiling that is multiplied by the BackOff of the highest threshold reached on every sample.  Once the error
+// rate is below every threshold the ceiling grows by 10% per sample until it no longer caps the wrapped limit.
+//
+// The wrapped limit keeps receiving every sample so latency based adjustments continue underneath the ceiling.  Drop
+// counts come from OnDropRate, which DefaultLimiter calls with the dropped and total requests of every sample window.
+// When OnDropRate is not called every sample counts as a single request.
+type ErrorRateLimit struct {
+	delegate   core.Limit
+	thresholds []ErrorRateThreshold
+
+	dropped         []int
+	totals          []int
+	index           int
+	count           int
+	pendingDropped  int
+	pendingTotal    int
+	pending         bool
+	ceiling         float64 // 0 when the wrapped limit is not capped
+	limit           int
+	errorRateSample core.MetricSampleListener
+
+	mu        sync.RWMutex
+	listeners []core.LimitChangeListener
+}
+
+// NewDefaultErrorRateLimit will create a default ErrorRateLimit over 10 samples with DefaultErrorRateThresholds.
+func NewDefaultErrorRateLimit(
+	name string,
+	delegate core.Limit,
+	registry core.MetricRegistry,
+	tags ...string,
+) (*ErrorRateLimit, error) {
+	return NewErrorRateLimit(name, delegate, 10, DefaultErrorRateThresholds, registry, tags...)
+}
+
+// NewErrorRateLimit will create a new ErrorRateLimit.
+// @param delegate: the wrapped limit.
+// @param window: number of samples the error rate is measured over, default 10.
+// @param thresholds: error rates and the back off applied at or above them.
+// @param registry: metric registry to publish metrics
+func NewErrorRateLimit(
+	name string,
+	delegate core.Limit,
+	window int,
+	thresholds []ErrorRateThreshold,
+	registry core.MetricRegistry,
+	tags ...string,
+) (*ErrorRateLimit, error) {
+	if delegate == nil {
+		return nil, fmt.Errorf("delegate must be specified")
+	}
+	if window <= 0 {
+		window = 10
+	}
+	if registry == nil {
+		registry = core.EmptyMetricRegistryInstance
+	}
+	if len(thresholds) == 0 {
+		return nil, fmt.Errorf("at least one threshold must be specified")
+	}
+	sorted := append([]ErrorRateThreshold(nil), thresholds...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ErrorRate < sorted[j].ErrorRate
+	})
+	for _, threshold := range sorted {
+		if threshold.ErrorRate < 0 || threshold.ErrorRate > 1 {
+			return nil, fmt.Errorf("threshold errorRate must be in [0, 1]")
+		}
+		if threshold.BackOff <= 0 || threshold.BackOff > 1 {
+			return nil, fmt.Errorf("threshold backOff must be in (0, 1]")
+		}
+	}
+
+	l := &ErrorRateLimit{
+		delegate:   delegate,
+		thresholds: sorted,
+		dropped:    make([]int, window),
+		totals:     make([]int, window),
+		limit:      delegate.EstimatedLimit(),
+		errorRateSample: registry.RegisterDistribution(
+			core.PrefixMetricWithName(core.MetricErrorRate, name), tags...),
+		listeners: make([]core.LimitChangeListener, 0),
+	}
+	delegate.NotifyOnChange(l.onDelegateChange)
+	return l, nil
+}
+
+// EstimatedLimit returns the limit of the wrapped limit, capped by the ceiling while the error rate is high.
+func (l *ErrorRateLimit) EstimatedLimit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.limit
+}
+
+// ErrorRate returns the ratio of dropped requests over the window.
+func (l *ErrorRateLimit) ErrorRate() float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.errorRate()
+}
+
+func (l *ErrorRateLimit) errorRate() float64 {
+	dropped, total := 0, 0
+	for i := 0; i < l.count; i++ {
+		dropped += l.dropped[i]
+		total += l.totals[i]
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(dropped) / float64(total)
+}
+
+// NotifyOnChange will register a callback to receive notification whenever the limit is updated to a new value.
+func (l *ErrorRateLimit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.mu.Lock()
+	l.listeners = append(l.listeners, consumer)
+	l.mu.Unlock()
+}
+
+// notifyListeners will call the callbacks on limit changes
+func (l *ErrorRateLimit) notifyListeners(newLimit int) {
+	for _, listener := range l.listeners {
+		listener(newLimit)
+	}
+}
+
+// onDelegateChange applies the ceiling to a changed limit of the wrapped limit.
+func (l *ErrorRateLimit) onDelegateChange(delegateLimit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setLimit(delegateLimit)
+}
+
+// setLimit will cap the limit of the wrapped limit with the ceiling and notify listeners if the result changed, it
+// must be called with mu held.
+func (l *ErrorRateLimit) setLimit(delegateLimit int) {
+	newLimit := delegateLimit
+	if l.ceiling > 0 && int(l.ceiling) < newLimit {
+		newLimit = int(l.ceiling)
+	}
+	if newLimit == l.limit {
+		return
+	}
+	l.limit = newLimit
+	l.notifyListeners(l.limit)
+}
+
+// OnDropRate records that dropped out of total requests of the next sample were dropped.
+func (l *ErrorRateLimit) OnDropRate(dropped int, total int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pendingDropped, l.pendingTotal, l.pending = dropped, total, true
+}
+
+// OnSample records the drops of the sample, passes the sample to the wrapped limit and updates the ceiling.
+func (l *ErrorRateLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	l.mu.Lock()
+	dropped, total := l.pendingDropped, l.pendingTotal
+	if !l.pending {
+		dropped, total = 0, 1
+		if didDrop {
+			dropped = 1
+		}
+	}
+	l.pending = false
+	l.dropped[l.index], l.totals[l.index] = dropped, total
+	l.index = (l.index + 1) % len(l.dropped)
+	if l.count < len(l.dropped) {
+		l.count++
+	}
+	errorRate := l.errorRate()
+	l.errorRateSample.AddSample(errorRate)
+	limitBefore := float64(l.limit)
+	l.mu.Unlock()
+
+	// the wrapped limit may notify onDelegateChange, so it is called without holding mu
+	l.delegate.OnSample(startTime, rtt, inFlight, didDrop)
+	delegateLimit := l.delegate.EstimatedLimit()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if threshold, ok := l.threshold(errorRate); ok {
+		if l.ceiling == 0 || limitBefore < l.ceiling {
+			l.ceiling = limitBefore
+		}
+		l.ceiling = math.Max(1, l.ceiling*threshold.BackOff)
+	} else if l.ceiling > 0 {
+		l.ceiling *= errorRateRecovery
+		if l.ceiling >= float64(delegateLimit) {
+			l.ceiling = 0
+		}
+	}
+	l.setLimit(delegateLimit)
+}
+
+// threshold returns the highest threshold reached by errorRate.
+func (l *ErrorRateLimit) threshold(errorRate float64) (ErrorRateThreshold, bool) {
+	for i := len(l.thresholds) - 1; i >= 0; i-- {
+		if errorRate >= l.thresholds[i].ErrorRate && errorRate > 0 {
+			return l.thresholds[i], true
+		}
+	}
+	return ErrorRateThreshold{}, false
+}
+
+// OnThroughput will delegate the throughput to the wrapped limit if it is a core.ThroughputLimit.
+func (l *ErrorRateLimit) OnThroughput(completed int, duration time.Duration) {
+	if throughputLimit, ok := l.delegate.(core.ThroughputLimit); ok {
+		throughputLimit.OnThroughput(completed, duration)
+	}
+}
+
+// ObserveRTT will delegate the RTT to the wrapped limit if it is a core.RTTObservingLimit.
+func (l *ErrorRateLimit) ObserveRTT(rtt int64) {
+	if observer, ok := l.delegate.(core.RTTObservingLimit); ok {
+		observer.ObserveRTT(rtt)
+	}
+}
+
+// CheckIdle will delegate the idle check to the wrapped limit if it is a core.IdleLimit, the ceiling still applies to
+// a decayed limit.
+func (l *ErrorRateLimit) CheckIdle() {
+	if idleLimit, ok := l.delegate.(core.IdleLimit); ok {
+		idleLimit.CheckIdle()
+	}
+}
+
+// ExportState returns the state of the wrapped limit, or core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *ErrorRateLimit) ExportState() (core.LimitState, error) {
+	if stateful, ok := l.delegate.(core.StatefulLimit); ok {
+		return stateful.ExportState()
+	}
+	return core.LimitState{}, core.ErrStateNotSupported
+}
+
+// ImportState will restore the state of the wrapped limit, or return core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *ErrorRateLimit) ImportState(state core.LimitState) error {
+	if stateful, ok := l.delegate.(core.StatefulLimit); ok {
+		return stateful.ImportState(state)
+	}
+	return core.ErrStateNotSupported
+}
+
+// Snapshot returns the current state of the limit including the snapshot of the wrapped limit.
+func (l *ErrorRateLimit) Snapshot() core.Snapshot {
+	// the wrapped limit notifies while holding its own lock, so it is inspected without holding mu
+	delegate := core.SnapshotOf(l.delegate)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "ErrorRateLimit",
+		Limit: l.limit,
+		Attributes: map[string]interface{}{
+			"errorRate": l.errorRate(),
+			"ceiling":   int(l.ceiling),
+		},
+		Delegate: delegate,
+	}
+}
+
+func (l *ErrorRateLimit) String() string {
+	delegate := fmt.Sprint(l.delegate)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return fmt.Sprintf("ErrorRateLimit{limit=%d, errorRate=%0.4f, delegate=%s}", l.limit, l.errorRate(), delegate)
+}
@@ -0,0 +1,228 @@
+package limit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+func createErrorRateLimit(delegate core.Limit) *ErrorRateLimit {
+	l, _ := NewErrorRateLimit("test", delegate, 4, []ErrorRateThreshold{
+		{ErrorRate: 0.1, BackOff: 1},
+		{ErrorRate: 0.5, BackOff: 0.5},
+	}, core.EmptyMetricRegistryInstance)
+	return l
+}
+
+// notifyingLimit is a fixed limit that holds its lock while notifying listeners of a sample, pausing on entered until
+// proceed is closed.
+type notifyingLimit struct {
+	mu        sync.Mutex
+	limit     int
+	listeners []core.LimitChangeListener
+	entered   chan struct{}
+	proceed   chan struct{}
+}
+
+func (l *notifyingLimit) EstimatedLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+func (l *notifyingLimit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.listeners = append(l.listeners, consumer)
+}
+
+func (l *notifyingLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	close(l.entered)
+	<-l.proceed
+	l.limit++
+	for _, listener := range l.listeners {
+		listener(l.limit)
+	}
+}
+
+func (l *notifyingLimit) Snapshot() core.Snapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return core.Snapshot{Type: "notifyingLimit", Limit: l.limit}
+}
+
+func TestErrorRateLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewDefaultErrorRateLimit("test", NewFixedLimit("test", 10, nil), nil)
+		asrt.NoError(err)
+		asrt.Equal(10, l.EstimatedLimit())
+		asrt.Equal(0.0, l.ErrorRate())
+		asrt.Equal("ErrorRateLimit{limit=10, errorRate=0.0000, delegate=FixedLimit{limit=10}}", l.String())
+
+		_, err = NewDefaultErrorRateLimit("test", nil, nil)
+		asrt.Error(err)
+		_, err = NewErrorRateLimit("test", NewFixedLimit("test", 10, nil), 0, nil, nil)
+		asrt.Error(err)
+		_, err = NewErrorRateLimit("test", NewFixedLimit("test", 10, nil), 0,
+			[]ErrorRateThreshold{{ErrorRate: 1.5, BackOff: 0.5}}, nil)
+		asrt.Error(err)
+		_, err = NewErrorRateLimit("test", NewFixedLimit("test", 10, nil), 0,
+			[]ErrorRateThreshold{{ErrorRate: 0.5, BackOff: 0}}, nil)
+		asrt.Error(err)
+	})
+
+	t.Run("DelegatesBelowThresholds", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		delegate := NewDefaultAIMDLimit("test", nil)
+		l := createErrorRateLimit(delegate)
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		l.OnDropRate(0, 100)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 10, false)
+		asrt.Equal(11, l.EstimatedLimit())
+		// a single drop in a large window is below every threshold
+		l.OnDropRate(1, 100)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 11, true)
+		asrt.Equal(9, l.EstimatedLimit())
+		asrt.Equal([]int{11, 9}, listener.changes)
+	})
+
+	t.Run("FreezesIncreases", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createErrorRateLimit(NewDefaultAIMDLimit("test", nil))
+		l.OnDropRate(20, 100)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 10, false)
+		asrt.InDelta(0.2, l.ErrorRate(), 0.001)
+		asrt.Equal(10, l.EstimatedLimit())
+		asrt.Equal(11, l.delegate.EstimatedLimit())
+	})
+
+	t.Run("BacksOff", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createErrorRateLimit(NewFixedLimit("test", 20, nil))
+		l.OnDropRate(60, 100)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(10, l.EstimatedLimit())
+		l.OnDropRate(60, 100)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(5, l.EstimatedLimit())
+		asrt.Equal(5, l.Snapshot().Attributes["ceiling"])
+
+		// the ceiling recovers once the failures leave the window
+		for i := 0; i < 5; i++ {
+			l.OnDropRate(0, 100)
+			l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		}
+		asrt.Equal(0.0, l.ErrorRate())
+		asrt.Equal(6, l.EstimatedLimit())
+		for i := 0; i < 20; i++ {
+			l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		}
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal(0, l.Snapshot().Attributes["ceiling"])
+	})
+
+	t.Run("CountsSamplesWithoutOnDropRate", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createErrorRateLimit(NewFixedLimit("test", 20, nil))
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, true)
+		asrt.Equal(0.5, l.ErrorRate())
+		asrt.Equal(10, l.EstimatedLimit())
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createErrorRateLimit(NewDefaultAIMDLimit("test", nil))
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal("AIMDLimit", state.Type)
+		state.Limit = 30
+		asrt.NoError(l.ImportState(state))
+		asrt.Equal(30, l.EstimatedLimit())
+
+		_, err = createErrorRateLimit(NewFixedLimit("test", 20, nil)).ExportState()
+		asrt.ErrorIs(err, core.ErrStateNotSupported)
+	})
+
+	t.Run("LockOrder", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		delegate := &notifyingLimit{limit: 10, entered: make(chan struct{}), proceed: make(chan struct{})}
+		l := createErrorRateLimit(delegate)
+		done := make(chan struct{}, 2)
+		go func() {
+			l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 10, false)
+			done <- struct{}{}
+		}()
+		<-delegate.entered
+		// the snapshot waits for the wrapped limit, which is about to notify the wrapper
+		go func() {
+			_ = l.Snapshot()
+			done <- struct{}{}
+		}()
+		time.Sleep(10 * time.Millisecond)
+		close(delegate.proceed)
+		for i := 0; i < 2; i++ {
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				asrt.FailNow("deadlock between OnSample and Snapshot")
+			}
+		}
+		asrt.Equal(11, l.EstimatedLimit())
+	})
+
+	t.Run("Concurrent", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		// the wrapped limit notifies while holding its own lock, so inspecting it while samples are applied must not
+		// deadlock
+		l := createErrorRateLimit(NewAIMDLimit("test", 20, 0.9, 1, nil))
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 2000; j++ {
+					l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 1000, (i+j)%3 == 0)
+				}
+			}(i)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 2000; j++ {
+					_ = l.Snapshot()
+					_ = l.String()
+				}
+			}()
+		}
+		wg.Wait()
+		asrt.GreaterOrEqual(l.EstimatedLimit(), 1)
+	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createErrorRateLimit(NewFixedLimit("test", 20, nil))
+		snapshot := l.Snapshot()
+		asrt.Equal("ErrorRateLimit", snapshot.Type)
+		asrt.Equal(20, snapshot.Limit)
+		asrt.Equal(0.0, snapshot.Attributes["errorRate"])
+		asrt.Equal("FixedLimit", snapshot.Delegate.Type)
+	})
+}
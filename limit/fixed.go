@@ -11,6 +11,7 @@ type FixedLimit struct {
 	limit         int
 	registry      core.MetricRegistry
 	commonSampler *core.CommonMetricSampler
+	tags          []string
 }
 
 // NewFixedLimit will return a new FixedLimit
@@ -26,11 +27,56 @@ func NewFixedLimit(name string, limit int, registry core.MetricRegistry, tags ..
 	l := &FixedLimit{
 		limit:    limit,
 		registry: registry,
+		tags:     tags,
 	}
 	l.commonSampler = core.NewCommonMetricSamplerOrNil(registry, l, name, tags...)
 	return l
 }
 
+// FixedLimitConfig configures a FixedLimit created with NewFixedLimitFromConfig.
+type FixedLimitConfig struct {
+	// Limit is the fixed limit, it is required.
+	Limit int `json:"limit"`
+
+	MetricRegistry core.MetricRegistry `json:"-"`
+	Tags           []string            `json:"tags,omitempty"`
+}
+
+// ApplyDefaults will set the default of every optional field with a zero value.
+func (c *FixedLimitConfig) ApplyDefaults() {
+	if c.MetricRegistry == nil {
+		c.MetricRegistry = core.EmptyMetricRegistryInstance
+	}
+}
+
+// Validate will return an error describing every invalid field.
+func (c *FixedLimitConfig) Validate() error {
+	v := &configValidator{limitType: "FixedLimit"}
+	if c.Limit < 1 {
+		v.errorf("limit must be >= 1, got %d", c.Limit)
+	}
+	return v.err()
+}
+
+// NewFixedLimitFromConfig will create a new FixedLimit, returning an error if any field of the config is invalid.  The
+// resolved config is available from FixedLimit.Config.
+func NewFixedLimitFromConfig(name string, config FixedLimitConfig) (*FixedLimit, error) {
+	config.ApplyDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return NewFixedLimit(name, config.Limit, config.MetricRegistry, config.Tags...), nil
+}
+
+// Config returns the configuration of the limit with every default resolved.
+func (l *FixedLimit) Config() FixedLimitConfig {
+	return FixedLimitConfig{
+		Limit:          l.limit,
+		MetricRegistry: l.registry,
+		Tags:           l.tags,
+	}
+}
+
 // EstimatedLimit will return the current limit.
 func (l *FixedLimit) EstimatedLimit() int {
 	return l.limit
@@ -47,6 +93,14 @@ func (l *FixedLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop
 	l.commonSampler.Sample(rtt, inFlight, didDrop)
 }
 
+// Snapshot returns the current state of the limit.
+func (l *FixedLimit) Snapshot() core.Snapshot {
+	return core.Snapshot{
+		Type:  "FixedLimit",
+		Limit: l.limit,
+	}
+}
+
 func (l FixedLimit) String() string {
 	return fmt.Sprintf("FixedLimit{limit=%d}", l.limit)
 }
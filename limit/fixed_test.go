@@ -28,3 +28,16 @@ func TestFixedLimit(t *testing.T) {
 
 	asrt.Equal("FixedLimit{limit=10}", l.String())
 }
+
+func TestFixedLimitFromConfig(t *testing.T) {
+	t.Parallel()
+	asrt := assert.New(t)
+	l, err := NewFixedLimitFromConfig("test", FixedLimitConfig{Limit: 10, Tags: []string{"a:b"}})
+	asrt.NoError(err)
+	asrt.Equal(10, l.EstimatedLimit())
+	asrt.Equal(10, l.Config().Limit)
+	asrt.Equal([]string{"a:b"}, l.Config().Tags)
+
+	_, err = NewFixedLimitFromConfig("test", FixedLimitConfig{})
+	asrt.EqualError(err, "invalid FixedLimit config: limit must be >= 1, got 0")
+}
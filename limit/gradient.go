@@ -3,7 +3,6 @@ package limit
 import (
 	"fmt"
 	"math"
-	"math/rand"
 	"sync"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
@@ -24,9 +23,12 @@ type GradientLimit struct {
 	rttTolerance         float64
 	probeInterval        int
 	resetRTTCounter      int
+	random               core.RandomSource
 	rttNoLoadMeasurement core.MeasurementInterface
 	listeners            []core.LimitChangeListener
 	logger               Logger
+	initialLimit         int
+	tags                 []string
 
 	// metrics
 	registry                   core.MetricRegistry
@@ -38,11 +40,23 @@ type GradientLimit struct {
 	mu sync.RWMutex
 }
 
-func nextProbeCountdown(probeInterval int) int {
+func nextProbeCountdown(random core.RandomSource, probeInterval int) int {
 	if probeInterval == ProbeDisabled {
 		return ProbeDisabled
 	}
-	return probeInterval + rand.Intn(probeInterval)
+	return probeInterval + random.Intn(probeInterval)
+}
+
+// SetRandom will replace the random source used to jitter the probe interval, i.e. with core.NewSeededRandomSource
+// for reproducible tests.  The current probe countdown is redrawn from the new source.
+func (l *GradientLimit) SetRandom(random core.RandomSource) {
+	if random == nil {
+		random = core.SystemRandomSourceInstance
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.random = random
+	l.resetRTTCounter = nextProbeCountdown(random, l.probeInterval)
 }
 
 // NewGradientLimitWithRegistry will create a new GradientLimitWithRegistry.
@@ -95,11 +109,14 @@ func NewGradientLimitWithRegistry(
 		smoothing:            smoothing,
 		rttTolerance:         rttTolerance,
 		probeInterval:        probeInterval,
-		resetRTTCounter:      nextProbeCountdown(probeInterval),
+		resetRTTCounter:      nextProbeCountdown(core.SystemRandomSourceInstance, probeInterval),
+		random:               core.SystemRandomSourceInstance,
 		rttNoLoadMeasurement: &measurements.MinimumMeasurement{},
 		listeners:            make([]core.LimitChangeListener, 0),
 		logger:               logger,
 		registry:             registry,
+		initialLimit:         initialLimit,
+		tags:                 tags,
 
 		minRTTSampleListener:       registry.RegisterDistribution(core.PrefixMetricWithName(core.MetricMinRTT, name), tags...),
 		minWindowRTTSampleListener: registry.RegisterDistribution(core.PrefixMetricWithName(core.MetricWindowMinRTT, name), tags...),
@@ -110,6 +127,114 @@ func NewGradientLimitWithRegistry(
 	return l
 }
 
+// GradientLimitConfig configures a GradientLimit created with NewGradientLimitFromConfig.  The zero value of every
+// field selects its default, any other invalid value is an error.
+type GradientLimitConfig struct {
+	// InitialLimit is the initial limit used by the limiter, default 50.
+	InitialLimit int `json:"initialLimit"`
+	// MinLimit is the minimum concurrency limit allowed, default 1.
+	MinLimit int `json:"minLimit"`
+	// MaxLimit is the maximum allowable concurrency, default 1000.
+	MaxLimit int `json:"maxLimit"`
+	// Smoothing in (0, 1] limits how aggressively the limit shrinks when queuing has been detected, default 0.2.
+	Smoothing float64 `json:"smoothing"`
+	// RTTTolerance >= 1 is the increase of the minimum latency tolerated before reducing the limit, default 2.
+	RTTTolerance float64 `json:"rttTolerance"`
+	// ProbeInterval is the number of updates between probes for a new RTT no load, default 1000.  Set to
+	// ProbeDisabled to disable probing.
+	ProbeInterval int `json:"probeInterval"`
+	// QueueSizeFunc returns how much the limit can grow while latencies remain low, default a square root of the
+	// limit with a minimum of 4.
+	QueueSizeFunc func(estimatedLimit int) int `json:"-"`
+
+	Logger         Logger              `json:"-"`
+	MetricRegistry core.MetricRegistry `json:"-"`
+	Tags           []string            `json:"tags,omitempty"`
+}
+
+// ApplyDefaults will set the default of every field with a zero value.
+func (c *GradientLimitConfig) ApplyDefaults() {
+	if c.InitialLimit == 0 {
+		c.InitialLimit = 50
+	}
+	if c.MinLimit == 0 {
+		c.MinLimit = 1
+	}
+	if c.MaxLimit == 0 {
+		c.MaxLimit = 1000
+	}
+	if c.Smoothing == 0 {
+		c.Smoothing = 0.2
+	}
+	if c.RTTTolerance == 0 {
+		c.RTTTolerance = 2.0
+	}
+	if c.ProbeInterval == 0 {
+		c.ProbeInterval = 1000
+	}
+	if c.QueueSizeFunc == nil {
+		c.QueueSizeFunc = functions.SqrtRootFunction(4)
+	}
+	// the remaining defaults are applied by NewGradientLimitWithRegistry
+}
+
+// Validate will return an error describing every invalid field.
+func (c *GradientLimitConfig) Validate() error {
+	v := &configValidator{limitType: "GradientLimit"}
+	v.limits(c.InitialLimit, c.MinLimit, c.MaxLimit)
+	v.smoothing(c.Smoothing)
+	v.rttTolerance(c.RTTTolerance)
+	v.probeInterval(c.ProbeInterval)
+	return v.err()
+}
+
+// probeInterval will validate that the probe interval is >= 1 or ProbeDisabled.
+func (v *configValidator) probeInterval(probeInterval int) {
+	if probeInterval < 1 && probeInterval != ProbeDisabled {
+		v.errorf("probeInterval must be >= 1 or ProbeDisabled, got %d", probeInterval)
+	}
+}
+
+// NewGradientLimitFromConfig will create a new GradientLimit, returning an error if any field of the config is
+// invalid.  The resolved config is available from GradientLimit.Config.
+func NewGradientLimitFromConfig(name string, config GradientLimitConfig) (*GradientLimit, error) {
+	config.ApplyDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return NewGradientLimitWithRegistry(
+		name,
+		config.InitialLimit,
+		config.MinLimit,
+		config.MaxLimit,
+		config.Smoothing,
+		config.QueueSizeFunc,
+		config.RTTTolerance,
+		config.ProbeInterval,
+		config.Logger,
+		config.MetricRegistry,
+		config.Tags...,
+	), nil
+}
+
+// Config returns the configuration of the limit with every default resolved, including changes made by Update.
+func (l *GradientLimit) Config() GradientLimitConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return GradientLimitConfig{
+		InitialLimit:   l.initialLimit,
+		MinLimit:       l.minLimit,
+		MaxLimit:       l.maxLimit,
+		Smoothing:      l.smoothing,
+		RTTTolerance:   l.rttTolerance,
+		ProbeInterval:  l.probeInterval,
+		QueueSizeFunc:  l.queueSizeFunc,
+		Logger:         l.logger,
+		MetricRegistry: l.registry,
+		Tags:           l.tags,
+	}
+}
+
 // EstimatedLimit returns the current estimated limit.
 func (l *GradientLimit) EstimatedLimit() int {
 	l.mu.RLock()
@@ -155,7 +280,7 @@ func (l *GradientLimit) OnSample(startTime int64, rtt int64, inFlight int, didDr
 	if l.probeInterval != ProbeDisabled {
 		l.resetRTTCounter--
 		if l.resetRTTCounter <= 0 {
-			l.resetRTTCounter = nextProbeCountdown(l.probeInterval)
+			l.resetRTTCounter = nextProbeCountdown(l.random, l.probeInterval)
 
 			l.estimatedLimit = math.Max(float64(l.minLimit), float64(queueSize))
 			l.rttNoLoadMeasurement.Reset()
@@ -202,6 +327,121 @@ func (l *GradientLimit) OnSample(startTime int64, rtt int64, inFlight int, didDr
 	l.notifyListeners(l.estimatedLimit)
 }
 
+// GradientLimitUpdate holds the parameters of a GradientLimit that can be changed at runtime with Update, nil fields
+// are left unchanged.
+type GradientLimitUpdate struct {
+	MinLimit      *int
+	MaxLimit      *int
+	Smoothing     *float64
+	RTTTolerance  *float64
+	ProbeInterval *int
+	QueueSizeFunc func(estimatedLimit int) int
+}
+
+// Update will atomically apply new parameters without resetting the estimated limit or the RTT no load baseline.  If
+// the estimated limit is outside new limit bounds it is clamped and listeners are notified.  A changed probeInterval
+// restarts the probe countdown.  Nothing is applied if any parameter is invalid.
+func (l *GradientLimit) Update(update GradientLimitUpdate) error {
+	v := &configValidator{limitType: "GradientLimit", update: true}
+	if update.MinLimit != nil {
+		v.atLeastOne("minLimit", *update.MinLimit)
+	}
+	if update.MaxLimit != nil {
+		v.atLeastOne("maxLimit", *update.MaxLimit)
+	}
+	if update.Smoothing != nil {
+		v.smoothing(*update.Smoothing)
+	}
+	if update.RTTTolerance != nil {
+		v.rttTolerance(*update.RTTTolerance)
+	}
+	if update.ProbeInterval != nil {
+		v.probeInterval(*update.ProbeInterval)
+	}
+	if err := v.err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	minLimit, maxLimit := l.minLimit, l.maxLimit
+	if update.MinLimit != nil {
+		minLimit = *update.MinLimit
+	}
+	if update.MaxLimit != nil {
+		maxLimit = *update.MaxLimit
+	}
+	if minLimit > maxLimit {
+		return fmt.Errorf("minLimit must be <= maxLimit")
+	}
+	l.minLimit, l.maxLimit = minLimit, maxLimit
+	if update.Smoothing != nil {
+		l.smoothing = *update.Smoothing
+	}
+	if update.RTTTolerance != nil {
+		l.rttTolerance = *update.RTTTolerance
+	}
+	if update.QueueSizeFunc != nil {
+		l.queueSizeFunc = update.QueueSizeFunc
+	}
+	if update.ProbeInterval != nil && *update.ProbeInterval != l.probeInterval {
+		l.probeInterval = *update.ProbeInterval
+		l.resetRTTCounter = nextProbeCountdown(l.random, l.probeInterval)
+	}
+	clamped := math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), l.estimatedLimit))
+	if clamped != l.estimatedLimit {
+		l.estimatedLimit = clamped
+		l.notifyListeners(l.estimatedLimit)
+	}
+	return nil
+}
+
+// ExportState returns the estimated limit and RTT no load baseline.
+func (l *GradientLimit) ExportState() (core.LimitState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "GradientLimit",
+		Limit:   l.estimatedLimit,
+		Values: map[string]float64{
+			"rttNoLoad": l.rttNoLoadMeasurement.Get(),
+		},
+	}, nil
+}
+
+// ImportState will restore the estimated limit and RTT no load baseline of a previously exported state.
+func (l *GradientLimit) ImportState(state core.LimitState) error {
+	if err := state.Check("GradientLimit"); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.estimatedLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), state.Limit))
+	if rttNoLoad := state.Values["rttNoLoad"]; rttNoLoad > 0 {
+		l.rttNoLoadMeasurement.Reset()
+		l.rttNoLoadMeasurement.Add(rttNoLoad)
+	}
+	l.notifyListeners(l.estimatedLimit)
+	return nil
+}
+
+// Snapshot returns the current state of the limit.
+func (l *GradientLimit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "GradientLimit",
+		Limit: int(l.estimatedLimit),
+		Attributes: map[string]interface{}{
+			"rttNoLoad":       int64(l.rttNoLoadMeasurement.Get()),
+			"minLimit":        l.minLimit,
+			"maxLimit":        l.maxLimit,
+			"resetRTTCounter": l.resetRTTCounter,
+		},
+	}
+}
+
 func (l *GradientLimit) String() string {
 	return fmt.Sprintf("GradientLimit{limit=%d, rttNoLoad=%d ms}",
 		l.EstimatedLimit(), l.RTTNoLoad()/1e6)
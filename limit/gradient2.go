@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
 	"github.com/platinummonkey/go-concurrency-limits/measurements"
@@ -19,7 +20,7 @@ import (
 //
 // The core algorithm re-calculates the limit every sampling window (ex. 1 second) using the formula
 //     // Calculate the gradient limiting to the range [0.5, 1.0] to filter outliers
-//     gradient = max(0.5, min(1.0, longtermRtt / currentRtt));
+//     gradient = max(0.5, min(1.0, rttTolerance * longtermRtt / currentRtt));
 //
 //     // Calculate the new limit by applying the gradient and allowing for some queuing
 //     newLimit = gradient * currentLimit + queueSize;
@@ -58,6 +59,16 @@ type Gradient2Limit struct {
 	longRTTSampleListener   core.MetricSampleListener
 	shortRTTSampleListener  core.MetricSampleListener
 	queueSizeSampleListener core.MetricSampleListener
+	initialLimit            int
+	longWindow              int
+	tags                    []string
+	// Tolerance of the short term RTT exceeding the long term RTT before the limit is reduced
+	rttTolerance  float64
+	driftRecovery Gradient2DriftRecovery
+	// When set the RTTs observed since the last sample are averaged and used instead of the sample RTT
+	useAverageRTT atomic.Bool
+	observedRTT   *rttAverage
+	idle          idleState
 
 	mu        sync.RWMutex
 	listeners []core.LimitChangeListener
@@ -65,6 +76,39 @@ type Gradient2Limit struct {
 	registry  core.MetricRegistry
 }
 
+// Gradient2DriftRecovery decays the long term RTT when it is substantially larger than the short term RTT, which
+// happens when latency returns to normal after a prolonged period of excessive load.  Decaying the long term RTT
+// without waiting for the exponential smoothing helps bring the system back to steady state.
+type Gradient2DriftRecovery struct {
+	// Ratio of the long term RTT to the short term RTT above which the long term RTT is decayed, default 2.
+	Ratio float64 `json:"ratio"`
+	// Decay multiplies the long term RTT on every sample above the ratio, default 0.9.  A decay of 1 disables drift
+	// recovery.
+	Decay float64 `json:"decay"`
+}
+
+// DefaultGradient2DriftRecovery is the drift recovery of a Gradient2Limit unless configured otherwise.
+var DefaultGradient2DriftRecovery = Gradient2DriftRecovery{Ratio: 2, Decay: 0.9}
+
+func (r *Gradient2DriftRecovery) applyDefaults() {
+	if r.Ratio == 0 {
+		r.Ratio = DefaultGradient2DriftRecovery.Ratio
+	}
+	if r.Decay == 0 {
+		r.Decay = DefaultGradient2DriftRecovery.Decay
+	}
+}
+
+func (r Gradient2DriftRecovery) validate() error {
+	if r.Ratio <= 1 {
+		return fmt.Errorf("driftRecovery.ratio must be > 1, got %g", r.Ratio)
+	}
+	if r.Decay <= 0 || r.Decay > 1 {
+		return fmt.Errorf("driftRecovery.decay must be in (0, 1], got %g", r.Decay)
+	}
+	return nil
+}
+
 // NewDefaultGradient2Limit create a default Gradient2Limit
 func NewDefaultGradient2Limit(
 	name string,
@@ -154,6 +198,13 @@ func NewGradient2Limit(
 		listeners:               make([]core.LimitChangeListener, 0),
 		logger:                  logger,
 		registry:                registry,
+		initialLimit:            initialLimit,
+		longWindow:              longWindow,
+		idle:                    newIdleState(IdlePolicy{}),
+		tags:                    tags,
+		rttTolerance:            1.0,
+		driftRecovery:           DefaultGradient2DriftRecovery,
+		observedRTT:             newRTTAverage(),
 	}
 
 	l.commonSampler = core.NewCommonMetricSamplerOrNil(registry, l, name, tags...)
@@ -161,6 +212,128 @@ func NewGradient2Limit(
 	return l, nil
 }
 
+// Gradient2LimitConfig configures a Gradient2Limit created with NewGradient2LimitFromConfig.  The zero value of every
+// field selects its default, which matches NewDefaultGradient2Limit, any other invalid value is an error.
+type Gradient2LimitConfig struct {
+	// InitialLimit is the initial limit used by the limiter, default 20.
+	InitialLimit int `json:"initialLimit"`
+	// MinLimit is the minimum concurrency limit allowed, default 20.
+	MinLimit int `json:"minLimit"`
+	// MaxLimit is the maximum allowable concurrency, default 200.
+	MaxLimit int `json:"maxLimit"`
+	// Smoothing in (0, 1] limits how aggressively the limit shrinks when queuing has been detected, default 0.2.
+	Smoothing float64 `json:"smoothing"`
+	// LongWindow is the number of samples of the long term exponential average RTT, default 600.
+	LongWindow int `json:"longWindow"`
+	// RTTTolerance >= 1 is how much the short term RTT may exceed the long term RTT before the limit is reduced, i.e.
+	// 1.5 tolerates a 50% increase which keeps bursty but healthy backends from collapsing to minLimit, default 1.
+	RTTTolerance float64 `json:"rttTolerance"`
+	// DriftRecovery configures the decay of the long term RTT after prolonged load, zero fields select their
+	// defaults.
+	DriftRecovery Gradient2DriftRecovery `json:"driftRecovery"`
+	// UseAverageRTT uses the average RTT of the requests observed through ObserveRTT since the previous sample
+	// instead of the sample RTT, which is the minimum RTT of the window when used with DefaultLimiter.
+	UseAverageRTT bool `json:"useAverageRTT"`
+	// Idle adjusts the limit once traffic stops, disabled by default.
+	Idle IdlePolicy `json:"idle"`
+	// QueueSizeFunc returns how much the limit can grow while latencies remain low, default 4.
+	QueueSizeFunc func(limit int) int `json:"-"`
+
+	Logger         Logger              `json:"-"`
+	MetricRegistry core.MetricRegistry `json:"-"`
+	Tags           []string            `json:"tags,omitempty"`
+}
+
+// ApplyDefaults will set the default of every field with a zero value.
+func (c *Gradient2LimitConfig) ApplyDefaults() {
+	if c.InitialLimit == 0 {
+		c.InitialLimit = 20
+	}
+	if c.MinLimit == 0 {
+		c.MinLimit = 20
+	}
+	if c.MaxLimit == 0 {
+		c.MaxLimit = 200
+	}
+	if c.Smoothing == 0 {
+		c.Smoothing = 0.2
+	}
+	if c.LongWindow == 0 {
+		c.LongWindow = 600
+	}
+	if c.RTTTolerance == 0 {
+		c.RTTTolerance = 1.0
+	}
+	c.DriftRecovery.applyDefaults()
+	c.Idle.applyDefaults()
+	if c.QueueSizeFunc == nil {
+		c.QueueSizeFunc = func(limit int) int { return 4 }
+	}
+	// the remaining defaults are applied by NewGradient2Limit
+}
+
+// Validate will return an error describing every invalid field.
+func (c *Gradient2LimitConfig) Validate() error {
+	v := &configValidator{limitType: "Gradient2Limit"}
+	v.limits(c.InitialLimit, c.MinLimit, c.MaxLimit)
+	v.smoothing(c.Smoothing)
+	v.atLeastOne("longWindow", c.LongWindow)
+	v.rttTolerance(c.RTTTolerance)
+	v.check(c.DriftRecovery.validate())
+	v.check(c.Idle.validate())
+	return v.err()
+}
+
+// NewGradient2LimitFromConfig will create a new Gradient2Limit, returning an error if any field of the config is
+// invalid.  The resolved config is available from Gradient2Limit.Config.
+func NewGradient2LimitFromConfig(name string, config Gradient2LimitConfig) (*Gradient2Limit, error) {
+	config.ApplyDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	l, err := NewGradient2Limit(
+		name,
+		config.InitialLimit,
+		config.MaxLimit,
+		config.MinLimit,
+		config.QueueSizeFunc,
+		config.Smoothing,
+		config.LongWindow,
+		config.Logger,
+		config.MetricRegistry,
+		config.Tags...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	l.rttTolerance = config.RTTTolerance
+	l.driftRecovery = config.DriftRecovery
+	l.useAverageRTT.Store(config.UseAverageRTT)
+	l.idle = newIdleState(config.Idle)
+	return l, nil
+}
+
+// Config returns the configuration of the limit with every default resolved, including changes made by Update.
+func (l *Gradient2Limit) Config() Gradient2LimitConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return Gradient2LimitConfig{
+		InitialLimit:   l.initialLimit,
+		MinLimit:       l.minLimit,
+		MaxLimit:       l.maxLimit,
+		Smoothing:      l.smoothing,
+		LongWindow:     l.longWindow,
+		RTTTolerance:   l.rttTolerance,
+		DriftRecovery:  l.driftRecovery,
+		UseAverageRTT:  l.useAverageRTT.Load(),
+		Idle:           l.idle.policy,
+		QueueSizeFunc:  l.queueSizeFunc,
+		Logger:         l.logger,
+		MetricRegistry: l.registry,
+		Tags:           l.tags,
+	}
+}
+
 // EstimatedLimit returns the current estimated limit.
 func (l *Gradient2Limit) EstimatedLimit() int {
 	l.mu.RLock()
@@ -182,12 +355,28 @@ func (l *Gradient2Limit) notifyListeners(newLimit int) {
 	}
 }
 
+// ObserveRTT records the RTT of a single request for the average RTT used instead of the sample RTT when
+// useAverageRTT is enabled, it is a noop otherwise.
+func (l *Gradient2Limit) ObserveRTT(rtt int64) {
+	if rtt <= 0 || !l.useAverageRTT.Load() {
+		return
+	}
+	l.observedRTT.add(rtt)
+}
+
 // OnSample the concurrency limit using a new rtt sample.
 func (l *Gradient2Limit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	l.commonSampler.Sample(rtt, inFlight, didDrop)
+	l.checkIdle()
+
+	if l.useAverageRTT.Load() {
+		if averageRTT, ok := l.observedRTT.take(); ok {
+			rtt = averageRTT
+		}
+	}
 
 	queueSize := l.queueSizeFunc(int(l.estimatedLimit))
 
@@ -201,9 +390,10 @@ func (l *Gradient2Limit) OnSample(startTime int64, rtt int64, inFlight int, didD
 	// If the long RTT is substantially larger than the short RTT then reduce the long RTT measurement.
 	// This can happen when latency returns to normal after a prolonged prior of excessive load.  Reducing the
 	// long RTT without waiting for the exponential smoothing helps bring the system back to steady state.
-	if (longRTT / shortRTT) > 2 {
+	if (longRTT/shortRTT) > l.driftRecovery.Ratio && l.driftRecovery.Decay < 1 {
+		decay := l.driftRecovery.Decay
 		l.longRTT.Update(func(value float64) float64 {
-			return value * 0.9
+			return value * decay
 		})
 	}
 
@@ -211,12 +401,13 @@ func (l *Gradient2Limit) OnSample(startTime int64, rtt int64, inFlight int, didD
 	if float64(inFlight) < l.estimatedLimit/2 {
 		return
 	}
+	l.idle.active()
 
 	// Rtt could be higher than rtt_noload because of smoothing rtt noload updates
 	// so set to 1.0 to indicate no queuing.  Otherwise calculate the slope and don't
 	// allow it to be reduced by more than half to avoid aggressive load-shedding due to
-	// outliers.
-	gradient := math.Max(0.5, math.Min(1.0, longRTT/shortRTT))
+	// outliers.  The tolerance allows the short RTT to exceed the long RTT before the limit is reduced.
+	gradient := math.Max(0.5, math.Min(1.0, l.rttTolerance*longRTT/shortRTT))
 	newLimit := l.estimatedLimit*gradient + float64(queueSize)
 	newLimit = l.estimatedLimit*(1-l.smoothing) + newLimit*l.smoothing
 	newLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), newLimit))
@@ -230,6 +421,162 @@ func (l *Gradient2Limit) OnSample(startTime int64, rtt int64, inFlight int, didD
 	l.notifyListeners(int(l.estimatedLimit))
 }
 
+// CheckIdle will apply the idle policy if the limit has been idle for longer than its timeout.
+func (l *Gradient2Limit) CheckIdle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checkIdle()
+}
+
+// checkIdle decays the estimated limit and resets the RTT measurements as configured by the idle policy, it must be
+// called with mu held.
+func (l *Gradient2Limit) checkIdle() {
+	newLimit, rebaseline := l.idle.check(l.estimatedLimit, float64(l.initialLimit))
+	if rebaseline {
+		l.shortRTT.Reset()
+		l.longRTT.Reset()
+	}
+	newLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), newLimit))
+	if newLimit != l.estimatedLimit {
+		l.estimatedLimit = newLimit
+		l.notifyListeners(int(l.estimatedLimit))
+	}
+}
+
+// Gradient2LimitUpdate holds the parameters of a Gradient2Limit that can be changed at runtime with Update, nil
+// fields are left unchanged.
+type Gradient2LimitUpdate struct {
+	MinLimit      *int
+	MaxLimit      *int
+	Smoothing     *float64
+	RTTTolerance  *float64
+	DriftRecovery *Gradient2DriftRecovery
+	UseAverageRTT *bool
+	Idle          *IdlePolicy
+	QueueSizeFunc func(limit int) int
+}
+
+// Update will atomically apply new parameters without resetting the estimated limit or the RTT measurements.  If the
+// estimated limit is outside new limit bounds it is clamped and listeners are notified.  Nothing is applied if any
+// parameter is invalid.
+func (l *Gradient2Limit) Update(update Gradient2LimitUpdate) error {
+	v := &configValidator{limitType: "Gradient2Limit", update: true}
+	if update.MinLimit != nil {
+		v.atLeastOne("minLimit", *update.MinLimit)
+	}
+	if update.MaxLimit != nil {
+		v.atLeastOne("maxLimit", *update.MaxLimit)
+	}
+	if update.Smoothing != nil {
+		v.smoothing(*update.Smoothing)
+	}
+	if update.RTTTolerance != nil {
+		v.rttTolerance(*update.RTTTolerance)
+	}
+	if update.DriftRecovery != nil {
+		v.check(update.DriftRecovery.validate())
+	}
+	if update.Idle != nil {
+		v.check(update.Idle.validate())
+	}
+	if err := v.err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	minLimit, maxLimit := l.minLimit, l.maxLimit
+	if update.MinLimit != nil {
+		minLimit = *update.MinLimit
+	}
+	if update.MaxLimit != nil {
+		maxLimit = *update.MaxLimit
+	}
+	if minLimit > maxLimit {
+		return fmt.Errorf("minLimit must be <= maxLimit")
+	}
+	l.minLimit, l.maxLimit = minLimit, maxLimit
+	if update.Smoothing != nil {
+		l.smoothing = *update.Smoothing
+	}
+	if update.QueueSizeFunc != nil {
+		l.queueSizeFunc = update.QueueSizeFunc
+	}
+	if update.RTTTolerance != nil {
+		l.rttTolerance = *update.RTTTolerance
+	}
+	if update.DriftRecovery != nil {
+		l.driftRecovery = *update.DriftRecovery
+	}
+	if update.UseAverageRTT != nil {
+		l.useAverageRTT.Store(*update.UseAverageRTT)
+		l.observedRTT.take()
+	}
+	if update.Idle != nil {
+		l.idle = newIdleState(*update.Idle)
+	}
+	clamped := math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), l.estimatedLimit))
+	if clamped != l.estimatedLimit {
+		l.estimatedLimit = clamped
+		l.notifyListeners(int(l.estimatedLimit))
+	}
+	return nil
+}
+
+// ExportState returns the estimated limit and the short and long term RTT measurements.
+func (l *Gradient2Limit) ExportState() (core.LimitState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "Gradient2Limit",
+		Limit:   l.estimatedLimit,
+		Values: map[string]float64{
+			"shortRTT": l.shortRTT.Get(),
+			"longRTT":  l.longRTT.Get(),
+		},
+	}, nil
+}
+
+// ImportState will restore the estimated limit and RTT measurements of a previously exported state.  The long term
+// RTT is restored as its first sample, so it keeps adapting while the exponential average warms up.
+func (l *Gradient2Limit) ImportState(state core.LimitState) error {
+	if err := state.Check("Gradient2Limit"); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.estimatedLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), state.Limit))
+	if shortRTT := state.Values["shortRTT"]; shortRTT > 0 {
+		l.shortRTT.Reset()
+		l.shortRTT.Add(shortRTT)
+	}
+	if longRTT := state.Values["longRTT"]; longRTT > 0 {
+		l.longRTT.Reset()
+		l.longRTT.Add(longRTT)
+	}
+	l.notifyListeners(int(l.estimatedLimit))
+	return nil
+}
+
+// Snapshot returns the current state of the limit.
+func (l *Gradient2Limit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "Gradient2Limit",
+		Limit: int(l.estimatedLimit),
+		Attributes: map[string]interface{}{
+			"shortRTT":      int64(l.shortRTT.Get()),
+			"longRTT":       int64(l.longRTT.Get()),
+			"minLimit":      l.minLimit,
+			"maxLimit":      l.maxLimit,
+			"rttTolerance":  l.rttTolerance,
+			"useAverageRTT": l.useAverageRTT.Load(),
+		},
+	}
+}
+
 func (l *Gradient2Limit) String() string {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
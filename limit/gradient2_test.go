@@ -70,4 +70,159 @@ func TestGradient2Limit(t *testing.T) {
 		}
 		asrt.Equal(21, l.EstimatedLimit())
 	})
+
+	t.Run("Update", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewGradient2Limit("test", 50, 0, 0, nil, -1, -1, NoopLimitLogger{}, nil)
+		asrt.NoError(err)
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		l.OnSample(0, 10, 50, false)
+		longRTT := l.longRTT.Get()
+
+		smoothing := 2.0
+		asrt.Error(l.Update(Gradient2LimitUpdate{Smoothing: &smoothing}))
+		// updates are validated like the config
+		smoothing = 0
+		asrt.EqualError(l.Update(Gradient2LimitUpdate{Smoothing: &smoothing}),
+			"invalid Gradient2Limit update: smoothing must be in (0, 1], got 0")
+		minLimit := 10
+		maxLimit := 5
+		asrt.Error(l.Update(Gradient2LimitUpdate{MinLimit: &minLimit, MaxLimit: &maxLimit}))
+
+		maxLimit = 20
+		asrt.NoError(l.Update(Gradient2LimitUpdate{MinLimit: &minLimit, MaxLimit: &maxLimit}))
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal(20, listener.changes[len(listener.changes)-1])
+		asrt.Equal(longRTT, l.longRTT.Get())
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewGradient2Limit("test", 50, 0, 0, nil, -1, -1, NoopLimitLogger{}, nil)
+		asrt.NoError(err)
+		l.OnSample(0, 10, 50, false)
+		l.OnSample(1, 20, 50, false)
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal("Gradient2Limit", state.Type)
+		asrt.Equal(20.0, state.Values["shortRTT"])
+		asrt.Equal(15.0, state.Values["longRTT"])
+
+		restored, err := NewGradient2Limit("test", 0, 0, 0, nil, -1, -1, NoopLimitLogger{}, nil)
+		asrt.NoError(err)
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(l.EstimatedLimit(), restored.EstimatedLimit())
+		asrt.Equal(20.0, restored.shortRTT.Get())
+		asrt.Equal(15.0, restored.longRTT.Get())
+	})
+
+	t.Run("FromConfig", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewGradient2LimitFromConfig("test", Gradient2LimitConfig{})
+		asrt.NoError(err)
+		asrt.Equal(NewDefaultGradient2Limit("test", nil, nil).EstimatedLimit(), l.EstimatedLimit())
+		config := l.Config()
+		asrt.Equal(20, config.InitialLimit)
+		asrt.Equal(20, config.MinLimit)
+		asrt.Equal(200, config.MaxLimit)
+		asrt.Equal(0.2, config.Smoothing)
+		asrt.Equal(600, config.LongWindow)
+		asrt.Equal(4, config.QueueSizeFunc(100))
+
+		_, err = NewGradient2LimitFromConfig("test", Gradient2LimitConfig{InitialLimit: 10, Smoothing: 2, LongWindow: -1})
+		asrt.EqualError(err, "invalid Gradient2Limit config: initialLimit 10 must be within [minLimit 20, maxLimit 200]; "+
+			"smoothing must be in (0, 1], got 2; longWindow must be >= 1, got -1")
+	})
+
+	createGradient2Limit := func(config Gradient2LimitConfig) *Gradient2Limit {
+		config.InitialLimit, config.MinLimit, config.MaxLimit = 50, 1, 100
+		config.Smoothing = 1
+		config.QueueSizeFunc = func(limit int) int { return 0 }
+		l, _ := NewGradient2LimitFromConfig("test", config)
+		return l
+	}
+
+	t.Run("RTTTolerance", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createGradient2Limit(Gradient2LimitConfig{})
+		l.OnSample(0, 100, 50, false)
+		l.OnSample(0, 140, 50, false)
+		asrt.Equal(42, l.EstimatedLimit())
+
+		// a 40% increase is within a tolerance of 1.5
+		l = createGradient2Limit(Gradient2LimitConfig{RTTTolerance: 1.5})
+		l.OnSample(0, 100, 50, false)
+		l.OnSample(0, 140, 50, false)
+		asrt.Equal(50, l.EstimatedLimit())
+		asrt.Equal(1.5, l.Snapshot().Attributes["rttTolerance"])
+	})
+
+	t.Run("DriftRecovery", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createGradient2Limit(Gradient2LimitConfig{})
+		l.OnSample(0, 1000, 50, false)
+		l.OnSample(0, 100, 50, false)
+		asrt.InDelta(495, l.longRTT.Get(), 0.001)
+
+		l = createGradient2Limit(Gradient2LimitConfig{DriftRecovery: Gradient2DriftRecovery{Ratio: 10}})
+		l.OnSample(0, 1000, 50, false)
+		l.OnSample(0, 100, 50, false)
+		asrt.InDelta(550, l.longRTT.Get(), 0.001)
+
+		l = createGradient2Limit(Gradient2LimitConfig{DriftRecovery: Gradient2DriftRecovery{Decay: 0.5}})
+		l.OnSample(0, 1000, 50, false)
+		l.OnSample(0, 100, 50, false)
+		asrt.InDelta(275, l.longRTT.Get(), 0.001)
+
+		_, err := NewGradient2LimitFromConfig("test", Gradient2LimitConfig{
+			RTTTolerance:  0.5,
+			DriftRecovery: Gradient2DriftRecovery{Ratio: 0.5},
+		})
+		asrt.EqualError(err, "invalid Gradient2Limit config: rttTolerance must be >= 1, got 0.5; "+
+			"driftRecovery.ratio must be > 1, got 0.5")
+	})
+
+	t.Run("UseAverageRTT", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createGradient2Limit(Gradient2LimitConfig{UseAverageRTT: true})
+		l.ObserveRTT(100)
+		l.ObserveRTT(300)
+		l.OnSample(0, 50, 50, false)
+		asrt.Equal(200.0, l.shortRTT.Get())
+
+		// the sample RTT is used without observed requests
+		l.OnSample(0, 50, 50, false)
+		asrt.Equal(50.0, l.shortRTT.Get())
+
+		// observations are ignored unless enabled
+		useAverageRTT := false
+		asrt.NoError(l.Update(Gradient2LimitUpdate{UseAverageRTT: &useAverageRTT}))
+		l.ObserveRTT(100)
+		l.OnSample(0, 50, 50, false)
+		asrt.Equal(50.0, l.shortRTT.Get())
+		asrt.False(l.Config().UseAverageRTT)
+	})
+
+	t.Run("UpdateTolerance", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createGradient2Limit(Gradient2LimitConfig{})
+		rttTolerance := 0.5
+		asrt.Error(l.Update(Gradient2LimitUpdate{RTTTolerance: &rttTolerance}))
+		asrt.Error(l.Update(Gradient2LimitUpdate{DriftRecovery: &Gradient2DriftRecovery{Ratio: 2, Decay: 0}}))
+
+		rttTolerance = 2
+		driftRecovery := Gradient2DriftRecovery{Ratio: 3, Decay: 0.95}
+		asrt.NoError(l.Update(Gradient2LimitUpdate{RTTTolerance: &rttTolerance, DriftRecovery: &driftRecovery}))
+		config := l.Config()
+		asrt.Equal(2.0, config.RTTTolerance)
+		asrt.Equal(driftRecovery, config.DriftRecovery)
+	})
 }
@@ -13,8 +13,8 @@ func TestGradientLimit(t *testing.T) {
 	t.Run("nextProbeInterval", func(t2 *testing.T) {
 		t2.Parallel()
 		asrt := assert.New(t2)
-		asrt.Equal(ProbeDisabled, nextProbeCountdown(ProbeDisabled))
-		asrt.True(nextProbeCountdown(1) > 0)
+		asrt.Equal(ProbeDisabled, nextProbeCountdown(core.SystemRandomSourceInstance, ProbeDisabled))
+		asrt.True(nextProbeCountdown(core.SystemRandomSourceInstance, 1) > 0)
 	})
 
 	t.Run("Default", func(t2 *testing.T) {
@@ -80,4 +80,93 @@ func TestGradientLimit(t *testing.T) {
 		}
 		asrt.Equal(12, l.EstimatedLimit())
 	})
+
+	t.Run("SetRandom", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		newLimit := func() *GradientLimit {
+			l := NewGradientLimitWithRegistry("test", 0, 0, 0, -1, nil, -1, 100, NoopLimitLogger{}, nil)
+			l.SetRandom(core.NewSeededRandomSource(7))
+			return l
+		}
+		l1 := newLimit()
+		l2 := newLimit()
+		asrt.Equal(l1.resetRTTCounter, l2.resetRTTCounter)
+		asrt.True(l1.resetRTTCounter >= 100 && l1.resetRTTCounter < 200)
+	})
+
+	t.Run("Update", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewGradientLimitWithRegistry("test", 50, 0, 0, -1, nil, -1, 0, NoopLimitLogger{}, nil)
+		l.OnSample(0, 10, 50, false)
+		rttNoLoad := l.RTTNoLoad()
+
+		minLimit := 60
+		maxLimit := 40
+		asrt.Error(l.Update(GradientLimitUpdate{MinLimit: &minLimit, MaxLimit: &maxLimit}))
+		probeInterval := 0
+		asrt.Error(l.Update(GradientLimitUpdate{ProbeInterval: &probeInterval}))
+		// updates are validated like the config
+		smoothing := 0.0
+		asrt.EqualError(l.Update(GradientLimitUpdate{Smoothing: &smoothing}),
+			"invalid GradientLimit update: smoothing must be in (0, 1], got 0")
+
+		asrt.NoError(l.Update(GradientLimitUpdate{MaxLimit: &maxLimit}))
+		asrt.Equal(40, l.EstimatedLimit())
+		asrt.Equal(rttNoLoad, l.RTTNoLoad())
+
+		minLimit = 45
+		maxLimit = 100
+		asrt.NoError(l.Update(GradientLimitUpdate{MinLimit: &minLimit, MaxLimit: &maxLimit}))
+		asrt.Equal(45, l.EstimatedLimit())
+
+		probeInterval = ProbeDisabled
+		asrt.NoError(l.Update(GradientLimitUpdate{ProbeInterval: &probeInterval}))
+		asrt.Equal(ProbeDisabled, l.resetRTTCounter)
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewGradientLimitWithRegistry("test", 50, 0, 0, -1, nil, -1, 0, NoopLimitLogger{}, nil)
+		l.OnSample(0, 10, 50, false)
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal("GradientLimit", state.Type)
+		asrt.Equal(10.0, state.Values["rttNoLoad"])
+
+		restored := NewGradientLimitWithRegistry("test", 0, 0, 0, -1, nil, -1, 0, NoopLimitLogger{}, nil)
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(l.EstimatedLimit(), restored.EstimatedLimit())
+		asrt.Equal(int64(10), restored.RTTNoLoad())
+
+		state.Limit = 0
+		asrt.Error(restored.ImportState(state))
+	})
+
+	t.Run("FromConfig", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewGradientLimitFromConfig("test", GradientLimitConfig{MinLimit: 10, ProbeInterval: ProbeDisabled})
+		asrt.NoError(err)
+		asrt.Equal(50, l.EstimatedLimit())
+		config := l.Config()
+		asrt.Equal(10, config.MinLimit)
+		asrt.Equal(1000, config.MaxLimit)
+		asrt.Equal(0.2, config.Smoothing)
+		asrt.Equal(2.0, config.RTTTolerance)
+		asrt.Equal(ProbeDisabled, config.ProbeInterval)
+
+		rttTolerance := 3.0
+		asrt.NoError(l.Update(GradientLimitUpdate{RTTTolerance: &rttTolerance}))
+		asrt.Equal(3.0, l.Config().RTTTolerance)
+
+		_, err = NewGradientLimitFromConfig("test", GradientLimitConfig{MinLimit: 100, MaxLimit: 10, RTTTolerance: 0.5})
+		asrt.EqualError(err, "invalid GradientLimit config: minLimit 100 must be <= maxLimit 10; "+
+			"rttTolerance must be >= 1, got 0.5")
+		_, err = NewGradientLimitFromConfig("test", GradientLimitConfig{Smoothing: -0.1, ProbeInterval: -5})
+		asrt.EqualError(err, "invalid GradientLimit config: smoothing must be in (0, 1], got -0.1; "+
+			"probeInterval must be >= 1 or ProbeDisabled, got -5")
+	})
 }
@@ -0,0 +1,106 @@
+package limit
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// IdlePolicy configures how a limit adjusts itself once traffic stops.  A limit only changes on samples near the
+// limit, so without one the limit learned during the last burst is kept indefinitely and the next burst, i.e. a
+// nightly batch, is admitted against a stale limit.  The policy applies once no sample had a drop or an in-flight count
+// of at least half the limit for Timeout.  It is checked on every sample and through core.IdleLimit, which
+// DefaultLimiter calls from the request path so the limit is adjusted before the first requests of a burst are
+// admitted.
+type IdlePolicy struct {
+	// Timeout is how long a limit may go without an active sample before it is idle, 0 disables the policy.
+	Timeout time.Duration `json:"timeout"`
+	// DecayHalfLife is how long it takes the distance between the limit and the initial limit to halve while idle, 0
+	// disables the decay.
+	DecayHalfLife time.Duration `json:"decayHalfLife"`
+	// Rebaseline resets the RTT measurements once idle, so the baseline is measured again on the next activity rather
+	// than compared against the latency of the last burst.
+	Rebaseline bool `json:"rebaseline"`
+
+	// Clock is used to measure the idle time, defaults to the system clock.
+	Clock core.Clock `json:"-"`
+}
+
+func (p *IdlePolicy) applyDefaults() {
+	if p.Clock == nil {
+		p.Clock = core.SystemClockInstance
+	}
+}
+
+func (p IdlePolicy) validate() error {
+	if p.Timeout < 0 {
+		return fmt.Errorf("idle.timeout must be >= 0, got %s", p.Timeout)
+	}
+	if p.DecayHalfLife < 0 {
+		return fmt.Errorf("idle.decayHalfLife must be >= 0, got %s", p.DecayHalfLife)
+	}
+	return nil
+}
+
+// idleState tracks the activity of a limit for its IdlePolicy, it is guarded by the lock of the limit.
+type idleState struct {
+	policy      IdlePolicy
+	lastActive  time.Time
+	decayStart  time.Time // zero until the limit is decayed in the current idle period
+	decayFrom   float64
+	decayed     float64
+	rebaselined bool
+}
+
+func newIdleState(policy IdlePolicy) idleState {
+	policy.applyDefaults()
+	return idleState{
+		policy:     policy,
+		lastActive: policy.Clock.Now(),
+	}
+}
+
+// active records a sample that adjusted the limit, ending the idle period.
+func (s *idleState) active() {
+	if s.policy.Timeout <= 0 {
+		return
+	}
+	s.lastActive = s.policy.Clock.Now()
+	s.decayStart = time.Time{}
+	s.rebaselined = false
+}
+
+// check returns limit decayed toward initialLimit for the time the limit has been idle, and whether the RTT
+// measurements should be reset, which is reported once per idle period.
+func (s *idleState) check(limit float64, initialLimit float64) (float64, bool) {
+	if s.policy.Timeout <= 0 {
+		return limit, false
+	}
+	now := s.policy.Clock.Now()
+	idleSince := s.lastActive.Add(s.policy.Timeout)
+	if !now.After(idleSince) {
+		return limit, false
+	}
+
+	if s.policy.DecayHalfLife > 0 {
+		// the decay is computed from the start of the idle period so that limits truncated to whole permits still
+		// converge, it restarts if the limit was changed by anything else in the meantime
+		if s.decayStart.IsZero() {
+			s.decayStart, s.decayFrom = idleSince, limit
+		} else if int(limit) != int(s.decayed) {
+			s.decayStart, s.decayFrom = now, limit
+		}
+		halfLives := now.Sub(s.decayStart).Seconds() / s.policy.DecayHalfLife.Seconds()
+		limit = initialLimit + (s.decayFrom-initialLimit)*math.Pow(0.5, halfLives)
+		if math.Abs(limit-initialLimit) < 1 {
+			// the limits are truncated, so the last permit would otherwise never be recovered
+			limit = initialLimit
+		}
+		s.decayed = limit
+	}
+	rebaseline := s.policy.Rebaseline && !s.rebaselined
+	s.rebaselined = true
+	return limit, rebaseline
+}
@@ -0,0 +1,183 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+func TestIdlePolicy(t *testing.T) {
+	t.Parallel()
+
+	createIdleAIMDLimit := func(fakeClock *clock.FakeClock) *AIMDLimit {
+		l, _ := NewAIMDLimitFromConfig("test", AIMDLimitConfig{
+			Idle: IdlePolicy{Timeout: time.Minute, DecayHalfLife: time.Minute, Clock: fakeClock},
+		})
+		return l
+	}
+	rtt := (10 * time.Millisecond).Nanoseconds()
+
+	t.Run("Disabled", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewAIMDLimit("test", 10, 0.9, 1, nil)
+		for i := 0; i < 20; i++ {
+			l.OnSample(0, rtt, l.EstimatedLimit(), false)
+		}
+		l.CheckIdle()
+		asrt.Equal(30, l.EstimatedLimit())
+	})
+
+	t.Run("Decay", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		l := createIdleAIMDLimit(fakeClock)
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		for i := 0; i < 20; i++ {
+			l.OnSample(0, rtt, l.EstimatedLimit(), false)
+		}
+		asrt.Equal(30, l.EstimatedLimit())
+
+		// not idle before the timeout
+		fakeClock.Advance(time.Minute)
+		l.CheckIdle()
+		asrt.Equal(30, l.EstimatedLimit())
+
+		// the distance to the initial limit halves every half life
+		fakeClock.Advance(time.Minute)
+		l.CheckIdle()
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal(20, listener.changes[len(listener.changes)-1])
+
+		// samples well below the limit do not end the idle period
+		fakeClock.Advance(time.Minute)
+		l.OnSample(0, rtt, 1, false)
+		asrt.Equal(15, l.EstimatedLimit())
+
+		// frequent checks converge to the initial limit
+		for i := 0; i < 600; i++ {
+			fakeClock.Advance(time.Second)
+			l.CheckIdle()
+		}
+		asrt.Equal(10, l.EstimatedLimit())
+	})
+
+	t.Run("DecayFromBelow", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		l := createIdleAIMDLimit(fakeClock)
+		for i := 0; i < 7; i++ {
+			l.OnSample(0, rtt, l.EstimatedLimit(), true)
+		}
+		asrt.Equal(3, l.EstimatedLimit())
+
+		for i := 0; i < 600; i++ {
+			fakeClock.Advance(time.Second)
+			l.CheckIdle()
+		}
+		asrt.Equal(10, l.EstimatedLimit())
+	})
+
+	t.Run("ActiveSample", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		l := createIdleAIMDLimit(fakeClock)
+		for i := 0; i < 20; i++ {
+			l.OnSample(0, rtt, l.EstimatedLimit(), false)
+		}
+		fakeClock.Advance(3 * time.Minute)
+		l.CheckIdle()
+		asrt.Equal(15, l.EstimatedLimit())
+
+		// a sample near the limit restarts the idle timeout
+		l.OnSample(0, rtt, l.EstimatedLimit(), false)
+		asrt.Equal(16, l.EstimatedLimit())
+		fakeClock.Advance(time.Minute)
+		l.CheckIdle()
+		asrt.Equal(16, l.EstimatedLimit())
+	})
+
+	t.Run("VegasRebaseline", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		l, err := NewVegasLimitFromConfig("test", VegasLimitConfig{
+			Idle: IdlePolicy{Timeout: time.Minute, Rebaseline: true, Clock: fakeClock},
+		})
+		asrt.NoError(err)
+		l.OnSample(0, rtt, 20, false)
+		asrt.Equal(rtt, l.RTTNoLoad())
+
+		fakeClock.Advance(2 * time.Minute)
+		l.CheckIdle()
+		asrt.Equal(int64(0), l.RTTNoLoad())
+		asrt.Equal(20, l.EstimatedLimit())
+
+		// the next activity measures a new baseline
+		l.OnSample(0, 5*rtt, 20, false)
+		asrt.Equal(5*rtt, l.RTTNoLoad())
+		asrt.Equal(time.Minute, l.Config().Idle.Timeout)
+	})
+
+	t.Run("Gradient2", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		l, err := NewGradient2LimitFromConfig("test", Gradient2LimitConfig{
+			InitialLimit: 20,
+			MinLimit:     10,
+			MaxLimit:     100,
+			Idle: IdlePolicy{
+				Timeout:       time.Minute,
+				DecayHalfLife: time.Minute,
+				Rebaseline:    true,
+				Clock:         fakeClock,
+			},
+		})
+		asrt.NoError(err)
+		asrt.NoError(l.ImportState(core.LimitState{
+			Version: core.LimitStateVersion,
+			Type:    "Gradient2Limit",
+			Limit:   60,
+			Values:  map[string]float64{"shortRTT": float64(rtt), "longRTT": float64(rtt)},
+		}))
+
+		fakeClock.Advance(2 * time.Minute)
+		l.CheckIdle()
+		asrt.Equal(40, l.EstimatedLimit())
+		asrt.Equal(0.0, l.shortRTT.Get())
+		asrt.Equal(0.0, l.longRTT.Get())
+	})
+
+	t.Run("Validation", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		_, err := NewAIMDLimitFromConfig("test", AIMDLimitConfig{Idle: IdlePolicy{Timeout: -time.Second}})
+		asrt.EqualError(err, "invalid AIMDLimit config: idle.timeout must be >= 0, got -1s")
+		_, err = NewVegasLimitFromConfig("test", VegasLimitConfig{Idle: IdlePolicy{DecayHalfLife: -time.Second}})
+		asrt.EqualError(err, "invalid VegasLimit config: idle.decayHalfLife must be >= 0, got -1s")
+
+		l := NewAIMDLimit("test", 10, 0.9, 1, nil)
+		asrt.Error(l.Update(AIMDLimitUpdate{Idle: &IdlePolicy{Timeout: -time.Second}}))
+
+		// an updated policy applies from the time of the update
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		asrt.NoError(l.Update(AIMDLimitUpdate{
+			Idle: &IdlePolicy{Timeout: time.Minute, DecayHalfLife: time.Minute, Clock: fakeClock},
+		}))
+		for i := 0; i < 10; i++ {
+			l.OnSample(0, rtt, l.EstimatedLimit(), false)
+		}
+		fakeClock.Advance(2 * time.Minute)
+		l.CheckIdle()
+		asrt.Equal(15, l.EstimatedLimit())
+	})
+}
@@ -0,0 +1,358 @@
+package limit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/measurements"
+)
+
+// LittlesLawLimit implements a throughput based concurrency limit using Little's law: the concurrency needed to
+// sustain a throughput is the throughput multiplied by the latency.  The limit is the throughput measured over a
+// sliding window multiplied by the RTT with no load, with headroom for the throughput to grow:
+//
+//	limit = headroom * throughput * rttNoLoad
+//
+// While the limit is what holds the throughput back, the throughput is limit / rtt and the limit grows as long as the
+// RTT stays below headroom * rttNoLoad, so headroom is the queueing delay tolerated as a multiple of the RTT with no
+// load.  Samples with fewer requests in flight than the limit measure the demand rather than the capacity, so they
+// never lower the limit.
+//
+// The throughput is taken from OnThroughput, which DefaultLimiter calls with the requests released in every sample
+// window.  When OnThroughput is never called every sample with a start time counts as one completed request.
+type LittlesLawLimit struct {
+	estimatedLimit float64
+	minLimit       int
+	maxLimit       int
+	headroom       float64
+	smoothing      float64
+	rttNoLoad      core.MeasurementInterface
+
+	completed        []int
+	durations        []int64
+	throughputIndex  int
+	throughputCount  int
+	observed         bool
+	lastCompletionAt int64
+
+	commonSampler            *core.CommonMetricSampler
+	minRTTSampleListener     core.MetricSampleListener
+	throughputSampleListener core.MetricSampleListener
+
+	mu        sync.RWMutex
+	listeners []core.LimitChangeListener
+	logger    Logger
+	registry  core.MetricRegistry
+}
+
+// NewDefaultLittlesLawLimit will create a default LittlesLawLimit.
+func NewDefaultLittlesLawLimit(
+	name string,
+	logger Logger,
+	registry core.MetricRegistry,
+	tags ...string,
+) *LittlesLawLimit {
+	l, _ := NewLittlesLawLimit(name, 20, 1, 1000, 10, 1.5, 0.2, nil, logger, registry, tags...)
+	return l
+}
+
+// NewLittlesLawLimit will create a new LittlesLawLimit.
+// @param initialLimit: Initial limit used by the limiter, default 20.
+// @param minLimit: Minimum concurrency limit allowed, default 1.
+// @param maxLimit: Maximum allowable concurrency, default 1000.
+// @param window: number of throughput samples the throughput is measured over, default 10.
+// @param headroom: multiple of the measured concurrency allowed, must be >= 1, default 1.5.
+// @param smoothing: factor (0 < x <= 1) of the new estimate applied to the limit, default 0.2.
+// @param rttNoLoad: measurement of the RTT with no load, default measurements.MinimumMeasurement.
+// @param registry: metric registry to publish metrics
+func NewLittlesLawLimit(
+	name string,
+	initialLimit int,
+	minLimit int,
+	maxLimit int,
+	window int,
+	headroom float64,
+	smoothing float64,
+	rttNoLoad core.MeasurementInterface,
+	logger Logger,
+	registry core.MetricRegistry,
+	tags ...string,
+) (*LittlesLawLimit, error) {
+	if initialLimit <= 0 {
+		initialLimit = 20
+	}
+	if minLimit <= 0 {
+		minLimit = 1
+	}
+	if maxLimit <= 0 {
+		maxLimit = 1000
+	}
+	if window <= 0 {
+		window = 10
+	}
+	if headroom == 0 {
+		headroom = 1.5
+	}
+	if smoothing == 0 {
+		smoothing = 0.2
+	}
+	if rttNoLoad == nil {
+		rttNoLoad = &measurements.MinimumMeasurement{}
+	}
+	if logger == nil {
+		logger = NoopLimitLogger{}
+	}
+	if registry == nil {
+		registry = core.EmptyMetricRegistryInstance
+	}
+	if minLimit > maxLimit {
+		return nil, fmt.Errorf("minLimit must be <= maxLimit")
+	}
+	if headroom < 1 {
+		return nil, fmt.Errorf("headroom must be >= 1")
+	}
+	if smoothing < 0 || smoothing > 1 {
+		return nil, fmt.Errorf("smoothing must be in (0, 1]")
+	}
+
+	l := &LittlesLawLimit{
+		estimatedLimit:           math.Max(float64(minLimit), math.Min(float64(maxLimit), float64(initialLimit))),
+		minLimit:                 minLimit,
+		maxLimit:                 maxLimit,
+		headroom:                 headroom,
+		smoothing:                smoothing,
+		rttNoLoad:                rttNoLoad,
+		completed:                make([]int, window),
+		durations:                make([]int64, window),
+		minRTTSampleListener:     registry.RegisterDistribution(core.PrefixMetricWithName(core.MetricMinRTT, name), tags...),
+		throughputSampleListener: registry.RegisterDistribution(core.PrefixMetricWithName(core.MetricThroughput, name), tags...),
+		listeners:                make([]core.LimitChangeListener, 0),
+		logger:                   logger,
+		registry:                 registry,
+	}
+	l.commonSampler = core.NewCommonMetricSamplerOrNil(registry, l, name, tags...)
+	return l, nil
+}
+
+// EstimatedLimit returns the current estimated limit.
+func (l *LittlesLawLimit) EstimatedLimit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return int(l.estimatedLimit)
+}
+
+// RTTNoLoad returns the RTT with no load in nanoseconds, or 0 before the first sample.
+func (l *LittlesLawLimit) RTTNoLoad() int64 {
+	return int64(l.rttNoLoad.Get())
+}
+
+// Throughput returns the throughput over the window in completed requests per second, or 0 before it is measured.
+func (l *LittlesLawLimit) Throughput() float64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.throughput() * 1e9
+}
+
+// NotifyOnChange will register a callback to receive notification whenever the limit is updated to a new value.
+func (l *LittlesLawLimit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.mu.Lock()
+	l.listeners = append(l.listeners, consumer)
+	l.mu.Unlock()
+}
+
+// notifyListeners will call the callbacks on limit changes
+func (l *LittlesLawLimit) notifyListeners(newLimit int) {
+	for _, listener := range l.listeners {
+		listener(newLimit)
+	}
+}
+
+// OnThroughput records that completed requests were released over duration.
+func (l *LittlesLawLimit) OnThroughput(completed int, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.observed = true
+	l.addThroughput(completed, duration.Nanoseconds())
+}
+
+func (l *LittlesLawLimit) addThroughput(completed int, duration int64) {
+	if duration <= 0 {
+		return
+	}
+	l.completed[l.throughputIndex] = completed
+	l.durations[l.throughputIndex] = duration
+	l.throughputIndex = (l.throughputIndex + 1) % len(l.completed)
+	if l.throughputCount < len(l.completed) {
+		l.throughputCount++
+	}
+	l.throughputSampleListener.AddSample(float64(completed) / time.Duration(duration).Seconds())
+}
+
+// throughput returns the completed requests per nanosecond over the window.
+func (l *LittlesLawLimit) throughput() float64 {
+	completed, duration := 0, int64(0)
+	for i := 0; i < l.throughputCount; i++ {
+		completed += l.completed[i]
+		duration += l.durations[i]
+	}
+	if duration == 0 {
+		return 0
+	}
+	return float64(completed) / float64(duration)
+}
+
+// OnSample the concurrency limit using a new rtt sample.
+func (l *LittlesLawLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.commonSampler.Sample(rtt, inFlight, didDrop)
+	if rtt <= 0 {
+		return
+	}
+
+	if !l.observed && startTime > 0 {
+		completedAt := startTime + rtt
+		if l.lastCompletionAt > 0 {
+			l.addThroughput(1, completedAt-l.lastCompletionAt)
+		}
+		l.lastCompletionAt = completedAt
+	}
+
+	rttNoLoad, _ := l.rttNoLoad.Add(float64(rtt))
+	l.minRTTSampleListener.AddSample(rttNoLoad)
+
+	throughput := l.throughput()
+	if throughput == 0 {
+		return
+	}
+	target := l.headroom * throughput * rttNoLoad
+	if target < l.estimatedLimit && float64(inFlight) < l.estimatedLimit {
+		// the demand was below the limit so the throughput says nothing about the capacity
+		return
+	}
+	newLimit := (1-l.smoothing)*l.estimatedLimit + l.smoothing*target
+	newLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), newLimit))
+	changed := int(newLimit) != int(l.estimatedLimit)
+	l.estimatedLimit = newLimit
+	if !changed {
+		return
+	}
+	if l.logger.IsDebugEnabled() {
+		l.logger.Debugf("new limit=%0.2f, throughput=%0.2f/s, rttNoLoad=%0.2f ms, target=%0.2f",
+			newLimit, throughput*1e9, rttNoLoad/1e6, target)
+	}
+	l.notifyListeners(int(l.estimatedLimit))
+}
+
+// LittlesLawLimitUpdate holds the parameters of a LittlesLawLimit that can be changed at runtime with Update, nil
+// fields are left unchanged.
+type LittlesLawLimitUpdate struct {
+	MinLimit  *int
+	MaxLimit  *int
+	Headroom  *float64
+	Smoothing *float64
+}
+
+// Update will atomically apply new parameters without resetting the estimated limit, the throughput or the RTT no load
+// baseline.  If the estimated limit is outside new limit bounds it is clamped and listeners are notified.  Nothing is
+// applied if any parameter is invalid.
+func (l *LittlesLawLimit) Update(update LittlesLawLimitUpdate) error {
+	if update.MinLimit != nil && *update.MinLimit < 1 {
+		return fmt.Errorf("minLimit must be >= 1")
+	}
+	if update.MaxLimit != nil && *update.MaxLimit < 1 {
+		return fmt.Errorf("maxLimit must be >= 1")
+	}
+	if update.Headroom != nil && *update.Headroom < 1 {
+		return fmt.Errorf("headroom must be >= 1")
+	}
+	if update.Smoothing != nil && (*update.Smoothing <= 0 || *update.Smoothing > 1) {
+		return fmt.Errorf("smoothing must be in (0, 1]")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	minLimit, maxLimit := l.minLimit, l.maxLimit
+	if update.MinLimit != nil {
+		minLimit = *update.MinLimit
+	}
+	if update.MaxLimit != nil {
+		maxLimit = *update.MaxLimit
+	}
+	if minLimit > maxLimit {
+		return fmt.Errorf("minLimit must be <= maxLimit")
+	}
+	l.minLimit, l.maxLimit = minLimit, maxLimit
+	if update.Headroom != nil {
+		l.headroom = *update.Headroom
+	}
+	if update.Smoothing != nil {
+		l.smoothing = *update.Smoothing
+	}
+	clamped := math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), l.estimatedLimit))
+	if clamped != l.estimatedLimit {
+		l.estimatedLimit = clamped
+		l.notifyListeners(int(l.estimatedLimit))
+	}
+	return nil
+}
+
+// ExportState returns the estimated limit and RTT no load baseline.
+func (l *LittlesLawLimit) ExportState() (core.LimitState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "LittlesLawLimit",
+		Limit:   l.estimatedLimit,
+		Values: map[string]float64{
+			"rttNoLoad": l.rttNoLoad.Get(),
+		},
+	}, nil
+}
+
+// ImportState will restore the estimated limit and RTT no load baseline of a previously exported state.  The
+// throughput is measured again.
+func (l *LittlesLawLimit) ImportState(state core.LimitState) error {
+	if err := state.Check("LittlesLawLimit"); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.estimatedLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), state.Limit))
+	if rttNoLoad := state.Values["rttNoLoad"]; rttNoLoad > 0 {
+		l.rttNoLoad.Reset()
+		l.rttNoLoad.Add(rttNoLoad)
+	}
+	l.notifyListeners(int(l.estimatedLimit))
+	return nil
+}
+
+// Snapshot returns the current state of the limit.
+func (l *LittlesLawLimit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "LittlesLawLimit",
+		Limit: int(l.estimatedLimit),
+		Attributes: map[string]interface{}{
+			"throughput": l.throughput() * 1e9,
+			"rttNoLoad":  l.rttNoLoad.Get(),
+			"headroom":   l.headroom,
+			"smoothing":  l.smoothing,
+			"minLimit":   l.minLimit,
+			"maxLimit":   l.maxLimit,
+		},
+	}
+}
+
+func (l *LittlesLawLimit) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return fmt.Sprintf("LittlesLawLimit{limit=%d, throughput=%0.2f/s, rttNoLoad=%d ms}",
+		int(l.estimatedLimit), l.throughput()*1e9, int64(l.rttNoLoad.Get())/1e6)
+}
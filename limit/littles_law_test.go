@@ -0,0 +1,153 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+func createLittlesLawLimit(window int, smoothing float64) *LittlesLawLimit {
+	l, _ := NewLittlesLawLimit("test", 20, 1, 100, window, 1.5, smoothing, nil, NoopLimitLogger{},
+		core.EmptyMetricRegistryInstance)
+	return l
+}
+
+func TestLittlesLawLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewDefaultLittlesLawLimit("test", nil, nil)
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal(0.0, l.Throughput())
+		asrt.Equal("LittlesLawLimit{limit=20, throughput=0.00/s, rttNoLoad=0 ms}", l.String())
+
+		_, err := NewLittlesLawLimit("test", 20, 10, 5, 0, 0, 0, nil, nil, nil)
+		asrt.Error(err)
+		_, err = NewLittlesLawLimit("test", 20, 1, 100, 0, 0.5, 0, nil, nil, nil)
+		asrt.Error(err)
+		_, err = NewLittlesLawLimit("test", 20, 1, 100, 0, 0, 2, nil, nil, nil)
+		asrt.Error(err)
+	})
+
+	t.Run("ThroughputTimesRTTNoLoad", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createLittlesLawLimit(10, 1)
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+
+		// no throughput has been measured yet
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(20, l.EstimatedLimit())
+
+		l.OnThroughput(1000, time.Second)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(15, l.EstimatedLimit())
+		l.OnThroughput(7000, time.Second)
+		l.OnSample(0, (20 * time.Millisecond).Nanoseconds(), 15, false)
+		asrt.Equal(60, l.EstimatedLimit())
+		asrt.Equal([]int{15, 60}, listener.changes)
+	})
+
+	t.Run("Smoothing", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createLittlesLawLimit(10, 0.5)
+		l.OnThroughput(4000, time.Second)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(40, l.EstimatedLimit())
+	})
+
+	t.Run("AppLimitedSamplesDoNotLowerTheLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createLittlesLawLimit(10, 1)
+		l.OnThroughput(100, time.Second)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 2, false)
+		asrt.Equal(20, l.EstimatedLimit())
+	})
+
+	t.Run("SlidingWindow", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createLittlesLawLimit(2, 1)
+		l.OnThroughput(1000, time.Second)
+		l.OnThroughput(3000, time.Second)
+		l.OnThroughput(5000, time.Second)
+		asrt.InDelta(4000, l.Throughput(), 0.001)
+	})
+
+	t.Run("CountsSamplesWithoutOnThroughput", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createLittlesLawLimit(10, 1)
+		startTime := time.Unix(1, 0).UnixNano()
+		for i := 0; i < 11; i++ {
+			l.OnSample(startTime+int64(i)*time.Millisecond.Nanoseconds(), (10 * time.Millisecond).Nanoseconds(), 20,
+				false)
+		}
+		asrt.InDelta(1000, l.Throughput(), 0.001)
+		asrt.Equal(15, l.EstimatedLimit())
+	})
+
+	t.Run("Update", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createLittlesLawLimit(10, 1)
+
+		// invalid updates apply nothing
+		maxLimit := 10
+		headroom := 0.5
+		asrt.Error(l.Update(LittlesLawLimitUpdate{MaxLimit: &maxLimit, Headroom: &headroom}))
+		asrt.Equal(20, l.EstimatedLimit())
+
+		headroom = 2
+		asrt.NoError(l.Update(LittlesLawLimitUpdate{MaxLimit: &maxLimit, Headroom: &headroom}))
+		asrt.Equal(10, l.EstimatedLimit())
+		asrt.Equal(2.0, l.headroom)
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createLittlesLawLimit(10, 1)
+		l.OnThroughput(1000, time.Second)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal(core.LimitState{
+			Version: core.LimitStateVersion,
+			Type:    "LittlesLawLimit",
+			Limit:   15,
+			Values:  map[string]float64{"rttNoLoad": float64((10 * time.Millisecond).Nanoseconds())},
+		}, state)
+
+		restored := createLittlesLawLimit(10, 1)
+		listener := testNotifyListener{}
+		restored.NotifyOnChange(listener.updater())
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(15, restored.EstimatedLimit())
+		asrt.Equal([]int{15}, listener.changes)
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), restored.RTTNoLoad())
+
+		state.Type = "VegasLimit"
+		asrt.Error(restored.ImportState(state))
+	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createLittlesLawLimit(10, 1)
+		l.OnThroughput(1000, time.Second)
+		snapshot := l.Snapshot()
+		asrt.Equal("LittlesLawLimit", snapshot.Type)
+		asrt.Equal(20, snapshot.Limit)
+		asrt.InDelta(1000, snapshot.Attributes["throughput"], 0.001)
+		asrt.Equal(1.5, snapshot.Attributes["headroom"])
+	})
+}
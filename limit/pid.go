@@ -0,0 +1,367 @@
+package limit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// PIDLimit implements a concurrency limit driven by a PID controller that holds the RTT at a setpoint.  The setpoint
+// is either a fixed target RTT or a target queueing delay above the RTT with no load, the minimum RTT observed.
+//
+// The error of every sample is relative to the setpoint so that the gains do not depend on the latency of the
+// service:
+//
+//	error = (setpoint - rtt) / setpoint
+//	limit = integral + kp * error - kd * (rtt - previousRTT) / setpoint
+//	integral = integral + ki * error
+//
+// All gains are in requests per unit of relative error, i.e. kp = 10 adds one request to the limit for every 10% the
+// RTT is below the setpoint.  A dropped sample counts as an RTT of twice the setpoint.
+//
+// The integral starts at the initial limit so there is no bump on the first sample, and it is held within the limit
+// bounds to prevent windup: while the limit is pinned at a bound the integral cannot keep growing past it, so the
+// limit reacts as soon as the error changes sign.  The derivative is taken on the RTT rather than the error so a
+// changed setpoint does not kick the limit.
+type PIDLimit struct {
+	estimatedLimit float64
+	minLimit       int
+	maxLimit       int
+
+	targetRTT        int64
+	targetQueueDelay int64
+	rttNoLoad        int64
+
+	kp       float64
+	ki       float64
+	kd       float64
+	integral float64
+	lastRTT  int64
+
+	commonSampler        *core.CommonMetricSampler
+	minRTTSampleListener core.MetricSampleListener
+
+	mu        sync.RWMutex
+	listeners []core.LimitChangeListener
+	logger    Logger
+	registry  core.MetricRegistry
+}
+
+// NewDefaultPIDLimit will create a default PIDLimit holding the RTT at targetRTT.
+func NewDefaultPIDLimit(
+	name string,
+	targetRTT time.Duration,
+	logger Logger,
+	registry core.MetricRegistry,
+	tags ...string,
+) (*PIDLimit, error) {
+	return NewPIDLimit(name, 20, 1, 1000, targetRTT, 0, 10, 2, 0, logger, registry, tags...)
+}
+
+// NewPIDLimit will create a new PIDLimit.  Exactly one of targetRTT and targetQueueDelay must be set.
+// @param initialLimit: Initial limit used by the limiter, default 20.
+// @param minLimit: Minimum concurrency limit allowed, default 1.
+// @param maxLimit: Maximum allowable concurrency, default 1000.
+// @param targetRTT: fixed RTT setpoint.
+// @param targetQueueDelay: setpoint as the queueing delay above the minimum RTT observed.
+// @param kp: proportional gain in requests per unit of relative error.
+// @param ki: integral gain in requests per unit of relative error per sample.
+// @param kd: derivative gain in requests per unit of relative RTT change per sample.
+// @param registry: metric registry to publish metrics
+func NewPIDLimit(
+	name string,
+	initialLimit int,
+	minLimit int,
+	maxLimit int,
+	targetRTT time.Duration,
+	targetQueueDelay time.Duration,
+	kp float64,
+	ki float64,
+	kd float64,
+	logger Logger,
+	registry core.MetricRegistry,
+	tags ...string,
+) (*PIDLimit, error) {
+	if initialLimit <= 0 {
+		initialLimit = 20
+	}
+	if minLimit <= 0 {
+		minLimit = 1
+	}
+	if maxLimit <= 0 {
+		maxLimit = 1000
+	}
+	if logger == nil {
+		logger = NoopLimitLogger{}
+	}
+	if registry == nil {
+		registry = core.EmptyMetricRegistryInstance
+	}
+	if minLimit > maxLimit {
+		return nil, fmt.Errorf("minLimit must be <= maxLimit")
+	}
+	if err := checkPIDSetpoint(targetRTT, targetQueueDelay); err != nil {
+		return nil, err
+	}
+	if kp < 0 || ki < 0 || kd < 0 {
+		return nil, fmt.Errorf("gains must be >= 0")
+	}
+
+	limit := math.Max(float64(minLimit), math.Min(float64(maxLimit), float64(initialLimit)))
+	l := &PIDLimit{
+		estimatedLimit:       limit,
+		minLimit:             minLimit,
+		maxLimit:             maxLimit,
+		targetRTT:            targetRTT.Nanoseconds(),
+		targetQueueDelay:     targetQueueDelay.Nanoseconds(),
+		kp:                   kp,
+		ki:                   ki,
+		kd:                   kd,
+		integral:             limit,
+		minRTTSampleListener: registry.RegisterDistribution(core.PrefixMetricWithName(core.MetricMinRTT, name), tags...),
+		listeners:            make([]core.LimitChangeListener, 0),
+		logger:               logger,
+		registry:             registry,
+	}
+	l.commonSampler = core.NewCommonMetricSamplerOrNil(registry, l, name, tags...)
+	return l, nil
+}
+
+func checkPIDSetpoint(targetRTT time.Duration, targetQueueDelay time.Duration) error {
+	if targetRTT < 0 || targetQueueDelay < 0 {
+		return fmt.Errorf("targetRTT and targetQueueDelay must be >= 0")
+	}
+	if (targetRTT > 0) == (targetQueueDelay > 0) {
+		return fmt.Errorf("exactly one of targetRTT and targetQueueDelay must be set")
+	}
+	return nil
+}
+
+// EstimatedLimit returns the current estimated limit.
+func (l *PIDLimit) EstimatedLimit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return int(l.estimatedLimit)
+}
+
+// RTTNoLoad returns the minimum RTT observed in nanoseconds, or 0 before the first sample.
+func (l *PIDLimit) RTTNoLoad() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.rttNoLoad
+}
+
+// Setpoint returns the RTT the controller is holding in nanoseconds, or 0 before the first sample when the setpoint
+// is a target queueing delay.
+func (l *PIDLimit) Setpoint() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.setpoint()
+}
+
+func (l *PIDLimit) setpoint() int64 {
+	if l.targetRTT > 0 {
+		return l.targetRTT
+	}
+	if l.rttNoLoad == 0 {
+		return 0
+	}
+	return l.rttNoLoad + l.targetQueueDelay
+}
+
+// NotifyOnChange will register a callback to receive notification whenever the limit is updated to a new value.
+func (l *PIDLimit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.mu.Lock()
+	l.listeners = append(l.listeners, consumer)
+	l.mu.Unlock()
+}
+
+// notifyListeners will call the callbacks on limit changes
+func (l *PIDLimit) notifyListeners(newLimit int) {
+	for _, listener := range l.listeners {
+		listener(newLimit)
+	}
+}
+
+// OnSample the concurrency limit using a new rtt sample.
+func (l *PIDLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.commonSampler.Sample(rtt, inFlight, didDrop)
+	if rtt <= 0 {
+		return
+	}
+
+	if l.rttNoLoad == 0 || rtt < l.rttNoLoad {
+		l.rttNoLoad = rtt
+	}
+	l.minRTTSampleListener.AddSample(float64(l.rttNoLoad))
+
+	setpoint := float64(l.setpoint())
+	measured := float64(rtt)
+	if didDrop {
+		measured = math.Max(measured, 2*setpoint)
+	}
+	lastRTT := measured
+	if l.lastRTT > 0 {
+		lastRTT = float64(l.lastRTT)
+	}
+	l.lastRTT = int64(measured)
+
+	err := (setpoint - measured) / setpoint
+	derivative := (measured - lastRTT) / setpoint
+	// the integral is held within the limit bounds so it does not wind up while the limit is saturated
+	l.integral = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), l.integral+l.ki*err))
+	output := l.integral + l.kp*err - l.kd*derivative
+
+	newLimit := math.Round(math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), output)))
+	if newLimit == l.estimatedLimit {
+		return
+	}
+	if l.logger.IsDebugEnabled() {
+		l.logger.Debugf("new limit=%0.2f, setpoint=%0.2f ms, rtt=%0.2f ms, error=%0.4f, integral=%0.2f",
+			newLimit, setpoint/1e6, measured/1e6, err, l.integral)
+	}
+	l.estimatedLimit = newLimit
+	l.notifyListeners(int(l.estimatedLimit))
+}
+
+// PIDLimitUpdate holds the parameters of a PIDLimit that can be changed at runtime with Update, nil fields are left
+// unchanged.  Setting one of TargetRTT or TargetQueueDelay to a positive value and the other to 0 switches the kind
+// of setpoint.
+type PIDLimitUpdate struct {
+	MinLimit         *int
+	MaxLimit         *int
+	TargetRTT        *time.Duration
+	TargetQueueDelay *time.Duration
+	Kp               *float64
+	Ki               *float64
+	Kd               *float64
+}
+
+// Update will atomically apply new parameters without resetting the estimated limit or the integral, so changing the
+// gains or setpoint does not bump the limit.  If the estimated limit is outside new limit bounds it is clamped and
+// listeners are notified.  Nothing is applied if any parameter is invalid.
+func (l *PIDLimit) Update(update PIDLimitUpdate) error {
+	if update.MinLimit != nil && *update.MinLimit < 1 {
+		return fmt.Errorf("minLimit must be >= 1")
+	}
+	if update.MaxLimit != nil && *update.MaxLimit < 1 {
+		return fmt.Errorf("maxLimit must be >= 1")
+	}
+	for _, gain := range []*float64{update.Kp, update.Ki, update.Kd} {
+		if gain != nil && *gain < 0 {
+			return fmt.Errorf("gains must be >= 0")
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	minLimit, maxLimit := l.minLimit, l.maxLimit
+	if update.MinLimit != nil {
+		minLimit = *update.MinLimit
+	}
+	if update.MaxLimit != nil {
+		maxLimit = *update.MaxLimit
+	}
+	if minLimit > maxLimit {
+		return fmt.Errorf("minLimit must be <= maxLimit")
+	}
+	targetRTT, targetQueueDelay := time.Duration(l.targetRTT), time.Duration(l.targetQueueDelay)
+	if update.TargetRTT != nil {
+		targetRTT = *update.TargetRTT
+	}
+	if update.TargetQueueDelay != nil {
+		targetQueueDelay = *update.TargetQueueDelay
+	}
+	if err := checkPIDSetpoint(targetRTT, targetQueueDelay); err != nil {
+		return err
+	}
+
+	l.minLimit, l.maxLimit = minLimit, maxLimit
+	l.targetRTT, l.targetQueueDelay = targetRTT.Nanoseconds(), targetQueueDelay.Nanoseconds()
+	if update.Kp != nil {
+		l.kp = *update.Kp
+	}
+	if update.Ki != nil {
+		l.ki = *update.Ki
+	}
+	if update.Kd != nil {
+		l.kd = *update.Kd
+	}
+	l.integral = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), l.integral))
+	clamped := math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), l.estimatedLimit))
+	if clamped != l.estimatedLimit {
+		l.estimatedLimit = clamped
+		l.notifyListeners(int(l.estimatedLimit))
+	}
+	return nil
+}
+
+// ExportState returns the estimated limit, the integral and the RTT no load baseline.
+func (l *PIDLimit) ExportState() (core.LimitState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "PIDLimit",
+		Limit:   l.estimatedLimit,
+		Values: map[string]float64{
+			"integral":  l.integral,
+			"rttNoLoad": float64(l.rttNoLoad),
+		},
+	}, nil
+}
+
+// ImportState will restore the estimated limit, integral and RTT no load baseline of a previously exported state.
+// Without an integral the controller continues from the restored limit.
+func (l *PIDLimit) ImportState(state core.LimitState) error {
+	if err := state.Check("PIDLimit"); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.estimatedLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), state.Limit))
+	l.integral = l.estimatedLimit
+	if integral, ok := state.Values["integral"]; ok {
+		l.integral = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), integral))
+	}
+	if rttNoLoad := state.Values["rttNoLoad"]; rttNoLoad > 0 {
+		l.rttNoLoad = int64(rttNoLoad)
+	}
+	l.lastRTT = 0
+	l.notifyListeners(int(l.estimatedLimit))
+	return nil
+}
+
+// Snapshot returns the current state of the limit.
+func (l *PIDLimit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "PIDLimit",
+		Limit: int(l.estimatedLimit),
+		Attributes: map[string]interface{}{
+			"setpoint":         l.setpoint(),
+			"targetRTT":        l.targetRTT,
+			"targetQueueDelay": l.targetQueueDelay,
+			"rttNoLoad":        l.rttNoLoad,
+			"kp":               l.kp,
+			"ki":               l.ki,
+			"kd":               l.kd,
+			"integral":         l.integral,
+			"minLimit":         l.minLimit,
+			"maxLimit":         l.maxLimit,
+		},
+	}
+}
+
+func (l *PIDLimit) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return fmt.Sprintf("PIDLimit{limit=%d, setpoint=%d ms}", int(l.estimatedLimit), l.setpoint()/1e6)
+}
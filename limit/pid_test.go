@@ -0,0 +1,185 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+func createPIDLimit(maxLimit int, kp float64, ki float64, kd float64) *PIDLimit {
+	l, _ := NewPIDLimit("test", 20, 1, maxLimit, 10*time.Millisecond, 0, kp, ki, kd, NoopLimitLogger{},
+		core.EmptyMetricRegistryInstance)
+	return l
+}
+
+func TestPIDLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewDefaultPIDLimit("test", 10*time.Millisecond, nil, nil)
+		asrt.NoError(err)
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), l.Setpoint())
+		asrt.Equal("PIDLimit{limit=20, setpoint=10 ms}", l.String())
+
+		_, err = NewPIDLimit("test", 20, 1, 100, 0, 0, 1, 1, 0, nil, nil)
+		asrt.Error(err)
+		_, err = NewPIDLimit("test", 20, 1, 100, time.Millisecond, time.Millisecond, 1, 1, 0, nil, nil)
+		asrt.Error(err)
+		_, err = NewPIDLimit("test", 20, 1, 100, time.Millisecond, 0, -1, 1, 0, nil, nil)
+		asrt.Error(err)
+		_, err = NewPIDLimit("test", 20, 10, 5, time.Millisecond, 0, 1, 1, 0, nil, nil)
+		asrt.Error(err)
+	})
+
+	t.Run("Proportional", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createPIDLimit(1000, 10, 0, 0)
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		l.OnSample(0, (5 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(25, l.EstimatedLimit())
+		l.OnSample(0, (20 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(10, l.EstimatedLimit())
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal([]int{25, 10, 20}, listener.changes)
+	})
+
+	t.Run("Integral", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createPIDLimit(1000, 0, 2, 0)
+		l.OnSample(0, (5 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(21, l.EstimatedLimit())
+		l.OnSample(0, (5 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(22, l.EstimatedLimit())
+
+		// at the setpoint the limit holds
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(22, l.EstimatedLimit())
+	})
+
+	t.Run("AntiWindup", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createPIDLimit(25, 0, 2, 0)
+		for i := 0; i < 100; i++ {
+			l.OnSample(0, (5 * time.Millisecond).Nanoseconds(), 20, false)
+		}
+		asrt.Equal(25, l.EstimatedLimit())
+
+		// the limit reacts on the first sample above the setpoint
+		l.OnSample(0, (15 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(24, l.EstimatedLimit())
+	})
+
+	t.Run("Derivative", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createPIDLimit(1000, 0, 0, 10)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(20, l.EstimatedLimit())
+		l.OnSample(0, (15 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(15, l.EstimatedLimit())
+		l.OnSample(0, (15 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(20, l.EstimatedLimit())
+	})
+
+	t.Run("DropCountsAsTwiceTheSetpoint", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createPIDLimit(1000, 10, 0, 0)
+		l.OnSample(0, (5 * time.Millisecond).Nanoseconds(), 20, true)
+		asrt.Equal(10, l.EstimatedLimit())
+	})
+
+	t.Run("TargetQueueDelay", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewPIDLimit("test", 20, 1, 1000, 0, 5*time.Millisecond, 0, 3, 0, nil, nil)
+		asrt.NoError(err)
+		asrt.Equal(int64(0), l.Setpoint())
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), l.RTTNoLoad())
+		asrt.Equal((15 * time.Millisecond).Nanoseconds(), l.Setpoint())
+		asrt.Equal(21, l.EstimatedLimit())
+		l.OnSample(0, (30 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(18, l.EstimatedLimit())
+	})
+
+	t.Run("Update", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createPIDLimit(1000, 0, 2, 0)
+		l.OnSample(0, (5 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(21, l.EstimatedLimit())
+
+		// invalid updates apply nothing
+		kp := 10.0
+		targetQueueDelay := 5 * time.Millisecond
+		asrt.Error(l.Update(PIDLimitUpdate{Kp: &kp, TargetQueueDelay: &targetQueueDelay}))
+		asrt.Equal(0.0, l.kp)
+
+		// switching the setpoint keeps the limit
+		targetRTT := time.Duration(0)
+		asrt.NoError(l.Update(PIDLimitUpdate{Kp: &kp, TargetRTT: &targetRTT, TargetQueueDelay: &targetQueueDelay}))
+		asrt.Equal(21, l.EstimatedLimit())
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), l.Setpoint())
+
+		// lowering the max clamps the limit
+		maxLimit := 15
+		asrt.NoError(l.Update(PIDLimitUpdate{MaxLimit: &maxLimit}))
+		asrt.Equal(15, l.EstimatedLimit())
+		asrt.Equal(15.0, l.integral)
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createPIDLimit(1000, 0, 2, 0)
+		l.OnSample(0, (5 * time.Millisecond).Nanoseconds(), 20, false)
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal(core.LimitState{
+			Version: core.LimitStateVersion,
+			Type:    "PIDLimit",
+			Limit:   21,
+			Values: map[string]float64{
+				"integral":  21,
+				"rttNoLoad": float64((5 * time.Millisecond).Nanoseconds()),
+			},
+		}, state)
+
+		restored := createPIDLimit(1000, 0, 2, 0)
+		listener := testNotifyListener{}
+		restored.NotifyOnChange(listener.updater())
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(21, restored.EstimatedLimit())
+		asrt.Equal([]int{21}, listener.changes)
+		restored.OnSample(0, (5 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(22, restored.EstimatedLimit())
+
+		state.Type = "VegasLimit"
+		asrt.Error(restored.ImportState(state))
+	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createPIDLimit(1000, 10, 2, 1)
+		snapshot := l.Snapshot()
+		asrt.Equal("PIDLimit", snapshot.Type)
+		asrt.Equal(20, snapshot.Limit)
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), snapshot.Attributes["setpoint"])
+		asrt.Equal(10.0, snapshot.Attributes["kp"])
+		asrt.Equal(2.0, snapshot.Attributes["ki"])
+		asrt.Equal(1.0, snapshot.Attributes["kd"])
+	})
+}
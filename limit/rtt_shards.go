@@ -0,0 +1,104 @@
+package limit
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// rttBufferShardSize is the number of RTTs a shard of an rttBuffer holds before it is merged by the request that
+// filled it, bounding the memory of limits whose samples stop.
+const rttBufferShardSize = 1024
+
+// rttBuffer buffers the RTTs of single requests until they are merged into a measurement when the sample window rolls
+// over.  Every RTT is added to a random shard, so that concurrent requests rarely wait on the same lock or the lock of
+// the measurement.
+type rttBuffer struct {
+	shards []rttBufferShard
+}
+
+type rttBufferShard struct {
+	mu   sync.Mutex
+	rtts []float64
+	_    [32]byte // pads the shard to a cache line
+}
+
+func newRTTBuffer() *rttBuffer {
+	return &rttBuffer{shards: make([]rttBufferShard, runtime.GOMAXPROCS(0))}
+}
+
+// add will buffer rtt, merging the shard into measurement once it is full.
+func (b *rttBuffer) add(rtt int64, measurement core.MeasurementInterface) {
+	shard := &b.shards[rand.IntN(len(b.shards))]
+	var full []float64
+	shard.mu.Lock()
+	shard.rtts = append(shard.rtts, float64(rtt))
+	if len(shard.rtts) >= rttBufferShardSize {
+		full, shard.rtts = shard.rtts, nil
+	}
+	shard.mu.Unlock()
+	addAll(measurement, full)
+}
+
+// merge will add every buffered RTT to measurement.
+func (b *rttBuffer) merge(measurement core.MeasurementInterface) {
+	for i := range b.shards {
+		shard := &b.shards[i]
+		shard.mu.Lock()
+		rtts := shard.rtts
+		shard.rtts = nil
+		shard.mu.Unlock()
+		addAll(measurement, rtts)
+	}
+}
+
+func addAll(measurement core.MeasurementInterface, rtts []float64) {
+	for _, rtt := range rtts {
+		measurement.Add(rtt)
+	}
+}
+
+// rttAverage sums the RTTs of single requests until the average is taken when the sample window rolls over.  Like an
+// rttBuffer every RTT is added to a random shard, so that concurrent requests rarely wait on the same lock.
+type rttAverage struct {
+	shards []rttAverageShard
+}
+
+type rttAverageShard struct {
+	mu    sync.Mutex
+	sum   int64
+	count int64
+	_     [40]byte // pads the shard to a cache line
+}
+
+func newRTTAverage() *rttAverage {
+	return &rttAverage{shards: make([]rttAverageShard, runtime.GOMAXPROCS(0))}
+}
+
+// add will include rtt in the average.
+func (a *rttAverage) add(rtt int64) {
+	shard := &a.shards[rand.IntN(len(a.shards))]
+	shard.mu.Lock()
+	shard.sum += rtt
+	shard.count++
+	shard.mu.Unlock()
+}
+
+// take returns the average of the RTTs added since the previous call and resets it, or false if none were added.
+func (a *rttAverage) take() (int64, bool) {
+	sum, count := int64(0), int64(0)
+	for i := range a.shards {
+		shard := &a.shards[i]
+		shard.mu.Lock()
+		sum += shard.sum
+		count += shard.count
+		shard.sum, shard.count = 0, 0
+		shard.mu.Unlock()
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return sum / count, true
+}
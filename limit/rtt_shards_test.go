@@ -0,0 +1,106 @@
+package limit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/measurements"
+)
+
+// countingMeasurement counts the values added to it.
+type countingMeasurement struct {
+	measurements.SingleMeasurement
+	count atomic.Int64
+}
+
+func (m *countingMeasurement) Add(value float64) (float64, bool) {
+	m.count.Add(1)
+	return m.SingleMeasurement.Add(value)
+}
+
+func TestRTTBuffer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Merge", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		b := newRTTBuffer()
+		m := &countingMeasurement{}
+		b.add(10, m)
+		b.add(20, m)
+		asrt.Equal(int64(0), m.count.Load())
+		b.merge(m)
+		asrt.Equal(int64(2), m.count.Load())
+		b.merge(m)
+		asrt.Equal(int64(2), m.count.Load())
+	})
+
+	t.Run("Concurrent", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		b := newRTTBuffer()
+		m := &countingMeasurement{}
+		// more RTTs than fit in the shards, which are merged while the sample windows roll over
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 2*rttBufferShardSize; j++ {
+					b.add(int64(j+1), m)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					b.merge(m)
+				}
+			}()
+		}
+		wg.Wait()
+		b.merge(m)
+		asrt.Equal(int64(8*2*rttBufferShardSize), m.count.Load())
+	})
+}
+
+func TestRTTAverage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Take", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		a := newRTTAverage()
+		_, ok := a.take()
+		asrt.False(ok)
+		a.add(100)
+		a.add(300)
+		average, ok := a.take()
+		asrt.True(ok)
+		asrt.Equal(int64(200), average)
+		_, ok = a.take()
+		asrt.False(ok)
+	})
+
+	t.Run("Concurrent", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		a := newRTTAverage()
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					a.add(100)
+				}
+			}()
+		}
+		wg.Wait()
+		average, ok := a.take()
+		asrt.True(ok)
+		asrt.Equal(int64(100), average)
+	})
+}
@@ -68,6 +68,14 @@ func (l *SettableLimit) SetLimit(limit int) {
 	l.notifyListeners(limit)
 }
 
+// Snapshot returns the current state of the limit.
+func (l *SettableLimit) Snapshot() core.Snapshot {
+	return core.Snapshot{
+		Type:  "SettableLimit",
+		Limit: l.EstimatedLimit(),
+	}
+}
+
 func (l *SettableLimit) String() string {
 	return fmt.Sprintf("SettableLimit{limit=%d}", atomic.LoadInt32(&l.limit))
 }
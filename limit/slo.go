@@ -0,0 +1,314 @@
+package limit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/measurements"
+)
+
+// sloMinBackoff is the largest decrease of the limit on a single sample, so one outlier cannot collapse the limit.
+const sloMinBackoff = 0.5
+
+// SLOLimit implements a concurrency limit that keeps a percentile of the RTT, i.e. p99, below a latency target.  While
+// the percentile is under the target the limit grows additively, and when it is over the limit shrinks in proportion
+// to how far it is over:
+//
+//	limit = limit * max(0.5, target / percentile)
+//
+// A dropped sample halves the limit.  The limit does not grow while fewer than half of it is in flight, since the
+// latency then says nothing about the latency at the limit.
+//
+// The percentile is tracked over every request through ObserveRTT, which DefaultLimiter calls for every successful
+// request.  The observed RTTs are buffered without contending on the percentile and merged into it on the next
+// sample.  When ObserveRTT is never called the RTT of every sample is used instead.
+type SLOLimit struct {
+	estimatedLimit float64
+	minLimit       int
+	maxLimit       int
+	increaseBy     int
+	percentile     float64
+	target         int64
+
+	rttPercentile core.MeasurementInterface
+	observedRTTs  *rttBuffer
+	observed      atomic.Bool
+
+	commonSampler               *core.CommonMetricSampler
+	percentileRTTSampleListener core.MetricSampleListener
+
+	mu        sync.RWMutex
+	listeners []core.LimitChangeListener
+	logger    Logger
+	registry  core.MetricRegistry
+}
+
+// NewDefaultSLOLimit will create a default SLOLimit keeping the percentile, i.e. 0.99, of the RTT below target.
+func NewDefaultSLOLimit(
+	name string,
+	percentile float64,
+	target time.Duration,
+	logger Logger,
+	registry core.MetricRegistry,
+	tags ...string,
+) (*SLOLimit, error) {
+	return NewSLOLimit(name, 20, 1, 1000, percentile, target, 1, nil, logger, registry, tags...)
+}
+
+// NewSLOLimit will create a new SLOLimit.
+// @param initialLimit: Initial limit used by the limiter, default 20.
+// @param minLimit: Minimum concurrency limit allowed, default 1.
+// @param maxLimit: Maximum allowable concurrency, default 1000.
+// @param percentile: percentile of the RTT to keep below the target, in (0, 1).
+// @param target: latency target of the percentile.
+// @param increaseBy: amount the limit grows by on a sample under the target, default 1.
+// @param rttPercentile: measurement of the percentile, default a measurements.WindowlessMovingPercentile.
+// @param registry: metric registry to publish metrics
+func NewSLOLimit(
+	name string,
+	initialLimit int,
+	minLimit int,
+	maxLimit int,
+	percentile float64,
+	target time.Duration,
+	increaseBy int,
+	rttPercentile core.MeasurementInterface,
+	logger Logger,
+	registry core.MetricRegistry,
+	tags ...string,
+) (*SLOLimit, error) {
+	if initialLimit <= 0 {
+		initialLimit = 20
+	}
+	if minLimit <= 0 {
+		minLimit = 1
+	}
+	if maxLimit <= 0 {
+		maxLimit = 1000
+	}
+	if increaseBy <= 0 {
+		increaseBy = 1
+	}
+	if logger == nil {
+		logger = NoopLimitLogger{}
+	}
+	if registry == nil {
+		registry = core.EmptyMetricRegistryInstance
+	}
+	if minLimit > maxLimit {
+		return nil, fmt.Errorf("minLimit must be <= maxLimit")
+	}
+	if target <= 0 {
+		return nil, fmt.Errorf("target must be > 0")
+	}
+	if percentile <= 0 || percentile >= 1 {
+		return nil, fmt.Errorf("percentile must be in (0, 1)")
+	}
+	if rttPercentile == nil {
+		rttPercentile, _ = measurements.NewWindowlessMovingPercentile(percentile, 0.01, 0.05, 0.05)
+	}
+
+	l := &SLOLimit{
+		estimatedLimit: math.Max(float64(minLimit), math.Min(float64(maxLimit), float64(initialLimit))),
+		minLimit:       minLimit,
+		maxLimit:       maxLimit,
+		increaseBy:     increaseBy,
+		percentile:     percentile,
+		target:         target.Nanoseconds(),
+		rttPercentile:  rttPercentile,
+		observedRTTs:   newRTTBuffer(),
+		percentileRTTSampleListener: registry.RegisterDistribution(
+			core.PrefixMetricWithName(core.MetricPercentileRTT, name), tags...),
+		listeners: make([]core.LimitChangeListener, 0),
+		logger:    logger,
+		registry:  registry,
+	}
+	l.commonSampler = core.NewCommonMetricSamplerOrNil(registry, l, name, tags...)
+	return l, nil
+}
+
+// EstimatedLimit returns the current estimated limit.
+func (l *SLOLimit) EstimatedLimit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return int(l.estimatedLimit)
+}
+
+// PercentileRTT returns the current estimate of the RTT percentile in nanoseconds, or 0 before the first sample.
+func (l *SLOLimit) PercentileRTT() int64 {
+	return int64(l.rttPercentile.Get())
+}
+
+// NotifyOnChange will register a callback to receive notification whenever the limit is updated to a new value.
+func (l *SLOLimit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.mu.Lock()
+	l.listeners = append(l.listeners, consumer)
+	l.mu.Unlock()
+}
+
+// notifyListeners will call the callbacks on limit changes
+func (l *SLOLimit) notifyListeners(newLimit int) {
+	for _, listener := range l.listeners {
+		listener(newLimit)
+	}
+}
+
+// ObserveRTT records the RTT of a single request for the percentile, it is merged into the percentile on the next
+// sample.
+func (l *SLOLimit) ObserveRTT(rtt int64) {
+	if rtt <= 0 {
+		return
+	}
+	if !l.observed.Load() {
+		l.observed.Store(true)
+	}
+	l.observedRTTs.add(rtt, l.rttPercentile)
+}
+
+// OnSample the concurrency limit using a new rtt sample.
+func (l *SLOLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.commonSampler.Sample(rtt, inFlight, didDrop)
+	if l.observed.Load() {
+		l.observedRTTs.merge(l.rttPercentile)
+	} else if rtt > 0 {
+		l.rttPercentile.Add(float64(rtt))
+	}
+	percentileRTT := l.rttPercentile.Get()
+	l.percentileRTTSampleListener.AddSample(percentileRTT)
+
+	newLimit := l.estimatedLimit
+	switch {
+	case didDrop:
+		newLimit = l.estimatedLimit * sloMinBackoff
+	case percentileRTT > float64(l.target):
+		newLimit = l.estimatedLimit * math.Max(sloMinBackoff, float64(l.target)/percentileRTT)
+	case percentileRTT > 0 && float64(inFlight) >= l.estimatedLimit/2:
+		newLimit = l.estimatedLimit + float64(l.increaseBy)
+	}
+	newLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), newLimit))
+	changed := int(newLimit) != int(l.estimatedLimit)
+	l.estimatedLimit = newLimit
+	if !changed {
+		return
+	}
+	if l.logger.IsDebugEnabled() {
+		l.logger.Debugf("new limit=%0.2f, p%g rtt=%0.2f ms, target=%0.2f ms",
+			newLimit, l.percentile*100, percentileRTT/1e6, float64(l.target)/1e6)
+	}
+	l.notifyListeners(int(l.estimatedLimit))
+}
+
+// SLOLimitUpdate holds the parameters of an SLOLimit that can be changed at runtime with Update, nil fields are left
+// unchanged.
+type SLOLimitUpdate struct {
+	MinLimit   *int
+	MaxLimit   *int
+	Target     *time.Duration
+	IncreaseBy *int
+}
+
+// Update will atomically apply new parameters without resetting the estimated limit or the percentile.  If the
+// estimated limit is outside new limit bounds it is clamped and listeners are notified.  Nothing is applied if any
+// parameter is invalid.
+func (l *SLOLimit) Update(update SLOLimitUpdate) error {
+	if update.MinLimit != nil && *update.MinLimit < 1 {
+		return fmt.Errorf("minLimit must be >= 1")
+	}
+	if update.MaxLimit != nil && *update.MaxLimit < 1 {
+		return fmt.Errorf("maxLimit must be >= 1")
+	}
+	if update.Target != nil && *update.Target <= 0 {
+		return fmt.Errorf("target must be > 0")
+	}
+	if update.IncreaseBy != nil && *update.IncreaseBy < 1 {
+		return fmt.Errorf("increaseBy must be >= 1")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	minLimit, maxLimit := l.minLimit, l.maxLimit
+	if update.MinLimit != nil {
+		minLimit = *update.MinLimit
+	}
+	if update.MaxLimit != nil {
+		maxLimit = *update.MaxLimit
+	}
+	if minLimit > maxLimit {
+		return fmt.Errorf("minLimit must be <= maxLimit")
+	}
+	l.minLimit, l.maxLimit = minLimit, maxLimit
+	if update.Target != nil {
+		l.target = update.Target.Nanoseconds()
+	}
+	if update.IncreaseBy != nil {
+		l.increaseBy = *update.IncreaseBy
+	}
+	clamped := math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), l.estimatedLimit))
+	if clamped != l.estimatedLimit {
+		l.estimatedLimit = clamped
+		l.notifyListeners(int(l.estimatedLimit))
+	}
+	return nil
+}
+
+// ExportState returns the estimated limit and the RTT percentile.
+func (l *SLOLimit) ExportState() (core.LimitState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "SLOLimit",
+		Limit:   l.estimatedLimit,
+		Values: map[string]float64{
+			"percentileRTT": l.rttPercentile.Get(),
+		},
+	}, nil
+}
+
+// ImportState will restore the estimated limit and RTT percentile of a previously exported state.
+func (l *SLOLimit) ImportState(state core.LimitState) error {
+	if err := state.Check("SLOLimit"); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.estimatedLimit = math.Max(float64(l.minLimit), math.Min(float64(l.maxLimit), state.Limit))
+	if percentileRTT := state.Values["percentileRTT"]; percentileRTT > 0 {
+		l.rttPercentile.Reset()
+		l.rttPercentile.Add(percentileRTT)
+	}
+	l.notifyListeners(int(l.estimatedLimit))
+	return nil
+}
+
+// Snapshot returns the current state of the limit.
+func (l *SLOLimit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "SLOLimit",
+		Limit: int(l.estimatedLimit),
+		Attributes: map[string]interface{}{
+			"percentile":    l.percentile,
+			"target":        l.target,
+			"percentileRTT": l.rttPercentile.Get(),
+			"increaseBy":    l.increaseBy,
+			"minLimit":      l.minLimit,
+			"maxLimit":      l.maxLimit,
+		},
+	}
+}
+
+func (l *SLOLimit) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return fmt.Sprintf("SLOLimit{limit=%d, p%g=%d ms, target=%d ms}", int(l.estimatedLimit), l.percentile*100,
+		int64(l.rttPercentile.Get())/1e6, l.target/1e6)
+}
@@ -0,0 +1,178 @@
+package limit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/measurements"
+)
+
+func createSLOLimit() *SLOLimit {
+	l, _ := NewSLOLimit("test", 20, 1, 100, 0.99, 50*time.Millisecond, 1, &measurements.SingleMeasurement{},
+		NoopLimitLogger{}, core.EmptyMetricRegistryInstance)
+	return l
+}
+
+func TestSLOLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Default", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewDefaultSLOLimit("test", 0.99, 50*time.Millisecond, nil, nil)
+		asrt.NoError(err)
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal("SLOLimit{limit=20, p99=0 ms, target=50 ms}", l.String())
+
+		// the moving percentile tracks every observed RTT once merged on the next sample
+		for i := 0; i < 100; i++ {
+			l.ObserveRTT((10 * time.Millisecond).Nanoseconds())
+		}
+		asrt.Equal(int64(0), l.PercentileRTT())
+		l.OnSample(0, (time.Millisecond).Nanoseconds(), 1, false)
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), l.PercentileRTT())
+
+		_, err = NewDefaultSLOLimit("test", 1, 50*time.Millisecond, nil, nil)
+		asrt.Error(err)
+		_, err = NewDefaultSLOLimit("test", 0.99, 0, nil, nil)
+		asrt.Error(err)
+		_, err = NewSLOLimit("test", 20, 10, 5, 0.99, 50*time.Millisecond, 1, nil, nil, nil)
+		asrt.Error(err)
+	})
+
+	t.Run("GrowsUnderTarget", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createSLOLimit()
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(21, l.EstimatedLimit())
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 11, false)
+		asrt.Equal(22, l.EstimatedLimit())
+		asrt.Equal([]int{21, 22}, listener.changes)
+
+		// the latency of a mostly idle limit says nothing about the limit
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 10, false)
+		asrt.Equal(22, l.EstimatedLimit())
+	})
+
+	t.Run("ShrinksProportionally", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createSLOLimit()
+		l.OnSample(0, (60 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(16, l.EstimatedLimit())
+
+		// at most halved
+		l.OnSample(0, (500 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(8, l.EstimatedLimit())
+	})
+
+	t.Run("DropHalvesTheLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createSLOLimit()
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, true)
+		asrt.Equal(10, l.EstimatedLimit())
+	})
+
+	t.Run("ObserveRTT", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createSLOLimit()
+		l.ObserveRTT((100 * time.Millisecond).Nanoseconds())
+
+		// the percentile of the observed requests is used rather than the sample
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal((100 * time.Millisecond).Nanoseconds(), l.PercentileRTT())
+		asrt.Equal(10, l.EstimatedLimit())
+	})
+
+	t.Run("ObserveRTTConcurrent", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		m := &countingMeasurement{}
+		l, _ := NewSLOLimit("test", 20, 1, 100, 0.99, 50*time.Millisecond, 1, m, nil, nil)
+		l.ObserveRTT((10 * time.Millisecond).Nanoseconds())
+		wg := sync.WaitGroup{}
+		for i := 0; i < 8; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					l.ObserveRTT((10 * time.Millisecond).Nanoseconds())
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					l.OnSample(0, (time.Millisecond).Nanoseconds(), 20, false)
+				}
+			}()
+		}
+		wg.Wait()
+		l.OnSample(0, (time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal(int64(8001), m.count.Load())
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), l.PercentileRTT())
+	})
+
+	t.Run("Update", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createSLOLimit()
+
+		// invalid updates apply nothing
+		target := time.Duration(0)
+		maxLimit := 10
+		asrt.Error(l.Update(SLOLimitUpdate{MaxLimit: &maxLimit, Target: &target}))
+		asrt.Equal(20, l.EstimatedLimit())
+
+		target = 5 * time.Millisecond
+		asrt.NoError(l.Update(SLOLimitUpdate{MaxLimit: &maxLimit, Target: &target}))
+		asrt.Equal(10, l.EstimatedLimit())
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 10, false)
+		asrt.Equal(5, l.EstimatedLimit())
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createSLOLimit()
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, false)
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal(core.LimitState{
+			Version: core.LimitStateVersion,
+			Type:    "SLOLimit",
+			Limit:   21,
+			Values:  map[string]float64{"percentileRTT": float64((10 * time.Millisecond).Nanoseconds())},
+		}, state)
+
+		restored := createSLOLimit()
+		listener := testNotifyListener{}
+		restored.NotifyOnChange(listener.updater())
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(21, restored.EstimatedLimit())
+		asrt.Equal([]int{21}, listener.changes)
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), restored.PercentileRTT())
+
+		state.Type = "VegasLimit"
+		asrt.Error(restored.ImportState(state))
+	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createSLOLimit()
+		snapshot := l.Snapshot()
+		asrt.Equal("SLOLimit", snapshot.Type)
+		asrt.Equal(20, snapshot.Limit)
+		asrt.Equal(0.99, snapshot.Attributes["percentile"])
+		asrt.Equal((50 * time.Millisecond).Nanoseconds(), snapshot.Attributes["target"])
+	})
+}
@@ -0,0 +1,322 @@
+package limit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// SuppressReasonTagName represents the metric tag of the MetricSuppressed count used for the suppression reason.
+const SuppressReasonTagName = "reason"
+
+// Reasons a StabilizedLimit did not report the limit of the wrapped limit, used as the SuppressReasonTagName tag of the
+// MetricSuppressed count.
+const (
+	SuppressReasonDeadBand = "dead_band"
+	SuppressReasonCooldown = "cooldown"
+	SuppressReasonRate     = "rate"
+)
+
+// StabilizedLimitConfig holds the bounds a StabilizedLimit applies to changes of the wrapped limit, zero values disable
+// a bound.
+type StabilizedLimitConfig struct {
+	// MaxIncrease is the largest increase of the limit on a single sample window.
+	MaxIncrease int
+	// MaxDecrease is the largest decrease of the limit on a single sample window.
+	MaxDecrease int
+	// MaxIncreaseRate is the largest increase of the limit per second.
+	MaxIncreaseRate float64
+	// MaxDecreaseRate is the largest decrease of the limit per second.
+	MaxDecreaseRate float64
+	// DeadBand is the difference to the wrapped limit up to which changes are not reported.
+	DeadBand int
+	// Cooldown is how long the limit is not increased after a decrease.
+	Cooldown time.Duration
+
+	MetricRegistry core.MetricRegistry
+	Tags           []string
+
+	// Clock is used for the rate bounds and the cooldown, defaults to the system clock.
+	Clock core.Clock
+}
+
+// ApplyDefaults is used by the StabilizedLimit constructor to set defaults for optional configuration arguments.
+func (c *StabilizedLimitConfig) ApplyDefaults() {
+	if c.MetricRegistry == nil {
+		c.MetricRegistry = core.EmptyMetricRegistryInstance
+	}
+	if c.Clock == nil {
+		c.Clock = core.SystemClockInstance
+	}
+}
+
+func (c *StabilizedLimitConfig) validate() error {
+	if c.MaxIncrease < 0 {
+		return fmt.Errorf("maxIncrease must be >= 0")
+	}
+	if c.MaxDecrease < 0 {
+		return fmt.Errorf("maxDecrease must be >= 0")
+	}
+	if c.MaxIncreaseRate < 0 {
+		return fmt.Errorf("maxIncreaseRate must be >= 0")
+	}
+	if c.MaxDecreaseRate < 0 {
+		return fmt.Errorf("maxDecreaseRate must be >= 0")
+	}
+	if c.DeadBand < 0 {
+		return fmt.Errorf("deadBand must be >= 0")
+	}
+	if c.Cooldown < 0 {
+		return fmt.Errorf("cooldown must be >= 0")
+	}
+	return nil
+}
+
+// StabilizedLimit implements a core.Limit decorator that damps the changes of the wrapped limit, so that an
+// oscillating algorithm does not flap whatever follows the limit, i.e. autoscalers or partition budgets.  Every sample
+// is passed to the wrapped limit and its new limit is then reported subject to the configured bounds:
+//
+//   - changes within the dead band of the reported limit are not reported.
+//   - the limit is not increased during the cooldown following a decrease.
+//   - an increase or decrease is clipped to the bound per sample window and the bound per second, the per second
+//     bounds accrue as a budget of up to one second, or a single permit.
+//
+// The wrapped limit is only followed on samples, changes made to it in between are reported on the next sample.
+// Suppressed and clipped changes are counted by the MetricSuppressed count, tagged with the reason.
+type StabilizedLimit struct {
+	delegate core.Limit
+	config   StabilizedLimitConfig
+
+	limit            int
+	lastSample       time.Time
+	lastDecrease     time.Time
+	increaseBudget   float64
+	decreaseBudget   float64
+	deadBandCounter  core.MetricSampleListener
+	cooldownCounter  core.MetricSampleListener
+	rateLimitCounter core.MetricSampleListener
+
+	mu        sync.RWMutex
+	listeners []core.LimitChangeListener
+}
+
+// NewStabilizedLimit will create a new StabilizedLimit.
+// @param delegate: the wrapped limit.
+// @param config: the bounds on changes of the wrapped limit.
+func NewStabilizedLimit(name string, delegate core.Limit, config StabilizedLimitConfig) (*StabilizedLimit, error) {
+	if delegate == nil {
+		return nil, fmt.Errorf("delegate must be specified")
+	}
+	config.ApplyDefaults()
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
+	counter := func(reason string) core.MetricSampleListener {
+		tags := append(append([]string(nil), config.Tags...), fmt.Sprintf("%s:%s", SuppressReasonTagName, reason))
+		return config.MetricRegistry.RegisterCount(core.PrefixMetricWithName(core.MetricSuppressed, name), tags...)
+	}
+	now := config.Clock.Now()
+	return &StabilizedLimit{
+		delegate:         delegate,
+		config:           config,
+		limit:            delegate.EstimatedLimit(),
+		lastSample:       now,
+		increaseBudget:   config.MaxIncreaseRate,
+		decreaseBudget:   config.MaxDecreaseRate,
+		deadBandCounter:  counter(SuppressReasonDeadBand),
+		cooldownCounter:  counter(SuppressReasonCooldown),
+		rateLimitCounter: counter(SuppressReasonRate),
+		listeners:        make([]core.LimitChangeListener, 0),
+	}, nil
+}
+
+// EstimatedLimit returns the last reported limit.
+func (l *StabilizedLimit) EstimatedLimit() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.limit
+}
+
+// NotifyOnChange will register a callback to receive notification whenever the limit is updated to a new value.
+func (l *StabilizedLimit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.mu.Lock()
+	l.listeners = append(l.listeners, consumer)
+	l.mu.Unlock()
+}
+
+// notifyListeners will call the callbacks on limit changes
+func (l *StabilizedLimit) notifyListeners(newLimit int) {
+	for _, listener := range l.listeners {
+		listener(newLimit)
+	}
+}
+
+// OnSample passes the sample to the wrapped limit and reports its new limit within the configured bounds.
+func (l *StabilizedLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	l.delegate.OnSample(startTime, rtt, inFlight, didDrop)
+	target := l.delegate.EstimatedLimit()
+	now := l.config.Clock.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elapsed := now.Sub(l.lastSample).Seconds()
+	l.lastSample = now
+	if elapsed > 0 {
+		l.increaseBudget = accrue(l.increaseBudget, l.config.MaxIncreaseRate, elapsed)
+		l.decreaseBudget = accrue(l.decreaseBudget, l.config.MaxDecreaseRate, elapsed)
+	}
+
+	delta := target - l.limit
+	switch {
+	case delta == 0:
+		return
+	case delta <= l.config.DeadBand && delta >= -l.config.DeadBand:
+		l.deadBandCounter.AddSample(1)
+		return
+	case delta > 0 && l.config.Cooldown > 0 && !l.lastDecrease.IsZero() &&
+		now.Before(l.lastDecrease.Add(l.config.Cooldown)):
+		l.cooldownCounter.AddSample(1)
+		return
+	}
+
+	step := delta
+	if delta > 0 {
+		step = l.clip(step, l.config.MaxIncrease, l.config.MaxIncreaseRate, l.increaseBudget)
+		l.increaseBudget -= float64(step)
+	} else {
+		step = -l.clip(-step, l.config.MaxDecrease, l.config.MaxDecreaseRate, l.decreaseBudget)
+		l.decreaseBudget += float64(step)
+	}
+	if step != delta {
+		l.rateLimitCounter.AddSample(1)
+	}
+	if step == 0 {
+		return
+	}
+	if step < 0 {
+		l.lastDecrease = now
+	}
+	l.limit += step
+	l.notifyListeners(l.limit)
+}
+
+// accrue returns the budget of a per second bound after elapsed seconds, capped at one second or a single permit so
+// that bounds below one permit per second still allow a change.
+func accrue(budget float64, rate float64, elapsed float64) float64 {
+	return math.Min(math.Max(1, rate), budget+rate*elapsed)
+}
+
+// clip returns step bounded by the per sample window bound and the accrued per second budget, if they are enabled.
+func (l *StabilizedLimit) clip(step int, maxStep int, rate float64, budget float64) int {
+	if maxStep > 0 && step > maxStep {
+		step = maxStep
+	}
+	if rate > 0 && float64(step) > budget {
+		step = int(budget)
+	}
+	return step
+}
+
+// OnThroughput will delegate the throughput to the wrapped limit if it is a core.ThroughputLimit.
+func (l *StabilizedLimit) OnThroughput(completed int, duration time.Duration) {
+	if throughputLimit, ok := l.delegate.(core.ThroughputLimit); ok {
+		throughputLimit.OnThroughput(completed, duration)
+	}
+}
+
+// ObserveRTT will delegate the RTT to the wrapped limit if it is a core.RTTObservingLimit.
+func (l *StabilizedLimit) ObserveRTT(rtt int64) {
+	if observer, ok := l.delegate.(core.RTTObservingLimit); ok {
+		observer.ObserveRTT(rtt)
+	}
+}
+
+// OnDropRate will delegate the drop rate to the wrapped limit if it is a core.DropRateLimit.
+func (l *StabilizedLimit) OnDropRate(dropped int, total int) {
+	if dropRateLimit, ok := l.delegate.(core.DropRateLimit); ok {
+		dropRateLimit.OnDropRate(dropped, total)
+	}
+}
+
+// CheckIdle will delegate the idle check to the wrapped limit if it is a core.IdleLimit and report a limit changed by
+// the check without applying any bounds, like an imported state.  The idle policy already moves the limit gradually,
+// and the decayed limit must be in effect before the first requests of the next burst are admitted rather than held
+// back for several sample windows.
+func (l *StabilizedLimit) CheckIdle() {
+	idleLimit, ok := l.delegate.(core.IdleLimit)
+	if !ok {
+		return
+	}
+	before := l.delegate.EstimatedLimit()
+	idleLimit.CheckIdle()
+	newLimit := l.delegate.EstimatedLimit()
+	if newLimit == before {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if newLimit == l.limit {
+		return
+	}
+	l.limit = newLimit
+	l.notifyListeners(l.limit)
+}
+
+// ExportState returns the state of the wrapped limit, or core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *StabilizedLimit) ExportState() (core.LimitState, error) {
+	if stateful, ok := l.delegate.(core.StatefulLimit); ok {
+		return stateful.ExportState()
+	}
+	return core.LimitState{}, core.ErrStateNotSupported
+}
+
+// ImportState will restore the state of the wrapped limit and report its limit without applying any bounds, or
+// return core.ErrStateNotSupported if it is not a core.StatefulLimit.
+func (l *StabilizedLimit) ImportState(state core.LimitState) error {
+	stateful, ok := l.delegate.(core.StatefulLimit)
+	if !ok {
+		return core.ErrStateNotSupported
+	}
+	if err := stateful.ImportState(state); err != nil {
+		return err
+	}
+	newLimit := l.delegate.EstimatedLimit()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = newLimit
+	l.notifyListeners(l.limit)
+	return nil
+}
+
+// Snapshot returns the current state of the limit including the snapshot of the wrapped limit.
+func (l *StabilizedLimit) Snapshot() core.Snapshot {
+	// the wrapped limit may notify while holding its own lock, so it is inspected without holding mu
+	delegate := core.SnapshotOf(l.delegate)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "StabilizedLimit",
+		Limit: l.limit,
+		Attributes: map[string]interface{}{
+			"maxIncrease":     l.config.MaxIncrease,
+			"maxDecrease":     l.config.MaxDecrease,
+			"maxIncreaseRate": l.config.MaxIncreaseRate,
+			"maxDecreaseRate": l.config.MaxDecreaseRate,
+			"deadBand":        l.config.DeadBand,
+			"cooldown":        l.config.Cooldown.Nanoseconds(),
+		},
+		Delegate: delegate,
+	}
+}
+
+func (l *StabilizedLimit) String() string {
+	delegate := fmt.Sprint(l.delegate)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return fmt.Sprintf("StabilizedLimit{limit=%d, delegate=%s}", l.limit, delegate)
+}
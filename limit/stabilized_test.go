@@ -0,0 +1,247 @@
+package limit
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// countingRegistry records the counts registered by a limit.
+type countingRegistry struct {
+	core.EmptyMetricRegistry
+	mu     sync.Mutex
+	counts map[string]float64
+}
+
+type countingListener struct {
+	registry *countingRegistry
+	key      string
+}
+
+func (c *countingListener) AddSample(value float64, tags ...string) {
+	c.registry.mu.Lock()
+	defer c.registry.mu.Unlock()
+	c.registry.counts[c.key] += value
+}
+
+func (r *countingRegistry) RegisterCount(ID string, tags ...string) core.MetricSampleListener {
+	return &countingListener{registry: r, key: ID + "|" + strings.Join(tags, ",")}
+}
+
+func (r *countingRegistry) count(ID string, tags ...string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counts[ID+"|"+strings.Join(tags, ",")]
+}
+
+func TestStabilizedLimit(t *testing.T) {
+	t.Parallel()
+
+	sample := func(l *StabilizedLimit, delegate *SettableLimit, newLimit int) {
+		delegate.SetLimit(newLimit)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), newLimit, false)
+	}
+
+	t.Run("Validation", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		_, err := NewStabilizedLimit("test", nil, StabilizedLimitConfig{})
+		asrt.Error(err)
+		_, err = NewStabilizedLimit("test", NewFixedLimit("test", 10, nil), StabilizedLimitConfig{MaxIncrease: -1})
+		asrt.Error(err)
+		_, err = NewStabilizedLimit("test", NewFixedLimit("test", 10, nil), StabilizedLimitConfig{Cooldown: -1})
+		asrt.Error(err)
+
+		l, err := NewStabilizedLimit("test", NewFixedLimit("test", 10, nil), StabilizedLimitConfig{})
+		asrt.NoError(err)
+		asrt.Equal(10, l.EstimatedLimit())
+		asrt.Equal("StabilizedLimit{limit=10, delegate=FixedLimit{limit=10}}", l.String())
+	})
+
+	t.Run("Unbounded", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		delegate := NewSettableLimit("test", 10, nil)
+		l, _ := NewStabilizedLimit("test", delegate, StabilizedLimitConfig{})
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		sample(l, delegate, 50)
+		sample(l, delegate, 5)
+		asrt.Equal([]int{50, 5}, listener.changes)
+	})
+
+	t.Run("MaxChangePerWindow", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		registry := &countingRegistry{counts: make(map[string]float64)}
+		delegate := NewSettableLimit("test", 20, nil)
+		l, _ := NewStabilizedLimit("test", delegate, StabilizedLimitConfig{
+			MaxIncrease:    5,
+			MaxDecrease:    2,
+			MetricRegistry: registry,
+		})
+		sample(l, delegate, 40)
+		asrt.Equal(25, l.EstimatedLimit())
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 40, false)
+		asrt.Equal(30, l.EstimatedLimit())
+		sample(l, delegate, 10)
+		asrt.Equal(28, l.EstimatedLimit())
+		asrt.Equal(3.0, registry.count("test.limit.suppressed", "reason:rate"))
+	})
+
+	t.Run("MaxChangePerSecond", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		delegate := NewSettableLimit("test", 20, nil)
+		l, _ := NewStabilizedLimit("test", delegate, StabilizedLimitConfig{
+			MaxIncreaseRate: 10,
+			Clock:           fakeClock,
+		})
+		sample(l, delegate, 100)
+		asrt.Equal(30, l.EstimatedLimit())
+
+		// the budget accrues with time
+		fakeClock.Advance(500 * time.Millisecond)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 100, false)
+		asrt.Equal(35, l.EstimatedLimit())
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 100, false)
+		asrt.Equal(35, l.EstimatedLimit())
+
+		// up to one second
+		fakeClock.Advance(time.Minute)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 100, false)
+		asrt.Equal(45, l.EstimatedLimit())
+	})
+
+	t.Run("DeadBand", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		registry := &countingRegistry{counts: make(map[string]float64)}
+		delegate := NewSettableLimit("test", 20, nil)
+		l, _ := NewStabilizedLimit("test", delegate, StabilizedLimitConfig{DeadBand: 3, MetricRegistry: registry})
+		sample(l, delegate, 23)
+		sample(l, delegate, 17)
+		asrt.Equal(20, l.EstimatedLimit())
+		sample(l, delegate, 24)
+		asrt.Equal(24, l.EstimatedLimit())
+		asrt.Equal(2.0, registry.count("test.limit.suppressed", "reason:dead_band"))
+	})
+
+	t.Run("Cooldown", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		registry := &countingRegistry{counts: make(map[string]float64)}
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		delegate := NewSettableLimit("test", 20, nil)
+		l, _ := NewStabilizedLimit("test", delegate, StabilizedLimitConfig{
+			Cooldown:       time.Second,
+			MetricRegistry: registry,
+			Clock:          fakeClock,
+		})
+		// increases are not delayed without a decrease
+		sample(l, delegate, 25)
+		asrt.Equal(25, l.EstimatedLimit())
+
+		sample(l, delegate, 15)
+		sample(l, delegate, 20)
+		asrt.Equal(15, l.EstimatedLimit())
+		// decreases are not delayed
+		sample(l, delegate, 10)
+		asrt.Equal(10, l.EstimatedLimit())
+
+		fakeClock.Advance(time.Second)
+		sample(l, delegate, 20)
+		asrt.Equal(20, l.EstimatedLimit())
+		asrt.Equal(1.0, registry.count("test.limit.suppressed", "reason:cooldown"))
+	})
+
+	t.Run("ForwardsOptionalInterfaces", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		littlesLaw := NewDefaultLittlesLawLimit("test", nil, nil)
+		l, _ := NewStabilizedLimit("test", littlesLaw, StabilizedLimitConfig{})
+		l.OnThroughput(1000, time.Second)
+		asrt.InDelta(1000, littlesLaw.Throughput(), 0.001)
+
+		slo := createSLOLimit()
+		l, _ = NewStabilizedLimit("test", slo, StabilizedLimitConfig{})
+		l.ObserveRTT((10 * time.Millisecond).Nanoseconds())
+		l.OnSample(0, (20 * time.Millisecond).Nanoseconds(), 20, false)
+		asrt.Equal((10 * time.Millisecond).Nanoseconds(), slo.PercentileRTT())
+
+		errorRate := createErrorRateLimit(NewFixedLimit("test", 20, nil))
+		l, _ = NewStabilizedLimit("test", errorRate, StabilizedLimitConfig{})
+		l.OnDropRate(1, 4)
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, true)
+		asrt.Equal(0.25, errorRate.ErrorRate())
+	})
+
+	t.Run("CheckIdle", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		aimd, err := NewAIMDLimitFromConfig("test", AIMDLimitConfig{
+			Idle: IdlePolicy{Timeout: time.Minute, DecayHalfLife: time.Minute, Clock: fakeClock},
+		})
+		asrt.NoError(err)
+		asrt.NoError(aimd.ImportState(core.LimitState{Version: core.LimitStateVersion, Type: "AIMDLimit", Limit: 50}))
+		l, _ := NewStabilizedLimit("test", aimd, StabilizedLimitConfig{MaxDecrease: 1, Clock: fakeClock})
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		l.CheckIdle()
+		asrt.Equal(50, l.EstimatedLimit())
+
+		// the decayed limit is reported without the bounds
+		fakeClock.Advance(2 * time.Minute)
+		l.CheckIdle()
+		asrt.Equal(aimd.EstimatedLimit(), l.EstimatedLimit())
+		asrt.Less(l.EstimatedLimit(), 49)
+		asrt.Equal([]int{l.EstimatedLimit()}, listener.changes)
+
+		// a check that does not change the wrapped limit leaves changes held back by the bounds alone
+		decayed := l.EstimatedLimit()
+		l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), decayed, true)
+		asrt.Equal(decayed-1, l.EstimatedLimit())
+		l.CheckIdle()
+		asrt.Equal(decayed-1, l.EstimatedLimit())
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, _ := NewStabilizedLimit("test", NewDefaultAIMDLimit("test", nil), StabilizedLimitConfig{MaxIncrease: 1})
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal("AIMDLimit", state.Type)
+
+		// restored state is not bounded
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		state.Limit = 30
+		asrt.NoError(l.ImportState(state))
+		asrt.Equal(30, l.EstimatedLimit())
+		asrt.Equal([]int{30}, listener.changes)
+
+		l, _ = NewStabilizedLimit("test", NewFixedLimit("test", 10, nil), StabilizedLimitConfig{})
+		_, err = l.ExportState()
+		asrt.ErrorIs(err, core.ErrStateNotSupported)
+	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, _ := NewStabilizedLimit("test", NewFixedLimit("test", 10, nil), StabilizedLimitConfig{DeadBand: 2})
+		snapshot := l.Snapshot()
+		asrt.Equal("StabilizedLimit", snapshot.Type)
+		asrt.Equal(10, snapshot.Limit)
+		asrt.Equal(2, snapshot.Attributes["deadBand"])
+		asrt.Equal("FixedLimit", snapshot.Delegate.Type)
+	})
+}
@@ -3,6 +3,7 @@ package limit
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
 )
@@ -79,6 +80,63 @@ func (l *TracedLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop
 	l.limit.OnSample(startTime, rtt, inFlight, didDrop)
 }
 
+// OnThroughput will log and delegate the throughput to the wrapped limit if it is a core.ThroughputLimit.
+func (l *TracedLimit) OnThroughput(completed int, duration time.Duration) {
+	l.logger.Debugf("completed=%d, duration=%d ms", completed, duration.Milliseconds())
+	if throughputLimit, ok := l.limit.(core.ThroughputLimit); ok {
+		throughputLimit.OnThroughput(completed, duration)
+	}
+}
+
+// ObserveRTT will delegate the RTT to the wrapped limit if it is a core.RTTObservingLimit.
+func (l *TracedLimit) ObserveRTT(rtt int64) {
+	if observer, ok := l.limit.(core.RTTObservingLimit); ok {
+		observer.ObserveRTT(rtt)
+	}
+}
+
+// OnDropRate will log and delegate the drop rate to the wrapped limit if it is a core.DropRateLimit.
+func (l *TracedLimit) OnDropRate(dropped int, total int) {
+	l.logger.Debugf("dropped=%d, total=%d", dropped, total)
+	if dropRateLimit, ok := l.limit.(core.DropRateLimit); ok {
+		dropRateLimit.OnDropRate(dropped, total)
+	}
+}
+
+// CheckIdle will delegate the idle check to the wrapped limit if it is a core.IdleLimit.
+func (l *TracedLimit) CheckIdle() {
+	if idleLimit, ok := l.limit.(core.IdleLimit); ok {
+		idleLimit.CheckIdle()
+	}
+}
+
+// ExportState returns the state of the wrapped limit, or core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *TracedLimit) ExportState() (core.LimitState, error) {
+	if stateful, ok := l.limit.(core.StatefulLimit); ok {
+		return stateful.ExportState()
+	}
+	return core.LimitState{}, core.ErrStateNotSupported
+}
+
+// ImportState will restore the state of the wrapped limit, or return core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *TracedLimit) ImportState(state core.LimitState) error {
+	if stateful, ok := l.limit.(core.StatefulLimit); ok {
+		return stateful.ImportState(state)
+	}
+	return core.ErrStateNotSupported
+}
+
+// Snapshot returns the snapshot of the wrapped limit.
+func (l *TracedLimit) Snapshot() core.Snapshot {
+	return core.Snapshot{
+		Type:     "TracedLimit",
+		Limit:    l.limit.EstimatedLimit(),
+		Delegate: core.SnapshotOf(l.limit),
+	}
+}
+
 func (l *TracedLimit) String() string {
 	return fmt.Sprintf("TracedLimit{limit=%v, logger=%v}", l.limit, l.logger)
 }
@@ -2,8 +2,12 @@ package limit
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+	"github.com/platinummonkey/go-concurrency-limits/core"
 )
 
 func TestNoopLimitLogger(t *testing.T) {
@@ -38,4 +42,74 @@ func TestTracedLimit(t *testing.T) {
 	asrt.Equal(10, l.EstimatedLimit())
 
 	asrt.Equal("TracedLimit{limit=SettableLimit{limit=10}, logger=NoopLimitLogger{}}", l.String())
+
+	snapshot := l.Snapshot()
+	asrt.Equal("TracedLimit", snapshot.Type)
+	asrt.Equal(10, snapshot.Limit)
+	asrt.Equal("SettableLimit", snapshot.Delegate.Type)
+	asrt.Equal("TracedLimit limit=10 inFlight=0\n  SettableLimit limit=10 inFlight=0\n", snapshot.Render())
+}
+
+func TestTracedLimit_OnThroughput(t *testing.T) {
+	t.Parallel()
+	asrt := assert.New(t)
+	delegate := NewDefaultLittlesLawLimit("test", nil, nil)
+	l := NewTracedLimit(delegate, NoopLimitLogger{})
+	l.OnThroughput(100, time.Second)
+	asrt.InDelta(100, delegate.Throughput(), 0.001)
+
+	// limits that do not use the throughput ignore it
+	settable := NewTracedLimit(NewSettableLimit("test", 10, nil), NoopLimitLogger{})
+	asrt.NotPanics(func() { settable.OnThroughput(1, time.Second) })
+}
+
+func TestTracedLimitState(t *testing.T) {
+	t.Parallel()
+	asrt := assert.New(t)
+	state := core.LimitState{Version: core.LimitStateVersion, Type: "AIMDLimit", Limit: 5}
+	l := NewTracedLimit(NewAIMDLimit("test", 10, 0.5, 1, nil), NoopLimitLogger{})
+	asrt.NoError(l.ImportState(state))
+	exported, err := l.ExportState()
+	asrt.NoError(err)
+	asrt.Equal(state, exported)
+
+	unsupported := NewTracedLimit(NewSettableLimit("test", 10, nil), NoopLimitLogger{})
+	_, err = unsupported.ExportState()
+	asrt.Equal(core.ErrStateNotSupported, err)
+	asrt.Equal(core.ErrStateNotSupported, unsupported.ImportState(state))
+}
+
+func TestTracedLimit_ObserveRTT(t *testing.T) {
+	t.Parallel()
+	asrt := assert.New(t)
+	delegate := createSLOLimit()
+	l := NewTracedLimit(delegate, NoopLimitLogger{})
+	l.ObserveRTT((10 * time.Millisecond).Nanoseconds())
+	l.OnSample(0, (20 * time.Millisecond).Nanoseconds(), 20, false)
+	asrt.Equal((10 * time.Millisecond).Nanoseconds(), delegate.PercentileRTT())
+}
+
+func TestTracedLimit_OnDropRate(t *testing.T) {
+	t.Parallel()
+	asrt := assert.New(t)
+	delegate := createErrorRateLimit(NewFixedLimit("test", 20, nil))
+	l := NewTracedLimit(delegate, NoopLimitLogger{})
+	l.OnDropRate(1, 4)
+	l.OnSample(0, (10 * time.Millisecond).Nanoseconds(), 20, true)
+	asrt.Equal(0.25, delegate.ErrorRate())
+}
+
+func TestTracedLimit_CheckIdle(t *testing.T) {
+	t.Parallel()
+	asrt := assert.New(t)
+	fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+	delegate, err := NewAIMDLimitFromConfig("test", AIMDLimitConfig{
+		Idle: IdlePolicy{Timeout: time.Minute, DecayHalfLife: time.Minute, Clock: fakeClock},
+	})
+	asrt.NoError(err)
+	asrt.NoError(delegate.ImportState(core.LimitState{Version: core.LimitStateVersion, Type: "AIMDLimit", Limit: 30}))
+	l := NewTracedLimit(delegate, NoopLimitLogger{})
+	fakeClock.Advance(2 * time.Minute)
+	l.CheckIdle()
+	asrt.Equal(20, l.EstimatedLimit())
 }
@@ -3,7 +3,6 @@ package limit
 import (
 	"fmt"
 	"math"
-	"math/rand"
 	"sync"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
@@ -34,6 +33,10 @@ type VegasLimit struct {
 	probeMultipler    int
 	probeJitter       float64
 	probeCount        int64
+	random            core.RandomSource
+	initialLimit      int
+	tags              []string
+	idle              idleState
 
 	listeners []core.LimitChangeListener
 	registry  core.MetricRegistry
@@ -175,8 +178,12 @@ func NewVegasLimitWithRegistry(
 		decreaseFunc:      decreaseFunc,
 		smoothing:         smoothing,
 		probeMultipler:    probeMultiplier,
-		probeJitter:       newProbeJitter(),
+		probeJitter:       newProbeJitter(core.SystemRandomSourceInstance),
 		probeCount:        0,
+		random:            core.SystemRandomSourceInstance,
+		initialLimit:      initialLimit,
+		tags:              tags,
+		idle:              newIdleState(IdlePolicy{}),
 		rttNoLoad:         rttNoLoad,
 		rttSampleListener: registry.RegisterDistribution(core.PrefixMetricWithName(core.MetricMinRTT, name), tags...),
 		listeners:         make([]core.LimitChangeListener, 0),
@@ -188,11 +195,127 @@ func NewVegasLimitWithRegistry(
 	return l
 }
 
+// VegasLimitConfig configures a VegasLimit created with NewVegasLimitFromConfig.  The zero value of every field
+// selects its default, any other invalid value is an error.
+type VegasLimitConfig struct {
+	// InitialLimit is the initial limit used by the limiter, default 20.
+	InitialLimit int `json:"initialLimit"`
+	// MaxLimit is the maximum allowable concurrency, default 1000.
+	MaxLimit int `json:"maxLimit"`
+	// Smoothing in (0, 1] is the weight of a new limit estimate, default 1 which replaces the limit by the estimate.
+	Smoothing float64 `json:"smoothing"`
+	// ProbeMultiplier sets how often the RTT no load is probed, in multiples of the limit, default 30.
+	ProbeMultiplier int `json:"probeMultiplier"`
+	// Idle adjusts the limit once traffic stops, disabled by default.
+	Idle IdlePolicy `json:"idle"`
+
+	// RTTNoLoad measures the RTT no load, default a measurements.MinimumMeasurement.
+	RTTNoLoad     core.MeasurementInterface            `json:"-"`
+	AlphaFunc     func(estimatedLimit int) int         `json:"-"`
+	BetaFunc      func(estimatedLimit int) int         `json:"-"`
+	ThresholdFunc func(estimatedLimit int) int         `json:"-"`
+	IncreaseFunc  func(estimatedLimit float64) float64 `json:"-"`
+	DecreaseFunc  func(estimatedLimit float64) float64 `json:"-"`
+
+	Logger         Logger              `json:"-"`
+	MetricRegistry core.MetricRegistry `json:"-"`
+	Tags           []string            `json:"tags,omitempty"`
+}
+
+// ApplyDefaults will set the default of every field with a zero value.
+func (c *VegasLimitConfig) ApplyDefaults() {
+	if c.InitialLimit == 0 {
+		c.InitialLimit = 20
+	}
+	if c.MaxLimit == 0 {
+		c.MaxLimit = 1000
+	}
+	if c.Smoothing == 0 {
+		c.Smoothing = 1.0
+	}
+	if c.ProbeMultiplier == 0 {
+		c.ProbeMultiplier = 30
+	}
+	c.Idle.applyDefaults()
+	// the remaining defaults are applied by NewVegasLimitWithRegistry
+}
+
+// Validate will return an error describing every invalid field.
+func (c *VegasLimitConfig) Validate() error {
+	v := &configValidator{limitType: "VegasLimit"}
+	v.limits(c.InitialLimit, 1, c.MaxLimit)
+	v.smoothing(c.Smoothing)
+	v.atLeastOne("probeMultiplier", c.ProbeMultiplier)
+	v.check(c.Idle.validate())
+	return v.err()
+}
+
+// NewVegasLimitFromConfig will create a new VegasLimit, returning an error if any field of the config is invalid.
+// The resolved config is available from VegasLimit.Config.
+func NewVegasLimitFromConfig(name string, config VegasLimitConfig) (*VegasLimit, error) {
+	config.ApplyDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	l := NewVegasLimitWithRegistry(
+		name,
+		config.InitialLimit,
+		config.RTTNoLoad,
+		config.MaxLimit,
+		config.Smoothing,
+		config.AlphaFunc,
+		config.BetaFunc,
+		config.ThresholdFunc,
+		config.IncreaseFunc,
+		config.DecreaseFunc,
+		config.ProbeMultiplier,
+		config.Logger,
+		config.MetricRegistry,
+		config.Tags...,
+	)
+	l.idle = newIdleState(config.Idle)
+	return l, nil
+}
+
+// Config returns the configuration of the limit with every default resolved, including changes made by Update.
+func (l *VegasLimit) Config() VegasLimitConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return VegasLimitConfig{
+		InitialLimit:    l.initialLimit,
+		MaxLimit:        l.maxLimit,
+		Smoothing:       l.smoothing,
+		ProbeMultiplier: l.probeMultipler,
+		Idle:            l.idle.policy,
+		RTTNoLoad:       l.rttNoLoad,
+		AlphaFunc:       l.alphaFunc,
+		BetaFunc:        l.betaFunc,
+		ThresholdFunc:   l.thresholdFunc,
+		IncreaseFunc:    l.increaseFunc,
+		DecreaseFunc:    l.decreaseFunc,
+		Logger:          l.logger,
+		MetricRegistry:  l.registry,
+		Tags:            l.tags,
+	}
+}
+
 // ProbeDisabled represents the disabled value for probing.
 const ProbeDisabled = -1
 
-func newProbeJitter() float64 {
-	return (rand.Float64() / 2.0) + 0.5
+func newProbeJitter(random core.RandomSource) float64 {
+	return (random.Float64() / 2.0) + 0.5
+}
+
+// SetRandom will replace the random source used for the probe jitter, i.e. with core.NewSeededRandomSource for
+// reproducible tests.  The current jitter is redrawn from the new source.
+func (l *VegasLimit) SetRandom(random core.RandomSource) {
+	if random == nil {
+		random = core.SystemRandomSourceInstance
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.random = random
+	l.probeJitter = newProbeJitter(random)
 }
 
 // EstimatedLimit returns the current estimated limit.
@@ -221,12 +344,16 @@ func (l *VegasLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	l.commonSampler.Sample(rtt, inFlight, didDrop)
+	l.checkIdle()
+	if didDrop || float64(inFlight)*2 >= l.estimatedLimit {
+		l.idle.active()
+	}
 
 	l.probeCount++
 	if l.shouldProbe() {
 		l.logger.Debugf("Probe triggered update to RTT No Load %d ms from %d ms",
 			rtt/1e6, int64(l.rttNoLoad.Get())/1e6)
-		l.probeJitter = newProbeJitter()
+		l.probeJitter = newProbeJitter(l.random)
 		l.probeCount = 0
 		l.rttNoLoad = &measurements.MinimumMeasurement{}
 		l.rttNoLoad.Add(float64(rtt))
@@ -243,6 +370,29 @@ func (l *VegasLimit) OnSample(startTime int64, rtt int64, inFlight int, didDrop
 	l.updateEstimatedLimit(startTime, rtt, inFlight, didDrop)
 }
 
+// CheckIdle will apply the idle policy if the limit has been idle for longer than its timeout.
+func (l *VegasLimit) CheckIdle() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checkIdle()
+}
+
+// checkIdle decays the estimated limit and resets the RTT no load baseline as configured by the idle policy, it must
+// be called with mu held.
+func (l *VegasLimit) checkIdle() {
+	newLimit, rebaseline := l.idle.check(l.estimatedLimit, float64(l.initialLimit))
+	if rebaseline {
+		l.logger.Debugf("Idle triggered reset of RTT No Load %d ms", int64(l.rttNoLoad.Get())/1e6)
+		l.rttNoLoad.Reset()
+		l.probeCount = 0
+	}
+	newLimit = math.Max(1, math.Min(float64(l.maxLimit), newLimit))
+	if newLimit != l.estimatedLimit {
+		l.estimatedLimit = newLimit
+		l.notifyListeners(l.estimatedLimit)
+	}
+}
+
 func (l *VegasLimit) shouldProbe() bool {
 	return int64(l.probeJitter*float64(l.probeMultipler)*l.estimatedLimit) <= l.probeCount
 }
@@ -289,6 +439,108 @@ func (l *VegasLimit) updateEstimatedLimit(startTime int64, rtt int64, inFlight i
 	l.notifyListeners(l.estimatedLimit)
 }
 
+// VegasLimitUpdate holds the parameters of a VegasLimit that can be changed at runtime with Update, nil fields are
+// left unchanged.
+type VegasLimitUpdate struct {
+	MaxLimit        *int
+	Smoothing       *float64
+	ProbeMultiplier *int
+	AlphaFunc       func(estimatedLimit int) int
+	BetaFunc        func(estimatedLimit int) int
+	ThresholdFunc   func(estimatedLimit int) int
+	IncreaseFunc    func(estimatedLimit float64) float64
+	DecreaseFunc    func(estimatedLimit float64) float64
+	Idle            *IdlePolicy
+}
+
+// Update will atomically apply new parameters without resetting the estimated limit or the RTT no load baseline.  If
+// the estimated limit is above a lowered maxLimit it is clamped and listeners are notified.  Nothing is applied if any
+// parameter is invalid.
+func (l *VegasLimit) Update(update VegasLimitUpdate) error {
+	v := &configValidator{limitType: "VegasLimit", update: true}
+	if update.MaxLimit != nil {
+		v.atLeastOne("maxLimit", *update.MaxLimit)
+	}
+	if update.Smoothing != nil {
+		v.smoothing(*update.Smoothing)
+	}
+	if update.ProbeMultiplier != nil {
+		v.atLeastOne("probeMultiplier", *update.ProbeMultiplier)
+	}
+	if update.Idle != nil {
+		v.check(update.Idle.validate())
+	}
+	if err := v.err(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if update.Smoothing != nil {
+		l.smoothing = *update.Smoothing
+	}
+	if update.ProbeMultiplier != nil {
+		l.probeMultipler = *update.ProbeMultiplier
+	}
+	if update.AlphaFunc != nil {
+		l.alphaFunc = update.AlphaFunc
+	}
+	if update.BetaFunc != nil {
+		l.betaFunc = update.BetaFunc
+	}
+	if update.ThresholdFunc != nil {
+		l.thresholdFunc = update.ThresholdFunc
+	}
+	if update.IncreaseFunc != nil {
+		l.increaseFunc = update.IncreaseFunc
+	}
+	if update.DecreaseFunc != nil {
+		l.decreaseFunc = update.DecreaseFunc
+	}
+	if update.Idle != nil {
+		l.idle = newIdleState(*update.Idle)
+	}
+	if update.MaxLimit != nil {
+		l.maxLimit = *update.MaxLimit
+		if l.estimatedLimit > float64(l.maxLimit) {
+			l.estimatedLimit = float64(l.maxLimit)
+			l.notifyListeners(l.estimatedLimit)
+		}
+	}
+	return nil
+}
+
+// ExportState returns the estimated limit and RTT no load baseline.
+func (l *VegasLimit) ExportState() (core.LimitState, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.LimitState{
+		Version: core.LimitStateVersion,
+		Type:    "VegasLimit",
+		Limit:   l.estimatedLimit,
+		Values: map[string]float64{
+			"rttNoLoad": l.rttNoLoad.Get(),
+		},
+	}, nil
+}
+
+// ImportState will restore the estimated limit and RTT no load baseline of a previously exported state.
+func (l *VegasLimit) ImportState(state core.LimitState) error {
+	if err := state.Check("VegasLimit"); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.estimatedLimit = math.Max(1, math.Min(float64(l.maxLimit), state.Limit))
+	if rttNoLoad := state.Values["rttNoLoad"]; rttNoLoad > 0 {
+		l.rttNoLoad.Reset()
+		l.rttNoLoad.Add(rttNoLoad)
+	}
+	l.probeCount = 0
+	l.notifyListeners(l.estimatedLimit)
+	return nil
+}
+
 // RTTNoLoad returns the current RTT No Load value.
 func (l *VegasLimit) RTTNoLoad() int64 {
 	l.mu.RLock()
@@ -296,6 +548,22 @@ func (l *VegasLimit) RTTNoLoad() int64 {
 	return int64(l.rttNoLoad.Get())
 }
 
+// Snapshot returns the current state of the limit.
+func (l *VegasLimit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return core.Snapshot{
+		Type:  "VegasLimit",
+		Limit: int(l.estimatedLimit),
+		Attributes: map[string]interface{}{
+			"rttNoLoad":  int64(l.rttNoLoad.Get()),
+			"maxLimit":   l.maxLimit,
+			"smoothing":  l.smoothing,
+			"probeCount": l.probeCount,
+		},
+	}
+}
+
 func (l *VegasLimit) String() string {
 	return fmt.Sprintf("VegasLimit{limit=%d, rttNoLoad=%d ms}",
 		l.EstimatedLimit(), l.RTTNoLoad())
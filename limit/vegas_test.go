@@ -148,4 +148,111 @@ func TestVegasLimit(t *testing.T) {
 		l.OnSample(20, (time.Millisecond * 20).Nanoseconds(), 100, false)
 		asrt.Equal(25, l.EstimatedLimit())
 	})
+
+	t.Run("SetRandom", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l1 := createVegasLimit()
+		l1.SetRandom(core.NewSeededRandomSource(42))
+		l2 := createVegasLimit()
+		l2.SetRandom(core.NewSeededRandomSource(42))
+		asrt.Equal(l1.probeJitter, l2.probeJitter)
+		asrt.True(l1.probeJitter >= 0.5 && l1.probeJitter < 1.0)
+
+		// the same seed probes after the same number of samples
+		for i := 0; i < 1000; i++ {
+			l1.OnSample(int64(i), (time.Millisecond * 10).Nanoseconds(), 1, false)
+			l2.OnSample(int64(i), (time.Millisecond * 10).Nanoseconds(), 1, false)
+			asrt.Equal(l1.probeCount, l2.probeCount)
+		}
+	})
+
+	t.Run("Update", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createVegasLimit()
+		listener := testNotifyListener{}
+		l.NotifyOnChange(listener.updater())
+		l.OnSample(0, (time.Millisecond * 10).Nanoseconds(), 10, false)
+		l.OnSample(10, (time.Millisecond * 10).Nanoseconds(), 11, false)
+		asrt.Equal(16, l.EstimatedLimit())
+
+		// invalid updates apply nothing
+		maxLimit := 0
+		smoothing := 0.5
+		asrt.Error(l.Update(VegasLimitUpdate{MaxLimit: &maxLimit, Smoothing: &smoothing}))
+		asrt.Equal(1.0, l.smoothing)
+		// updates are validated like the config
+		zeroSmoothing := 0.0
+		asrt.EqualError(l.Update(VegasLimitUpdate{Smoothing: &zeroSmoothing}),
+			"invalid VegasLimit update: smoothing must be in (0, 1], got 0")
+		asrt.Equal(1.0, l.smoothing)
+
+		// lowering the max clamps the limit but keeps the RTT baseline
+		maxLimit = 12
+		asrt.NoError(l.Update(VegasLimitUpdate{MaxLimit: &maxLimit, Smoothing: &smoothing}))
+		asrt.Equal(12, l.EstimatedLimit())
+		asrt.Equal(12, listener.changes[len(listener.changes)-1])
+		asrt.Equal((time.Millisecond * 10).Nanoseconds(), l.RTTNoLoad())
+		asrt.Equal(0.5, l.smoothing)
+
+		// raising the max leaves the limit alone
+		maxLimit = 100
+		asrt.NoError(l.Update(VegasLimitUpdate{MaxLimit: &maxLimit}))
+		asrt.Equal(12, l.EstimatedLimit())
+	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := createVegasLimit()
+		l.OnSample(0, (time.Millisecond * 10).Nanoseconds(), 10, false)
+		l.OnSample(10, (time.Millisecond * 10).Nanoseconds(), 11, false)
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal(core.LimitState{
+			Version: core.LimitStateVersion,
+			Type:    "VegasLimit",
+			Limit:   16,
+			Values:  map[string]float64{"rttNoLoad": float64((time.Millisecond * 10).Nanoseconds())},
+		}, state)
+
+		restored := createVegasLimit()
+		listener := testNotifyListener{}
+		restored.NotifyOnChange(listener.updater())
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(16, restored.EstimatedLimit())
+		asrt.Equal([]int{16}, listener.changes)
+		asrt.Equal((time.Millisecond * 10).Nanoseconds(), restored.RTTNoLoad())
+
+		// the limit is clamped to the max limit
+		state.Limit = 500
+		asrt.NoError(restored.ImportState(state))
+		asrt.Equal(20, restored.EstimatedLimit())
+
+		state.Type = "GradientLimit"
+		asrt.Error(restored.ImportState(state))
+		state.Type = "VegasLimit"
+		state.Version = core.LimitStateVersion + 1
+		asrt.Error(restored.ImportState(state))
+	})
+
+	t.Run("FromConfig", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewVegasLimitFromConfig("test", VegasLimitConfig{MaxLimit: 100, Smoothing: 0.5})
+		asrt.NoError(err)
+		asrt.Equal(20, l.EstimatedLimit())
+		config := l.Config()
+		asrt.Equal(20, config.InitialLimit)
+		asrt.Equal(100, config.MaxLimit)
+		asrt.Equal(0.5, config.Smoothing)
+		asrt.Equal(30, config.ProbeMultiplier)
+		asrt.NotNil(config.AlphaFunc)
+
+		// every invalid field is reported
+		_, err = NewVegasLimitFromConfig("test", VegasLimitConfig{InitialLimit: 2000, Smoothing: 1.5, ProbeMultiplier: -2})
+		asrt.EqualError(err, "invalid VegasLimit config: initialLimit 2000 must be within [minLimit 1, maxLimit 1000]; "+
+			"smoothing must be in (0, 1], got 1.5; probeMultiplier must be >= 1, got -2")
+	})
 }
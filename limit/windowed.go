@@ -22,6 +22,7 @@ type WindowedLimit struct {
 	sample        *measurements.ImmutableSampleWindow
 	registry      core.MetricRegistry
 	commonSampler *core.CommonMetricSampler
+	tags          []string
 
 	mu sync.RWMutex
 }
@@ -93,12 +94,107 @@ func NewWindowedLimit(
 		delegate: delegate,
 		sample:   measurements.NewDefaultImmutableSampleWindow(),
 		registry: registry,
+		tags:     tags,
 	}
 	l.commonSampler = core.NewCommonMetricSamplerOrNil(registry, l, name, tags...)
 	return l, nil
 
 }
 
+// WindowedLimitConfig configures a WindowedLimit created with NewWindowedLimitFromConfig.  The zero value of every
+// optional field selects its default, any other invalid value is an error.
+type WindowedLimitConfig struct {
+	// Delegate is the wrapped limit, it is required.
+	Delegate core.Limit `json:"-"`
+	// MinWindowTime is the minimum window duration for sampling a new minimum RTT, at least 100ms, default 1s.
+	MinWindowTime time.Duration `json:"minWindowTime"`
+	// MaxWindowTime is the maximum window duration for sampling a new minimum RTT, at least 100ms, default 1s.
+	MaxWindowTime time.Duration `json:"maxWindowTime"`
+	// WindowSize is the minimum number of samples of a window, at least 10, default 10.
+	WindowSize int `json:"windowSize"`
+	// MinRTTThreshold is the RTT below which samples are ignored, default 100ms.
+	MinRTTThreshold time.Duration `json:"minRTTThreshold"`
+
+	MetricRegistry core.MetricRegistry `json:"-"`
+	Tags           []string            `json:"tags,omitempty"`
+}
+
+// ApplyDefaults will set the default of every optional field with a zero value.
+func (c *WindowedLimitConfig) ApplyDefaults() {
+	if c.MinWindowTime == 0 {
+		c.MinWindowTime = defaultWindowedMinWindowTime
+	}
+	if c.MaxWindowTime == 0 {
+		c.MaxWindowTime = defaultWindowedMaxWindowTime
+	}
+	if c.WindowSize == 0 {
+		c.WindowSize = defaultWindowedWindowSize
+	}
+	if c.MinRTTThreshold == 0 {
+		c.MinRTTThreshold = defaultWindowedMinRTTThreshold
+	}
+	if c.MetricRegistry == nil {
+		c.MetricRegistry = core.EmptyMetricRegistryInstance
+	}
+}
+
+// Validate will return an error describing every invalid field.
+func (c *WindowedLimitConfig) Validate() error {
+	v := &configValidator{limitType: "WindowedLimit"}
+	if c.Delegate == nil {
+		v.errorf("delegate must be specified")
+	}
+	if c.MinWindowTime < 100*time.Millisecond {
+		v.errorf("minWindowTime must be >= 100ms, got %s", c.MinWindowTime)
+	}
+	if c.MaxWindowTime < 100*time.Millisecond {
+		v.errorf("maxWindowTime must be >= 100ms, got %s", c.MaxWindowTime)
+	} else if c.MaxWindowTime < c.MinWindowTime {
+		v.errorf("maxWindowTime %s must be >= minWindowTime %s", c.MaxWindowTime, c.MinWindowTime)
+	}
+	if c.WindowSize < 10 {
+		v.errorf("windowSize must be >= 10, got %d", c.WindowSize)
+	}
+	if c.MinRTTThreshold < 0 {
+		v.errorf("minRTTThreshold must be >= 0, got %s", c.MinRTTThreshold)
+	}
+	return v.err()
+}
+
+// NewWindowedLimitFromConfig will create a new WindowedLimit, returning an error if any field of the config is
+// invalid.  The resolved config is available from WindowedLimit.Config.
+func NewWindowedLimitFromConfig(name string, config WindowedLimitConfig) (*WindowedLimit, error) {
+	config.ApplyDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return NewWindowedLimit(
+		name,
+		config.MinWindowTime.Nanoseconds(),
+		config.MaxWindowTime.Nanoseconds(),
+		int32(config.WindowSize),
+		config.MinRTTThreshold.Nanoseconds(),
+		config.Delegate,
+		config.MetricRegistry,
+		config.Tags...,
+	)
+}
+
+// Config returns the configuration of the limit with every default resolved.
+func (l *WindowedLimit) Config() WindowedLimitConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return WindowedLimitConfig{
+		Delegate:        l.delegate,
+		MinWindowTime:   time.Duration(l.minWindowTime),
+		MaxWindowTime:   time.Duration(l.maxWindowTime),
+		WindowSize:      int(l.windowSize),
+		MinRTTThreshold: time.Duration(l.minRTTThreshold),
+		MetricRegistry:  l.registry,
+		Tags:            l.tags,
+	}
+}
+
 // EstimatedLimit returns the current estimated limit.
 func (l *WindowedLimit) EstimatedLimit() int {
 	l.mu.RLock()
@@ -137,6 +233,41 @@ func (l *WindowedLimit) OnSample(startTime int64, rtt int64, inFlight int, didDr
 	}
 }
 
+// ExportState returns the state of the delegate limit, or core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *WindowedLimit) ExportState() (core.LimitState, error) {
+	if stateful, ok := l.delegate.(core.StatefulLimit); ok {
+		return stateful.ExportState()
+	}
+	return core.LimitState{}, core.ErrStateNotSupported
+}
+
+// ImportState will restore the state of the delegate limit, or return core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *WindowedLimit) ImportState(state core.LimitState) error {
+	if stateful, ok := l.delegate.(core.StatefulLimit); ok {
+		return stateful.ImportState(state)
+	}
+	return core.ErrStateNotSupported
+}
+
+// Snapshot returns the current state of the limit including the snapshot of its delegate.
+func (l *WindowedLimit) Snapshot() core.Snapshot {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	rttCandidate := l.sample.CandidateRTTNanoseconds()
+	if rttCandidate == math.MaxInt64 {
+		rttCandidate = 0
+	}
+	return core.Snapshot{
+		Type:                    "WindowedLimit",
+		Limit:                   l.delegate.EstimatedLimit(),
+		InFlight:                l.sample.MaxInFlight(),
+		CandidateRTTNanoseconds: rttCandidate,
+		Delegate:                core.SnapshotOf(l.delegate),
+	}
+}
+
 func (l *WindowedLimit) String() string {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
 )
 
 func TestWindowedLimit(t *testing.T) {
@@ -78,4 +80,44 @@ func TestWindowedLimit(t *testing.T) {
 		asrt.Equal("WindowedLimit{minWindowTime=100000000, maxWindowTime=200000000, minRTTThreshold=10, "+
 			"windowSize=10, delegate=SettableLimit{limit=10}", l.String())
 	})
+
+	t.Run("State", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		state := core.LimitState{Version: core.LimitStateVersion, Type: "AIMDLimit", Limit: 5}
+		l := NewDefaultWindowedLimit("test", NewAIMDLimit("test", 10, 0.5, 1, nil), nil)
+		asrt.NoError(l.ImportState(state))
+		asrt.Equal(5, l.EstimatedLimit())
+		exported, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal(state, exported)
+
+		unsupported := NewDefaultWindowedLimit("test", NewSettableLimit("test", 10, nil), nil)
+		_, err = unsupported.ExportState()
+		asrt.Equal(core.ErrStateNotSupported, err)
+		asrt.Equal(core.ErrStateNotSupported, unsupported.ImportState(state))
+	})
+
+	t.Run("FromConfig", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		delegate := NewSettableLimit("test", 10, nil)
+		l, err := NewWindowedLimitFromConfig("test", WindowedLimitConfig{Delegate: delegate, WindowSize: 20})
+		asrt.NoError(err)
+		asrt.Equal(10, l.EstimatedLimit())
+		config := l.Config()
+		asrt.Equal(time.Second, config.MinWindowTime)
+		asrt.Equal(time.Second, config.MaxWindowTime)
+		asrt.Equal(20, config.WindowSize)
+		asrt.Equal(100*time.Millisecond, config.MinRTTThreshold)
+
+		_, err = NewWindowedLimitFromConfig("test", WindowedLimitConfig{
+			MinWindowTime: 2 * time.Second,
+			WindowSize:    5,
+		})
+		asrt.EqualError(err, "invalid WindowedLimit config: delegate must be specified; "+
+			"maxWindowTime 1s must be >= minWindowTime 2s; windowSize must be >= 10, got 5")
+		_, err = NewWindowedLimitFromConfig("test", WindowedLimitConfig{Delegate: delegate, MinWindowTime: time.Millisecond})
+		asrt.EqualError(err, "invalid WindowedLimit config: minWindowTime must be >= 100ms, got 1ms")
+	})
 }
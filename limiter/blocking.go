@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
@@ -20,10 +21,16 @@ import (
 type BlockingLimiter struct {
 	logger   limit.Logger
 	delegate core.Limiter
+	clock    core.Clock
 	timeout  time.Duration
 
 	mu     sync.Mutex
 	notify chan struct{} // closed (and replaced) whenever a token is released
+
+	waiting   int64
+	rejected  uint64
+	observers observers
+	lifecycle lifecycle
 }
 
 // NewBlockingLimiter will create a new blocking limiter
@@ -41,6 +48,7 @@ func NewBlockingLimiter(
 	return &BlockingLimiter{
 		logger:   logger,
 		delegate: delegate,
+		clock:    core.SystemClockInstance,
 		timeout:  timeout,
 		notify:   make(chan struct{}),
 	}
@@ -55,6 +63,12 @@ func (l *BlockingLimiter) currentNotify() <-chan struct{} {
 	return l.notify
 }
 
+// release ends the outstanding acquisition and wakes the waiters.
+func (l *BlockingLimiter) release() {
+	l.lifecycle.end()
+	l.broadcastRelease()
+}
+
 // broadcastRelease closes the current notify channel (waking all waiters) and
 // installs a fresh one for the next round of waiters.
 func (l *BlockingLimiter) broadcastRelease() {
@@ -66,12 +80,24 @@ func (l *BlockingLimiter) broadcastRelease() {
 }
 
 // tryAcquire will block when attempting to acquire a token
-func (l *BlockingLimiter) tryAcquire(ctx context.Context) (core.Listener, bool) {
+func (l *BlockingLimiter) tryAcquire(ctx context.Context, weight int) (core.Listener, core.RejectReason) {
+	waiting := false
+	var waitStart time.Time
+	defer func() {
+		if waiting {
+			atomic.AddInt64(&l.waiting, -1)
+			l.observers.dequeued(ctx, l.clock.Now().Sub(waitStart))
+		}
+	}()
 	for {
+		if l.lifecycle.isClosed() {
+			return nil, core.RejectReasonClosed
+		}
+
 		// if the context has already been cancelled, fail quickly
 		if err := ctx.Err(); err != nil {
 			l.logger.Debugf("context cancelled ctx=%v", ctx)
-			return nil, false
+			return nil, core.RejectReasonContextDone
 		}
 
 		// Capture the notify channel BEFORE trying to acquire.
@@ -80,33 +106,48 @@ func (l *BlockingLimiter) tryAcquire(ctx context.Context) (core.Listener, bool)
 		notify := l.currentNotify()
 
 		// try to acquire a new token and return immediately if successful
-		listener, ok := l.delegate.Acquire(ctx)
-		if ok && listener != nil {
+		listener, reason := acquireN(ctx, l.delegate, weight)
+		if listener != nil {
 			l.logger.Debugf("delegate returned a listener ctx=%v", ctx)
-			return listener, true
+			return listener, ""
+		}
+		if reason == core.RejectReasonUnsupported {
+			// no release will allow the delegate to acquire the weight
+			return nil, reason
 		}
 
 		// We have reached the limit so block until:
 		// - A token is released (notify channel is closed)
 		// - A timeout
 		// - The context is cancelled
+		// - The limiter is closed
 		l.logger.Debugf("Blocking waiting for release or timeout ctx=%v", ctx)
+		if !waiting {
+			waiting = true
+			waitStart = l.clock.Now()
+			l.observers.queued(ctx, int(atomic.AddInt64(&l.waiting, 1)))
+		}
 		if l.timeout > 0 {
-			timer := time.NewTimer(l.timeout)
+			timer := l.clock.NewTimer(l.timeout)
 			select {
 			case <-ctx.Done():
 				timer.Stop()
-				return nil, false
+				return nil, core.RejectReasonContextDone
+			case <-l.lifecycle.done():
+				timer.Stop()
+				return nil, core.RejectReasonClosed
 			case <-notify:
 				timer.Stop()
 				// token was released, loop and retry
-			case <-timer.C:
+			case <-timer.C():
 				// per-attempt timeout: loop back and check context before retrying
 			}
 		} else {
 			select {
 			case <-ctx.Done():
-				return nil, false
+				return nil, core.RejectReasonContextDone
+			case <-l.lifecycle.done():
+				return nil, core.RejectReasonClosed
 			case <-notify:
 				// token was released, loop and retry
 			}
@@ -121,18 +162,96 @@ func (l *BlockingLimiter) tryAcquire(ctx context.Context) (core.Listener, bool)
 //
 // context Context for the request. The context is used by advanced strategies such as LookupPartitionStrategy.
 func (l *BlockingLimiter) Acquire(ctx context.Context) (core.Listener, bool) {
-	delegateListener, ok := l.tryAcquire(ctx)
-	if !ok && delegateListener == nil {
+	return l.AcquireN(ctx, 1)
+}
+
+// AcquireN a token worth weight units of concurrency from the limiter, blocking while the delegate does not have
+// enough capacity.  The delegate must implement core.WeightedLimiter to support a weight other than 1, otherwise
+// the acquisition is rejected immediately with core.RejectReasonUnsupported.
+func (l *BlockingLimiter) AcquireN(ctx context.Context, weight int) (core.Listener, bool) {
+	listener, rejection := l.acquireN(ctx, weight)
+	return listener, rejection == nil
+}
+
+// AcquireWithReason will acquire a token like Acquire, returning a *core.RejectionError when it is rejected.
+func (l *BlockingLimiter) AcquireWithReason(ctx context.Context) (core.Listener, error) {
+	return l.AcquireNWithReason(ctx, 1)
+}
+
+// AcquireNWithReason will acquire a token like AcquireN, returning a *core.RejectionError when it is rejected.
+func (l *BlockingLimiter) AcquireNWithReason(ctx context.Context, weight int) (core.Listener, error) {
+	listener, rejection := l.acquireN(ctx, weight)
+	if rejection != nil {
+		return nil, rejection
+	}
+	return listener, nil
+}
+
+func (l *BlockingLimiter) acquireN(ctx context.Context, weight int) (core.Listener, *core.RejectionError) {
+	l.observers.acquireAttempt(ctx)
+	if !l.lifecycle.begin() {
+		atomic.AddUint64(&l.rejected, 1)
+		l.observers.rejected(ctx, core.RejectReasonClosed)
+		return nil, core.NewRejectionError(core.RejectReasonClosed)
+	}
+	delegateListener, reason := l.tryAcquire(ctx, weight)
+	if delegateListener == nil {
+		l.lifecycle.end()
 		l.logger.Debugf("did not acquire ctx=%v", ctx)
-		return nil, false
+		atomic.AddUint64(&l.rejected, 1)
+		l.observers.rejected(ctx, reason)
+		return nil, core.NewRejectionError(reason)
 	}
 	l.logger.Debugf("acquired, returning listener ctx=%v", ctx)
+	l.observers.acquired(ctx, listenerInFlight(delegateListener))
 	return &DelegateListener{
 		delegateListener: delegateListener,
-		onRelease:        l.broadcastRelease,
-	}, true
+		onRelease:        l.observers.releaseObserver(ctx, l.clock, l.release),
+	}, nil
+}
+
+// Close the limiter so that every new acquisition is rejected immediately and every blocked caller is woken and
+// rejected.  Tokens that were already acquired are unaffected.
+func (l *BlockingLimiter) Close() {
+	l.lifecycle.close()
+}
+
+// Drain will Close the limiter and then block until every outstanding listener has been released or ctx is done.
+func (l *BlockingLimiter) Drain(ctx context.Context) error {
+	return l.lifecycle.drain(ctx)
+}
+
+// SetClock will replace the clock used for waiting, i.e. with a clock.FakeClock in tests.  It must be called before
+// the limiter is used.
+func (l *BlockingLimiter) SetClock(clock core.Clock) {
+	if clock == nil {
+		clock = core.SystemClockInstance
+	}
+	l.clock = clock
+}
+
+// RetryAfter estimates how long a rejected caller should wait, that is the retry delay of the delegate for every
+// caller already waiting plus one.  Returns 0 if the delegate has no estimate.
+func (l *BlockingLimiter) RetryAfter() time.Duration {
+	return core.RetryAfter(l.delegate) * time.Duration(atomic.LoadInt64(&l.waiting)+1)
+}
+
+// AddObserver will register an observer to receive the lifecycle callbacks of every request.
+func (l *BlockingLimiter) AddObserver(observer core.LimiterObserver) {
+	l.observers.add(observer)
 }
 
 func (l *BlockingLimiter) String() string {
 	return fmt.Sprintf("BlockingLimiter{delegate=%v}", l.delegate)
 }
+
+// Snapshot returns the current state of the limiter including the snapshot of its delegate.
+func (l *BlockingLimiter) Snapshot() core.Snapshot {
+	return wrapperSnapshot("BlockingLimiter", l.delegate, core.Snapshot{
+		QueueDepth: int(atomic.LoadInt64(&l.waiting)),
+		Rejected:   atomic.LoadUint64(&l.rejected),
+		Attributes: map[string]interface{}{
+			"timeout": l.timeout,
+		},
+	})
+}
@@ -267,5 +267,20 @@ func TestBlockingLimiter(t *testing.T) {
 		// we only expect half of them to complete before their deadlines
 		asrt.InDelta(4, sumReleased, 1.0, "expected roughly half to succeed")
 	})
-}
 
+	t.Run("AcquireNUnweightedDelegate", func(t2 *testing.T) {
+		asrt := assert.New(t2)
+		// without a timeout or deadline the caller would wait forever for a release that cannot help
+		for _, delegate := range []core.Limiter{
+			&unweightedLimiter{delegate: newObserverTestLimiter(10)},
+			newUnweightedStrategyLimiter(10),
+		} {
+			blockingLimiter := NewBlockingLimiter(delegate, 0, limit.NoopLimitLogger{})
+			_, err := blockingLimiter.AcquireNWithReason(context.Background(), 2)
+			asrt.ErrorIs(err, core.ErrUnsupported)
+			listener, err := blockingLimiter.AcquireNWithReason(context.Background(), 1)
+			asrt.NoError(err)
+			listener.OnSuccess()
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package limiter
+
+import (
+	"math"
+	"time"
+)
+
+// codel implements the drop decision of CoDel (controlled delay, RFC 8289) for a backlog.  The sojourn time is the
+// time the oldest waiter has been queued, which for a FIFO backlog is the waiter about to be dequeued.  Once the
+// sojourn time stays above target for a whole interval there is a standing queue and codel starts dropping, at a rate
+// that grows with the square root of the number of drops until the sojourn time falls below target again.
+type codel struct {
+	target   time.Duration
+	interval time.Duration
+
+	firstAboveTime time.Time
+	dropNext       time.Time
+	count          int
+	lastCount      int
+	dropping       bool
+}
+
+// okToDrop reports whether the sojourn time has been above target for at least an interval.
+func (c *codel) okToDrop(now time.Time, sojourn time.Duration) bool {
+	if sojourn < c.target {
+		c.firstAboveTime = time.Time{}
+		return false
+	}
+	if c.firstAboveTime.IsZero() {
+		c.firstAboveTime = now.Add(c.interval)
+		return false
+	}
+	return !now.Before(c.firstAboveTime)
+}
+
+// shouldDrop is called with the sojourn time of the oldest waiter every time the backlog is about to be dequeued and
+// reports whether that waiter should be dropped.  It is called again with the next oldest waiter after every drop.
+func (c *codel) shouldDrop(now time.Time, sojourn time.Duration) bool {
+	okToDrop := c.okToDrop(now, sojourn)
+	if c.dropping {
+		if !okToDrop {
+			c.dropping = false
+			return false
+		}
+		if now.Before(c.dropNext) {
+			return false
+		}
+		c.count++
+		c.dropNext = c.controlLaw(c.dropNext)
+		return true
+	}
+	if !okToDrop {
+		return false
+	}
+
+	c.dropping = true
+	// resume near the previous drop rate if the standing queue came back shortly after the last dropping state
+	delta := c.count - c.lastCount
+	if delta > 1 && now.Sub(c.dropNext) < 16*c.interval {
+		c.count = delta
+	} else {
+		c.count = 1
+	}
+	c.dropNext = c.controlLaw(now)
+	c.lastCount = c.count
+	return true
+}
+
+// empty is called when the backlog is dequeued while empty, which ends any standing queue.
+func (c *codel) empty() {
+	c.firstAboveTime = time.Time{}
+	c.dropping = false
+}
+
+func (c *codel) controlLaw(t time.Time) time.Time {
+	return t.Add(time.Duration(float64(c.interval) / math.Sqrt(float64(c.count))))
+}
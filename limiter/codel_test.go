@@ -0,0 +1,63 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoDel(t *testing.T) {
+	t.Parallel()
+	at := func(d time.Duration) time.Time {
+		return time.Unix(0, 0).Add(d)
+	}
+
+	t.Run("BelowTarget", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		c := &codel{target: 5 * time.Millisecond, interval: 100 * time.Millisecond}
+		for i := 0; i < 100; i++ {
+			asrt.False(c.shouldDrop(at(time.Duration(i)*10*time.Millisecond), 4*time.Millisecond))
+		}
+	})
+
+	t.Run("DropsAtIncreasingRate", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		c := &codel{target: 5 * time.Millisecond, interval: 100 * time.Millisecond}
+
+		// a standing queue must persist for an interval
+		asrt.False(c.shouldDrop(at(0), 10*time.Millisecond))
+		asrt.False(c.shouldDrop(at(99*time.Millisecond), 10*time.Millisecond))
+		asrt.True(c.shouldDrop(at(100*time.Millisecond), 10*time.Millisecond))
+		asrt.Equal(at(200*time.Millisecond), c.dropNext)
+
+		asrt.False(c.shouldDrop(at(150*time.Millisecond), 10*time.Millisecond))
+		asrt.True(c.shouldDrop(at(200*time.Millisecond), 10*time.Millisecond))
+		asrt.Equal(2, c.count)
+		asrt.Equal(at(200*time.Millisecond+70710678), c.dropNext)
+
+		// the queue is drained
+		asrt.False(c.shouldDrop(at(300*time.Millisecond), 4*time.Millisecond))
+		asrt.False(c.dropping)
+	})
+
+	t.Run("ResumesNearThePreviousRate", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		c := &codel{target: 5 * time.Millisecond, interval: 100 * time.Millisecond}
+		c.shouldDrop(at(0), 10*time.Millisecond)
+		now := 100 * time.Millisecond
+		for i := 0; i < 4; i++ {
+			asrt.True(c.shouldDrop(at(now), 10*time.Millisecond))
+			now = c.dropNext.Sub(at(0))
+		}
+		asrt.Equal(4, c.count)
+		c.empty()
+
+		asrt.False(c.shouldDrop(at(now), 10*time.Millisecond))
+		asrt.True(c.shouldDrop(at(now+100*time.Millisecond), 10*time.Millisecond))
+		asrt.Equal(3, c.count)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
@@ -15,10 +16,16 @@ import (
 type DeadlineLimiter struct {
 	logger   limit.Logger
 	delegate core.Limiter
+	clock    core.Clock
 	deadline time.Time
 
 	mu     sync.Mutex
 	notify chan struct{} // closed (and replaced) whenever a token is released
+
+	waiting   int64
+	rejected  uint64
+	observers observers
+	lifecycle lifecycle
 }
 
 // NewDeadlineLimiter will create a new DeadlineLimiter that will wrap a limiter such that acquire will block until a
@@ -34,6 +41,7 @@ func NewDeadlineLimiter(
 	return &DeadlineLimiter{
 		logger:   logger,
 		delegate: delegate,
+		clock:    core.SystemClockInstance,
 		deadline: deadline,
 		notify:   make(chan struct{}),
 	}
@@ -46,6 +54,12 @@ func (l *DeadlineLimiter) currentNotify() <-chan struct{} {
 	return l.notify
 }
 
+// release ends the outstanding acquisition and wakes the waiters.
+func (l *DeadlineLimiter) release() {
+	l.lifecycle.end()
+	l.broadcastRelease()
+}
+
 // broadcastRelease closes the current notify channel (waking all waiters) and
 // installs a fresh one for the next round of waiters.
 func (l *DeadlineLimiter) broadcastRelease() {
@@ -57,18 +71,30 @@ func (l *DeadlineLimiter) broadcastRelease() {
 }
 
 // tryAcquire will block when attempting to acquire a token
-func (l *DeadlineLimiter) tryAcquire(ctx context.Context) (listener core.Listener, ok bool) {
+func (l *DeadlineLimiter) tryAcquire(ctx context.Context, weight int) (core.Listener, core.RejectReason) {
+	waiting := false
+	var waitStart time.Time
+	defer func() {
+		if waiting {
+			atomic.AddInt64(&l.waiting, -1)
+			l.observers.dequeued(ctx, l.clock.Now().Sub(waitStart))
+		}
+	}()
 	for {
+		if l.lifecycle.isClosed() {
+			return nil, core.RejectReasonClosed
+		}
+
 		// if the context has already been cancelled, fail quickly
 		if err := ctx.Err(); err != nil {
 			l.logger.Debugf("context cancelled ctx=%v", ctx)
-			return nil, false
+			return nil, core.RejectReasonContextDone
 		}
 
 		// if the deadline has passed, fail quickly
-		remaining := time.Until(l.deadline)
+		remaining := l.deadline.Sub(l.clock.Now())
 		if remaining <= 0 {
-			return nil, false
+			return nil, core.RejectReasonDeadlineExceeded
 		}
 
 		// Capture the notify channel BEFORE trying to acquire so a release
@@ -76,31 +102,44 @@ func (l *DeadlineLimiter) tryAcquire(ctx context.Context) (listener core.Listene
 		notify := l.currentNotify()
 
 		// try to acquire a new token and return immediately if successful
-		listener, ok := l.delegate.Acquire(ctx)
-		if ok && listener != nil {
+		listener, reason := acquireN(ctx, l.delegate, weight)
+		if listener != nil {
 			l.logger.Debugf("delegate returned a listener ctx=%v", ctx)
-			return listener, true
+			return listener, ""
+		}
+		if reason == core.RejectReasonUnsupported {
+			// no release will allow the delegate to acquire the weight
+			return nil, reason
 		}
 
 		// We have reached the limit so block until:
 		// - A token is released (notify channel is closed)
 		// - The deadline passes
 		// - The context is cancelled
+		// - The limiter is closed
 		l.logger.Debugf("Blocking waiting for release or timeout ctx=%v", ctx)
-		remaining = time.Until(l.deadline)
+		if !waiting {
+			waiting = true
+			waitStart = l.clock.Now()
+			l.observers.queued(ctx, int(atomic.AddInt64(&l.waiting, 1)))
+		}
+		remaining = l.deadline.Sub(l.clock.Now())
 		if remaining <= 0 {
-			return nil, false
+			return nil, core.RejectReasonDeadlineExceeded
 		}
-		timer := time.NewTimer(remaining)
+		timer := l.clock.NewTimer(remaining)
 		select {
 		case <-ctx.Done():
 			timer.Stop()
-			return nil, false
+			return nil, core.RejectReasonContextDone
+		case <-l.lifecycle.done():
+			timer.Stop()
+			return nil, core.RejectReasonClosed
 		case <-notify:
 			timer.Stop()
 			// token was released, loop and retry
-		case <-timer.C:
-			return nil, false
+		case <-timer.C():
+			return nil, core.RejectReasonDeadlineExceeded
 		}
 		l.logger.Debugf("blocking released, trying again to acquire ctx=%v", ctx)
 	}
@@ -112,19 +151,100 @@ func (l *DeadlineLimiter) tryAcquire(ctx context.Context) (listener core.Listene
 //
 // context Context for the request. The context is used by advanced strategies such as LookupPartitionStrategy.
 func (l *DeadlineLimiter) Acquire(ctx context.Context) (listener core.Listener, ok bool) {
-	delegateListener, ok := l.tryAcquire(ctx)
-	if !ok && delegateListener == nil {
+	return l.AcquireN(ctx, 1)
+}
+
+// AcquireN a token worth weight units of concurrency from the limiter, blocking while the delegate does not have
+// enough capacity.  The delegate must implement core.WeightedLimiter to support a weight other than 1, otherwise
+// the acquisition is rejected immediately with core.RejectReasonUnsupported.
+func (l *DeadlineLimiter) AcquireN(ctx context.Context, weight int) (core.Listener, bool) {
+	listener, rejection := l.acquireN(ctx, weight)
+	return listener, rejection == nil
+}
+
+// AcquireWithReason will acquire a token like Acquire, returning a *core.RejectionError when it is rejected.
+func (l *DeadlineLimiter) AcquireWithReason(ctx context.Context) (core.Listener, error) {
+	return l.AcquireNWithReason(ctx, 1)
+}
+
+// AcquireNWithReason will acquire a token like AcquireN, returning a *core.RejectionError when it is rejected.
+func (l *DeadlineLimiter) AcquireNWithReason(ctx context.Context, weight int) (core.Listener, error) {
+	listener, rejection := l.acquireN(ctx, weight)
+	if rejection != nil {
+		return nil, rejection
+	}
+	return listener, nil
+}
+
+func (l *DeadlineLimiter) acquireN(ctx context.Context, weight int) (core.Listener, *core.RejectionError) {
+	l.observers.acquireAttempt(ctx)
+	if !l.lifecycle.begin() {
+		atomic.AddUint64(&l.rejected, 1)
+		l.observers.rejected(ctx, core.RejectReasonClosed)
+		return nil, core.NewRejectionError(core.RejectReasonClosed)
+	}
+	delegateListener, reason := l.tryAcquire(ctx, weight)
+	if delegateListener == nil {
+		l.lifecycle.end()
 		l.logger.Debugf("did not acquire ctx=%v", ctx)
-		return nil, false
+		atomic.AddUint64(&l.rejected, 1)
+		l.observers.rejected(ctx, reason)
+		return nil, core.NewRejectionError(reason)
 	}
 	l.logger.Debugf("acquired, returning listener ctx=%v", ctx)
+	l.observers.acquired(ctx, listenerInFlight(delegateListener))
 	return &DelegateListener{
 		delegateListener: delegateListener,
-		onRelease:        l.broadcastRelease,
-	}, true
+		onRelease:        l.observers.releaseObserver(ctx, l.clock, l.release),
+	}, nil
+}
+
+// Close the limiter so that every new acquisition is rejected immediately and every blocked caller is woken and
+// rejected.  Tokens that were already acquired are unaffected.
+func (l *DeadlineLimiter) Close() {
+	l.lifecycle.close()
+}
+
+// Drain will Close the limiter and then block until every outstanding listener has been released or ctx is done.
+func (l *DeadlineLimiter) Drain(ctx context.Context) error {
+	return l.lifecycle.drain(ctx)
+}
+
+// SetClock will replace the clock used for waiting, i.e. with a clock.FakeClock in tests.  It must be called before
+// the limiter is used.
+func (l *DeadlineLimiter) SetClock(clock core.Clock) {
+	if clock == nil {
+		clock = core.SystemClockInstance
+	}
+	l.clock = clock
+}
+
+// RetryAfter estimates how long a rejected caller should wait, that is the retry delay of the delegate for every
+// caller already waiting plus one.  Returns 0 once the deadline has passed or if the delegate has no estimate.
+func (l *DeadlineLimiter) RetryAfter() time.Duration {
+	if !l.clock.Now().Before(l.deadline) {
+		return 0
+	}
+	return core.RetryAfter(l.delegate) * time.Duration(atomic.LoadInt64(&l.waiting)+1)
+}
+
+// AddObserver will register an observer to receive the lifecycle callbacks of every request.
+func (l *DeadlineLimiter) AddObserver(observer core.LimiterObserver) {
+	l.observers.add(observer)
 }
 
 // String implements Stringer for easy debugging.
 func (l *DeadlineLimiter) String() string {
 	return fmt.Sprintf("DeadlineLimiter{delegate=%v}", l.delegate)
 }
+
+// Snapshot returns the current state of the limiter including the snapshot of its delegate.
+func (l *DeadlineLimiter) Snapshot() core.Snapshot {
+	return wrapperSnapshot("DeadlineLimiter", l.delegate, core.Snapshot{
+		QueueDepth: int(atomic.LoadInt64(&l.waiting)),
+		Rejected:   atomic.LoadUint64(&l.rejected),
+		Attributes: map[string]interface{}{
+			"deadline": l.deadline,
+		},
+	})
+}
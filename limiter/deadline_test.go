@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/platinummonkey/go-concurrency-limits/clock"
 	"github.com/platinummonkey/go-concurrency-limits/core"
 	"github.com/platinummonkey/go-concurrency-limits/limit"
 	"github.com/platinummonkey/go-concurrency-limits/strategy"
@@ -139,4 +140,59 @@ func TestDeadlineLimiter(t *testing.T) {
 
 		asrt.True(deadlineFound.Load().(bool), "expected deadline limit to be reached but not after 2 attempts")
 	})
+
+	t.Run("FakeClock", func(t2 *testing.T) {
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		deadlineLimiter := NewDeadlineLimiter(newObserverTestLimiter(1), fakeClock.Now().Add(time.Minute), nil)
+		deadlineLimiter.SetClock(fakeClock)
+
+		listener, ok := deadlineLimiter.Acquire(context.Background())
+		asrt.True(ok)
+
+		acquired := make(chan bool)
+		go func() {
+			_, ok := deadlineLimiter.Acquire(context.Background())
+			acquired <- ok
+		}()
+
+		// the waiter blocks on a timer for the remaining minute until the clock is advanced
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(59 * time.Second)
+		asrt.Equal(1, fakeClock.PendingTimers())
+		fakeClock.Advance(time.Second)
+		asrt.False(<-acquired)
+		listener.OnSuccess()
+	})
+
+	t.Run("AcquireWithReason", func(t2 *testing.T) {
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		deadlineLimiter := NewDeadlineLimiter(newObserverTestLimiter(1), fakeClock.Now().Add(time.Minute), nil)
+		deadlineLimiter.SetClock(fakeClock)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := deadlineLimiter.AcquireWithReason(ctx)
+		asrt.ErrorIs(err, core.ErrContextDone)
+
+		fakeClock.Advance(time.Minute)
+		_, err = deadlineLimiter.AcquireWithReason(context.Background())
+		asrt.ErrorIs(err, core.ErrDeadlineExceeded)
+	})
+
+	t.Run("AcquireNUnweightedDelegate", func(t2 *testing.T) {
+		asrt := assert.New(t2)
+		for _, delegate := range []core.Limiter{
+			&unweightedLimiter{delegate: newObserverTestLimiter(10)},
+			newUnweightedStrategyLimiter(10),
+		} {
+			deadlineLimiter := NewDeadlineLimiter(delegate, time.Now().Add(time.Hour), nil)
+			_, err := deadlineLimiter.AcquireNWithReason(context.Background(), 2)
+			asrt.ErrorIs(err, core.ErrUnsupported)
+			listener, err := deadlineLimiter.AcquireNWithReason(context.Background(), 1)
+			asrt.NoError(err)
+			listener.OnSuccess()
+		}
+	})
 }
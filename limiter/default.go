@@ -22,7 +22,9 @@ const (
 
 // DefaultListener for
 type DefaultListener struct {
+	ctx                context.Context
 	currentMaxInFlight int64
+	weight             int64
 	inFlight           *int64
 	token              core.StrategyToken
 	startTime          int64
@@ -34,17 +36,23 @@ type DefaultListener struct {
 // OnSuccess is called as a notification that the operation succeeded and internally measured latency should be
 // used as an RTT sample.
 func (l *DefaultListener) OnSuccess() {
-	atomic.AddInt64(l.inFlight, -1)
+	atomic.AddInt64(l.inFlight, -l.weight)
 	l.token.Release()
-	endTime := time.Now().UnixNano()
+	l.limiter.lifecycle.end()
+	atomic.AddUint64(&l.limiter.released, 1)
+	endTime := l.limiter.clock.Now().UnixNano()
 	rtt := endTime - l.startTime
+	l.limiter.observers.released(l.ctx, core.ReleaseOutcomeSuccess, time.Duration(rtt))
 
 	if rtt < l.minRTTThreshold {
 		return
 	}
+	if l.limiter.rttObserver != nil {
+		l.limiter.rttObserver.ObserveRTT(rtt)
+	}
 	_, current := l.limiter.updateAndGetSample(
 		func(window measurements.ImmutableSampleWindow) measurements.ImmutableSampleWindow {
-			return *(window.AddSample(-1, rtt, int(l.currentMaxInFlight)))
+			return *(window.AddSample(endTime, rtt, int(l.currentMaxInFlight)))
 		},
 	)
 
@@ -54,75 +62,126 @@ func (l *DefaultListener) OnSuccess() {
 // OnIgnore is called to indicate the operation failed before any meaningful RTT measurement could be made and
 // should be ignored to not introduce an artificially low RTT.
 func (l *DefaultListener) OnIgnore() {
-	atomic.AddInt64(l.inFlight, -1)
+	atomic.AddInt64(l.inFlight, -l.weight)
 	l.token.Release()
+	l.limiter.lifecycle.end()
+	atomic.AddUint64(&l.limiter.released, 1)
+	if l.limiter.observers.enabled() {
+		endTime := l.limiter.clock.Now().UnixNano()
+		l.limiter.observers.released(l.ctx, core.ReleaseOutcomeIgnore, time.Duration(endTime-l.startTime))
+	}
 }
 
 // OnDropped is called to indicate the request failed and was dropped due to being rejected by an external limit or
 // hitting a timeout.  Loss based Limit implementations will likely do an aggressive reducing in limit when this
 // happens.
 func (l *DefaultListener) OnDropped() {
-	atomic.AddInt64(l.inFlight, -1)
+	atomic.AddInt64(l.inFlight, -l.weight)
 	l.token.Release()
+	l.limiter.lifecycle.end()
+	atomic.AddUint64(&l.limiter.released, 1)
+	atomic.AddUint64(&l.limiter.dropped, 1)
+	endTime := l.limiter.clock.Now().UnixNano()
+	l.limiter.observers.released(l.ctx, core.ReleaseOutcomeDropped, time.Duration(endTime-l.startTime))
 	_, current := l.limiter.updateAndGetSample(func(window measurements.ImmutableSampleWindow) measurements.ImmutableSampleWindow {
-		return *(window.AddDroppedSample(-1, int(l.currentMaxInFlight)))
+		return *(window.AddDroppedSample(endTime, int(l.currentMaxInFlight)))
 	})
 
-	l.updateLimit(time.Now().UnixNano(), current)
+	l.updateLimit(endTime, current)
+}
+
+func (l *DefaultListener) acquiredInFlight() int {
+	return int(l.currentMaxInFlight)
 }
 
 func (l *DefaultListener) updateLimit(endTime int64, current measurements.ImmutableSampleWindow) {
-	if endTime > l.nextUpdateTime {
-		// double check just to be sure
-		l.limiter.mu.Lock()
-		defer l.limiter.mu.Unlock()
-		if endTime > l.limiter.nextUpdateTime {
-			if l.limiter.isWindowReady(current) {
-				l.limiter.sample = measurements.NewImmutableSampleWindow(
-					-1,
-					0,
-					0,
-					0,
-					0,
-					false,
-				)
-				minWindowTime := current.CandidateRTTNanoseconds() * 2
-				if l.limiter.minWindowTime > minWindowTime {
-					minWindowTime = l.limiter.minWindowTime
-				}
-				minVal := l.limiter.maxWindowTime
-				if minWindowTime < minVal {
-					minVal = minWindowTime
-				}
-				l.limiter.nextUpdateTime = endTime + minVal
-				l.limiter.limit.OnSample(
-					0,
-					current.CandidateRTTNanoseconds(),
-					current.MaxInFlight(),
-					current.DidDrop(),
-				)
-				l.limiter.strategy.SetLimit(l.limiter.limit.EstimatedLimit())
-			}
-		}
+	if endTime <= l.nextUpdateTime || endTime <= atomic.LoadInt64(&l.limiter.nextUpdateTime) {
+		return
+	}
+	if !l.limiter.isWindowReady(current) {
+		return
+	}
+
+	// Only the window rollover is serialized, the listener that wins the lock swaps in a fresh window and feeds the
+	// completed one to the limit algorithm.
+	l.limiter.mu.Lock()
+	defer l.limiter.mu.Unlock()
+	if endTime <= atomic.LoadInt64(&l.limiter.nextUpdateTime) {
+		// another listener already rolled the window over
+		return
+	}
+	// use the latest window so samples added concurrently since `current` was built are not lost
+	completed := l.limiter.sample.Load()
+	if !l.limiter.isWindowReady(*completed) {
+		return
+	}
+	completed = l.limiter.sample.Swap(measurements.NewImmutableSampleWindow(
+		-1,
+		0,
+		0,
+		0,
+		0,
+		false,
+	))
+	minWindowTime := completed.CandidateRTTNanoseconds() * 2
+	if l.limiter.minWindowTime > minWindowTime {
+		minWindowTime = l.limiter.minWindowTime
+	}
+	minVal := l.limiter.maxWindowTime
+	if minWindowTime < minVal {
+		minVal = minWindowTime
+	}
+	atomic.StoreInt64(&l.limiter.nextUpdateTime, endTime+minVal)
+	l.limiter.updateReleaseRate(endTime, completed)
+	if dropRateLimit, ok := l.limiter.limit.(core.DropRateLimit); ok {
+		dropRateLimit.OnDropRate(completed.DropCount(), completed.SampleCount()+completed.DropCount())
 	}
+	l.limiter.limit.OnSample(
+		0,
+		completed.CandidateRTTNanoseconds(),
+		completed.MaxInFlight(),
+		completed.DidDrop(),
+	)
+	l.limiter.strategy.SetLimit(l.limiter.limit.EstimatedLimit())
 }
 
 // DefaultLimiter is a Limiter that combines a plugable limit algorithm and enforcement strategy to enforce concurrency
 // limits to a fixed resource.
+//
+// The acquire and release paths are lock free: the in-flight count and the next update time are atomics and the
+// current sample window is an immutable value behind an atomic pointer that is replaced with compare and swap.  The
+// only lock is taken by the single listener that rolls a completed sample window over into the limit algorithm, so
+// the strategy is relied upon to be safe for concurrent use.
 type DefaultLimiter struct {
 	limit           core.Limit
+	rttObserver     core.RTTObservingLimit // limit when it observes every RTT, otherwise nil
+	idleLimit       core.IdleLimit         // limit when it has an idle policy, otherwise nil
 	strategy        core.Strategy
+	weighted        core.WeightedStrategy // strategy when it supports weights, otherwise nil
 	minWindowTime   int64
 	maxWindowTime   int64
 	windowSize      int
 	minRTTThreshold int64
 	logger          limit.Logger
 	registry        core.MetricRegistry
+	clock           core.Clock
 
-	sample         *measurements.ImmutableSampleWindow
+	sample         atomic.Pointer[measurements.ImmutableSampleWindow]
 	inFlight       *int64
 	nextUpdateTime int64
-	mu             sync.RWMutex
+	nextIdleCheck  int64
+	dropped        uint64
+	rejected       uint64
+	released       uint64
+	mu             sync.Mutex // serializes sample window rollover
+
+	// release rate of the last completed sample window, updated on rollover
+	releaseRate     atomic.Uint64 // float64 bits, releases per second
+	windowRTT       int64         // average RTT of the last completed sample window
+	lastRollover    int64         // guarded by mu
+	releasedAtStart uint64        // guarded by mu
+	observers       observers
+	lifecycle       lifecycle
 }
 
 // NewDefaultLimiterWithDefaults will create a DefaultLimit Limiter with the provided minimum config.
@@ -203,7 +262,7 @@ func NewDefaultLimiter(
 	inFlight := int64(0)
 
 	strategy.SetLimit(limit.EstimatedLimit())
-	return &DefaultLimiter{
+	l := &DefaultLimiter{
 		limit:           limit,
 		strategy:        strategy,
 		minWindowTime:   minWindowTime,
@@ -211,10 +270,16 @@ func NewDefaultLimiter(
 		minRTTThreshold: minRTTThreshold,
 		windowSize:      windowSize,
 		inFlight:        &inFlight,
-		sample:          measurements.NewDefaultImmutableSampleWindow(),
 		logger:          logger,
 		registry:        registry,
-	}, nil
+		clock:           core.SystemClockInstance,
+		lastRollover:    core.SystemClockInstance.Now().UnixNano(),
+	}
+	l.rttObserver, _ = limit.(core.RTTObservingLimit)
+	l.idleLimit, _ = limit.(core.IdleLimit)
+	l.weighted, _ = strategy.(core.WeightedStrategy)
+	l.sample.Store(measurements.NewDefaultImmutableSampleWindow())
+	return l, nil
 }
 
 // Acquire a token from the limiter.  Returns an Optional.empty() if the limit has been exceeded.
@@ -223,38 +288,202 @@ func NewDefaultLimiter(
 //
 // context Context for the request. The context is used by advanced strategies such as LookupPartitionStrategy.
 func (l *DefaultLimiter) Acquire(ctx context.Context) (core.Listener, bool) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	return l.AcquireN(ctx, 1)
+}
+
+// AcquireN a token worth weight units of concurrency from the limiter.  The weighted in-flight count is what is
+// reported to the sample window.  A weight other than 1 requires the strategy to implement core.WeightedStrategy,
+// otherwise the acquisition is rejected with core.RejectReasonUnsupported.
+//
+// context Context for the request. The context is used by advanced strategies such as LookupPartitionStrategy.
+func (l *DefaultLimiter) AcquireN(ctx context.Context, weight int) (core.Listener, bool) {
+	listener, rejection := l.acquireN(ctx, weight)
+	return listener, rejection == nil
+}
+
+// AcquireWithReason will acquire a token like Acquire, returning a *core.RejectionError when it is rejected.  A
+// partitioned strategy reports the partition that exceeded its limit.
+func (l *DefaultLimiter) AcquireWithReason(ctx context.Context) (core.Listener, error) {
+	return l.AcquireNWithReason(ctx, 1)
+}
+
+// AcquireNWithReason will acquire a token like AcquireN, returning a *core.RejectionError when it is rejected.
+func (l *DefaultLimiter) AcquireNWithReason(ctx context.Context, weight int) (core.Listener, error) {
+	listener, rejection := l.acquireN(ctx, weight)
+	if rejection != nil {
+		return nil, rejection
+	}
+	return listener, nil
+}
+
+func (l *DefaultLimiter) acquireN(ctx context.Context, weight int) (core.Listener, *core.RejectionError) {
+	if weight < 1 {
+		weight = 1
+	}
+	l.observers.acquireAttempt(ctx)
+	if !l.lifecycle.begin() {
+		atomic.AddUint64(&l.rejected, 1)
+		l.observers.rejected(ctx, core.RejectReasonClosed)
+		return nil, core.NewRejectionError(core.RejectReasonClosed)
+	}
+
+	if l.idleLimit != nil {
+		l.checkIdle()
+	}
 
 	// Did we exceed the limit?
-	token, ok := l.strategy.TryAcquire(ctx)
-	if !ok || token == nil {
-		return nil, false
+	token, rejection := l.tryAcquireStrategy(ctx, weight)
+	if rejection != nil {
+		l.lifecycle.end()
+		atomic.AddUint64(&l.rejected, 1)
+		l.observers.rejected(ctx, rejection.Reason)
+		return nil, rejection
 	}
 
-	startTime := time.Now().UnixNano()
-	currentMaxInFlight := atomic.AddInt64(l.inFlight, 1)
+	startTime := l.clock.Now().UnixNano()
+	currentMaxInFlight := atomic.AddInt64(l.inFlight, int64(weight))
+	l.observers.acquired(ctx, int(currentMaxInFlight))
 	return &DefaultListener{
+		ctx:                ctx,
 		currentMaxInFlight: currentMaxInFlight,
+		weight:             int64(weight),
 		inFlight:           l.inFlight,
 		token:              token,
 		startTime:          startTime,
 		minRTTThreshold:    l.minRTTThreshold,
 		limiter:            l,
-		nextUpdateTime:     l.nextUpdateTime,
-	}, true
+		nextUpdateTime:     atomic.LoadInt64(&l.nextUpdateTime),
+	}, nil
+}
 
+// checkIdle gives the limit a chance to apply its idle policy before a request is admitted, at most once per
+// maxWindowTime.  Without traffic no sample window rolls over, so the first requests of a burst would otherwise be
+// admitted against the limit of the previous burst.
+func (l *DefaultLimiter) checkIdle() {
+	now := l.clock.Now().UnixNano()
+	next := atomic.LoadInt64(&l.nextIdleCheck)
+	if now < next || !atomic.CompareAndSwapInt64(&l.nextIdleCheck, next, now+l.maxWindowTime) {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.idleLimit.CheckIdle()
+	l.strategy.SetLimit(l.limit.EstimatedLimit())
+}
+
+// SetClock will replace the clock used to measure RTT and schedule limit updates, i.e. with a clock.FakeClock in
+// tests.  It must be called before the limiter is used.
+func (l *DefaultLimiter) SetClock(clock core.Clock) {
+	if clock == nil {
+		clock = core.SystemClockInstance
+	}
+	l.clock = clock
+	l.lastRollover = clock.Now().UnixNano()
+	l.sample.Store(measurements.NewImmutableSampleWindow(clock.Now().UnixNano(), 0, 0, 0, 0, false))
+}
+
+// AddObserver will register an observer to receive the lifecycle callbacks of every request.
+func (l *DefaultLimiter) AddObserver(observer core.LimiterObserver) {
+	l.observers.add(observer)
+}
+
+// Close the limiter so that every new acquisition is rejected immediately.  Tokens that were already acquired are
+// unaffected.
+func (l *DefaultLimiter) Close() {
+	l.lifecycle.close()
+}
+
+// Drain will Close the limiter and then block until every outstanding listener has been released or ctx is done.
+func (l *DefaultLimiter) Drain(ctx context.Context) error {
+	return l.lifecycle.drain(ctx)
+}
+
+func (l *DefaultLimiter) tryAcquireStrategy(ctx context.Context, weight int) (core.StrategyToken, *core.RejectionError) {
+	var token core.StrategyToken
+	var ok bool
+	switch {
+	case weight == 1:
+		token, ok = l.strategy.TryAcquire(ctx)
+	case l.weighted == nil:
+		// the strategy can only account for single units, so no release allows the weight to be acquired
+		return nil, core.NewRejectionError(core.RejectReasonUnsupported)
+	default:
+		token, ok = l.weighted.TryAcquireN(ctx, weight)
+	}
+	if !ok || token == nil {
+		return nil, strategyRejection(token)
+	}
+	return token, nil
+}
+
+// updateReleaseRate records the release rate and average RTT of the completed sample window and passes the throughput
+// to a core.ThroughputLimit, it must be called with the rollover lock held.
+func (l *DefaultLimiter) updateReleaseRate(endTime int64, completed *measurements.ImmutableSampleWindow) {
+	released := atomic.LoadUint64(&l.released)
+	if elapsed := endTime - l.lastRollover; elapsed > 0 {
+		rate := float64(released-l.releasedAtStart) / time.Duration(elapsed).Seconds()
+		l.releaseRate.Store(math.Float64bits(rate))
+		if throughputLimit, ok := l.limit.(core.ThroughputLimit); ok {
+			throughputLimit.OnThroughput(int(released-l.releasedAtStart), time.Duration(elapsed))
+		}
+	}
+	l.lastRollover = endTime
+	l.releasedAtStart = released
+	if rtt := completed.AverageRTTNanoseconds(); rtt > 0 {
+		atomic.StoreInt64(&l.windowRTT, rtt)
+	}
+}
+
+// ReleaseRate returns the number of tokens released per second during the last completed sample window, or 0 if no
+// window has completed yet.
+func (l *DefaultLimiter) ReleaseRate() float64 {
+	return math.Float64frombits(l.releaseRate.Load())
+}
+
+// RetryAfter estimates how long a rejected caller should wait for a token to be available, that is the time for the
+// in-flight requests over the limit plus one to be released.  The observed release rate is used when a sample window
+// has completed, otherwise the rate is derived from the limit and the average RTT with Little's law.  Returns 0 when
+// no RTT has been measured yet.
+func (l *DefaultLimiter) RetryAfter() time.Duration {
+	limit := l.limit.EstimatedLimit()
+	rate := l.ReleaseRate()
+	if rate <= 0 {
+		rtt := atomic.LoadInt64(&l.windowRTT)
+		if sample := l.sample.Load(); sample != nil && sample.AverageRTTNanoseconds() > 0 {
+			rtt = sample.AverageRTTNanoseconds()
+		}
+		if rtt <= 0 || limit <= 0 {
+			return 0
+		}
+		rate = float64(limit) / time.Duration(rtt).Seconds()
+	}
+	excess := atomic.LoadInt64(l.inFlight) - int64(limit) + 1
+	if excess < 1 {
+		excess = 1
+	}
+	return time.Duration(float64(excess) / rate * float64(time.Second))
+}
+
+// strategyRejection returns why the strategy did not acquire the token, defaulting to the limit being exceeded.
+func strategyRejection(token core.StrategyToken) *core.RejectionError {
+	if rejecting, ok := token.(core.RejectingStrategyToken); ok {
+		if rejection := rejecting.Rejection(); rejection != nil {
+			return rejection
+		}
+	}
+	return core.NewRejectionError(core.RejectReasonLimitExceeded)
 }
 
 func (l *DefaultLimiter) updateAndGetSample(
 	f func(sample measurements.ImmutableSampleWindow) measurements.ImmutableSampleWindow,
 ) (measurements.ImmutableSampleWindow, measurements.ImmutableSampleWindow) {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-	before := *l.sample
-	after := f(before)
-	l.sample = &after
-	return before, after
+	for {
+		current := l.sample.Load()
+		after := f(*current)
+		if l.sample.CompareAndSwap(current, &after) {
+			return *current, after
+		}
+	}
 }
 
 func (l *DefaultLimiter) isWindowReady(sample measurements.ImmutableSampleWindow) bool {
@@ -263,19 +492,33 @@ func (l *DefaultLimiter) isWindowReady(sample measurements.ImmutableSampleWindow
 
 // EstimatedLimit will return the current estimated limit.
 func (l *DefaultLimiter) EstimatedLimit() int {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
 	return l.limit.EstimatedLimit()
 }
 
 func (l *DefaultLimiter) String() string {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
 	rttCandidate := int64(-1)
-	if l.sample != nil {
-		rttCandidate = l.sample.CandidateRTTNanoseconds() / 1000
+	if sample := l.sample.Load(); sample != nil {
+		rttCandidate = sample.CandidateRTTNanoseconds() / 1000
 	}
 	return fmt.Sprintf(
 		"DefaultLimiter{RTTCandidate=%d ms, maxInFlight=%d, limit=%v, strategy=%v}",
 		rttCandidate, l.inFlight, l.limit, l.strategy)
 }
+
+// Snapshot returns the current state of the limiter including the snapshots of its limit algorithm and strategy.
+func (l *DefaultLimiter) Snapshot() core.Snapshot {
+	rttCandidate := int64(0)
+	if sample := l.sample.Load(); sample != nil && sample.CandidateRTTNanoseconds() < math.MaxInt64 {
+		rttCandidate = sample.CandidateRTTNanoseconds()
+	}
+	return core.Snapshot{
+		Type:                    "DefaultLimiter",
+		Limit:                   l.limit.EstimatedLimit(),
+		InFlight:                int(atomic.LoadInt64(l.inFlight)),
+		CandidateRTTNanoseconds: rttCandidate,
+		Dropped:                 atomic.LoadUint64(&l.dropped),
+		Rejected:                atomic.LoadUint64(&l.rejected),
+		Algorithm:               core.SnapshotOf(l.limit),
+		Strategy:                core.SnapshotOf(l.strategy),
+	}
+}
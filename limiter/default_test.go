@@ -2,18 +2,61 @@ package limiter
 
 import (
 	"context"
+	"math"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/platinummonkey/go-concurrency-limits/clock"
 	"github.com/platinummonkey/go-concurrency-limits/core"
 	"github.com/platinummonkey/go-concurrency-limits/limit"
 	"github.com/platinummonkey/go-concurrency-limits/measurements"
 	"github.com/platinummonkey/go-concurrency-limits/strategy"
+	"github.com/platinummonkey/go-concurrency-limits/strategy/matchers"
 )
 
+// unweightedStrategy hides the weighted acquisition support of the delegate strategy.
+type unweightedStrategy struct {
+	delegate core.Strategy
+}
+
+func (s *unweightedStrategy) TryAcquire(ctx context.Context) (core.StrategyToken, bool) {
+	return s.delegate.TryAcquire(ctx)
+}
+
+func (s *unweightedStrategy) SetLimit(limit int) {
+	s.delegate.SetLimit(limit)
+}
+
+// unweightedLimiter hides the weighted acquisition support of the delegate limiter.
+type unweightedLimiter struct {
+	delegate core.Limiter
+}
+
+func (l *unweightedLimiter) Acquire(ctx context.Context) (core.Listener, bool) {
+	return l.delegate.Acquire(ctx)
+}
+
+// newUnweightedStrategyLimiter will create a DefaultLimiter of the given limit whose strategy only accounts for single
+// units.
+func newUnweightedStrategyLimiter(limitCount int) *DefaultLimiter {
+	l, _ := NewDefaultLimiter(
+		limit.NewFixedLimit("test", limitCount, nil),
+		defaultMinWindowTime,
+		defaultMaxWindowTime,
+		defaultMinRTTThreshold,
+		defaultWindowSize,
+		&unweightedStrategy{delegate: strategy.NewSimpleStrategy(limitCount)},
+		limit.NoopLimitLogger{},
+		core.EmptyMetricRegistryInstance,
+	)
+	return l
+}
+
 func TestDefaultListener(t *testing.T) {
 	t.Parallel()
 	asrt := assert.New(t)
@@ -28,9 +71,10 @@ func TestDefaultListener(t *testing.T) {
 		limit.NoopLimitLogger{},
 		core.EmptyMetricRegistryInstance,
 	)
-	limiter.sample = measurements.NewDefaultImmutableSampleWindow()
+	limiter.sample.Store(measurements.NewDefaultImmutableSampleWindow())
 	listener := DefaultListener{
 		currentMaxInFlight: 1,
+		weight:             1,
 		inFlight:           &inFlight,
 		token:              core.NewAcquiredStrategyToken(1, f),
 		startTime:          time.Now().Unix(),
@@ -232,4 +276,465 @@ func TestDefaultLimiter(t *testing.T) {
 		asrt.NotNil(listener)
 		listener.OnSuccess()
 	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewDefaultLimiter(
+			limit.NewFixedLimit("test", 2, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(2),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+
+		listener1, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+		listener2, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+		_, ok = l.Acquire(context.Background())
+		asrt.False(ok)
+
+		snapshot := l.Snapshot()
+		asrt.Equal("DefaultLimiter", snapshot.Type)
+		asrt.Equal(2, snapshot.Limit)
+		asrt.Equal(2, snapshot.InFlight)
+		asrt.Equal(uint64(1), snapshot.Rejected)
+		asrt.Equal(uint64(0), snapshot.Dropped)
+		asrt.Equal("FixedLimit", snapshot.Algorithm.Type)
+		asrt.Equal("SimpleStrategy", snapshot.Strategy.Type)
+		asrt.Equal(uint64(1), snapshot.Strategy.Rejected)
+
+		listener1.OnDropped()
+		listener2.OnSuccess()
+		snapshot = l.Snapshot()
+		asrt.Equal(0, snapshot.InFlight)
+		asrt.Equal(uint64(1), snapshot.Dropped)
+	})
+
+	t.Run("AcquireN", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewDefaultLimiter(
+			limit.NewFixedLimit("test", 10, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(10),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+
+		heavy, ok := l.AcquireN(context.Background(), 8)
+		asrt.True(ok)
+		asrt.Equal(int64(8), heavy.(*DefaultListener).currentMaxInFlight)
+		_, ok = l.AcquireN(context.Background(), 3)
+		asrt.False(ok)
+		light, ok := l.AcquireN(context.Background(), 2)
+		asrt.True(ok)
+		asrt.Equal(int64(10), light.(*DefaultListener).currentMaxInFlight)
+		asrt.Equal(10, l.Snapshot().InFlight)
+
+		heavy.OnSuccess()
+		light.OnIgnore()
+		asrt.Equal(0, l.Snapshot().InFlight)
+	})
+
+	t.Run("AcquireNUnweightedStrategy", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewDefaultLimiter(
+			limit.NewFixedLimit("test", 10, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			&unweightedStrategy{delegate: strategy.NewSimpleStrategy(10)},
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+
+		_, ok := l.AcquireN(context.Background(), 2)
+		asrt.False(ok)
+		_, err = l.AcquireNWithReason(context.Background(), 2)
+		asrt.ErrorIs(err, core.ErrUnsupported)
+		listener, ok := l.AcquireN(context.Background(), 1)
+		asrt.True(ok)
+		listener.OnSuccess()
+	})
+
+	t.Run("AcquireWithReason", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := newObserverTestLimiter(1)
+
+		listener, err := l.AcquireWithReason(context.Background())
+		asrt.NoError(err)
+		_, err = l.AcquireWithReason(context.Background())
+		asrt.ErrorIs(err, core.ErrLimitExceeded)
+		_, err = l.AcquireNWithReason(context.Background(), 2)
+		asrt.ErrorIs(err, core.ErrLimitExceeded)
+		listener.OnSuccess()
+
+		l.Close()
+		_, err = l.AcquireWithReason(context.Background())
+		asrt.ErrorIs(err, core.ErrLimiterClosed)
+	})
+
+	t.Run("AcquireWithReasonPartition", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		partitions := map[string]*strategy.LookupPartition{
+			"batch": strategy.NewLookupPartitionWithMetricRegistry("batch", 0.5, 2, core.EmptyMetricRegistryInstance),
+			"live":  strategy.NewLookupPartitionWithMetricRegistry("live", 0.5, 2, core.EmptyMetricRegistryInstance),
+		}
+		s, err := strategy.NewLookupPartitionStrategyWithMetricRegistry(
+			partitions, nil, 2, core.EmptyMetricRegistryInstance)
+		asrt.NoError(err)
+		l, err := NewDefaultLimiter(
+			limit.NewFixedLimit("test", 2, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			s,
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+
+		ctxBatch := context.WithValue(context.Background(), matchers.LookupPartitionContextKey, "batch")
+		first, err := l.AcquireWithReason(ctxBatch)
+		asrt.NoError(err)
+		second, err := l.AcquireWithReason(ctxBatch)
+		asrt.NoError(err)
+
+		_, err = l.AcquireWithReason(ctxBatch)
+		asrt.Equal(core.NewPartitionRejectionError("batch"), err)
+		asrt.ErrorIs(err, core.ErrPartitionExceeded)
+		asrt.ErrorIs(err, core.NewPartitionRejectionError("batch"))
+		asrt.NotErrorIs(err, core.NewPartitionRejectionError("live"))
+		first.OnSuccess()
+		second.OnSuccess()
+	})
+
+	t.Run("RetryAfter", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		l := newObserverTestLimiter(1)
+		l.SetClock(fakeClock)
+		asrt.Equal(time.Duration(0), l.RetryAfter())
+
+		// before a window completes the rate is derived from the limit and the average RTT
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+		fakeClock.Advance(50 * time.Millisecond)
+		listener.OnSuccess()
+		asrt.Equal(0.0, l.ReleaseRate())
+		asrt.Equal(50*time.Millisecond, l.RetryAfter())
+
+		// the first completed window observes a release every 10ms
+		for i := 0; i < defaultWindowSize; i++ {
+			listener, ok = l.Acquire(context.Background())
+			asrt.True(ok)
+			fakeClock.Advance(10 * time.Millisecond)
+			listener.OnSuccess()
+		}
+		asrt.InDelta(float64(defaultWindowSize+1)/1.05, l.ReleaseRate(), 0.001)
+
+		// a caller over the limit waits for one release
+		listener, ok = l.Acquire(context.Background())
+		asrt.True(ok)
+		asrt.InDelta(float64(1.05/float64(defaultWindowSize+1)*float64(time.Second)), float64(l.RetryAfter()),
+			float64(time.Microsecond))
+		listener.OnSuccess()
+	})
+
+	t.Run("ThroughputLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		littlesLaw := limit.NewDefaultLittlesLawLimit("test", nil, nil)
+		l, err := NewDefaultLimiter(
+			littlesLaw,
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(20),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+		l.SetClock(fakeClock)
+
+		// the releases of a completed window are passed to the limit
+		for i := 0; i <= defaultWindowSize; i++ {
+			listener, ok := l.Acquire(context.Background())
+			asrt.True(ok)
+			fakeClock.Advance(100 * time.Millisecond)
+			listener.OnSuccess()
+		}
+		asrt.InDelta(10, littlesLaw.Throughput(), 0.001)
+		asrt.Equal((100 * time.Millisecond).Nanoseconds(), littlesLaw.RTTNoLoad())
+		// the demand stayed below the limit
+		asrt.Equal(20, littlesLaw.EstimatedLimit())
+	})
+
+	t.Run("RTTObservingLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		slo, err := limit.NewSLOLimit("test", 20, 1, 100, 0.99, 50*time.Millisecond, 1,
+			&measurements.SingleMeasurement{}, nil, nil)
+		asrt.NoError(err)
+		l, err := NewDefaultLimiter(
+			slo,
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(20),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+		l.SetClock(fakeClock)
+
+		// every request is observed before a window completes
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+		fakeClock.Advance(20 * time.Millisecond)
+		listener.OnSuccess()
+		// the observed RTTs are merged into the percentile on the next sample
+		slo.OnSample(0, time.Millisecond.Nanoseconds(), 1, false)
+		asrt.Equal((20 * time.Millisecond).Nanoseconds(), slo.PercentileRTT())
+	})
+
+	t.Run("IdleLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		aimd, err := limit.NewAIMDLimitFromConfig("test", limit.AIMDLimitConfig{
+			Idle: limit.IdlePolicy{Timeout: time.Minute, DecayHalfLife: time.Minute, Clock: fakeClock},
+		})
+		asrt.NoError(err)
+		asrt.NoError(aimd.ImportState(core.LimitState{Version: core.LimitStateVersion, Type: "AIMDLimit", Limit: 50}))
+		l, err := NewDefaultLimiter(
+			aimd,
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(50),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+		l.SetClock(fakeClock)
+
+		// the limit of the last burst is decayed before the first request of the next burst is admitted
+		fakeClock.Advance(2 * time.Minute)
+		for i := 0; i < 30; i++ {
+			_, ok := l.Acquire(context.Background())
+			asrt.True(ok)
+		}
+		asrt.Equal(30, l.EstimatedLimit())
+		_, ok := l.Acquire(context.Background())
+		asrt.False(ok)
+	})
+
+	t.Run("StabilizedIdleLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		aimd, err := limit.NewAIMDLimitFromConfig("test", limit.AIMDLimitConfig{
+			Idle: limit.IdlePolicy{Timeout: time.Minute, DecayHalfLife: time.Minute, Clock: fakeClock},
+		})
+		asrt.NoError(err)
+		asrt.NoError(aimd.ImportState(core.LimitState{Version: core.LimitStateVersion, Type: "AIMDLimit", Limit: 50}))
+		stabilized, err := limit.NewStabilizedLimit("test", aimd,
+			limit.StabilizedLimitConfig{MaxDecrease: 1, Clock: fakeClock})
+		asrt.NoError(err)
+		l, err := NewDefaultLimiter(
+			stabilized,
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(50),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+		l.SetClock(fakeClock)
+
+		// the decay is not held back by the bounds of the stabilized limit
+		fakeClock.Advance(2 * time.Minute)
+		for i := 0; i < 30; i++ {
+			_, ok := l.Acquire(context.Background())
+			asrt.True(ok)
+		}
+		asrt.Equal(30, l.EstimatedLimit())
+		_, ok := l.Acquire(context.Background())
+		asrt.False(ok)
+	})
+
+	t.Run("DropRateLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		errorRate, err := limit.NewDefaultErrorRateLimit("test", limit.NewFixedLimit("test", 20, nil), nil)
+		asrt.NoError(err)
+		l, err := NewDefaultLimiter(
+			errorRate,
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(20),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+		l.SetClock(fakeClock)
+
+		// the drops of a completed window are passed to the limit rather than a single didDrop
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+		fakeClock.Advance(10 * time.Millisecond)
+		listener.OnDropped()
+		for i := 0; i <= defaultWindowSize; i++ {
+			listener, ok := l.Acquire(context.Background())
+			asrt.True(ok)
+			fakeClock.Advance(10 * time.Millisecond)
+			listener.OnSuccess()
+		}
+		asrt.InDelta(1.0/float64(defaultWindowSize+2), errorRate.ErrorRate(), 0.001)
+	})
+
+	t.Run("ConcurrentAcquireNeverExceedsLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l, err := NewDefaultLimiter(
+			limit.NewFixedLimit("test", 5, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			0,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(5),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+
+		var current, maxSeen int64
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					listener, ok := l.Acquire(context.Background())
+					if !ok {
+						continue
+					}
+					n := atomic.AddInt64(&current, 1)
+					for {
+						m := atomic.LoadInt64(&maxSeen)
+						if n <= m || atomic.CompareAndSwapInt64(&maxSeen, m, n) {
+							break
+						}
+					}
+					atomic.AddInt64(&current, -1)
+					listener.OnSuccess()
+				}
+			}()
+		}
+		wg.Wait()
+		asrt.LessOrEqual(maxSeen, int64(5))
+		asrt.Equal(0, l.Snapshot().InFlight)
+	})
+
+	t.Run("FakeClock", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		l, err := NewDefaultLimiter(
+			limit.NewSettableLimit("test", 10, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(10),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+		l.SetClock(fakeClock)
+
+		// every request takes exactly 5ms of fake time
+		for i := 0; i < defaultWindowSize; i++ {
+			listener, ok := l.Acquire(context.Background())
+			asrt.True(ok)
+			fakeClock.Advance(5 * time.Millisecond)
+			listener.OnSuccess()
+		}
+		asrt.Equal((5 * time.Millisecond).Nanoseconds(), l.Snapshot().CandidateRTTNanoseconds)
+	})
+}
+
+// BenchmarkDefaultLimiter measures the acquire and release hot path from parallel goroutines.  Run with
+// `-cpu 1,2,4,8,...` to see how throughput scales with GOMAXPROCS.
+func BenchmarkDefaultLimiter(b *testing.B) {
+	benchLimiter := func(b *testing.B, strategy core.Strategy, minRTTThreshold int64) {
+		l, err := NewDefaultLimiter(
+			limit.NewFixedLimit("test", math.MaxInt32, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			minRTTThreshold,
+			defaultWindowSize,
+			strategy,
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		if err != nil {
+			b.Fatal(err.Error())
+		}
+		ctx := context.Background()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				listener, ok := l.Acquire(ctx)
+				if !ok {
+					b.Error("expected to acquire")
+					return
+				}
+				listener.OnSuccess()
+			}
+		})
+	}
+
+	b.Run("simple_strategy", func(b *testing.B) {
+		benchLimiter(b, strategy.NewSimpleStrategy(math.MaxInt32), defaultMinRTTThreshold)
+	})
+
+	b.Run("simple_strategy_sampled", func(b *testing.B) {
+		// every release is recorded into the sample window
+		benchLimiter(b, strategy.NewSimpleStrategy(math.MaxInt32), 0)
+	})
+
+	b.Run("precise_strategy", func(b *testing.B) {
+		benchLimiter(b, strategy.NewPreciseStrategy(math.MaxInt32), defaultMinRTTThreshold)
+	})
 }
@@ -9,7 +9,7 @@ import (
 // goroutines that are waiting for a slot to become available.
 type DelegateListener struct {
 	delegateListener core.Listener
-	onRelease        func() // called after every On* method; may be nil
+	onRelease        func(outcome core.ReleaseOutcome) // called after every On* method; may be nil
 }
 
 // NewDelegateListener creates a new DelegateListener that delegates all calls
@@ -26,7 +26,7 @@ func NewDelegateListener(delegateListener core.Listener) *DelegateListener {
 func (l *DelegateListener) OnDropped() {
 	l.delegateListener.OnDropped()
 	if l.onRelease != nil {
-		l.onRelease()
+		l.onRelease(core.ReleaseOutcomeDropped)
 	}
 }
 
@@ -35,7 +35,7 @@ func (l *DelegateListener) OnDropped() {
 func (l *DelegateListener) OnIgnore() {
 	l.delegateListener.OnIgnore()
 	if l.onRelease != nil {
-		l.onRelease()
+		l.onRelease(core.ReleaseOutcomeIgnore)
 	}
 }
 
@@ -44,6 +44,22 @@ func (l *DelegateListener) OnIgnore() {
 func (l *DelegateListener) OnSuccess() {
 	l.delegateListener.OnSuccess()
 	if l.onRelease != nil {
-		l.onRelease()
+		l.onRelease(core.ReleaseOutcomeSuccess)
 	}
 }
+
+func (l *DelegateListener) acquiredInFlight() int {
+	return listenerInFlight(l.delegateListener)
+}
+
+// wrapperSnapshot fills in the type, the delegate snapshot and the limit and in-flight values reported by the
+// delegate for limiters that decorate another limiter.
+func wrapperSnapshot(typeName string, delegate core.Limiter, snapshot core.Snapshot) core.Snapshot {
+	snapshot.Type = typeName
+	snapshot.Delegate = core.SnapshotOf(delegate)
+	if snapshot.Delegate != nil {
+		snapshot.Limit = snapshot.Delegate.Limit
+		snapshot.InFlight = snapshot.Delegate.InFlight
+	}
+	return snapshot
+}
@@ -0,0 +1,84 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// lifecycle tracks whether a limiter has been closed and how many acquisitions are outstanding so that the limiter
+// can be drained.  Every acquisition attempt is counted from the moment it starts, which closes the race between an
+// attempt checking the closed flag and Drain observing no outstanding acquisitions.
+//
+// The zero value is ready to use.
+type lifecycle struct {
+	initOnce    sync.Once
+	closeOnce   sync.Once
+	drainedOnce sync.Once
+	closedFlag  int32
+	closed      chan struct{} // closed by Close, wakes waiting callers
+	drained     chan struct{} // closed once the limiter is closed and nothing is outstanding
+	outstanding int64
+}
+
+func (c *lifecycle) init() {
+	c.initOnce.Do(func() {
+		c.closed = make(chan struct{})
+		c.drained = make(chan struct{})
+	})
+}
+
+// begin counts a new acquisition attempt.  Returns false without counting the attempt if the limiter is closed.
+func (c *lifecycle) begin() bool {
+	atomic.AddInt64(&c.outstanding, 1)
+	if c.isClosed() {
+		c.end()
+		return false
+	}
+	return true
+}
+
+// end is called when an attempt is rejected or an acquired listener is released.
+func (c *lifecycle) end() {
+	if atomic.AddInt64(&c.outstanding, -1) == 0 && c.isClosed() {
+		c.signalDrained()
+	}
+}
+
+func (c *lifecycle) isClosed() bool {
+	return atomic.LoadInt32(&c.closedFlag) == 1
+}
+
+// done returns a channel that is closed when the limiter is closed.
+func (c *lifecycle) done() <-chan struct{} {
+	c.init()
+	return c.closed
+}
+
+func (c *lifecycle) close() {
+	c.init()
+	c.closeOnce.Do(func() {
+		atomic.StoreInt32(&c.closedFlag, 1)
+		close(c.closed)
+	})
+	if atomic.LoadInt64(&c.outstanding) == 0 {
+		c.signalDrained()
+	}
+}
+
+func (c *lifecycle) signalDrained() {
+	c.init()
+	c.drainedOnce.Do(func() {
+		close(c.drained)
+	})
+}
+
+func (c *lifecycle) drain(ctx context.Context) error {
+	c.close()
+	select {
+	case <-c.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
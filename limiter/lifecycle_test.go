@@ -0,0 +1,108 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+func TestDrainableLimiter(t *testing.T) {
+	t.Parallel()
+
+	drainables := map[string]func() core.DrainableLimiter{
+		"DefaultLimiter": func() core.DrainableLimiter {
+			return newObserverTestLimiter(1)
+		},
+		"BlockingLimiter": func() core.DrainableLimiter {
+			return NewBlockingLimiter(newObserverTestLimiter(1), 0, nil)
+		},
+		"DeadlineLimiter": func() core.DrainableLimiter {
+			return NewDeadlineLimiter(newObserverTestLimiter(1), time.Now().Add(time.Hour), nil)
+		},
+		"QueueBlockingLimiter": func() core.DrainableLimiter {
+			return NewQueueBlockingLimiterFromConfig(newObserverTestLimiter(1), QueueLimiterConfig{
+				MaxBacklogTimeout: time.Hour,
+			})
+		},
+	}
+
+	for name, newLimiter := range drainables {
+		name, newLimiter := name, newLimiter
+		t.Run(name, func(t2 *testing.T) {
+			t2.Parallel()
+			asrt := assert.New(t2)
+			l := newLimiter()
+			observer := &recordingObserver{}
+			l.(core.ObservableLimiter).AddObserver(observer)
+
+			listener, ok := l.Acquire(context.Background())
+			asrt.True(ok)
+
+			// a second caller either waits or is rejected because of the limit
+			waiterDone := make(chan bool)
+			go func() {
+				_, ok := l.Acquire(context.Background())
+				waiterDone <- ok
+			}()
+
+			if name != "DefaultLimiter" {
+				asrt.Eventually(func() bool {
+					return core.SnapshotOf(l).QueueDepth == 1
+				}, time.Second, time.Millisecond)
+			}
+
+			l.Close()
+			drained := make(chan error)
+			go func() {
+				drained <- l.Drain(context.Background())
+			}()
+
+			// waiting callers are woken and rejected
+			select {
+			case ok := <-waiterDone:
+				asrt.False(ok)
+			case <-time.After(5 * time.Second):
+				asrt.Fail("waiter was not woken")
+			}
+
+			// new acquisitions are rejected
+			_, ok = l.Acquire(context.Background())
+			asrt.False(ok)
+			asrt.Contains(observer.Events(), "rejected:closed")
+
+			// drain waits for the outstanding listener
+			select {
+			case <-drained:
+				asrt.Fail("drain returned with an outstanding listener")
+			case <-time.After(20 * time.Millisecond):
+			}
+			listener.OnSuccess()
+			select {
+			case err := <-drained:
+				asrt.NoError(err)
+			case <-time.After(5 * time.Second):
+				asrt.Fail("drain did not return")
+			}
+
+			asrt.NoError(l.Drain(context.Background()))
+		})
+	}
+
+	t.Run("DrainContextDone", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := newObserverTestLimiter(1)
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		asrt.Equal(context.DeadlineExceeded, l.Drain(ctx))
+		listener.OnSuccess()
+		asrt.NoError(l.Drain(context.Background()))
+	})
+}
@@ -0,0 +1,120 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// observers is a copy on write set of core.LimiterObserver, so notifying on the request path is a single atomic load
+// when no observer is registered.
+type observers struct {
+	mu   sync.Mutex
+	list atomic.Pointer[[]core.LimiterObserver]
+}
+
+func (o *observers) add(observer core.LimiterObserver) {
+	if observer == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var next []core.LimiterObserver
+	if current := o.list.Load(); current != nil {
+		next = append(next, *current...)
+	}
+	next = append(next, observer)
+	o.list.Store(&next)
+}
+
+func (o *observers) load() []core.LimiterObserver {
+	if current := o.list.Load(); current != nil {
+		return *current
+	}
+	return nil
+}
+
+func (o *observers) enabled() bool {
+	return o.list.Load() != nil
+}
+
+func (o *observers) acquireAttempt(ctx context.Context) {
+	for _, observer := range o.load() {
+		observer.OnAcquireAttempt(ctx)
+	}
+}
+
+func (o *observers) acquired(ctx context.Context, inFlight int) {
+	for _, observer := range o.load() {
+		observer.OnAcquired(ctx, inFlight)
+	}
+}
+
+func (o *observers) rejected(ctx context.Context, reason core.RejectReason) {
+	for _, observer := range o.load() {
+		observer.OnRejected(ctx, reason)
+	}
+}
+
+func (o *observers) queued(ctx context.Context, queueDepth int) {
+	for _, observer := range o.load() {
+		observer.OnQueued(ctx, queueDepth)
+	}
+}
+
+func (o *observers) dequeued(ctx context.Context, waited time.Duration) {
+	for _, observer := range o.load() {
+		observer.OnDequeued(ctx, waited)
+	}
+}
+
+func (o *observers) released(ctx context.Context, outcome core.ReleaseOutcome, rtt time.Duration) {
+	for _, observer := range o.load() {
+		observer.OnReleased(ctx, outcome, rtt)
+	}
+}
+
+// releaseNotifier returns a callback that notifies the observers of a release with the time since this call, or nil
+// when no observer is registered.
+func (o *observers) releaseNotifier(ctx context.Context, clock core.Clock) func(outcome core.ReleaseOutcome) {
+	if !o.enabled() {
+		return nil
+	}
+	start := clock.Now()
+	return func(outcome core.ReleaseOutcome) {
+		o.released(ctx, outcome, clock.Now().Sub(start))
+	}
+}
+
+// releaseObserver returns the onRelease callback for a DelegateListener that wakes waiters with wake and notifies the
+// observers of the release.
+func (o *observers) releaseObserver(
+	ctx context.Context,
+	clock core.Clock,
+	wake func(),
+) func(outcome core.ReleaseOutcome) {
+	notify := o.releaseNotifier(ctx, clock)
+	if notify == nil {
+		return func(core.ReleaseOutcome) { wake() }
+	}
+	return func(outcome core.ReleaseOutcome) {
+		wake()
+		notify(outcome)
+	}
+}
+
+// inFlightReporter is implemented by the listeners of this package to report the in flight count observed when the
+// token was acquired.
+type inFlightReporter interface {
+	acquiredInFlight() int
+}
+
+func listenerInFlight(listener core.Listener) int {
+	if r, ok := listener.(inFlightReporter); ok {
+		return r.acquiredInFlight()
+	}
+	return 0
+}
@@ -0,0 +1,200 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+	"github.com/platinummonkey/go-concurrency-limits/strategy"
+)
+
+// recordingObserver records every callback as a short event string.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(event string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+func (o *recordingObserver) Events() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.events...)
+}
+
+func (o *recordingObserver) OnAcquireAttempt(ctx context.Context) {
+	o.record("attempt")
+}
+
+func (o *recordingObserver) OnAcquired(ctx context.Context, inFlight int) {
+	o.record(fmt.Sprintf("acquired:%d", inFlight))
+}
+
+func (o *recordingObserver) OnRejected(ctx context.Context, reason core.RejectReason) {
+	o.record("rejected:" + string(reason))
+}
+
+func (o *recordingObserver) OnQueued(ctx context.Context, queueDepth int) {
+	o.record(fmt.Sprintf("queued:%d", queueDepth))
+}
+
+func (o *recordingObserver) OnDequeued(ctx context.Context, waited time.Duration) {
+	o.record("dequeued")
+}
+
+func (o *recordingObserver) OnReleased(ctx context.Context, outcome core.ReleaseOutcome, rtt time.Duration) {
+	o.record("released:" + string(outcome))
+}
+
+func newObserverTestLimiter(limitCount int) *DefaultLimiter {
+	l, _ := NewDefaultLimiter(
+		limit.NewFixedLimit("test", limitCount, nil),
+		defaultMinWindowTime,
+		defaultMaxWindowTime,
+		defaultMinRTTThreshold,
+		defaultWindowSize,
+		strategy.NewSimpleStrategy(limitCount),
+		limit.NoopLimitLogger{},
+		core.EmptyMetricRegistryInstance,
+	)
+	return l
+}
+
+func TestLimiterObserver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("DefaultLimiter", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := newObserverTestLimiter(1)
+		observer := &recordingObserver{}
+		l.AddObserver(observer)
+		l.AddObserver(core.NoopLimiterObserver{})
+
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+		_, ok = l.Acquire(context.Background())
+		asrt.False(ok)
+		listener.OnDropped()
+
+		asrt.Equal([]string{
+			"attempt", "acquired:1",
+			"attempt", "rejected:limit_exceeded",
+			"released:dropped",
+		}, observer.Events())
+	})
+
+	t.Run("BlockingLimiter", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewBlockingLimiter(newObserverTestLimiter(1), 0, nil)
+		observer := &recordingObserver{}
+		l.AddObserver(observer)
+
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, ok = l.Acquire(ctx)
+		asrt.False(ok)
+		listener.OnSuccess()
+
+		asrt.Equal([]string{
+			"attempt", "acquired:1",
+			"attempt", "queued:1", "dequeued", "rejected:context_done",
+			"released:success",
+		}, observer.Events())
+	})
+
+	t.Run("DeadlineLimiter", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewDeadlineLimiter(newObserverTestLimiter(1), time.Now().Add(10*time.Millisecond), nil)
+		observer := &recordingObserver{}
+		l.AddObserver(observer)
+
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+		_, ok = l.Acquire(context.Background())
+		asrt.False(ok)
+		listener.OnIgnore()
+
+		asrt.Equal([]string{
+			"attempt", "acquired:1",
+			"attempt", "queued:1", "dequeued", "rejected:deadline_exceeded",
+			"released:ignore",
+		}, observer.Events())
+	})
+
+	t.Run("QueueBlockingLimiter", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewQueueBlockingLimiterFromConfig(newObserverTestLimiter(1), QueueLimiterConfig{
+			MaxBacklogSize:    1,
+			MaxBacklogTimeout: time.Hour,
+		})
+		observer := &recordingObserver{}
+		l.AddObserver(observer)
+
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+
+		acquired := make(chan core.Listener)
+		go func() {
+			queuedListener, _ := l.Acquire(context.Background())
+			acquired <- queuedListener
+		}()
+		asrt.Eventually(func() bool { return l.backlog.len() == 1 }, time.Second, time.Millisecond)
+
+		// the backlog is at capacity
+		_, ok = l.Acquire(context.Background())
+		asrt.False(ok)
+
+		listener.OnSuccess()
+		queuedListener := <-acquired
+		asrt.NotNil(queuedListener)
+		queuedListener.OnSuccess()
+
+		events := observer.Events()
+		asrt.Equal([]string{"attempt", "acquired:1"}, events[:2])
+		asrt.Contains(events, "queued:1")
+		asrt.Contains(events, "rejected:queue_full")
+		asrt.Contains(events, "dequeued")
+		// the first release races with the queued caller being handed its token
+		asrt.Equal("released:success", events[len(events)-1])
+		asrt.Len(events, 10)
+	})
+
+	t.Run("QueueBlockingLimiterTimeout", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		l := NewQueueBlockingLimiterFromConfig(newObserverTestLimiter(1), QueueLimiterConfig{
+			MaxBacklogTimeout: 10 * time.Millisecond,
+		})
+		observer := &recordingObserver{}
+		l.AddObserver(observer)
+
+		listener, ok := l.Acquire(context.Background())
+		asrt.True(ok)
+		_, ok = l.Acquire(context.Background())
+		asrt.False(ok)
+		listener.OnSuccess()
+
+		asrt.Equal([]string{
+			"attempt", "acquired:1",
+			"attempt", "queued:1", "dequeued", "rejected:queue_timeout",
+			"released:success",
+		}, observer.Events())
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
@@ -36,6 +37,8 @@ const (
 
 type queueElement struct {
 	ctx         context.Context
+	weight      int
+	queuedAt    time.Time
 	releaseChan chan<- core.Listener
 }
 
@@ -50,6 +53,11 @@ func (e *queueElement) setListener(listener core.Listener) bool {
 	}
 }
 
+// drop will wake the waiter without a listener, it must already have been evicted from the queue.
+func (e *queueElement) drop() {
+	close(e.releaseChan)
+}
+
 func (q *queue) evictionFunc(e *list.Element) func() {
 	return func() {
 		q.mu.Lock()
@@ -75,7 +83,7 @@ func (q *queue) push(ctx context.Context) (EvictFunc, <-chan core.Listener) {
 	defer q.mu.Unlock()
 	releaseChan := make(chan core.Listener)
 
-	e := &queueElement{ctx: ctx, releaseChan: releaseChan}
+	e := &queueElement{ctx: ctx, weight: 1, releaseChan: releaseChan}
 
 	// We always push to the front of the list regardless of
 	// queue order. As usage of the list will always assume
@@ -87,25 +95,30 @@ func (q *queue) push(ctx context.Context) (EvictFunc, <-chan core.Listener) {
 
 var errQueueIsFull = fmt.Errorf("queue is full")
 
-func (q *queue) pushWithCapacity(ctx context.Context, maxCapacity uint64) (EvictFunc, <-chan core.Listener, error) {
+func (q *queue) pushWithCapacity(
+	ctx context.Context,
+	weight int,
+	maxCapacity uint64,
+	now time.Time,
+) (EvictFunc, <-chan core.Listener, int, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
 	// Restrict backlog size so the queue doesn't grow unbounded during an outage
 	if uint64(q.list.Len()) >= maxCapacity {
-		return nil, nil, errQueueIsFull
+		return nil, nil, 0, errQueueIsFull
 	}
 
 	releaseChan := make(chan core.Listener)
 
-	e := &queueElement{ctx: ctx, releaseChan: releaseChan}
+	e := &queueElement{ctx: ctx, weight: weight, queuedAt: now, releaseChan: releaseChan}
 
 	// We always push to the front of the list regardless of
 	// queue order. As usage of the list will always assume
 	// Front == newest and Back == Oldest
 	listElement := q.list.PushFront(e)
 
-	return q.evictionFunc(listElement), releaseChan, nil
+	return q.evictionFunc(listElement), releaseChan, q.list.Len(), nil
 }
 
 func (q *queue) pop() *queueElement {
@@ -141,16 +154,35 @@ func (q *queue) peek() (EvictFunc, *queueElement) {
 	return nil, nil
 }
 
+// oldest will return the element that has been queued the longest regardless of the ordering.  The element returned
+// is not evicted from the queue until EvictFunc is invoked
+func (q *queue) oldest() (EvictFunc, *queueElement) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	element := q.list.Back()
+	if element != nil {
+		return q.evictionFunc(element), element.Value.(*queueElement)
+	}
+
+	return nil, nil
+}
+
 // QueueBlockingListener implements a blocking listener for the QueueBlockingLimiter
 type QueueBlockingListener struct {
 	delegateListener core.Listener
 	limiter          *QueueBlockingLimiter
+	onRelease        func(outcome core.ReleaseOutcome) // called after every On* method; may be nil
 }
 
 func (l *QueueBlockingListener) unblock() {
 	l.limiter.mu.Lock()
 	defer l.limiter.mu.Unlock()
 
+	if l.limiter.codel != nil {
+		l.limiter.dropStandingQueue()
+	}
+
 	evict, nextEvent := l.limiter.backlog.peek()
 
 	// The queue is empty
@@ -158,9 +190,9 @@ func (l *QueueBlockingListener) unblock() {
 		return
 	}
 
-	listener, ok := l.limiter.delegate.Acquire(nextEvent.ctx)
+	listener, _ := acquireN(nextEvent.ctx, l.limiter.delegate, nextEvent.weight)
 
-	if ok && listener != nil {
+	if listener != nil {
 		// We successfully acquired a listener from the
 		// delegate. Now we can evict the element from
 		// the queue
@@ -181,21 +213,37 @@ func (l *QueueBlockingListener) unblock() {
 // happens.
 func (l *QueueBlockingListener) OnDropped() {
 	l.delegateListener.OnDropped()
+	l.limiter.lifecycle.end()
 	l.unblock()
+	if l.onRelease != nil {
+		l.onRelease(core.ReleaseOutcomeDropped)
+	}
 }
 
 // OnIgnore is called to indicate the operation failed before any meaningful RTT measurement could be made and
 // should be ignored to not introduce an artificially low RTT.
 func (l *QueueBlockingListener) OnIgnore() {
 	l.delegateListener.OnIgnore()
+	l.limiter.lifecycle.end()
 	l.unblock()
+	if l.onRelease != nil {
+		l.onRelease(core.ReleaseOutcomeIgnore)
+	}
 }
 
 // OnSuccess is called as a notification that the operation succeeded and internally measured latency should be
 // used as an RTT sample.
 func (l *QueueBlockingListener) OnSuccess() {
 	l.delegateListener.OnSuccess()
+	l.limiter.lifecycle.end()
 	l.unblock()
+	if l.onRelease != nil {
+		l.onRelease(core.ReleaseOutcomeSuccess)
+	}
+}
+
+func (l *QueueBlockingListener) acquiredInFlight() int {
+	return listenerInFlight(l.delegateListener)
 }
 
 // QueueBlockingLimiter implements a Limiter that blocks the caller when the limit has been reached.  This strategy
@@ -203,15 +251,25 @@ func (l *QueueBlockingListener) OnSuccess() {
 // the limit has been reached.  To help keep success latencies low and minimize timeouts any blocked requests are
 // processed in last in/first out order.
 //
+// With CoDelTarget set the backlog is managed with CoDel: once the oldest caller has been queued for longer than the
+// target for a whole interval the oldest callers are dropped, at an increasing rate, until the standing queue is gone.
+// This keeps the queueing delay short under sustained overload rather than making every caller wait for the
+// maxBacklogTimeout.
+//
 // Use this limiter only when the concurrency model allows the limiter to be blocked.
 type QueueBlockingLimiter struct {
 	delegate            core.Limiter
 	maxBacklogSize      uint64
 	maxBacklogTimeout   time.Duration
 	backlogEvictDoneCtx bool
-
-	backlog *queue
-	mu      sync.RWMutex
+	clock               core.Clock
+	codel               *codel
+
+	backlog   *queue
+	rejected  uint64
+	mu        sync.RWMutex
+	observers observers
+	lifecycle lifecycle
 }
 
 // QueueLimiterConfig is a struct used to encapsulate the constructor arguments
@@ -222,8 +280,18 @@ type QueueLimiterConfig struct {
 	MaxBacklogTimeout   time.Duration `yaml:"maxBacklogTimeout,omitempty" json:"maxBacklogTimeout,omitempty"`
 	BacklogEvictDoneCtx bool          `yaml:"backlogEvictDoneCtx,omitempty" json:"backlogEvictDoneCtx,omitempty"`
 
+	// CoDelTarget enables CoDel queue management when positive, it is the queueing delay tolerated before a standing
+	// queue is considered to exist, typically 5ms.
+	CoDelTarget time.Duration `yaml:"codelTarget,omitempty" json:"codelTarget,omitempty"`
+	// CoDelInterval is how long the queueing delay must stay above CoDelTarget before callers are dropped, it should
+	// be about the RTT of a request, defaults to 100ms.
+	CoDelInterval time.Duration `yaml:"codelInterval,omitempty" json:"codelInterval,omitempty"`
+
 	MetricRegistry core.MetricRegistry
 	Tags           []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
+	// Clock is used for backlog timeouts, defaults to the system clock.
+	Clock core.Clock `yaml:"-" json:"-"`
 }
 
 // ApplyDefaults is used by QueueBlockingLimiter constructors
@@ -246,6 +314,14 @@ func (c *QueueLimiterConfig) ApplyDefaults() {
 		c.Ordering = OrderingLIFO
 	}
 
+	if c.Clock == nil {
+		c.Clock = core.SystemClockInstance
+	}
+
+	if c.CoDelTarget > 0 && c.CoDelInterval <= 0 {
+		c.CoDelInterval = time.Millisecond * 100
+	}
+
 	c.Tags = append(c.Tags, metricTagOrdering, string(c.Ordering))
 }
 
@@ -262,11 +338,15 @@ func NewQueueBlockingLimiterFromConfig(
 		maxBacklogSize:      uint64(config.MaxBacklogSize),
 		maxBacklogTimeout:   config.MaxBacklogTimeout,
 		backlogEvictDoneCtx: config.BacklogEvictDoneCtx,
+		clock:               config.Clock,
 		backlog: &queue{
 			list:     list.New(),
 			ordering: config.Ordering,
 		},
 	}
+	if config.CoDelTarget > 0 {
+		l.codel = &codel{target: config.CoDelTarget, interval: config.CoDelInterval}
+	}
 
 	config.MetricRegistry.RegisterGauge(
 		core.MetricQueueLimit, core.NewIntMetricSupplierWrapper(func() int {
@@ -289,19 +369,30 @@ func NewQueueBlockingLimiterWithDefaults(
 	)
 }
 
-func (l *QueueBlockingLimiter) tryAcquire(ctx context.Context) core.Listener {
+func (l *QueueBlockingLimiter) tryAcquire(ctx context.Context, weight int) (core.Listener, core.RejectReason) {
 	// Try to acquire a token and return immediately if successful
-	listener, ok := l.delegate.Acquire(ctx)
-	if ok && listener != nil {
-		return listener
+	listener, reason := acquireN(ctx, l.delegate, weight)
+	if listener != nil {
+		return listener, ""
+	}
+	if reason == core.RejectReasonUnsupported {
+		// no release will allow the delegate to acquire the weight, so it must not be queued
+		return nil, reason
 	}
 
 	// Create a holder for a listener and block until a listener is released by another
 	// operation.  Holders will be unblocked in LIFO or FIFO order depending on whatever
 	// ordering was configured when backlog was instantiated
-	evict, eventReleaseChan, err := l.backlog.pushWithCapacity(ctx, l.maxBacklogSize)
+	evict, eventReleaseChan, queueDepth, err := l.backlog.pushWithCapacity(ctx, weight, l.maxBacklogSize, l.clock.Now())
 	if err != nil {
-		return nil
+		return nil, core.RejectReasonQueueFull
+	}
+	if l.observers.enabled() {
+		l.observers.queued(ctx, queueDepth)
+		queuedAt := l.clock.Now()
+		defer func() {
+			l.observers.dequeued(ctx, l.clock.Now().Sub(queuedAt))
+		}()
 	}
 
 	// We're using a nil chan so that we
@@ -317,28 +408,36 @@ func (l *QueueBlockingLimiter) tryAcquire(ctx context.Context) core.Listener {
 	if l.maxBacklogTimeout > 0 {
 		// use NewTimer over time.After so that we don't have to
 		// wait for the timeout to elapse in order to release memory
-		timer := time.NewTimer(l.maxBacklogTimeout)
+		timer := l.clock.NewTimer(l.maxBacklogTimeout)
 		defer timer.Stop()
 
-		backlogTimeout = timer.C
+		backlogTimeout = timer.C()
 	}
 
 	select {
-	case listener = <-eventReleaseChan:
+	case listener, ok := <-eventReleaseChan:
 		// If we have received a listener then that means
 		// that 'unblock' has already evicted this element
-		// from the queue for us.
-		return listener
+		// from the queue for us.  A closed channel means
+		// that the element was dropped to shorten the queue.
+		if !ok {
+			return nil, core.RejectReasonQueueDropped
+		}
+		return listener, ""
 	case <-backlogTimeout:
 		// Remove the holder from the backlog.
 		evict()
-		return nil
+		return nil, core.RejectReasonQueueTimeout
 	case <-ctxDone:
 		// The context has been cancelled before `maxBacklogTimeout`
 		// could elapse. Since this context no longer needs a listener
 		// we evict it from the backlog to free up space.
 		evict()
-		return nil
+		return nil, core.RejectReasonContextDone
+	case <-l.lifecycle.done():
+		// The limiter has been closed, give up our place in the backlog.
+		evict()
+		return nil, core.RejectReasonClosed
 	}
 }
 
@@ -349,17 +448,116 @@ func (l *QueueBlockingLimiter) tryAcquire(ctx context.Context) core.Listener {
 // ctx Context for the request. The context is used by advanced strategies such as LookupPartitionStrategy
 // and early queue eviction on context cancellation.
 func (l *QueueBlockingLimiter) Acquire(ctx context.Context) (core.Listener, bool) {
-	delegateListener := l.tryAcquire(ctx)
+	return l.AcquireN(ctx, 1)
+}
+
+// AcquireN a token worth weight units of concurrency from the limiter, queueing while the delegate does not have
+// enough capacity.  Queued requests are unblocked in the configured order, so a heavy request at the head of the
+// backlog waits until enough weight has been released.  The delegate must implement core.WeightedLimiter to
+// support a weight other than 1, otherwise the acquisition is rejected immediately with core.RejectReasonUnsupported.
+func (l *QueueBlockingLimiter) AcquireN(ctx context.Context, weight int) (core.Listener, bool) {
+	listener, rejection := l.acquireN(ctx, weight)
+	return listener, rejection == nil
+}
+
+// AcquireWithReason will acquire a token like Acquire, returning a *core.RejectionError when it is rejected.
+func (l *QueueBlockingLimiter) AcquireWithReason(ctx context.Context) (core.Listener, error) {
+	return l.AcquireNWithReason(ctx, 1)
+}
+
+// AcquireNWithReason will acquire a token like AcquireN, returning a *core.RejectionError when it is rejected.
+func (l *QueueBlockingLimiter) AcquireNWithReason(ctx context.Context, weight int) (core.Listener, error) {
+	listener, rejection := l.acquireN(ctx, weight)
+	if rejection != nil {
+		return nil, rejection
+	}
+	return listener, nil
+}
+
+func (l *QueueBlockingLimiter) acquireN(ctx context.Context, weight int) (core.Listener, *core.RejectionError) {
+	if weight < 1 {
+		weight = 1
+	}
+	l.observers.acquireAttempt(ctx)
+	if !l.lifecycle.begin() {
+		atomic.AddUint64(&l.rejected, 1)
+		l.observers.rejected(ctx, core.RejectReasonClosed)
+		return nil, core.NewRejectionError(core.RejectReasonClosed)
+	}
+	delegateListener, reason := l.tryAcquire(ctx, weight)
 	if delegateListener == nil {
-		return nil, false
+		l.lifecycle.end()
+		atomic.AddUint64(&l.rejected, 1)
+		l.observers.rejected(ctx, reason)
+		return nil, core.NewRejectionError(reason)
 	}
+	l.observers.acquired(ctx, listenerInFlight(delegateListener))
 	return &QueueBlockingListener{
 		delegateListener: delegateListener,
 		limiter:          l,
-	}, true
+		onRelease:        l.observers.releaseNotifier(ctx, l.clock),
+	}, nil
+}
+
+// dropStandingQueue will drop the oldest waiters while CoDel detects a standing queue, it must be called with mu
+// held.
+func (l *QueueBlockingLimiter) dropStandingQueue() {
+	now := l.clock.Now()
+	for {
+		evict, oldest := l.backlog.oldest()
+		if oldest == nil {
+			l.codel.empty()
+			return
+		}
+		if !l.codel.shouldDrop(now, now.Sub(oldest.queuedAt)) {
+			return
+		}
+		evict()
+		oldest.drop()
+	}
+}
+
+// Close the limiter so that every new acquisition is rejected immediately and every queued caller is woken and
+// rejected.  Tokens that were already acquired are unaffected.
+func (l *QueueBlockingLimiter) Close() {
+	l.lifecycle.close()
+}
+
+// Drain will Close the limiter and then block until every outstanding listener has been released or ctx is done.
+func (l *QueueBlockingLimiter) Drain(ctx context.Context) error {
+	return l.lifecycle.drain(ctx)
+}
+
+// RetryAfter estimates how long a rejected caller should wait, that is the retry delay of the delegate for every
+// queued caller plus one.  Returns 0 if the delegate has no estimate.
+func (l *QueueBlockingLimiter) RetryAfter() time.Duration {
+	return core.RetryAfter(l.delegate) * time.Duration(l.backlog.len()+1)
+}
+
+// AddObserver will register an observer to receive the lifecycle callbacks of every request.
+func (l *QueueBlockingLimiter) AddObserver(observer core.LimiterObserver) {
+	l.observers.add(observer)
 }
 
 func (l *QueueBlockingLimiter) String() string {
 	return fmt.Sprintf("QueueBlockingLimiter{delegate=%v, maxBacklogSize=%d, maxBacklogTimeout=%v, ordering=%v}",
 		l.delegate, l.maxBacklogSize, l.maxBacklogTimeout, l.backlog.ordering)
 }
+
+// Snapshot returns the current state of the limiter including the snapshot of its delegate.
+func (l *QueueBlockingLimiter) Snapshot() core.Snapshot {
+	attributes := map[string]interface{}{
+		"maxBacklogSize":    l.maxBacklogSize,
+		"maxBacklogTimeout": l.maxBacklogTimeout,
+		"ordering":          l.backlog.ordering,
+	}
+	if l.codel != nil {
+		attributes["codelTarget"] = l.codel.target
+		attributes["codelInterval"] = l.codel.interval
+	}
+	return wrapperSnapshot("QueueBlockingLimiter", l.delegate, core.Snapshot{
+		QueueDepth: int(l.backlog.len()),
+		Rejected:   atomic.LoadUint64(&l.rejected),
+		Attributes: attributes,
+	})
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/platinummonkey/go-concurrency-limits/clock"
 	"github.com/platinummonkey/go-concurrency-limits/core"
 	"github.com/platinummonkey/go-concurrency-limits/limit"
 	"github.com/platinummonkey/go-concurrency-limits/strategy"
@@ -468,4 +469,215 @@ func TestQueueBlockingLimiter_Lifo(t *testing.T) {
 
 		asrt.Equal(limiter.backlog.len(), uint64(5))
 	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		delegateLimiter, _ := NewDefaultLimiter(
+			limit.NewFixedLimit("test", 1, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(1),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		limiter := NewQueueBlockingLimiterFromConfig(delegateLimiter, QueueLimiterConfig{
+			MaxBacklogSize:    1,
+			MaxBacklogTimeout: time.Hour,
+		})
+
+		listener, ok := limiter.Acquire(context.Background())
+		asrt.True(ok)
+
+		queued := make(chan core.Listener)
+		go func() {
+			l, _ := limiter.Acquire(context.Background())
+			queued <- l
+		}()
+		asrt.Eventually(func() bool { return limiter.backlog.len() == 1 }, time.Second, time.Millisecond)
+
+		// backlog is full so this is rejected immediately
+		_, ok = limiter.Acquire(context.Background())
+		asrt.False(ok)
+
+		snapshot := limiter.Snapshot()
+		asrt.Equal("QueueBlockingLimiter", snapshot.Type)
+		asrt.Equal(1, snapshot.Limit)
+		asrt.Equal(1, snapshot.InFlight)
+		asrt.Equal(1, snapshot.QueueDepth)
+		asrt.Equal(uint64(1), snapshot.Rejected)
+		asrt.Equal("DefaultLimiter", snapshot.Delegate.Type)
+		asrt.Equal("FixedLimit", snapshot.Delegate.Algorithm.Type)
+
+		depths := make([]int, 0)
+		snapshot.Walk(func(depth int, s *core.Snapshot) {
+			depths = append(depths, depth)
+		})
+		asrt.Equal([]int{0, 1, 2, 2}, depths)
+
+		listener.OnSuccess()
+		(<-queued).OnSuccess()
+	})
+
+	t.Run("AcquireN", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		delegateLimiter, _ := NewDefaultLimiter(
+			limit.NewFixedLimit("test", 10, nil),
+			defaultMinWindowTime,
+			defaultMaxWindowTime,
+			defaultMinRTTThreshold,
+			defaultWindowSize,
+			strategy.NewSimpleStrategy(10),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		limiter := NewQueueBlockingLimiterFromConfig(delegateLimiter, QueueLimiterConfig{
+			MaxBacklogTimeout: time.Hour,
+		})
+
+		first, ok := limiter.AcquireN(context.Background(), 5)
+		asrt.True(ok)
+		second, ok := limiter.AcquireN(context.Background(), 4)
+		asrt.True(ok)
+
+		acquired := make(chan core.Listener)
+		go func() {
+			listener, _ := limiter.AcquireN(context.Background(), 6)
+			acquired <- listener
+		}()
+		asrt.Eventually(func() bool { return limiter.backlog.len() == 1 }, time.Second, time.Millisecond)
+
+		// releasing 4 units is not enough for the queued weight of 6
+		second.OnSuccess()
+		asrt.Equal(uint64(1), limiter.backlog.len())
+
+		first.OnSuccess()
+		listener := <-acquired
+		asrt.NotNil(listener)
+		asrt.Equal(6, delegateLimiter.Snapshot().InFlight)
+		listener.OnSuccess()
+	})
+
+	t.Run("AcquireNUnweightedDelegate", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		for _, delegate := range []core.Limiter{
+			&unweightedLimiter{delegate: newObserverTestLimiter(10)},
+			newUnweightedStrategyLimiter(10),
+		} {
+			limiter := NewQueueBlockingLimiterFromConfig(delegate, QueueLimiterConfig{MaxBacklogTimeout: time.Hour})
+			_, err := limiter.AcquireNWithReason(context.Background(), 2)
+			asrt.ErrorIs(err, core.ErrUnsupported)
+			// the request was never queued
+			asrt.Equal(uint64(0), limiter.backlog.len())
+			listener, err := limiter.AcquireNWithReason(context.Background(), 1)
+			asrt.NoError(err)
+			listener.OnSuccess()
+		}
+	})
+
+	t.Run("AcquireWithReason", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		limiter := NewQueueBlockingLimiterFromConfig(newObserverTestLimiter(1), QueueLimiterConfig{
+			MaxBacklogSize:    1,
+			MaxBacklogTimeout: time.Second,
+			Clock:             fakeClock,
+		})
+
+		listener, err := limiter.AcquireWithReason(context.Background())
+		asrt.NoError(err)
+
+		queued := make(chan error)
+		go func() {
+			_, err := limiter.AcquireWithReason(context.Background())
+			queued <- err
+		}()
+		fakeClock.BlockUntil(1)
+
+		_, err = limiter.AcquireWithReason(context.Background())
+		asrt.ErrorIs(err, core.ErrQueueFull)
+
+		fakeClock.Advance(time.Second)
+		asrt.ErrorIs(<-queued, core.ErrQueueTimeout)
+		listener.OnSuccess()
+	})
+
+	t.Run("RetryAfter", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		delegateLimiter := newObserverTestLimiter(1)
+		delegateLimiter.SetClock(fakeClock)
+		limiter := NewQueueBlockingLimiterFromConfig(delegateLimiter, QueueLimiterConfig{Clock: fakeClock})
+		asrt.Equal(time.Duration(0), limiter.RetryAfter())
+
+		listener, ok := limiter.Acquire(context.Background())
+		asrt.True(ok)
+		fakeClock.Advance(10 * time.Millisecond)
+		listener.OnSuccess()
+		asrt.Equal(10*time.Millisecond, limiter.RetryAfter())
+
+		listener, ok = limiter.Acquire(context.Background())
+		asrt.True(ok)
+		acquired := make(chan core.Listener)
+		go func() {
+			queued, _ := limiter.Acquire(context.Background())
+			acquired <- queued
+		}()
+		asrt.Eventually(func() bool { return limiter.backlog.len() == 1 }, time.Second, time.Millisecond)
+
+		// the queued caller is served first
+		asrt.Equal(20*time.Millisecond, limiter.RetryAfter())
+		listener.OnSuccess()
+		(<-acquired).OnSuccess()
+	})
+
+	t.Run("CoDel", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		limiter := NewQueueBlockingLimiterFromConfig(newObserverTestLimiter(1), QueueLimiterConfig{
+			Ordering:    OrderingFIFO,
+			CoDelTarget: 5 * time.Millisecond,
+			Clock:       fakeClock,
+		})
+		asrt.Equal(100*time.Millisecond, limiter.Snapshot().Attributes["codelInterval"])
+
+		type result struct {
+			listener core.Listener
+			err      error
+		}
+		listener, err := limiter.AcquireWithReason(context.Background())
+		asrt.NoError(err)
+		results := make([]chan result, 3)
+		for i := range results {
+			results[i] = make(chan result, 1)
+			go func(results chan<- result) {
+				listener, err := limiter.AcquireWithReason(context.Background())
+				results <- result{listener, err}
+			}(results[i])
+			fakeClock.BlockUntil(i + 1)
+		}
+
+		// the queueing delay must stay above the target for an interval
+		fakeClock.Advance(10 * time.Millisecond)
+		listener.OnSuccess()
+		first := <-results[0]
+		asrt.NoError(first.err)
+
+		// the oldest waiter is dropped, then the next one is within the drop interval
+		fakeClock.Advance(100 * time.Millisecond)
+		first.listener.OnSuccess()
+		second := <-results[1]
+		asrt.ErrorIs(second.err, core.ErrQueueDropped)
+		third := <-results[2]
+		asrt.NoError(third.err)
+		third.listener.OnSuccess()
+		asrt.Equal(uint64(1), limiter.Snapshot().Rejected)
+	})
 }
@@ -0,0 +1,39 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// acquireN will acquire a token worth weight units from the given limiter.  Only limiters implementing
+// core.WeightedLimiter support a weight other than 1, any other limiter rejects weighted acquisitions with
+// core.RejectReasonUnsupported, as does a weighted limiter that reports it can never acquire the weight.  Callers must
+// not wait for a release after such a rejection.
+func acquireN(ctx context.Context, l core.Limiter, weight int) (core.Listener, core.RejectReason) {
+	if weight <= 1 {
+		if listener, ok := l.Acquire(ctx); ok && listener != nil {
+			return listener, ""
+		}
+		return nil, core.RejectReasonLimitExceeded
+	}
+	if weighted, ok := l.(core.WeightedReasonLimiter); ok {
+		listener, err := weighted.AcquireNWithReason(ctx, weight)
+		if err == nil && listener != nil {
+			return listener, ""
+		}
+		var rejection *core.RejectionError
+		if errors.As(err, &rejection) && rejection.Reason == core.RejectReasonUnsupported {
+			return nil, core.RejectReasonUnsupported
+		}
+		return nil, core.RejectReasonLimitExceeded
+	}
+	if weighted, ok := l.(core.WeightedLimiter); ok {
+		if listener, ok := weighted.AcquireN(ctx, weight); ok && listener != nil {
+			return listener, ""
+		}
+		return nil, core.RejectReasonLimitExceeded
+	}
+	return nil, core.RejectReasonUnsupported
+}
@@ -12,6 +12,7 @@ type ImmutableSampleWindow struct {
 	minRTT      int64
 	maxInFlight int
 	sampleCount int
+	dropCount   int
 	sum         int64
 	didDrop     bool
 }
@@ -62,7 +63,9 @@ func (s *ImmutableSampleWindow) AddSample(startTime int64, rtt int64, maxInFligh
 	if startTime < 0 {
 		startTime = time.Now().UnixNano()
 	}
-	return NewImmutableSampleWindow(startTime, minRTT, s.sum+rtt, maxInFlight, s.sampleCount+1, s.didDrop)
+	w := NewImmutableSampleWindow(startTime, minRTT, s.sum+rtt, maxInFlight, s.sampleCount+1, s.didDrop)
+	w.dropCount = s.dropCount
+	return w
 }
 
 // AddDroppedSample will create a new immutable sample that was dropped.
@@ -73,7 +76,9 @@ func (s *ImmutableSampleWindow) AddDroppedSample(startTime int64, maxInFlight in
 	if startTime < 0 {
 		startTime = time.Now().UnixNano()
 	}
-	return NewImmutableSampleWindow(startTime, s.minRTT, s.sum, maxInFlight, s.sampleCount, true)
+	w := NewImmutableSampleWindow(startTime, s.minRTT, s.sum, maxInFlight, s.sampleCount, true)
+	w.dropCount = s.dropCount + 1
+	return w
 }
 
 // StartTimeNanoseconds returns the epoch start time in nanoseconds.
@@ -104,6 +109,11 @@ func (s *ImmutableSampleWindow) SampleCount() int {
 	return s.sampleCount
 }
 
+// DropCount is the number of dropped samples in the sample window, which are not included in SampleCount.
+func (s *ImmutableSampleWindow) DropCount() int {
+	return s.dropCount
+}
+
 // DidDrop returns True if there was a timeout.
 func (s *ImmutableSampleWindow) DidDrop() bool {
 	return s.didDrop
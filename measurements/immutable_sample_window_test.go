@@ -26,8 +26,12 @@ func TestImmutableSampleWindow(t *testing.T) {
 	// Adding a dropped sample should mark the window as having contained dropped tokens
 	w3 := w2.AddDroppedSample(-10, 500)
 	asrt.True(w3.DidDrop())
+	asrt.Equal(1, w3.DropCount())
+	asrt.Equal(1, w3.SampleCount())
 
 	// Adding a successful sample should not void the dropped marker on the window
 	w4 := w3.AddSample(10, 10, 5)
 	asrt.True(w4.DidDrop())
+	asrt.Equal(1, w4.DropCount())
+	asrt.Equal(2, w4.SampleCount())
 }
@@ -0,0 +1,3 @@
+// Package persist saves the learned state of limit algorithms and restores it at startup, so that a freshly deployed
+// instance starts from the limit its predecessor learned instead of relearning it from the initial limit.
+package persist
@@ -0,0 +1,211 @@
+package persist
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// Config is the optional configuration of a Persister.
+type Config struct {
+	// Interval is the time between periodic saves once started.  Defaults to 30 seconds.
+	Interval time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
+	// MaxAge is the age after which a saved state is too stale to restore and is dropped.  Zero keeps states forever.
+	MaxAge time.Duration `yaml:"maxAge,omitempty" json:"maxAge,omitempty"`
+	// OnError is called with the errors of periodic saves, and with the error of loading the store if it is first
+	// loaded by a save rather than by Register.
+	OnError func(err error) `yaml:"-" json:"-"`
+
+	// Clock is used to timestamp and schedule saves, defaults to the system clock.
+	Clock core.Clock `yaml:"-" json:"-"`
+}
+
+// ApplyDefaults is used by NewPersister to set defaults for optional persister configuration arguments
+func (c *Config) ApplyDefaults() {
+	if c.Interval <= 0 {
+		c.Interval = 30 * time.Second
+	}
+
+	if c.MaxAge < 0 {
+		c.MaxAge = 0
+	}
+
+	if c.OnError == nil {
+		c.OnError = func(err error) {}
+	}
+
+	if c.Clock == nil {
+		c.Clock = core.SystemClockInstance
+	}
+}
+
+// Persister seeds registered limits with their saved state and periodically saves the state of every registered
+// limit to a Store.  Saved states of limits that are not registered (yet) are kept until they are older than MaxAge,
+// so that lazily created limits, i.e. those of a registry.KeyedRegistry, are still seeded after a restart.
+type Persister struct {
+	store    Store
+	interval time.Duration
+	maxAge   time.Duration
+	onError  func(err error)
+	clock    core.Clock
+
+	mu      sync.Mutex
+	loaded  bool
+	saved   map[string]Entry
+	limits  map[string]core.StatefulLimit
+	started bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewPersister will create a new Persister using the given store.
+func NewPersister(store Store, config Config) *Persister {
+	config.ApplyDefaults()
+	return &Persister{
+		store:    store,
+		interval: config.Interval,
+		maxAge:   config.MaxAge,
+		onError:  config.OnError,
+		clock:    config.Clock,
+		limits:   make(map[string]core.StatefulLimit),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Register will seed the limit with its saved state, if there is one that is not stale, and include it in future
+// saves.  It returns whether the limit was seeded.  A saved state that can not be imported, i.e. because the limit
+// algorithm changed, is dropped and its error returned, the limit is still registered and keeps its initial state.
+// Likewise a store that can not be loaded, i.e. a corrupt file, is treated as empty and its error returned by the
+// first call, so that the next save replaces it.
+func (p *Persister) Register(name string, l core.StatefulLimit) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limits[name] = l
+	if err := p.loadLocked(); err != nil {
+		return false, err
+	}
+
+	entry, ok := p.saved[name]
+	if !ok {
+		return false, nil
+	}
+	delete(p.saved, name)
+	if p.isStale(entry) {
+		return false, nil
+	}
+	if err := l.ImportState(entry.State); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Unregister will stop saving the named limit, its last saved state is kept until it is stale.
+func (p *Persister) Unregister(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.limits[name]
+	if !ok {
+		return
+	}
+	delete(p.limits, name)
+	if state, err := l.ExportState(); err == nil {
+		p.saved[name] = Entry{SavedAt: p.clock.Now(), State: state}
+	}
+}
+
+// Save will export the state of every registered limit and write it to the store.  Limits that do not support state,
+// i.e. a decorator around a limit without state, are skipped.
+func (p *Persister) Save() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.loadLocked(); err != nil {
+		p.onError(err)
+	}
+
+	now := p.clock.Now()
+	entries := make(map[string]Entry, len(p.saved)+len(p.limits))
+	for name, entry := range p.saved {
+		if p.isStale(entry) {
+			delete(p.saved, name)
+			continue
+		}
+		entries[name] = entry
+	}
+	for name, l := range p.limits {
+		state, err := l.ExportState()
+		if errors.Is(err, core.ErrStateNotSupported) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		entries[name] = Entry{SavedAt: now, State: state}
+	}
+	return p.store.Save(entries)
+}
+
+// Start will save every interval in a background goroutine until Stop is called.
+func (p *Persister) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started {
+		return
+	}
+	p.started = true
+	go p.run()
+}
+
+// Stop will stop a started persister and save a final time, so the state survives a graceful shutdown.
+func (p *Persister) Stop() error {
+	p.mu.Lock()
+	started := p.started
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	p.mu.Unlock()
+	if started {
+		<-p.done
+	}
+	return p.Save()
+}
+
+func (p *Persister) run() {
+	defer close(p.done)
+	for {
+		timer := p.clock.NewTimer(p.interval)
+		select {
+		case <-p.stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+			if err := p.Save(); err != nil {
+				p.onError(err)
+			}
+		}
+	}
+}
+
+// loadLocked will load the saved states the first time it is called, returning the error of the store only then.
+// The saved states are empty if the store can not be loaded.
+func (p *Persister) loadLocked() error {
+	if p.loaded {
+		return nil
+	}
+	p.loaded = true
+	saved, err := p.store.Load()
+	if err != nil {
+		p.saved = make(map[string]Entry)
+		return err
+	}
+	p.saved = saved
+	return nil
+}
+
+func (p *Persister) isStale(entry Entry) bool {
+	return p.maxAge > 0 && p.clock.Now().Sub(entry.SavedAt) > p.maxAge
+}
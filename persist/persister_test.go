@@ -0,0 +1,195 @@
+package persist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	saves   int
+	err     error
+}
+
+func (s *memoryStore) Load() (map[string]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make(map[string]Entry, len(s.entries))
+	for k, v := range s.entries {
+		entries[k] = v
+	}
+	return entries, s.err
+}
+
+func (s *memoryStore) Save(entries map[string]Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+	s.saves++
+	return s.err
+}
+
+func (s *memoryStore) saveCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
+
+func TestPersister(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RestoreAcrossRestart", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		store := NewFileStore(filepath.Join(t2.TempDir(), "limits.json"))
+
+		// the first instance learns a lower limit and saves it on shutdown
+		p1 := NewPersister(store, Config{})
+		l1 := limit.NewAIMDLimit("test", 10, 0.5, 1, nil)
+		restored, err := p1.Register("aimd", l1)
+		asrt.NoError(err)
+		asrt.False(restored)
+		l1.OnSample(0, 1, 1, true)
+		asrt.Equal(5, l1.EstimatedLimit())
+		asrt.NoError(p1.Stop())
+
+		// the next instance starts from it
+		p2 := NewPersister(store, Config{})
+		l2 := limit.NewAIMDLimit("test", 10, 0.5, 1, nil)
+		restored, err = p2.Register("aimd", l2)
+		asrt.NoError(err)
+		asrt.True(restored)
+		asrt.Equal(5, l2.EstimatedLimit())
+	})
+
+	t.Run("KeepsUnregisteredUntilStale", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+		state := core.LimitState{Version: core.LimitStateVersion, Type: "AIMDLimit", Limit: 7}
+		store := &memoryStore{entries: map[string]Entry{
+			"fresh": {SavedAt: fakeClock.Now(), State: state},
+			"stale": {SavedAt: fakeClock.Now().Add(-2 * time.Hour), State: state},
+		}}
+		p := NewPersister(store, Config{MaxAge: time.Hour, Clock: fakeClock})
+
+		l := limit.NewAIMDLimit("test", 10, 0.5, 1, nil)
+		restored, err := p.Register("stale", l)
+		asrt.NoError(err)
+		asrt.False(restored)
+		asrt.Equal(10, l.EstimatedLimit())
+
+		asrt.NoError(p.Save())
+		asrt.Len(store.entries, 2)
+		asrt.Equal(7.0, store.entries["fresh"].State.Limit)
+		asrt.Equal(10.0, store.entries["stale"].State.Limit)
+
+		// unregistered limits keep their last state, until it ages out
+		p.Unregister("stale")
+		p.Unregister("missing")
+		fakeClock.Advance(30 * time.Minute)
+		asrt.NoError(p.Save())
+		asrt.Len(store.entries, 2)
+		fakeClock.Advance(time.Hour)
+		asrt.NoError(p.Save())
+		asrt.Empty(store.entries)
+	})
+
+	t.Run("ImportError", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		store := &memoryStore{entries: map[string]Entry{
+			"a": {SavedAt: time.Now(), State: core.LimitState{Version: core.LimitStateVersion, Type: "VegasLimit",
+				Limit: 7}},
+		}}
+		p := NewPersister(store, Config{})
+		l := limit.NewAIMDLimit("test", 10, 0.5, 1, nil)
+		restored, err := p.Register("a", l)
+		asrt.Error(err)
+		asrt.False(restored)
+		asrt.Equal(10, l.EstimatedLimit())
+
+		// unsupported limits are skipped on save
+		_, err = p.Register("traced", limit.NewTracedLimit(limit.NewFixedLimit("fixed", 10, nil), limit.NoopLimitLogger{}))
+		asrt.NoError(err)
+		asrt.NoError(p.Save())
+		asrt.Len(store.entries, 1)
+		asrt.Equal("AIMDLimit", store.entries["a"].State.Type)
+	})
+
+	t.Run("LoadError", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		store := &memoryStore{err: errors.New("unavailable")}
+		p := NewPersister(store, Config{})
+		_, err := p.Register("a", limit.NewAIMDLimit("test", 10, 0.5, 1, nil))
+		asrt.EqualError(err, "unavailable")
+		asrt.EqualError(p.Save(), "unavailable")
+
+		// the limit is registered and the load error is only reported once
+		store.err = nil
+		_, err = p.Register("b", limit.NewAIMDLimit("test", 10, 0.5, 1, nil))
+		asrt.NoError(err)
+		asrt.NoError(p.Save())
+		asrt.Len(store.entries, 2)
+	})
+
+	t.Run("CorruptFile", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		path := filepath.Join(t2.TempDir(), "limits.json")
+		asrt.NoError(os.WriteFile(path, []byte("{not json"), 0o600))
+
+		// the corrupt file is treated as empty and replaced by the next save
+		p := NewPersister(NewFileStore(path), Config{})
+		l := limit.NewAIMDLimit("test", 10, 0.5, 1, nil)
+		l.OnSample(-1, 1, 1, true)
+		restored, err := p.Register("a", l)
+		asrt.Error(err)
+		asrt.False(restored)
+		asrt.NoError(p.Save())
+
+		restored, err = NewPersister(NewFileStore(path), Config{}).Register("a", limit.NewAIMDLimit("test", 10, 0.5, 1, nil))
+		asrt.NoError(err)
+		asrt.True(restored)
+
+		// a save that loads the store first reports the error through OnError
+		asrt.NoError(os.WriteFile(path, []byte("{not json"), 0o600))
+		var errs []error
+		p = NewPersister(NewFileStore(path), Config{OnError: func(err error) { errs = append(errs, err) }})
+		asrt.NoError(p.Save())
+		asrt.NoError(p.Save())
+		asrt.Len(errs, 1)
+		_, err = NewFileStore(path).Load()
+		asrt.NoError(err)
+	})
+
+	t.Run("Periodic", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		store := &memoryStore{}
+		p := NewPersister(store, Config{Interval: time.Second, Clock: fakeClock})
+		_, err := p.Register("a", limit.NewAIMDLimit("test", 10, 0.5, 1, nil))
+		asrt.NoError(err)
+		p.Start()
+		p.Start()
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(time.Second)
+		fakeClock.BlockUntil(1)
+		asrt.Equal(1, store.saveCount())
+		asrt.NoError(p.Stop())
+		asrt.Equal(2, store.saveCount())
+	})
+}
@@ -0,0 +1,91 @@
+package persist
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// fileFormatVersion is the version of the FileStore file format.
+const fileFormatVersion = 1
+
+// Entry is the saved state of a single named limit.
+type Entry struct {
+	SavedAt time.Time       `json:"savedAt"`
+	State   core.LimitState `json:"state"`
+}
+
+// Store saves and loads the states of named limits.
+type Store interface {
+	// Load returns the saved entries by limit name, or an empty map if nothing was saved yet.
+	Load() (map[string]Entry, error)
+	// Save replaces the saved entries.
+	Save(entries map[string]Entry) error
+}
+
+type file struct {
+	Version int              `json:"version"`
+	Limits  map[string]Entry `json:"limits"`
+}
+
+// FileStore is a Store that keeps the entries in a single JSON file.  Saves write a temporary file and rename it over
+// the previous one, so a crash never leaves a partially written file behind.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore will create a FileStore using the file at path, which does not need to exist yet.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Load will read the entries from the file.
+func (s *FileStore) Load() (map[string]Entry, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return make(map[string]Entry), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f := file{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid limit state file %s: %w", s.path, err)
+	}
+	if f.Version != fileFormatVersion {
+		return nil, fmt.Errorf("unsupported limit state file version %d", f.Version)
+	}
+	if f.Limits == nil {
+		f.Limits = make(map[string]Entry)
+	}
+	return f.Limits, nil
+}
+
+// Save will atomically replace the file with the entries.
+func (s *FileStore) Save(entries map[string]Entry) error {
+	data, err := json.MarshalIndent(file{Version: fileFormatVersion, Limits: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *FileStore) String() string {
+	return fmt.Sprintf("FileStore{path=%s}", s.path)
+}
@@ -0,0 +1,65 @@
+package persist
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RoundTrip", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		path := filepath.Join(t2.TempDir(), "limits.json")
+		s := NewFileStore(path)
+
+		entries, err := s.Load()
+		asrt.NoError(err)
+		asrt.Empty(entries)
+
+		saved := map[string]Entry{
+			"a": {
+				SavedAt: time.Unix(100, 0).UTC(),
+				State: core.LimitState{
+					Version: core.LimitStateVersion,
+					Type:    "VegasLimit",
+					Limit:   42.5,
+					Values:  map[string]float64{"rttNoLoad": 1e6},
+				},
+			},
+		}
+		asrt.NoError(s.Save(saved))
+		entries, err = s.Load()
+		asrt.NoError(err)
+		asrt.Equal(saved, entries)
+
+		// no temporary files are left behind
+		files, err := os.ReadDir(filepath.Dir(path))
+		asrt.NoError(err)
+		asrt.Len(files, 1)
+		asrt.Equal("FileStore{path="+path+"}", s.String())
+	})
+
+	t.Run("Invalid", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		dir := t2.TempDir()
+		garbage := filepath.Join(dir, "garbage.json")
+		future := filepath.Join(dir, "future.json")
+		asrt.NoError(os.WriteFile(garbage, []byte("{"), 0o600))
+		asrt.NoError(os.WriteFile(future, []byte(`{"version": 99}`), 0o600))
+
+		_, err := NewFileStore(garbage).Load()
+		asrt.Error(err)
+		_, err = NewFileStore(future).Load()
+		asrt.Error(err)
+		asrt.Error(NewFileStore(filepath.Join(dir, "missing", "limits.json")).Save(nil))
+	})
+}
@@ -0,0 +1,5 @@
+// Package recording provides record and replay of the samples fed to a limit algorithm.  A Limit wraps any
+// core.Limit and writes every OnSample call, along with the optional calls a limiter makes through the extensions of
+// core.Limit, to a compact binary trace, which can later be fed through any other limit implementation with Replay to
+// compare how the algorithms would have reacted to the same production traffic.
+package recording
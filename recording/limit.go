@@ -0,0 +1,143 @@
+package recording
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// Limit implements core.Limit by delegating to another limit and recording every sample to a trace.  The optional
+// calls of core.ThroughputLimit, core.RTTObservingLimit, core.DropRateLimit and core.IdleLimit are recorded as well,
+// whether or not the wrapped limit implements them, so that a replay through a limit that does reproduces them.
+type Limit struct {
+	limit  core.Limit
+	writer *Writer
+	clock  core.Clock
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewLimit returns a new wrapped Limit that records every sample to writer.
+func NewLimit(limit core.Limit, writer *Writer) *Limit {
+	return &Limit{
+		limit:  limit,
+		writer: writer,
+		clock:  core.SystemClockInstance,
+	}
+}
+
+// SetClock will set the clock used to timestamp the recorded samples.
+func (l *Limit) SetClock(clock core.Clock) {
+	if clock == nil {
+		clock = core.SystemClockInstance
+	}
+	l.clock = clock
+}
+
+// EstimatedLimit returns the estimated limit.
+func (l *Limit) EstimatedLimit() int {
+	return l.limit.EstimatedLimit()
+}
+
+// NotifyOnChange will register a callback to receive notification whenever the limit is updated to a new value.
+func (l *Limit) NotifyOnChange(consumer core.LimitChangeListener) {
+	l.limit.NotifyOnChange(consumer)
+}
+
+// OnSample will record and delegate the update of the sample.  A failure to record never fails the sample, the first
+// error is kept and returned by Err.
+func (l *Limit) OnSample(startTime int64, rtt int64, inFlight int, didDrop bool) {
+	l.record(Sample{
+		Kind:      KindSample,
+		StartTime: startTime,
+		RTT:       rtt,
+		InFlight:  inFlight,
+		DidDrop:   didDrop,
+	})
+	l.limit.OnSample(startTime, rtt, inFlight, didDrop)
+}
+
+// OnThroughput will record the throughput and delegate it to the wrapped limit if it is a core.ThroughputLimit.
+func (l *Limit) OnThroughput(completed int, duration time.Duration) {
+	l.record(Sample{Kind: KindThroughput, Completed: completed, Duration: duration})
+	if throughputLimit, ok := l.limit.(core.ThroughputLimit); ok {
+		throughputLimit.OnThroughput(completed, duration)
+	}
+}
+
+// ObserveRTT will record the RTT and delegate it to the wrapped limit if it is a core.RTTObservingLimit.
+func (l *Limit) ObserveRTT(rtt int64) {
+	l.record(Sample{Kind: KindRTT, RTT: rtt})
+	if rttLimit, ok := l.limit.(core.RTTObservingLimit); ok {
+		rttLimit.ObserveRTT(rtt)
+	}
+}
+
+// OnDropRate will record the drop rate and delegate it to the wrapped limit if it is a core.DropRateLimit.
+func (l *Limit) OnDropRate(dropped, total int) {
+	l.record(Sample{Kind: KindDropRate, Dropped: dropped, Total: total})
+	if dropRateLimit, ok := l.limit.(core.DropRateLimit); ok {
+		dropRateLimit.OnDropRate(dropped, total)
+	}
+}
+
+// CheckIdle will record the idle check and delegate it to the wrapped limit if it is a core.IdleLimit.
+func (l *Limit) CheckIdle() {
+	l.record(Sample{Kind: KindIdle})
+	if idleLimit, ok := l.limit.(core.IdleLimit); ok {
+		idleLimit.CheckIdle()
+	}
+}
+
+// record will write the sample timestamped with the clock, keeping the first error.
+func (l *Limit) record(sample Sample) {
+	sample.Time = l.clock.Now()
+	if err := l.writer.Write(sample); err != nil {
+		l.mu.Lock()
+		if l.err == nil {
+			l.err = err
+		}
+		l.mu.Unlock()
+	}
+}
+
+// Err returns the first error encountered while recording, if any.
+func (l *Limit) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+// ExportState returns the state of the wrapped limit, or core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *Limit) ExportState() (core.LimitState, error) {
+	if stateful, ok := l.limit.(core.StatefulLimit); ok {
+		return stateful.ExportState()
+	}
+	return core.LimitState{}, core.ErrStateNotSupported
+}
+
+// ImportState will restore the state of the wrapped limit, or return core.ErrStateNotSupported if it is not a
+// core.StatefulLimit.
+func (l *Limit) ImportState(state core.LimitState) error {
+	if stateful, ok := l.limit.(core.StatefulLimit); ok {
+		return stateful.ImportState(state)
+	}
+	return core.ErrStateNotSupported
+}
+
+// Snapshot returns the snapshot of the wrapped limit.
+func (l *Limit) Snapshot() core.Snapshot {
+	return core.Snapshot{
+		Type:     "RecordingLimit",
+		Limit:    l.limit.EstimatedLimit(),
+		Delegate: core.SnapshotOf(l.limit),
+	}
+}
+
+func (l *Limit) String() string {
+	return fmt.Sprintf("RecordingLimit{limit=%v}", l.limit)
+}
@@ -0,0 +1,176 @@
+package recording
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// spyLimit is a limit that records the optional calls it receives.
+type spyLimit struct {
+	*limit.SettableLimit
+	calls []string
+}
+
+func newSpyLimit() *spyLimit {
+	return &spyLimit{SettableLimit: limit.NewSettableLimit("spy", 10, nil)}
+}
+
+func (l *spyLimit) OnThroughput(completed int, duration time.Duration) {
+	l.calls = append(l.calls, fmt.Sprintf("throughput %d %s", completed, duration))
+}
+
+func (l *spyLimit) ObserveRTT(rtt int64) {
+	l.calls = append(l.calls, fmt.Sprintf("rtt %d", rtt))
+}
+
+func (l *spyLimit) OnDropRate(dropped, total int) {
+	l.calls = append(l.calls, fmt.Sprintf("drop rate %d/%d", dropped, total))
+}
+
+func (l *spyLimit) CheckIdle() {
+	l.calls = append(l.calls, "idle")
+}
+
+func TestLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Records", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		buf := &bytes.Buffer{}
+		w, err := NewWriter(buf)
+		asrt.NoError(err)
+		fakeClock := clock.NewFakeClock(time.Unix(10, 0))
+		l := NewLimit(limit.NewAIMDLimit("test", 10, 0.5, 1, nil), w)
+		l.SetClock(fakeClock)
+
+		l.OnSample(0, 10, 10, false)
+		fakeClock.Advance(time.Second)
+		l.OnSample(0, 10, 10, true)
+		asrt.Equal(5, l.EstimatedLimit())
+		asrt.NoError(w.Flush())
+		asrt.NoError(l.Err())
+
+		r, err := NewReader(buf)
+		asrt.NoError(err)
+		samples, err := r.ReadAll()
+		asrt.NoError(err)
+		asrt.Len(samples, 2)
+		asrt.True(samples[0].Time.Equal(time.Unix(10, 0)))
+		asrt.True(samples[1].Time.Equal(time.Unix(11, 0)))
+		asrt.False(samples[0].DidDrop)
+		asrt.True(samples[1].DidDrop)
+		asrt.Equal(10, samples[1].InFlight)
+
+		asrt.Equal("RecordingLimit{limit=AIMDLimit{limit=5, backOffRatio=0.5000}}", l.String())
+		snapshot := l.Snapshot()
+		asrt.Equal("RecordingLimit", snapshot.Type)
+		asrt.Equal(5, snapshot.Limit)
+		asrt.Equal("AIMDLimit", snapshot.Delegate.Type)
+
+		state, err := l.ExportState()
+		asrt.NoError(err)
+		asrt.Equal(5.0, state.Limit)
+		asrt.NoError(l.ImportState(state))
+	})
+
+	optionalCalls := []struct {
+		kind   Kind
+		call   func(l *Limit)
+		calls  []string
+		sample Sample
+	}{
+		{
+			kind:   KindThroughput,
+			call:   func(l *Limit) { l.OnThroughput(100, time.Second) },
+			calls:  []string{"throughput 100 1s"},
+			sample: Sample{Completed: 100, Duration: time.Second},
+		},
+		{
+			kind:   KindRTT,
+			call:   func(l *Limit) { l.ObserveRTT(int64(5 * time.Millisecond)) },
+			calls:  []string{"rtt 5000000"},
+			sample: Sample{RTT: int64(5 * time.Millisecond)},
+		},
+		{
+			kind:   KindDropRate,
+			call:   func(l *Limit) { l.OnDropRate(1, 4) },
+			calls:  []string{"drop rate 1/4"},
+			sample: Sample{Dropped: 1, Total: 4},
+		},
+		{
+			kind:  KindIdle,
+			call:  func(l *Limit) { l.CheckIdle() },
+			calls: []string{"idle"},
+		},
+	}
+	for _, c := range optionalCalls {
+		c := c
+		t.Run(c.kind.String(), func(t2 *testing.T) {
+			t2.Parallel()
+			asrt := assert.New(t2)
+			buf := &bytes.Buffer{}
+			w, err := NewWriter(buf)
+			asrt.NoError(err)
+			fakeClock := clock.NewFakeClock(time.Unix(10, 0))
+			spy := newSpyLimit()
+			l := NewLimit(spy, w)
+			l.SetClock(fakeClock)
+			c.call(l)
+			asrt.Equal(c.calls, spy.calls)
+			// a wrapped limit that does not implement the call still records it
+			c.call(NewLimit(limit.NewSettableLimit("test", 10, nil), w))
+			asrt.NoError(w.Flush())
+
+			r, err := NewReader(buf)
+			asrt.NoError(err)
+			samples, err := r.ReadAll()
+			asrt.NoError(err)
+			asrt.Len(samples, 2)
+			asrt.True(samples[0].Time.Equal(time.Unix(10, 0)))
+			expected := c.sample
+			expected.Kind = c.kind
+			expected.Time = samples[0].Time
+			asrt.Equal(expected, samples[0])
+			asrt.Equal(c.kind, samples[1].Kind)
+
+			replayed := newSpyLimit()
+			trajectory := Replay(samples, "spy", replayed)
+			asrt.Equal(append(c.calls, c.calls...), replayed.calls)
+			asrt.Len(trajectory.Limits, 2)
+		})
+	}
+
+	t.Run("WriteErrorDoesNotFailSample", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		w, err := NewWriter(failingWriter{})
+		asrt.NoError(err)
+		l := NewLimit(limit.NewSettableLimit("test", 10, nil), w)
+		// fill the buffer so that the write reaches the failing writer
+		for i := 0; i < 1000; i++ {
+			l.OnSample(0, 10, 10, false)
+		}
+		asrt.Error(l.Err())
+		asrt.Equal(10, l.EstimatedLimit())
+
+		_, err = l.ExportState()
+		asrt.Equal(core.ErrStateNotSupported, err)
+		asrt.Equal(core.ErrStateNotSupported, l.ImportState(core.LimitState{}))
+	})
+}
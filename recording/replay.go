@@ -0,0 +1,147 @@
+package recording
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// Summary aggregates the limit trajectory of a replay.
+type Summary struct {
+	InitialLimit int `json:"initialLimit"`
+	FinalLimit   int `json:"finalLimit"`
+	MinLimit     int `json:"minLimit"`
+	MaxLimit     int `json:"maxLimit"`
+	// Changes is the number of samples after which the limit changed.
+	Changes int `json:"changes"`
+}
+
+// Trajectory is the limit of a single limit algorithm after each sample of a replayed trace.
+type Trajectory struct {
+	Name    string  `json:"name"`
+	Limits  []int   `json:"limits"`
+	Summary Summary `json:"summary"`
+}
+
+// Replay will feed the samples through l in order and return the limit after each sample.  Samples of an optional call
+// that l does not implement leave the limit unchanged.  Limits that measure time, i.e. with an IdlePolicy, do so with
+// their own clock rather than the time of the samples.
+func Replay(samples []Sample, name string, l core.Limit) Trajectory {
+	initial := l.EstimatedLimit()
+	trajectory := Trajectory{
+		Name:   name,
+		Limits: make([]int, 0, len(samples)),
+		Summary: Summary{
+			InitialLimit: initial,
+			FinalLimit:   initial,
+			MinLimit:     initial,
+			MaxLimit:     initial,
+		},
+	}
+	previous := initial
+	for _, s := range samples {
+		replay(s, l)
+		current := l.EstimatedLimit()
+		trajectory.Limits = append(trajectory.Limits, current)
+		if current != previous {
+			trajectory.Summary.Changes++
+		}
+		if current < trajectory.Summary.MinLimit {
+			trajectory.Summary.MinLimit = current
+		}
+		if current > trajectory.Summary.MaxLimit {
+			trajectory.Summary.MaxLimit = current
+		}
+		previous = current
+	}
+	trajectory.Summary.FinalLimit = previous
+	return trajectory
+}
+
+// replay will make the call recorded by s on l.
+func replay(s Sample, l core.Limit) {
+	switch s.Kind {
+	case KindSample:
+		l.OnSample(s.StartTime, s.RTT, s.InFlight, s.DidDrop)
+	case KindThroughput:
+		if throughputLimit, ok := l.(core.ThroughputLimit); ok {
+			throughputLimit.OnThroughput(s.Completed, s.Duration)
+		}
+	case KindRTT:
+		if rttLimit, ok := l.(core.RTTObservingLimit); ok {
+			rttLimit.ObserveRTT(s.RTT)
+		}
+	case KindDropRate:
+		if dropRateLimit, ok := l.(core.DropRateLimit); ok {
+			dropRateLimit.OnDropRate(s.Dropped, s.Total)
+		}
+	case KindIdle:
+		if idleLimit, ok := l.(core.IdleLimit); ok {
+			idleLimit.CheckIdle()
+		}
+	}
+}
+
+// Result is a trace together with the trajectories of one or more limit algorithms replayed over it.
+type Result struct {
+	Samples      []Sample     `json:"samples"`
+	Trajectories []Trajectory `json:"trajectories"`
+}
+
+// NewResult will replay samples through each of the limits, named by the matching entry of names.
+func NewResult(samples []Sample, names []string, limits []core.Limit) (*Result, error) {
+	if len(names) != len(limits) {
+		return nil, fmt.Errorf("got %d names for %d limits", len(names), len(limits))
+	}
+	result := &Result{Samples: samples, Trajectories: make([]Trajectory, 0, len(limits))}
+	for i, l := range limits {
+		result.Trajectories = append(result.Trajectories, Replay(samples, names[i], l))
+	}
+	return result, nil
+}
+
+// WriteCSV will write one row per sample with a header row, followed by one limit column per trajectory.  Times are
+// in seconds since the first sample and RTTs in milliseconds, the call column is the Kind of the sample.
+func (r *Result) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"time_s", "call", "rtt_ms", "in_flight", "did_drop"}
+	for _, t := range r.Trajectories {
+		header = append(header, "limit_"+t.Name)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	var start time.Time
+	if len(r.Samples) > 0 {
+		start = r.Samples[0].Time
+	}
+	for i, s := range r.Samples {
+		record := []string{
+			strconv.FormatFloat(s.Time.Sub(start).Seconds(), 'f', -1, 64),
+			s.Kind.String(),
+			strconv.FormatFloat(float64(s.RTT)/float64(time.Millisecond), 'f', 3, 64),
+			strconv.Itoa(s.InFlight),
+			strconv.FormatBool(s.DidDrop),
+		}
+		for _, t := range r.Trajectories {
+			record = append(record, strconv.Itoa(t.Limits[i]))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON will write the samples and trajectories as indented JSON.
+func (r *Result) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
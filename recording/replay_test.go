@@ -0,0 +1,62 @@
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+)
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+	start := time.Unix(0, 0)
+	samples := []Sample{
+		{Time: start, RTT: int64(time.Millisecond), InFlight: 10},
+		{Time: start.Add(time.Second), RTT: int64(time.Millisecond), InFlight: 10, DidDrop: true},
+		{Time: start.Add(2 * time.Second), RTT: int64(time.Millisecond), InFlight: 10},
+	}
+
+	t.Run("Trajectory", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		trajectory := Replay(samples, "aimd", limit.NewAIMDLimit("aimd", 10, 0.5, 1, nil))
+		asrt.Equal("aimd", trajectory.Name)
+		asrt.Equal([]int{11, 5, 6}, trajectory.Limits)
+		asrt.Equal(Summary{InitialLimit: 10, FinalLimit: 6, MinLimit: 5, MaxLimit: 11, Changes: 3},
+			trajectory.Summary)
+	})
+
+	t.Run("Output", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		result, err := NewResult(
+			samples,
+			[]string{"aimd", "fixed"},
+			[]core.Limit{limit.NewAIMDLimit("aimd", 10, 0.5, 1, nil), limit.NewFixedLimit("fixed", 10, nil)},
+		)
+		asrt.NoError(err)
+
+		buf := &bytes.Buffer{}
+		asrt.NoError(result.WriteCSV(buf))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		asrt.Equal("time_s,call,rtt_ms,in_flight,did_drop,limit_aimd,limit_fixed", lines[0])
+		asrt.Equal("1,sample,1.000,10,true,5,10", lines[2])
+		asrt.Len(lines, len(samples)+1)
+
+		buf.Reset()
+		asrt.NoError(result.WriteJSON(buf))
+		decoded := Result{}
+		asrt.NoError(json.Unmarshal(buf.Bytes(), &decoded))
+		asrt.Len(decoded.Trajectories, 2)
+		asrt.Equal(10, decoded.Trajectories[1].Summary.FinalLimit)
+
+		_, err = NewResult(samples, []string{"aimd"}, nil)
+		asrt.Error(err)
+	})
+}
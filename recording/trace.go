@@ -0,0 +1,298 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// The trace format is a header of the magic bytes and a format version, followed by one record per call.  Every record
+// starts with
+//
+//	flags       1 byte, bit 0 is didDrop and bits 1-3 the Kind
+//	time        signed varint, nanoseconds since the time of the previous record (the unix epoch for the first)
+//
+// followed by the arguments of the call, for KindSample
+//
+//	startTime   signed varint
+//	rtt         signed varint, nanoseconds
+//	inFlight    unsigned varint
+//
+// for KindThroughput
+//
+//	completed   unsigned varint
+//	duration    signed varint, nanoseconds
+//
+// for KindRTT
+//
+//	rtt         signed varint, nanoseconds
+//
+// and for KindDropRate
+//
+//	dropped     unsigned varint
+//	total       unsigned varint
+//
+// KindIdle records have no arguments.
+// Version 1 traces only contain KindSample records and are still read.
+const (
+	magic         = "GCLR"
+	version       = byte(2)
+	minVersion    = byte(1)
+	flagDidDrop   = byte(1)
+	flagKindShift = 1
+	flagKindMask  = byte(7)
+)
+
+// ErrInvalidTrace is returned when reading data that is not a trace written by a Writer.
+var ErrInvalidTrace = errors.New("invalid trace")
+
+// Kind is the limit method a Sample records a call to.
+type Kind byte
+
+const (
+	// KindSample is a call to core.Limit.OnSample.
+	KindSample Kind = iota
+	// KindThroughput is a call to core.ThroughputLimit.OnThroughput.
+	KindThroughput
+	// KindRTT is a call to core.RTTObservingLimit.ObserveRTT.
+	KindRTT
+	// KindDropRate is a call to core.DropRateLimit.OnDropRate.
+	KindDropRate
+	// KindIdle is a call to core.IdleLimit.CheckIdle.
+	KindIdle
+
+	numKinds
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindSample:
+		return "sample"
+	case KindThroughput:
+		return "throughput"
+	case KindRTT:
+		return "rtt"
+	case KindDropRate:
+		return "drop_rate"
+	case KindIdle:
+		return "idle"
+	default:
+		return fmt.Sprintf("Kind(%d)", byte(k))
+	}
+}
+
+// Sample is a single recorded call to the limit, only the fields of its Kind are set.
+type Sample struct {
+	// Kind is the method that was called.
+	Kind Kind `json:"kind,omitempty"`
+	// Time is when the sample was recorded.
+	Time time.Time `json:"time"`
+	// StartTime, RTT, InFlight and DidDrop are the arguments passed to OnSample, RTT is also the argument passed to
+	// ObserveRTT.
+	StartTime int64 `json:"startTime"`
+	RTT       int64 `json:"rttNs"`
+	InFlight  int   `json:"inFlight"`
+	DidDrop   bool  `json:"didDrop"`
+	// Completed and Duration are the arguments passed to OnThroughput.
+	Completed int           `json:"completed,omitempty"`
+	Duration  time.Duration `json:"durationNs,omitempty"`
+	// Dropped and Total are the arguments passed to OnDropRate.
+	Dropped int `json:"dropped,omitempty"`
+	Total   int `json:"total,omitempty"`
+}
+
+// Writer writes samples to a trace.  It is safe for concurrent use.
+type Writer struct {
+	mu   sync.Mutex
+	w    *bufio.Writer
+	last int64
+	buf  [1 + 4*binary.MaxVarintLen64]byte
+}
+
+// NewWriter will write the trace header to w and return a Writer for the samples.  Writes are buffered, call Flush
+// before closing w.
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(magic); err != nil {
+		return nil, err
+	}
+	if err := bw.WriteByte(version); err != nil {
+		return nil, err
+	}
+	return &Writer{w: bw}, nil
+}
+
+// Write will append a sample to the trace.
+func (w *Writer) Write(sample Sample) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if sample.Kind >= numKinds {
+		return fmt.Errorf("unknown sample kind %s", sample.Kind)
+	}
+	now := sample.Time.UnixNano()
+	flags := byte(sample.Kind) << flagKindShift
+	if sample.DidDrop {
+		flags |= flagDidDrop
+	}
+	w.buf[0] = flags
+	n := 1
+	n += binary.PutVarint(w.buf[n:], now-w.last)
+	switch sample.Kind {
+	case KindSample:
+		n += binary.PutVarint(w.buf[n:], sample.StartTime)
+		n += binary.PutVarint(w.buf[n:], sample.RTT)
+		n += binary.PutUvarint(w.buf[n:], nonNegative(sample.InFlight))
+	case KindThroughput:
+		n += binary.PutUvarint(w.buf[n:], nonNegative(sample.Completed))
+		n += binary.PutVarint(w.buf[n:], int64(sample.Duration))
+	case KindRTT:
+		n += binary.PutVarint(w.buf[n:], sample.RTT)
+	case KindDropRate:
+		n += binary.PutUvarint(w.buf[n:], nonNegative(sample.Dropped))
+		n += binary.PutUvarint(w.buf[n:], nonNegative(sample.Total))
+	}
+	if _, err := w.w.Write(w.buf[:n]); err != nil {
+		return err
+	}
+	w.last = now
+	return nil
+}
+
+// Flush will write any buffered samples to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Flush()
+}
+
+// Reader reads samples from a trace.
+type Reader struct {
+	r    *bufio.Reader
+	last int64
+}
+
+// NewReader will read and validate the trace header from r and return a Reader for the samples.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %v", ErrInvalidTrace, err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("%w: bad magic %q", ErrInvalidTrace, header[:len(magic)])
+	}
+	if header[len(magic)] < minVersion || header[len(magic)] > version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidTrace, header[len(magic)])
+	}
+	return &Reader{r: br}, nil
+}
+
+// Next will return the next sample of the trace, or io.EOF once the trace is exhausted.  A trace that ends in the
+// middle of a sample returns io.ErrUnexpectedEOF.
+func (r *Reader) Next() (Sample, error) {
+	flags, err := r.r.ReadByte()
+	if err != nil {
+		return Sample{}, err
+	}
+	kind := Kind(flags >> flagKindShift & flagKindMask)
+	if kind >= numKinds {
+		return Sample{}, fmt.Errorf("%w: unknown sample kind %d", ErrInvalidTrace, byte(kind))
+	}
+	delta, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return Sample{}, truncated(err)
+	}
+	sample := Sample{Kind: kind, DidDrop: flags&flagDidDrop != 0}
+	switch kind {
+	case KindSample:
+		err = r.readSample(&sample)
+	case KindThroughput:
+		err = r.readThroughput(&sample)
+	case KindRTT:
+		sample.RTT, err = binary.ReadVarint(r.r)
+	case KindDropRate:
+		err = r.readDropRate(&sample)
+	}
+	if err != nil {
+		return Sample{}, truncated(err)
+	}
+	r.last += delta
+	sample.Time = time.Unix(0, r.last)
+	return sample, nil
+}
+
+func (r *Reader) readSample(sample *Sample) error {
+	startTime, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return err
+	}
+	rtt, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return err
+	}
+	inFlight, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	sample.StartTime, sample.RTT, sample.InFlight = startTime, rtt, int(inFlight)
+	return nil
+}
+
+func (r *Reader) readThroughput(sample *Sample) error {
+	completed, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	duration, err := binary.ReadVarint(r.r)
+	if err != nil {
+		return err
+	}
+	sample.Completed, sample.Duration = int(completed), time.Duration(duration)
+	return nil
+}
+
+// ReadAll will read all remaining samples of the trace.
+func (r *Reader) ReadAll() ([]Sample, error) {
+	samples := make([]Sample, 0)
+	for {
+		sample, err := r.Next()
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return samples, err
+		}
+		samples = append(samples, sample)
+	}
+}
+
+func (r *Reader) readDropRate(sample *Sample) error {
+	dropped, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	total, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return err
+	}
+	sample.Dropped, sample.Total = int(dropped), int(total)
+	return nil
+}
+
+func nonNegative(v int) uint64 {
+	if v < 0 {
+		return 0
+	}
+	return uint64(v)
+}
+
+func truncated(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package recording
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+	samples := []Sample{
+		{Time: time.Unix(100, 0), StartTime: 0, RTT: int64(10 * time.Millisecond), InFlight: 5},
+		{Time: time.Unix(100, int64(time.Second)), StartTime: 7, RTT: int64(12 * time.Millisecond), InFlight: 9,
+			DidDrop: true},
+		// time going backwards must still round trip
+		{Time: time.Unix(99, 0), StartTime: -1, RTT: 0, InFlight: 0},
+		{Kind: KindThroughput, Time: time.Unix(101, 0), Completed: 120, Duration: time.Second},
+		{Kind: KindRTT, Time: time.Unix(101, 5), RTT: int64(8 * time.Millisecond)},
+		{Kind: KindDropRate, Time: time.Unix(102, 0), Dropped: 3, Total: 40},
+		{Kind: KindIdle, Time: time.Unix(160, 0)},
+	}
+
+	t.Run("RoundTrip", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		buf := &bytes.Buffer{}
+		w, err := NewWriter(buf)
+		asrt.NoError(err)
+		for _, s := range samples {
+			asrt.NoError(w.Write(s))
+		}
+		asrt.NoError(w.Flush())
+
+		r, err := NewReader(buf)
+		asrt.NoError(err)
+		read, err := r.ReadAll()
+		asrt.NoError(err)
+		asrt.Len(read, len(samples))
+		for i := range samples {
+			asrt.Equal(samples[i].Kind, read[i].Kind)
+			asrt.True(samples[i].Time.Equal(read[i].Time))
+			asrt.Equal(samples[i].StartTime, read[i].StartTime)
+			asrt.Equal(samples[i].RTT, read[i].RTT)
+			asrt.Equal(samples[i].InFlight, read[i].InFlight)
+			asrt.Equal(samples[i].DidDrop, read[i].DidDrop)
+			asrt.Equal(samples[i].Completed, read[i].Completed)
+			asrt.Equal(samples[i].Duration, read[i].Duration)
+			asrt.Equal(samples[i].Dropped, read[i].Dropped)
+			asrt.Equal(samples[i].Total, read[i].Total)
+		}
+		_, err = r.Next()
+		asrt.Equal(io.EOF, err)
+	})
+
+	t.Run("Compact", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		buf := &bytes.Buffer{}
+		w, _ := NewWriter(buf)
+		base := time.Unix(1600000000, 0)
+		for i := 0; i < 1000; i++ {
+			asrt.NoError(w.Write(Sample{Time: base.Add(time.Duration(i) * time.Millisecond), RTT: 1000000, InFlight: 10}))
+		}
+		asrt.NoError(w.Flush())
+		asrt.True(buf.Len() < 1000*12, "trace of %d bytes is not compact", buf.Len())
+	})
+
+	t.Run("InvalidHeader", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		_, err := NewReader(bytes.NewBufferString("nope!"))
+		asrt.True(errors.Is(err, ErrInvalidTrace))
+		_, err = NewReader(bytes.NewBufferString("GCLR\x09"))
+		asrt.True(errors.Is(err, ErrInvalidTrace))
+		_, err = NewReader(bytes.NewBufferString(""))
+		asrt.True(errors.Is(err, ErrInvalidTrace))
+	})
+
+	t.Run("Version1", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		buf := &bytes.Buffer{}
+		w, _ := NewWriter(buf)
+		asrt.NoError(w.Write(samples[1]))
+		asrt.NoError(w.Flush())
+		data := buf.Bytes()
+		data[len(magic)] = 1
+
+		r, err := NewReader(bytes.NewReader(data))
+		asrt.NoError(err)
+		read, err := r.ReadAll()
+		asrt.NoError(err)
+		asrt.Len(read, 1)
+		asrt.Equal(KindSample, read[0].Kind)
+		asrt.Equal(samples[1].RTT, read[0].RTT)
+	})
+
+	t.Run("UnknownKind", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		buf := &bytes.Buffer{}
+		w, _ := NewWriter(buf)
+		asrt.Error(w.Write(Sample{Kind: numKinds}))
+		asrt.NoError(w.Flush())
+		buf.WriteByte(byte(numKinds) << flagKindShift)
+		buf.WriteByte(0)
+
+		r, err := NewReader(buf)
+		asrt.NoError(err)
+		_, err = r.Next()
+		asrt.True(errors.Is(err, ErrInvalidTrace))
+	})
+
+	t.Run("Truncated", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		buf := &bytes.Buffer{}
+		w, _ := NewWriter(buf)
+		asrt.NoError(w.Write(samples[1]))
+		asrt.NoError(w.Flush())
+		data := buf.Bytes()[:buf.Len()-1]
+
+		r, err := NewReader(bytes.NewReader(data))
+		asrt.NoError(err)
+		_, err = r.Next()
+		asrt.Equal(io.ErrUnexpectedEOF, err)
+	})
+}
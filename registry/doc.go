@@ -0,0 +1,2 @@
+// Package registry provides keyed LimiterRegistry implementations, i.e. one limiter per downstream host or RPC method.
+package registry
@@ -0,0 +1,270 @@
+package registry
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// Factory builds the limiter for a key the first time the key is requested.  Returning nil will cause Get to return
+// nil without caching anything for the key.
+type Factory func(key string) core.Limiter
+
+// Config is the optional configuration of a KeyedRegistry.
+type Config struct {
+	// IdleTTL is the duration a key may go without being requested before its limiter is evicted.  A limiter that
+	// reports in flight tokens through core.Inspectable is never considered idle.  Zero disables idle eviction.
+	IdleTTL time.Duration `yaml:"idleTTL,omitempty" json:"idleTTL,omitempty"`
+	// MaxKeys caps the number of keys held by the registry, the least recently used key is evicted to make room for
+	// a new one.  Keys whose limiter reports in flight tokens through core.Inspectable are skipped, so if every key is
+	// busy the cap is exceeded until one of them can be evicted.  Zero disables the cap.
+	MaxKeys int `yaml:"maxKeys,omitempty" json:"maxKeys,omitempty"`
+	// OnEvict is called outside of the registry lock with every evicted key and its limiter.
+	OnEvict func(key string, limiter core.Limiter) `yaml:"-" json:"-"`
+
+	MetricRegistry core.MetricRegistry
+	Tags           []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
+	// Clock is used to track idle time, defaults to the system clock.
+	Clock core.Clock `yaml:"-" json:"-"`
+}
+
+// ApplyDefaults is used by KeyedRegistry constructors to set defaults for optional registry configuration arguments
+func (c *Config) ApplyDefaults() {
+	if c.IdleTTL < 0 {
+		c.IdleTTL = 0
+	}
+
+	if c.MaxKeys < 0 {
+		c.MaxKeys = 0
+	}
+
+	if c.MetricRegistry == nil {
+		c.MetricRegistry = core.EmptyMetricRegistryInstance
+	}
+
+	if c.Clock == nil {
+		c.Clock = core.SystemClockInstance
+	}
+}
+
+type entry struct {
+	key        string
+	limiter    core.Limiter
+	lastAccess time.Time
+}
+
+// KeyedRegistry implements core.LimiterRegistry by lazily creating one limiter per key from a Factory.  Keys are kept
+// in least recently used order so that idle keys can be expired and the total number of keys can be capped.
+//
+// Expired keys are evicted lazily whenever Get is called, or explicitly with EvictIdle.
+type KeyedRegistry struct {
+	factory Factory
+	idleTTL time.Duration
+	maxKeys int
+	onEvict func(key string, limiter core.Limiter)
+	clock   core.Clock
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+
+	evictedListener core.MetricSampleListener
+}
+
+// NewKeyedRegistry will create a new KeyedRegistry using the given factory.
+func NewKeyedRegistry(factory Factory, config Config) *KeyedRegistry {
+	config.ApplyDefaults()
+
+	r := &KeyedRegistry{
+		factory: factory,
+		idleTTL: config.IdleTTL,
+		maxKeys: config.MaxKeys,
+		onEvict: config.OnEvict,
+		clock:   config.Clock,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	config.MetricRegistry.RegisterGauge(
+		core.MetricRegistryKeys, core.NewIntMetricSupplierWrapper(r.Len), config.Tags...)
+	r.evictedListener = config.MetricRegistry.RegisterCount(core.MetricRegistryEvicted, config.Tags...)
+
+	return r
+}
+
+// NewKeyedRegistryWithDefaults will create a new KeyedRegistry that never evicts keys.
+func NewKeyedRegistryWithDefaults(factory Factory) *KeyedRegistry {
+	return NewKeyedRegistry(factory, Config{})
+}
+
+// Get the limiter for the given key, creating it with the factory if this is the first time the key is seen.  The
+// factory is called while holding the registry lock so that at most one limiter is ever created per key.
+func (r *KeyedRegistry) Get(key string) core.Limiter {
+	r.mu.Lock()
+	now := r.clock.Now()
+	evicted := r.expireLocked(now)
+
+	if elem, ok := r.entries[key]; ok {
+		e := elem.Value.(*entry)
+		e.lastAccess = now
+		r.lru.MoveToFront(elem)
+		r.mu.Unlock()
+		r.notifyEvicted(evicted)
+		return e.limiter
+	}
+
+	l := r.factory(key)
+	if l == nil {
+		r.mu.Unlock()
+		r.notifyEvicted(evicted)
+		return nil
+	}
+	if r.maxKeys > 0 && r.lru.Len() >= r.maxKeys {
+		evicted = append(evicted, r.evictLRULocked(r.lru.Len()-r.maxKeys+1)...)
+	}
+	r.entries[key] = r.lru.PushFront(&entry{key: key, limiter: l, lastAccess: now})
+	r.mu.Unlock()
+	r.notifyEvicted(evicted)
+	return l
+}
+
+// Remove the limiter for the given key, OnEvict is called for the removed limiter.  Returns true if the key was
+// present.
+func (r *KeyedRegistry) Remove(key string) bool {
+	r.mu.Lock()
+	elem, ok := r.entries[key]
+	if !ok {
+		r.mu.Unlock()
+		return false
+	}
+	evicted := r.removeLocked(elem)
+	r.mu.Unlock()
+	r.notifyEvicted([]*entry{evicted})
+	return true
+}
+
+// EvictIdle will evict every key that has been idle for longer than the configured IdleTTL and return the number of
+// evicted keys.
+func (r *KeyedRegistry) EvictIdle() int {
+	r.mu.Lock()
+	evicted := r.expireLocked(r.clock.Now())
+	r.mu.Unlock()
+	r.notifyEvicted(evicted)
+	return len(evicted)
+}
+
+// Len returns the number of keys currently held.
+func (r *KeyedRegistry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lru.Len()
+}
+
+// Keys returns the keys currently held, most recently used first.
+func (r *KeyedRegistry) Keys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]string, 0, r.lru.Len())
+	for elem := r.lru.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*entry).key)
+	}
+	return keys
+}
+
+// Range calls fn for every key and limiter, most recently used first, until fn returns false.  Iteration does not
+// count as an access and fn is called without holding the registry lock so it may call back into the registry.
+func (r *KeyedRegistry) Range(fn func(key string, limiter core.Limiter) bool) {
+	r.mu.Lock()
+	entries := make([]entry, 0, r.lru.Len())
+	for elem := r.lru.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, *elem.Value.(*entry))
+	}
+	r.mu.Unlock()
+
+	for _, e := range entries {
+		if !fn(e.key, e.limiter) {
+			return
+		}
+	}
+}
+
+// Snapshot returns the snapshots of every held limiter as partitions named after their key.
+func (r *KeyedRegistry) Snapshot() core.Snapshot {
+	snapshot := core.Snapshot{
+		Type: "KeyedRegistry",
+		Attributes: map[string]interface{}{
+			"idleTTL": r.idleTTL,
+			"maxKeys": r.maxKeys,
+		},
+	}
+	r.Range(func(key string, limiter core.Limiter) bool {
+		partition := core.SnapshotOf(limiter)
+		if partition == nil {
+			partition = &core.Snapshot{Type: fmt.Sprintf("%T", limiter)}
+		}
+		partition.Name = key
+		snapshot.Partitions = append(snapshot.Partitions, partition)
+		snapshot.InFlight += partition.InFlight
+		return true
+	})
+	return snapshot
+}
+
+func (r *KeyedRegistry) String() string {
+	return fmt.Sprintf("KeyedRegistry{keys=%d, idleTTL=%v, maxKeys=%d}", r.Len(), r.idleTTL, r.maxKeys)
+}
+
+// expireLocked removes idle entries from the back of the lru list.  Entries that still have tokens in flight are
+// treated as accessed now.
+func (r *KeyedRegistry) expireLocked(now time.Time) []*entry {
+	if r.idleTTL <= 0 {
+		return nil
+	}
+	var evicted []*entry
+	for elem := r.lru.Back(); elem != nil; elem = r.lru.Back() {
+		e := elem.Value.(*entry)
+		if now.Sub(e.lastAccess) < r.idleTTL {
+			break
+		}
+		if s := core.SnapshotOf(e.limiter); s != nil && s.InFlight > 0 {
+			e.lastAccess = now
+			r.lru.MoveToFront(elem)
+			continue
+		}
+		evicted = append(evicted, r.removeLocked(elem))
+	}
+	return evicted
+}
+
+// evictLRULocked removes up to n entries from the back of the lru list.  Entries that still have tokens in flight are
+// skipped, evicting them would hand their key a new limiter while the old one is still enforcing the same requests.
+func (r *KeyedRegistry) evictLRULocked(n int) []*entry {
+	var evicted []*entry
+	for elem := r.lru.Back(); elem != nil && len(evicted) < n; {
+		prev := elem.Prev()
+		if s := core.SnapshotOf(elem.Value.(*entry).limiter); s == nil || s.InFlight == 0 {
+			evicted = append(evicted, r.removeLocked(elem))
+		}
+		elem = prev
+	}
+	return evicted
+}
+
+func (r *KeyedRegistry) removeLocked(elem *list.Element) *entry {
+	e := r.lru.Remove(elem).(*entry)
+	delete(r.entries, e.key)
+	return e
+}
+
+func (r *KeyedRegistry) notifyEvicted(evicted []*entry) {
+	for _, e := range evicted {
+		r.evictedListener.AddSample(1.0)
+		if r.onEvict != nil {
+			r.onEvict(e.key, e.limiter)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+	"github.com/platinummonkey/go-concurrency-limits/limiter"
+	"github.com/platinummonkey/go-concurrency-limits/strategy"
+)
+
+func testFactory(created *int) Factory {
+	return func(key string) core.Limiter {
+		*created++
+		l, _ := limiter.NewDefaultLimiterWithDefaults(
+			key,
+			strategy.NewSimpleStrategy(10),
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		return l
+	}
+}
+
+func TestKeyedRegistry(t *testing.T) {
+	t.Parallel()
+
+	t.Run("LazyCreation", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		created := 0
+		r := NewKeyedRegistryWithDefaults(testFactory(&created))
+		asrt.Equal(0, r.Len())
+
+		a := r.Get("a")
+		asrt.NotNil(a)
+		asrt.Equal(1, created)
+		asrt.Same(a, r.Get("a"))
+		asrt.Equal(1, created)
+
+		asrt.NotSame(a, r.Get("b"))
+		asrt.Equal(2, created)
+		asrt.Equal([]string{"b", "a"}, r.Keys())
+	})
+
+	t.Run("NilFactoryResult", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		r := NewKeyedRegistryWithDefaults(func(key string) core.Limiter { return nil })
+		asrt.Nil(r.Get("a"))
+		asrt.Equal(0, r.Len())
+	})
+
+	t.Run("IdleTTL", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		created := 0
+		var evicted []string
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		r := NewKeyedRegistry(testFactory(&created), Config{
+			IdleTTL: time.Minute,
+			OnEvict: func(key string, limiter core.Limiter) {
+				evicted = append(evicted, key)
+			},
+			Clock: fakeClock,
+		})
+
+		r.Get("a")
+		r.Get("b")
+		fakeClock.Advance(30 * time.Second)
+		r.Get("a")
+		fakeClock.Advance(45 * time.Second)
+
+		// b has been idle for 75s, a for 45s
+		asrt.Equal(1, r.EvictIdle())
+		asrt.Equal([]string{"b"}, evicted)
+		asrt.Equal([]string{"a"}, r.Keys())
+
+		// a limiter with tokens in flight is not idle
+		listener, ok := r.Get("a").Acquire(context.Background())
+		asrt.True(ok)
+		fakeClock.Advance(2 * time.Minute)
+		asrt.Equal(0, r.EvictIdle())
+		listener.OnSuccess()
+		fakeClock.Advance(2 * time.Minute)
+
+		// expired keys are evicted lazily on Get
+		r.Get("c")
+		asrt.Equal([]string{"b", "a"}, evicted)
+		asrt.Equal([]string{"c"}, r.Keys())
+	})
+
+	t.Run("MaxKeys", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		created := 0
+		var evicted []string
+		r := NewKeyedRegistry(testFactory(&created), Config{
+			MaxKeys: 2,
+			OnEvict: func(key string, limiter core.Limiter) {
+				evicted = append(evicted, key)
+			},
+		})
+
+		r.Get("a")
+		r.Get("b")
+		r.Get("a")
+		r.Get("c")
+		asrt.Equal([]string{"b"}, evicted)
+		asrt.Equal([]string{"c", "a"}, r.Keys())
+		asrt.Equal(2, r.Len())
+	})
+
+	t.Run("MaxKeysInFlight", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		created := 0
+		var evicted []string
+		r := NewKeyedRegistry(testFactory(&created), Config{
+			MaxKeys: 2,
+			OnEvict: func(key string, limiter core.Limiter) {
+				evicted = append(evicted, key)
+			},
+		})
+
+		// the least recently used key without tokens in flight is evicted
+		a, ok := r.Get("a").Acquire(context.Background())
+		asrt.True(ok)
+		r.Get("b")
+		r.Get("c")
+		asrt.Equal([]string{"b"}, evicted)
+		asrt.Equal([]string{"c", "a"}, r.Keys())
+
+		// the cap is exceeded while every key is busy
+		c, ok := r.Get("c").Acquire(context.Background())
+		asrt.True(ok)
+		r.Get("d")
+		asrt.Equal([]string{"b"}, evicted)
+		asrt.Equal([]string{"d", "c", "a"}, r.Keys())
+
+		// and shrinks back once keys can be evicted
+		a.OnSuccess()
+		c.OnSuccess()
+		r.Get("e")
+		asrt.Equal([]string{"b", "a", "c"}, evicted)
+		asrt.Equal([]string{"e", "d"}, r.Keys())
+	})
+
+	t.Run("Remove", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		created := 0
+		r := NewKeyedRegistryWithDefaults(testFactory(&created))
+		r.Get("a")
+		asrt.True(r.Remove("a"))
+		asrt.False(r.Remove("a"))
+		r.Get("a")
+		asrt.Equal(2, created)
+	})
+
+	t.Run("Range", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		created := 0
+		r := NewKeyedRegistryWithDefaults(testFactory(&created))
+		r.Get("a")
+		r.Get("b")
+		r.Get("c")
+
+		var seen []string
+		r.Range(func(key string, limiter core.Limiter) bool {
+			seen = append(seen, key)
+			// calling back into the registry must not deadlock
+			r.Get(key)
+			return len(seen) < 2
+		})
+		asrt.Equal([]string{"c", "b"}, seen)
+
+		snapshot := r.Snapshot()
+		asrt.Equal("KeyedRegistry", snapshot.Type)
+		asrt.Len(snapshot.Partitions, 3)
+		asrt.Equal("DefaultLimiter", snapshot.Partitions[0].Type)
+	})
+
+	t.Run("Concurrent", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		var mu sync.Mutex
+		created := 0
+		r := NewKeyedRegistry(func(key string) core.Limiter {
+			mu.Lock()
+			created++
+			mu.Unlock()
+			return limiter.NewBlockingLimiter(nil, 0, nil)
+		}, Config{MaxKeys: 50})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					r.Get(fmt.Sprintf("key-%d", j%20))
+				}
+			}()
+		}
+		wg.Wait()
+		asrt.Equal(20, created)
+		asrt.Equal(20, r.Len())
+	})
+}
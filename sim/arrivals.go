@@ -0,0 +1,117 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ArrivalProcess models when requests arrive at the limiter.  Processes that also have a Validate() error method are
+// validated by Run before the simulation starts.
+type ArrivalProcess interface {
+	// Next returns the time until the next request arrives, which must be > 0.
+	Next(r *rand.Rand) time.Duration
+}
+
+// maxArrivalRate is the highest rate per second of the built-in arrival processes, requests would otherwise arrive
+// more often than the nanosecond resolution of the virtual clock.
+const maxArrivalRate = float64(time.Second)
+
+// interArrival returns the time until the next request arrives at rate, at least one nanosecond so that the virtual
+// clock always advances.
+func interArrival(seconds float64, rate float64) time.Duration {
+	next := time.Duration(seconds * float64(time.Second) / rate)
+	if next < 1 {
+		next = 1
+	}
+	return next
+}
+
+// ConstantArrivals models requests arriving at a fixed rate per second.
+type ConstantArrivals struct {
+	Rate float64
+}
+
+// Next returns the fixed inter-arrival time.
+func (a ConstantArrivals) Next(r *rand.Rand) time.Duration {
+	return interArrival(1, a.Rate)
+}
+
+// Validate will return an error if the rate is not in (0, 1e9].
+func (a ConstantArrivals) Validate() error {
+	if !(a.Rate > 0) || a.Rate > maxArrivalRate {
+		return fmt.Errorf("constant arrivals rate must be in (0, %g], got %g", maxArrivalRate, a.Rate)
+	}
+	return nil
+}
+
+func (a ConstantArrivals) String() string {
+	return fmt.Sprintf("constant:%g/s", a.Rate)
+}
+
+// PoissonArrivals models requests arriving independently at an average rate per second, the inter-arrival times are
+// exponentially distributed.
+type PoissonArrivals struct {
+	Rate float64
+}
+
+// Next returns an exponentially distributed inter-arrival time.
+func (a PoissonArrivals) Next(r *rand.Rand) time.Duration {
+	return interArrival(r.ExpFloat64(), a.Rate)
+}
+
+// Validate will return an error if the rate is not in (0, 1e9].
+func (a PoissonArrivals) Validate() error {
+	if !(a.Rate > 0) || a.Rate > maxArrivalRate {
+		return fmt.Errorf("poisson arrivals rate must be in (0, %g], got %g", maxArrivalRate, a.Rate)
+	}
+	return nil
+}
+
+func (a PoissonArrivals) String() string {
+	return fmt.Sprintf("poisson:%g/s", a.Rate)
+}
+
+// RampArrivals models a rate that changes linearly from StartRate to EndRate over Duration, then stays at EndRate.
+// The inter-arrival times are exponentially distributed around the current rate.
+type RampArrivals struct {
+	StartRate float64
+	EndRate   float64
+	Duration  time.Duration
+
+	elapsed time.Duration
+}
+
+// Next returns an exponentially distributed inter-arrival time for the current rate.
+func (a *RampArrivals) Next(r *rand.Rand) time.Duration {
+	rate := a.EndRate
+	if a.Duration > 0 && a.elapsed < a.Duration {
+		rate = a.StartRate + (a.EndRate-a.StartRate)*float64(a.elapsed)/float64(a.Duration)
+	}
+	if rate <= 0 {
+		// nothing arrives while the rate is zero, step forward to look again
+		a.elapsed += time.Millisecond
+		return time.Millisecond
+	}
+	next := interArrival(r.ExpFloat64(), rate)
+	a.elapsed += next
+	return next
+}
+
+// Validate will return an error if a rate is not in [0, 1e9] or the duration is < 0.
+func (a *RampArrivals) Validate() error {
+	if !(a.StartRate >= 0) || a.StartRate > maxArrivalRate {
+		return fmt.Errorf("ramp arrivals startRate must be in [0, %g], got %g", maxArrivalRate, a.StartRate)
+	}
+	if !(a.EndRate >= 0) || a.EndRate > maxArrivalRate {
+		return fmt.Errorf("ramp arrivals endRate must be in [0, %g], got %g", maxArrivalRate, a.EndRate)
+	}
+	if a.Duration < 0 {
+		return fmt.Errorf("ramp arrivals duration must be >= 0, got %v", a.Duration)
+	}
+	return nil
+}
+
+func (a *RampArrivals) String() string {
+	return fmt.Sprintf("ramp:%g/s-%g/s over %v", a.StartRate, a.EndRate, a.Duration)
+}
@@ -0,0 +1,101 @@
+package sim
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// FailureWindow injects failures and extra latency into the backend between Start and End, measured from the start
+// of the simulation.
+type FailureWindow struct {
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	// FailureRate is the probability in [0, 1] that a request fails during the window.
+	FailureRate float64 `json:"failureRate"`
+	// LatencyMultiplier multiplies the service time of requests started during the window, 0 leaves it unchanged.
+	LatencyMultiplier float64 `json:"latencyMultiplier,omitempty"`
+}
+
+// Backend models the resource protected by the limiter.
+type Backend struct {
+	// ServiceTime is the distribution of the service time of a single request without any contention.
+	ServiceTime Distribution
+	// Capacity is the concurrency knee of the backend.  Once more than Capacity requests are in flight the service
+	// time grows, modeling queueing inside the backend.  Zero disables the knee.
+	Capacity int
+	// Degradation is how quickly the service time grows past the knee, the service time is multiplied by
+	// 1 + Degradation * (inFlight - Capacity) / Capacity.  Defaults to 1.
+	Degradation float64
+	// FailureRate is the probability in [0, 1] that any request fails, failures are reported with OnDropped.
+	FailureRate float64
+	// Timeout is the maximum time a request may take, slower requests are cut short and reported with OnDropped.
+	// Zero disables the timeout.
+	Timeout time.Duration
+	// Failures are time windows of injected failures and latency.
+	Failures []FailureWindow
+}
+
+// ApplyDefaults is used by Run to set defaults for optional backend configuration arguments
+func (b *Backend) ApplyDefaults() {
+	if b.ServiceTime == nil {
+		b.ServiceTime = ConstantDistribution{Value: 10 * time.Millisecond}
+	}
+	if b.Degradation <= 0 {
+		b.Degradation = 1
+	}
+}
+
+// Validate will return an error if the backend is misconfigured.
+func (b *Backend) Validate() error {
+	if b.Capacity < 0 {
+		return fmt.Errorf("backend capacity must be >= 0")
+	}
+	if b.FailureRate < 0 || b.FailureRate > 1 {
+		return fmt.Errorf("backend failureRate must be in [0, 1]")
+	}
+	if b.Timeout < 0 {
+		return fmt.Errorf("backend timeout must be >= 0")
+	}
+	for i, w := range b.Failures {
+		if w.End < w.Start {
+			return fmt.Errorf("backend failures[%d] end must be >= start", i)
+		}
+		if w.FailureRate < 0 || w.FailureRate > 1 {
+			return fmt.Errorf("backend failures[%d] failureRate must be in [0, 1]", i)
+		}
+		if w.LatencyMultiplier < 0 {
+			return fmt.Errorf("backend failures[%d] latencyMultiplier must be >= 0", i)
+		}
+	}
+	return nil
+}
+
+// serve returns the latency of a request started at elapsed with inFlight requests in the backend (including this
+// one) and whether the request failed.
+func (b *Backend) serve(r *rand.Rand, elapsed time.Duration, inFlight int) (time.Duration, bool) {
+	latency := float64(b.ServiceTime.Sample(r))
+	if b.Capacity > 0 && inFlight > b.Capacity {
+		latency *= 1 + b.Degradation*float64(inFlight-b.Capacity)/float64(b.Capacity)
+	}
+
+	failureRate := b.FailureRate
+	for _, w := range b.Failures {
+		if elapsed < w.Start || elapsed >= w.End {
+			continue
+		}
+		if w.FailureRate > failureRate {
+			failureRate = w.FailureRate
+		}
+		if w.LatencyMultiplier > 0 {
+			latency *= w.LatencyMultiplier
+		}
+	}
+
+	failed := failureRate > 0 && r.Float64() < failureRate
+	result := time.Duration(latency)
+	if b.Timeout > 0 && result > b.Timeout {
+		return b.Timeout, true
+	}
+	return result, failed
+}
@@ -0,0 +1,169 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Distribution is a distribution of durations, i.e. the service time of the backend.
+type Distribution interface {
+	// Sample returns a new non-negative duration drawn from the distribution.
+	Sample(r *rand.Rand) time.Duration
+}
+
+// ConstantDistribution always returns the same duration.
+type ConstantDistribution struct {
+	Value time.Duration
+}
+
+// Sample returns the constant value.
+func (d ConstantDistribution) Sample(r *rand.Rand) time.Duration {
+	return d.Value
+}
+
+func (d ConstantDistribution) String() string {
+	return fmt.Sprintf("const:%v", d.Value)
+}
+
+// UniformDistribution returns durations uniformly distributed in [Min, Max).
+type UniformDistribution struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Sample returns a uniformly distributed duration.
+func (d UniformDistribution) Sample(r *rand.Rand) time.Duration {
+	if d.Max <= d.Min {
+		return d.Min
+	}
+	return d.Min + time.Duration(r.Int63n(int64(d.Max-d.Min)))
+}
+
+func (d UniformDistribution) String() string {
+	return fmt.Sprintf("uniform:%v,%v", d.Min, d.Max)
+}
+
+// ExponentialDistribution returns exponentially distributed durations with the given mean.
+type ExponentialDistribution struct {
+	Mean time.Duration
+}
+
+// Sample returns an exponentially distributed duration.
+func (d ExponentialDistribution) Sample(r *rand.Rand) time.Duration {
+	return time.Duration(r.ExpFloat64() * float64(d.Mean))
+}
+
+func (d ExponentialDistribution) String() string {
+	return fmt.Sprintf("exp:%v", d.Mean)
+}
+
+// NormalDistribution returns normally distributed durations, truncated at zero.
+type NormalDistribution struct {
+	Mean   time.Duration
+	StdDev time.Duration
+}
+
+// Sample returns a normally distributed duration.
+func (d NormalDistribution) Sample(r *rand.Rand) time.Duration {
+	return time.Duration(math.Max(0, r.NormFloat64()*float64(d.StdDev)+float64(d.Mean)))
+}
+
+func (d NormalDistribution) String() string {
+	return fmt.Sprintf("normal:%v,%v", d.Mean, d.StdDev)
+}
+
+// LogNormalDistribution returns log-normally distributed durations with the given median, a common model for a long
+// tailed service time.  Sigma is the standard deviation of the underlying normal distribution.
+type LogNormalDistribution struct {
+	Median time.Duration
+	Sigma  float64
+}
+
+// Sample returns a log-normally distributed duration.
+func (d LogNormalDistribution) Sample(r *rand.Rand) time.Duration {
+	return time.Duration(float64(d.Median) * math.Exp(r.NormFloat64()*d.Sigma))
+}
+
+func (d LogNormalDistribution) String() string {
+	return fmt.Sprintf("lognormal:%v,%v", d.Median, d.Sigma)
+}
+
+// ParseDistribution parses a distribution from a "<kind>:<args>" spec, one of:
+//
+//	const:10ms
+//	uniform:5ms,15ms
+//	exp:10ms
+//	normal:10ms,2ms
+//	lognormal:10ms,0.5
+func ParseDistribution(spec string) (Distribution, error) {
+	kind, args, _ := strings.Cut(spec, ":")
+	var params []string
+	if args != "" {
+		params = strings.Split(args, ",")
+	}
+	durations := func(n int) ([]time.Duration, error) {
+		if len(params) != n {
+			return nil, fmt.Errorf("distribution %q expects %d arguments, got %d", kind, n, len(params))
+		}
+		values := make([]time.Duration, n)
+		for i, p := range params {
+			d, err := time.ParseDuration(strings.TrimSpace(p))
+			if err != nil {
+				return nil, fmt.Errorf("distribution %q: %w", kind, err)
+			}
+			if d < 0 {
+				return nil, fmt.Errorf("distribution %q: duration %v must be >= 0", kind, d)
+			}
+			values[i] = d
+		}
+		return values, nil
+	}
+
+	switch kind {
+	case "const":
+		v, err := durations(1)
+		if err != nil {
+			return nil, err
+		}
+		return ConstantDistribution{Value: v[0]}, nil
+	case "uniform":
+		v, err := durations(2)
+		if err != nil {
+			return nil, err
+		}
+		if v[1] < v[0] {
+			return nil, fmt.Errorf("distribution %q: min must be <= max", kind)
+		}
+		return UniformDistribution{Min: v[0], Max: v[1]}, nil
+	case "exp":
+		v, err := durations(1)
+		if err != nil {
+			return nil, err
+		}
+		return ExponentialDistribution{Mean: v[0]}, nil
+	case "normal":
+		v, err := durations(2)
+		if err != nil {
+			return nil, err
+		}
+		return NormalDistribution{Mean: v[0], StdDev: v[1]}, nil
+	case "lognormal":
+		if len(params) != 2 {
+			return nil, fmt.Errorf("distribution %q expects 2 arguments, got %d", kind, len(params))
+		}
+		median, err := time.ParseDuration(strings.TrimSpace(params[0]))
+		if err != nil {
+			return nil, fmt.Errorf("distribution %q: %w", kind, err)
+		}
+		var sigma float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(params[1]), "%g", &sigma); err != nil || sigma < 0 {
+			return nil, fmt.Errorf("distribution %q: sigma must be a number >= 0", kind)
+		}
+		return LogNormalDistribution{Median: median, Sigma: sigma}, nil
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", kind)
+	}
+}
@@ -0,0 +1,4 @@
+// Package sim provides a discrete-event simulator that drives a limiter stack against a modeled backend in virtual
+// time, producing a time series of the limit, in-flight count, latency and rejections.  It is intended to compare
+// limit algorithms and tune their parameters without deploying them.
+package sim
@@ -0,0 +1,38 @@
+package sim
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+)
+
+// LimitNames are the limit algorithms known to NewLimitByName.
+var LimitNames = []string{"vegas", "gradient", "gradient2", "aimd", "bbr", "pid", "littleslaw", "fixed"}
+
+// NewLimitByName will create one of the limit algorithms of the limit package with default parameters, for use by
+// command line tools.  maxLimit is only used by algorithms that support a maximum, pid holds the queueing delay at 5ms.
+func NewLimitByName(name string, initialLimit int, maxLimit int) (core.Limit, error) {
+	logger := limit.NoopLimitLogger{}
+	switch name {
+	case "vegas":
+		return limit.NewDefaultVegasLimitWithLimit(name, initialLimit, logger, nil), nil
+	case "gradient":
+		return limit.NewGradientLimitWithRegistry(name, initialLimit, 0, maxLimit, -1, nil, -1, 0, logger, nil), nil
+	case "gradient2":
+		return limit.NewGradient2Limit(name, initialLimit, maxLimit, 0, nil, -1, -1, logger, nil)
+	case "aimd":
+		return limit.NewAIMDLimit(name, initialLimit, 0.9, 1, nil), nil
+	case "bbr":
+		return limit.NewBBRLimit(name, initialLimit, 0, maxLimit, 0, 0, logger, nil)
+	case "pid":
+		return limit.NewPIDLimit(name, initialLimit, 0, maxLimit, 0, 5*time.Millisecond, 10, 2, 0, logger, nil)
+	case "littleslaw":
+		return limit.NewLittlesLawLimit(name, initialLimit, 0, maxLimit, 0, 0, 0, nil, logger, nil)
+	case "fixed":
+		return limit.NewFixedLimit(name, initialLimit, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown limit %q", name)
+	}
+}
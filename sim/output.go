@@ -0,0 +1,95 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Point is a single point of the simulation time series, counters cover the interval ending at Time.
+type Point struct {
+	// Time is the virtual time since the start of the simulation.
+	Time time.Duration `json:"timeNs"`
+	// Limit is the limit reported by the limiter at Time.
+	Limit int `json:"limit"`
+	// InFlight is the number of requests in the backend at Time.
+	InFlight int `json:"inFlight"`
+
+	Arrivals  int `json:"arrivals"`
+	Accepted  int `json:"accepted"`
+	Rejected  int `json:"rejected"`
+	Succeeded int `json:"succeeded"`
+	Dropped   int `json:"dropped"`
+
+	// Latency statistics of the requests that succeeded during the interval.
+	LatencyMean time.Duration `json:"latencyMeanNs"`
+	LatencyP50  time.Duration `json:"latencyP50Ns"`
+	LatencyP99  time.Duration `json:"latencyP99Ns"`
+}
+
+// Summary aggregates a whole simulation run.
+type Summary struct {
+	Arrivals    int           `json:"arrivals"`
+	Accepted    int           `json:"accepted"`
+	Rejected    int           `json:"rejected"`
+	Succeeded   int           `json:"succeeded"`
+	Dropped     int           `json:"dropped"`
+	LatencyMean time.Duration `json:"latencyMeanNs"`
+	LatencyP50  time.Duration `json:"latencyP50Ns"`
+	LatencyP99  time.Duration `json:"latencyP99Ns"`
+	FinalLimit  int           `json:"finalLimit"`
+}
+
+// Result is the output of a simulation run.
+type Result struct {
+	Points  []Point `json:"points"`
+	Summary Summary `json:"summary"`
+}
+
+var csvHeader = []string{
+	"time_s", "limit", "in_flight", "arrivals", "accepted", "rejected", "succeeded", "dropped",
+	"latency_mean_ms", "latency_p50_ms", "latency_p99_ms",
+}
+
+// WriteCSV will write the time series as CSV with a header row.  Times are in seconds and latencies in milliseconds.
+func (r *Result) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	seconds := func(d time.Duration) string {
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+	}
+	milliseconds := func(d time.Duration) string {
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+	}
+	for _, p := range r.Points {
+		record := []string{
+			seconds(p.Time),
+			strconv.Itoa(p.Limit),
+			strconv.Itoa(p.InFlight),
+			strconv.Itoa(p.Arrivals),
+			strconv.Itoa(p.Accepted),
+			strconv.Itoa(p.Rejected),
+			strconv.Itoa(p.Succeeded),
+			strconv.Itoa(p.Dropped),
+			milliseconds(p.LatencyMean),
+			milliseconds(p.LatencyP50),
+			milliseconds(p.LatencyP99),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJSON will write the time series and summary as indented JSON.
+func (r *Result) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
@@ -0,0 +1,294 @@
+package sim
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+	"github.com/platinummonkey/go-concurrency-limits/limiter"
+)
+
+// LimiterFactory builds the limiter stack under test using the virtual clock of the simulation.  The limiter must
+// never block, a blocking limiter would block the single threaded event loop that advances the clock.
+type LimiterFactory func(clock core.Clock) (core.Limiter, error)
+
+// NewDefaultLimiterFactory returns a LimiterFactory for a limiter.DefaultLimiter using the given limit algorithm and
+// strategy, with the same sample windows as limiter.NewDefaultLimiterWithDefaults.
+func NewDefaultLimiterFactory(l core.Limit, strategy core.Strategy) LimiterFactory {
+	return func(clock core.Clock) (core.Limiter, error) {
+		defaultLimiter, err := limiter.NewDefaultLimiter(
+			l,
+			int64(time.Second),
+			int64(time.Second),
+			int64(100*time.Microsecond),
+			100,
+			strategy,
+			limit.NoopLimitLogger{},
+			core.EmptyMetricRegistryInstance,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defaultLimiter.SetClock(clock)
+		return defaultLimiter, nil
+	}
+}
+
+// Config is the configuration of a simulation run.
+type Config struct {
+	// Duration is the amount of virtual time to simulate.  Defaults to 1 minute.
+	Duration time.Duration
+	// Interval is the width of each point of the output time series.  Defaults to 1 second.
+	Interval time.Duration
+	// Arrivals is the arrival process of requests.  Defaults to a Poisson process of 100 requests per second.
+	Arrivals ArrivalProcess
+	// Backend is the modeled backend.
+	Backend Backend
+	// Seed seeds all randomness of the run so that runs are reproducible.
+	Seed int64
+	// Start is the virtual wall clock time at the start of the run.  Defaults to the unix epoch.
+	Start time.Time
+}
+
+// ApplyDefaults is used by Run to set defaults for optional simulation configuration arguments
+func (c *Config) ApplyDefaults() {
+	if c.Duration <= 0 {
+		c.Duration = time.Minute
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.Arrivals == nil {
+		c.Arrivals = PoissonArrivals{Rate: 100}
+	}
+	if c.Start.IsZero() {
+		c.Start = time.Unix(0, 0)
+	}
+	c.Backend.ApplyDefaults()
+}
+
+type eventKind int
+
+const (
+	eventArrival eventKind = iota
+	eventCompletion
+	eventTick
+)
+
+type event struct {
+	at       time.Duration
+	seq      uint64
+	kind     eventKind
+	listener core.Listener
+	started  time.Duration
+	failed   bool
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at == q[j].at {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at < q[j].at
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return e
+}
+
+// simulation holds the state of a single run.
+type simulation struct {
+	config   Config
+	clock    *clock.FakeClock
+	random   *rand.Rand
+	limiter  core.Limiter
+	events   eventQueue
+	seq      uint64
+	inFlight int
+
+	interval  Point
+	latencies []time.Duration
+	all       []time.Duration
+	result    Result
+}
+
+// Run will simulate the limiter built by factory against the configured backend and return the time series.
+func Run(config Config, factory LimiterFactory) (*Result, error) {
+	config.ApplyDefaults()
+	if err := config.Backend.Validate(); err != nil {
+		return nil, err
+	}
+	if v, ok := config.Arrivals.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if factory == nil {
+		return nil, fmt.Errorf("limiter factory must be provided")
+	}
+
+	s := &simulation{
+		config: config,
+		clock:  clock.NewFakeClock(config.Start),
+		random: rand.New(rand.NewSource(config.Seed)),
+	}
+	l, err := factory(s.clock)
+	if err != nil {
+		return nil, err
+	}
+	s.limiter = l
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	return &s.result, nil
+}
+
+func (s *simulation) schedule(e *event) {
+	s.seq++
+	e.seq = s.seq
+	heap.Push(&s.events, e)
+}
+
+func (s *simulation) run() error {
+	if err := s.scheduleArrival(0); err != nil {
+		return err
+	}
+	s.schedule(&event{at: s.config.Interval, kind: eventTick})
+
+	for s.events.Len() > 0 {
+		e := heap.Pop(&s.events).(*event)
+		if e.at > s.config.Duration {
+			break
+		}
+		s.clock.Set(s.config.Start.Add(e.at))
+		switch e.kind {
+		case eventArrival:
+			if err := s.arrive(e.at); err != nil {
+				return err
+			}
+		case eventCompletion:
+			s.complete(e)
+		case eventTick:
+			s.tick(e.at)
+		}
+	}
+	s.result.Summary = s.summary()
+	return nil
+}
+
+// scheduleArrival will schedule the next arrival after now, returning an error if the arrival process would not
+// advance the virtual clock.
+func (s *simulation) scheduleArrival(now time.Duration) error {
+	next := s.config.Arrivals.Next(s.random)
+	if next <= 0 {
+		return fmt.Errorf("arrival process %v returned a non-positive inter-arrival time %v", s.config.Arrivals, next)
+	}
+	s.schedule(&event{at: now + next, kind: eventArrival})
+	return nil
+}
+
+func (s *simulation) arrive(now time.Duration) error {
+	if err := s.scheduleArrival(now); err != nil {
+		return err
+	}
+	s.interval.Arrivals++
+
+	listener, ok := s.limiter.Acquire(context.Background())
+	if !ok {
+		s.interval.Rejected++
+		return nil
+	}
+	s.interval.Accepted++
+	s.inFlight++
+	latency, failed := s.config.Backend.serve(s.random, now, s.inFlight)
+	s.schedule(&event{
+		at:       now + latency,
+		kind:     eventCompletion,
+		listener: listener,
+		started:  now,
+		failed:   failed,
+	})
+	return nil
+}
+
+func (s *simulation) complete(e *event) {
+	s.inFlight--
+	if e.failed {
+		s.interval.Dropped++
+		e.listener.OnDropped()
+		return
+	}
+	s.interval.Succeeded++
+	latency := e.at - e.started
+	s.latencies = append(s.latencies, latency)
+	s.all = append(s.all, latency)
+	e.listener.OnSuccess()
+}
+
+func (s *simulation) tick(now time.Duration) {
+	p := s.interval
+	p.Time = now
+	p.Limit = s.currentLimit()
+	p.InFlight = s.inFlight
+	p.LatencyMean, p.LatencyP50, p.LatencyP99 = latencyStats(s.latencies)
+	s.result.Points = append(s.result.Points, p)
+
+	s.interval = Point{}
+	s.latencies = s.latencies[:0]
+	s.schedule(&event{at: now + s.config.Interval, kind: eventTick})
+}
+
+func (s *simulation) currentLimit() int {
+	if snapshot := core.SnapshotOf(s.limiter); snapshot != nil {
+		return snapshot.Limit
+	}
+	if estimator, ok := s.limiter.(interface{ EstimatedLimit() int }); ok {
+		return estimator.EstimatedLimit()
+	}
+	return 0
+}
+
+func (s *simulation) summary() Summary {
+	summary := Summary{}
+	for _, p := range s.result.Points {
+		summary.Arrivals += p.Arrivals
+		summary.Accepted += p.Accepted
+		summary.Rejected += p.Rejected
+		summary.Succeeded += p.Succeeded
+		summary.Dropped += p.Dropped
+	}
+	summary.LatencyMean, summary.LatencyP50, summary.LatencyP99 = latencyStats(s.all)
+	if n := len(s.result.Points); n > 0 {
+		summary.FinalLimit = s.result.Points[n-1].Limit
+	}
+	return summary
+}
+
+// latencyStats returns the mean, median and 99th percentile of the latencies.  The slice is sorted in place.
+func latencyStats(latencies []time.Duration) (time.Duration, time.Duration, time.Duration) {
+	if len(latencies) == 0 {
+		return 0, 0, 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	return sum / time.Duration(len(latencies)), percentile(0.5), percentile(0.99)
+}
@@ -0,0 +1,183 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+	"github.com/platinummonkey/go-concurrency-limits/strategy"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("FixedLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		result, err := Run(Config{
+			Duration: 10 * time.Second,
+			Arrivals: ConstantArrivals{Rate: 2000},
+			Backend: Backend{
+				ServiceTime: ConstantDistribution{Value: 10 * time.Millisecond},
+			},
+		}, NewDefaultLimiterFactory(limit.NewFixedLimit("test", 10, nil), strategy.NewSimpleStrategy(10)))
+		asrt.NoError(err)
+		asrt.Len(result.Points, 10)
+
+		// 2000/s at 10ms needs 20 in flight, so roughly half is rejected
+		summary := result.Summary
+		asrt.Equal(summary.Arrivals, summary.Accepted+summary.Rejected)
+		asrt.InDelta(0.5, float64(summary.Rejected)/float64(summary.Arrivals), 0.01)
+		asrt.Equal(10*time.Millisecond, summary.LatencyP99)
+		asrt.Equal(10, summary.FinalLimit)
+		for _, p := range result.Points {
+			asrt.LessOrEqual(p.InFlight, 10)
+		}
+	})
+
+	t.Run("Deterministic", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		run := func() *Result {
+			result, err := Run(Config{
+				Duration: 20 * time.Second,
+				Arrivals: PoissonArrivals{Rate: 500},
+				Backend: Backend{
+					ServiceTime: ExponentialDistribution{Mean: 20 * time.Millisecond},
+					Capacity:    20,
+					FailureRate: 0.01,
+				},
+				Seed: 42,
+			}, NewDefaultLimiterFactory(limit.NewDefaultAIMDLimit("test", nil), strategy.NewSimpleStrategy(10)))
+			asrt.NoError(err)
+			return result
+		}
+		asrt.Equal(run(), run())
+	})
+
+	t.Run("FailureWindow", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		result, err := Run(Config{
+			Duration: 30 * time.Second,
+			Arrivals: PoissonArrivals{Rate: 1000},
+			Backend: Backend{
+				ServiceTime: ConstantDistribution{Value: 5 * time.Millisecond},
+				Failures: []FailureWindow{
+					{Start: 10 * time.Second, End: 20 * time.Second, FailureRate: 0.5},
+				},
+			},
+			Seed: 1,
+		}, NewDefaultLimiterFactory(limit.NewAIMDLimit("test", 20, 0.9, 1, nil), strategy.NewSimpleStrategy(20)))
+		asrt.NoError(err)
+
+		before := result.Points[9]
+		during := result.Points[19]
+		asrt.Zero(before.Dropped)
+		asrt.NotZero(during.Dropped)
+		// the loss based limit backs off while requests fail
+		asrt.Less(during.Limit, before.Limit)
+	})
+
+	t.Run("InvalidBackend", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		_, err := Run(Config{Backend: Backend{FailureRate: 2}}, nil)
+		asrt.EqualError(err, "backend failureRate must be in [0, 1]")
+		_, err = Run(Config{}, nil)
+		asrt.EqualError(err, "limiter factory must be provided")
+	})
+
+	t.Run("InvalidArrivals", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		factory := NewDefaultLimiterFactory(limit.NewFixedLimit("test", 10, nil), strategy.NewSimpleStrategy(10))
+		_, err := Run(Config{Duration: time.Second, Arrivals: PoissonArrivals{}}, factory)
+		asrt.EqualError(err, "poisson arrivals rate must be in (0, 1e+09], got 0")
+		_, err = Run(Config{Duration: time.Second, Arrivals: ConstantArrivals{}}, factory)
+		asrt.EqualError(err, "constant arrivals rate must be in (0, 1e+09], got 0")
+		_, err = Run(Config{Duration: time.Second, Arrivals: ConstantArrivals{Rate: 2e9}}, factory)
+		asrt.EqualError(err, "constant arrivals rate must be in (0, 1e+09], got 2e+09")
+		_, err = Run(Config{Duration: time.Second, Arrivals: &RampArrivals{StartRate: -1, EndRate: 10}}, factory)
+		asrt.EqualError(err, "ramp arrivals startRate must be in [0, 1e+09], got -1")
+	})
+
+	t.Run("NonPositiveArrival", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		factory := NewDefaultLimiterFactory(limit.NewFixedLimit("test", 10, nil), strategy.NewSimpleStrategy(10))
+		_, err := Run(Config{Duration: time.Second, Arrivals: &stalledArrivals{}}, factory)
+		asrt.EqualError(err, "arrival process stalled returned a non-positive inter-arrival time 0s")
+		_, err = Run(Config{Duration: time.Second, Arrivals: &stalledArrivals{after: 5}}, factory)
+		asrt.EqualError(err, "arrival process stalled returned a non-positive inter-arrival time 0s")
+	})
+
+	t.Run("Output", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		result, err := Run(Config{
+			Duration: 3 * time.Second,
+			Arrivals: ConstantArrivals{Rate: 100},
+		}, NewDefaultLimiterFactory(limit.NewFixedLimit("test", 10, nil), strategy.NewSimpleStrategy(10)))
+		asrt.NoError(err)
+
+		var csvOut bytes.Buffer
+		asrt.NoError(result.WriteCSV(&csvOut))
+		lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+		asrt.Len(lines, 4)
+		asrt.Equal(strings.Join(csvHeader, ","), lines[0])
+		asrt.True(strings.HasPrefix(lines[1], "1,10,"))
+
+		var jsonOut bytes.Buffer
+		asrt.NoError(result.WriteJSON(&jsonOut))
+		var decoded Result
+		asrt.NoError(json.Unmarshal(jsonOut.Bytes(), &decoded))
+		asrt.Equal(*result, decoded)
+	})
+}
+
+// stalledArrivals returns a zero inter-arrival time after the given number of arrivals.
+type stalledArrivals struct {
+	after int
+}
+
+func (a *stalledArrivals) Next(r *rand.Rand) time.Duration {
+	if a.after > 0 {
+		a.after--
+		return time.Millisecond
+	}
+	return 0
+}
+
+func (a *stalledArrivals) String() string {
+	return "stalled"
+}
+
+func TestParseDistribution(t *testing.T) {
+	t.Parallel()
+	asrt := assert.New(t)
+
+	valid := map[string]Distribution{
+		"const:10ms":         ConstantDistribution{Value: 10 * time.Millisecond},
+		"uniform:5ms,15ms":   UniformDistribution{Min: 5 * time.Millisecond, Max: 15 * time.Millisecond},
+		"exp:10ms":           ExponentialDistribution{Mean: 10 * time.Millisecond},
+		"normal:10ms, 2ms":   NormalDistribution{Mean: 10 * time.Millisecond, StdDev: 2 * time.Millisecond},
+		"lognormal:10ms,0.5": LogNormalDistribution{Median: 10 * time.Millisecond, Sigma: 0.5},
+	}
+	for spec, expected := range valid {
+		d, err := ParseDistribution(spec)
+		asrt.NoError(err, spec)
+		asrt.Equal(expected, d, spec)
+	}
+
+	for _, spec := range []string{"", "const", "const:abc", "uniform:10ms", "uniform:15ms,5ms", "zipf:1", "exp:-1s"} {
+		_, err := ParseDistribution(spec)
+		asrt.Error(err, spec)
+	}
+}
@@ -0,0 +1,321 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+	"github.com/platinummonkey/go-concurrency-limits/limiter"
+	"github.com/platinummonkey/go-concurrency-limits/strategy"
+)
+
+// Sample window defaults, matching limiter.NewDefaultLimiterWithDefaults.
+const (
+	defaultMinWindowTime   = time.Second
+	defaultMaxWindowTime   = time.Second
+	defaultMinRTTThreshold = 100 * time.Microsecond
+	defaultWindowSize      = 100
+)
+
+// Options supplies the dependencies of a stack that can not be described by a document.
+type Options struct {
+	// MetricRegistries are the registries that may be selected by name with metrics.registry.
+	MetricRegistries map[string]core.MetricRegistry
+	// Logger is used by the limit algorithm and limiters, defaults to limit.NoopLimitLogger.
+	Logger limit.Logger
+	// LookupFunc assigns requests to partitions of a lookupPartition strategy, defaults to
+	// matchers.DefaultStringLookupFunc.
+	LookupFunc func(ctx context.Context) string
+	// Clock is used by the limiters and the idle policy of the limit, defaults to the system clock.
+	Clock core.Clock
+}
+
+// Stack is a limiter built from a Config, along with the components it was built from.
+type Stack struct {
+	// Limiter is the outermost limiter of the stack and the one that should be used.
+	Limiter core.Limiter
+	// Default is the DefaultLimiter at the core of the stack.
+	Default *limiter.DefaultLimiter
+	// Limit is the limit algorithm, including any WindowedLimit wrapper.
+	Limit    core.Limit
+	Strategy core.Strategy
+	Registry core.MetricRegistry
+
+	// algorithm is the limit algorithm without any WindowedLimit wrapper.
+	algorithm core.Limit
+	config    Config
+	clock     core.Clock
+	mu        sync.Mutex
+}
+
+// Build will validate the config and construct the limiter stack it describes.
+func Build(config Config, options Options) (*Stack, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if options.Logger == nil {
+		options.Logger = limit.NoopLimitLogger{}
+	}
+	if options.Clock == nil {
+		options.Clock = core.SystemClockInstance
+	}
+
+	registry := core.MetricRegistry(core.EmptyMetricRegistryInstance)
+	if config.Metrics.Registry != "" {
+		r, ok := options.MetricRegistries[config.Metrics.Registry]
+		if !ok {
+			return nil, &ValidationError{Errors: []*FieldError{{
+				Field:   "metrics.registry",
+				Message: fmt.Sprintf("unknown metric registry %q", config.Metrics.Registry),
+			}}}
+		}
+		registry = r
+	}
+	tags := config.Metrics.Tags
+
+	algorithm, l, err := buildLimit(config, options.Logger, options.Clock, registry, tags)
+	if err != nil {
+		return nil, err
+	}
+	s, err := buildStrategy(config.Strategy, l.EstimatedLimit(), options.LookupFunc, registry, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	lc := config.Limiter
+	defaultLimiter, err := limiter.NewDefaultLimiter(
+		l,
+		durationOrDefault(lc.MinWindowTime, defaultMinWindowTime),
+		durationOrDefault(lc.MaxWindowTime, defaultMaxWindowTime),
+		durationOrDefault(lc.MinRTTThreshold, defaultMinRTTThreshold),
+		intOrDefault(lc.WindowSize, defaultWindowSize),
+		s,
+		options.Logger,
+		registry,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("limiter: %w", err)
+	}
+	defaultLimiter.SetClock(options.Clock)
+
+	result := &Stack{
+		Limiter:  defaultLimiter,
+		Default:  defaultLimiter,
+		Limit:    l,
+		Strategy: s,
+		Registry: registry,
+
+		algorithm: algorithm,
+		config:    config,
+		clock:     options.Clock,
+	}
+	switch {
+	case config.Blocking != nil:
+		blocking := limiter.NewBlockingLimiter(defaultLimiter, config.Blocking.Timeout.Duration(), options.Logger)
+		blocking.SetClock(options.Clock)
+		result.Limiter = blocking
+	case config.Queue != nil:
+		result.Limiter = limiter.NewQueueBlockingLimiterFromConfig(defaultLimiter, limiter.QueueLimiterConfig{
+			Ordering:            limiter.QueueOrdering(config.Queue.Ordering),
+			MaxBacklogSize:      config.Queue.MaxBacklogSize,
+			MaxBacklogTimeout:   config.Queue.MaxBacklogTimeout.Duration(),
+			BacklogEvictDoneCtx: config.Queue.BacklogEvictDoneCtx,
+			CoDelTarget:         config.Queue.CoDelTarget.Duration(),
+			CoDelInterval:       config.Queue.CoDelInterval.Duration(),
+			MetricRegistry:      registry,
+			Tags:                append([]string(nil), tags...),
+			Clock:               options.Clock,
+		})
+	}
+	return result, nil
+}
+
+// buildLimit returns the limit algorithm and the limit to use, which is the algorithm wrapped in any WindowedLimit.
+// The parameters are validated by the config of the limit.
+func buildLimit(
+	config Config,
+	logger limit.Logger,
+	clock core.Clock,
+	registry core.MetricRegistry,
+	tags []string,
+) (core.Limit, core.Limit, error) {
+	c := config.Limit
+	var l core.Limit
+	var err error
+	switch c.Algorithm {
+	case AlgorithmVegas:
+		vegas := vegasConfig(c, clock)
+		vegas.Logger, vegas.MetricRegistry, vegas.Tags = logger, registry, tags
+		l, err = limit.NewVegasLimitFromConfig(config.Name, vegas)
+	case AlgorithmGradient:
+		gradient := gradientConfig(c)
+		gradient.Logger, gradient.MetricRegistry, gradient.Tags = logger, registry, tags
+		l, err = limit.NewGradientLimitFromConfig(config.Name, gradient)
+	case AlgorithmGradient2:
+		gradient2 := gradient2Config(c, clock)
+		gradient2.Logger, gradient2.MetricRegistry, gradient2.Tags = logger, registry, tags
+		l, err = limit.NewGradient2LimitFromConfig(config.Name, gradient2)
+	case AlgorithmAIMD:
+		aimd := aimdConfig(c, clock)
+		aimd.MetricRegistry, aimd.Tags = registry, tags
+		l, err = limit.NewAIMDLimitFromConfig(config.Name, aimd)
+	case AlgorithmFixed:
+		l, err = limit.NewFixedLimitFromConfig(config.Name, limit.FixedLimitConfig{
+			Limit:          c.InitialLimit,
+			MetricRegistry: registry,
+			Tags:           tags,
+		})
+	case AlgorithmSettable:
+		l = limit.NewSettableLimit(config.Name, c.InitialLimit, registry, tags...)
+	}
+	if err != nil {
+		return nil, nil, fieldError("limit", err)
+	}
+
+	if w := c.Windowed; w != nil {
+		windowed, err := limit.NewWindowedLimitFromConfig(config.Name, limit.WindowedLimitConfig{
+			Delegate:        l,
+			MinWindowTime:   w.MinWindowTime.Duration(),
+			MaxWindowTime:   w.MaxWindowTime.Duration(),
+			WindowSize:      w.WindowSize,
+			MinRTTThreshold: w.MinRTTThreshold.Duration(),
+			MetricRegistry:  registry,
+			Tags:            tags,
+		})
+		if err != nil {
+			return nil, nil, fieldError("limit.windowed", err)
+		}
+		return l, windowed, nil
+	}
+	return l, l, nil
+}
+
+// vegasConfig returns the VegasLimit parameters of c, unset fields are left zero to select the defaults of the
+// config.
+func vegasConfig(c LimitConfig, clock core.Clock) limit.VegasLimitConfig {
+	config := limit.VegasLimitConfig{InitialLimit: c.InitialLimit, MaxLimit: c.MaxLimit}
+	if v := c.Vegas; v != nil {
+		config.Smoothing = floatOrDefault(v.Smoothing, 0)
+		config.ProbeMultiplier = v.ProbeMultiplier
+		config.Idle = v.Idle.policy(clock)
+	}
+	return config
+}
+
+// gradientConfig returns the GradientLimit parameters of c, unset fields are left zero to select the defaults of the
+// config.
+func gradientConfig(c LimitConfig) limit.GradientLimitConfig {
+	config := limit.GradientLimitConfig{InitialLimit: c.InitialLimit, MinLimit: c.MinLimit, MaxLimit: c.MaxLimit}
+	if g := c.Gradient; g != nil {
+		config.Smoothing = floatOrDefault(g.Smoothing, 0)
+		config.RTTTolerance = floatOrDefault(g.RTTTolerance, 0)
+		config.ProbeInterval = g.ProbeInterval
+		config.QueueSizeFunc = constantQueueSize(g.QueueSize)
+	}
+	return config
+}
+
+// gradient2Config returns the Gradient2Limit parameters of c, unset fields are left zero to select the defaults of
+// the config.
+func gradient2Config(c LimitConfig, clock core.Clock) limit.Gradient2LimitConfig {
+	config := limit.Gradient2LimitConfig{InitialLimit: c.InitialLimit, MinLimit: c.MinLimit, MaxLimit: c.MaxLimit}
+	if g := c.Gradient2; g != nil {
+		config.Smoothing = floatOrDefault(g.Smoothing, 0)
+		config.LongWindow = g.LongWindow
+		config.QueueSizeFunc = constantQueueSize(g.QueueSize)
+		config.RTTTolerance = floatOrDefault(g.RTTTolerance, 0)
+		if r := g.DriftRecovery; r != nil {
+			config.DriftRecovery.Ratio = floatOrDefault(r.Ratio, 0)
+			config.DriftRecovery.Decay = floatOrDefault(r.Decay, 0)
+		}
+		config.UseAverageRTT = g.UseAverageRTT
+		config.Idle = g.Idle.policy(clock)
+	}
+	return config
+}
+
+// aimdConfig returns the AIMDLimit parameters of c, unset fields are left zero to select the defaults of the config.
+func aimdConfig(c LimitConfig, clock core.Clock) limit.AIMDLimitConfig {
+	config := limit.AIMDLimitConfig{InitialLimit: c.InitialLimit}
+	if a := c.AIMD; a != nil {
+		config.BackOffRatio = floatOrDefault(a.BackOffRatio, 0)
+		config.IncreaseBy = a.IncreaseBy
+		config.Idle = a.Idle.policy(clock)
+	}
+	return config
+}
+
+// policy returns the idle policy of c, which is disabled if c is nil.
+func (c *IdleConfig) policy(clock core.Clock) limit.IdlePolicy {
+	policy := limit.IdlePolicy{Clock: clock}
+	if c != nil {
+		policy.Timeout = c.Timeout.Duration()
+		policy.DecayHalfLife = c.Decay.Duration()
+		policy.Rebaseline = c.Rebaseline
+	}
+	return policy
+}
+
+func buildStrategy(
+	c StrategyConfig,
+	initialLimit int,
+	lookupFunc func(ctx context.Context) string,
+	registry core.MetricRegistry,
+	tags []string,
+) (core.Strategy, error) {
+	switch c.Type {
+	case StrategyPrecise:
+		return strategy.NewPreciseStrategyWithMetricRegistry(initialLimit, registry, tags...), nil
+	case StrategyLookupPartition:
+		partitions := make(map[string]*strategy.LookupPartition, len(c.Partitions))
+		for _, p := range c.Partitions {
+			partitions[p.Name] = strategy.NewLookupPartitionWithMetricRegistry(
+				p.Name, p.Percent, int32(initialLimit), registry)
+		}
+		s, err := strategy.NewLookupPartitionStrategyWithMetricRegistry(
+			partitions, lookupFunc, int32(initialLimit), registry)
+		if err != nil {
+			return nil, fieldError("strategy", err)
+		}
+		return s, nil
+	default:
+		return strategy.NewSimpleStrategyWithMetricRegistry(initialLimit, registry, tags...), nil
+	}
+}
+
+// fieldError reports a constructor error against the section of the document that configured it.
+func fieldError(field string, err error) error {
+	return &ValidationError{Errors: []*FieldError{{Field: field, Message: err.Error()}}}
+}
+
+func constantQueueSize(size int) func(int) int {
+	if size <= 0 {
+		return nil
+	}
+	return func(int) int { return size }
+}
+
+// durationOrDefault returns the duration in nanoseconds, as taken by the limiter constructors.
+func durationOrDefault(d Duration, def time.Duration) int64 {
+	if d <= 0 {
+		return int64(def)
+	}
+	return int64(d)
+}
+
+func intOrDefault(v int, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+func floatOrDefault(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
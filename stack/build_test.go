@@ -0,0 +1,182 @@
+package stack
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+	"github.com/platinummonkey/go-concurrency-limits/limiter"
+	"github.com/platinummonkey/go-concurrency-limits/strategy"
+)
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Algorithms", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		expected := map[string]string{
+			AlgorithmVegas:     "VegasLimit",
+			AlgorithmGradient:  "GradientLimit",
+			AlgorithmGradient2: "Gradient2Limit",
+			AlgorithmAIMD:      "AIMDLimit",
+			AlgorithmFixed:     "FixedLimit",
+			AlgorithmSettable:  "SettableLimit",
+		}
+		for algorithm, typ := range expected {
+			s, err := Build(Config{Name: "test", Limit: LimitConfig{Algorithm: algorithm, InitialLimit: 20}}, Options{})
+			asrt.NoError(err, algorithm)
+			asrt.Equal(typ, core.SnapshotOf(s.Limit).Type, algorithm)
+			asrt.Equal(20, s.Limit.EstimatedLimit(), algorithm)
+			asrt.Equal(s.Default, s.Limiter)
+			_, ok := s.Strategy.(*strategy.SimpleStrategy)
+			asrt.True(ok)
+		}
+	})
+
+	t.Run("YAML", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		config, err := ParseYAML([]byte(testYAML))
+		asrt.NoError(err)
+		s, err := Build(config, Options{})
+		asrt.NoError(err)
+
+		g2, ok := s.Limit.(*limit.Gradient2Limit)
+		asrt.True(ok)
+		asrt.Equal(20, g2.EstimatedLimit())
+		asrt.Equal(1.5, g2.Config().RTTTolerance)
+		asrt.Equal(limit.Gradient2DriftRecovery{Ratio: 3, Decay: 0.5}, g2.Config().DriftRecovery)
+		asrt.True(g2.Config().UseAverageRTT)
+		_, ok = s.Strategy.(*strategy.LookupPartitionStrategy)
+		asrt.True(ok)
+		queue, ok := s.Limiter.(*limiter.QueueBlockingLimiter)
+		asrt.True(ok)
+		asrt.Equal(5*time.Millisecond, queue.Snapshot().Attributes["codelTarget"])
+
+		listener, ok := s.Limiter.Acquire(context.Background())
+		asrt.True(ok)
+		listener.OnSuccess()
+	})
+
+	t.Run("Blocking", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		config, err := ParseJSON([]byte(testJSON))
+		asrt.NoError(err)
+		s, err := Build(config, Options{})
+		asrt.NoError(err)
+		_, ok := s.Limiter.(*limiter.BlockingLimiter)
+		asrt.True(ok)
+		asrt.Equal(10, s.Limit.EstimatedLimit())
+		asrt.Equal(limit.IdlePolicy{Timeout: time.Minute, DecayHalfLife: 5 * time.Minute,
+			Clock: core.SystemClockInstance}, s.Limit.(*limit.AIMDLimit).Config().Idle)
+	})
+
+	t.Run("Idle", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		config := Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmAIMD, InitialLimit: 10,
+			AIMD: &AIMDConfig{IncreaseBy: 10, Idle: &IdleConfig{
+				Timeout: Duration(time.Second), Decay: Duration(time.Second)}}}}
+		s, err := Build(config, Options{Clock: fakeClock})
+		asrt.NoError(err)
+		s.Limit.OnSample(0, 1, 10, false)
+		asrt.Equal(20, s.Limit.EstimatedLimit())
+
+		// reloading an unchanged idle policy does not restart the idle period
+		fakeClock.Advance(3 * time.Second)
+		asrt.NoError(s.Reload(config))
+		s.Limit.(core.IdleLimit).CheckIdle()
+		asrt.Less(s.Limit.EstimatedLimit(), 20)
+
+		config.Limit.AIMD.Idle.Timeout = Duration(-time.Second)
+		asrt.EqualError(s.Reload(config), "invalid limiter config: limit: invalid AIMDLimit update: "+
+			"idle.timeout must be >= 0, got -1s")
+		_, err = Build(config, Options{})
+		asrt.EqualError(err, "invalid limiter config: limit: invalid AIMDLimit config: "+
+			"idle.timeout must be >= 0, got -1s")
+	})
+
+	t.Run("Windowed", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		s, err := Build(Config{Name: "test", Limit: LimitConfig{
+			Algorithm: AlgorithmVegas,
+			Windowed:  &WindowedConfig{MinWindowTime: Duration(time.Second), WindowSize: 20},
+		}}, Options{})
+		asrt.NoError(err)
+		snapshot := core.SnapshotOf(s.Limit)
+		asrt.Equal("WindowedLimit", snapshot.Type)
+		asrt.Equal("VegasLimit", snapshot.Delegate.Type)
+	})
+
+	t.Run("MetricRegistry", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		registry := &core.EmptyMetricRegistry{}
+		config := Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas},
+			Metrics: MetricsConfig{Registry: "main"}}
+
+		s, err := Build(config, Options{MetricRegistries: map[string]core.MetricRegistry{"main": registry}})
+		asrt.NoError(err)
+		asrt.Equal(registry, s.Registry)
+
+		_, err = Build(config, Options{})
+		asrt.Equal([]string{"metrics.registry"}, fields(err))
+	})
+
+	t.Run("Invalid", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		_, err := Build(Config{Name: "test"}, Options{})
+		asrt.Equal([]string{"limit.algorithm"}, fields(err))
+
+		// constructor errors are reported against the section that configured them
+		_, err = Build(Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmGradient2, MinLimit: 2000}},
+			Options{})
+		asrt.Equal([]string{"limit"}, fields(err))
+	})
+
+	t.Run("InvalidParameters", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		smoothing := 1.5
+		tolerance := 0.5
+		// the parameters are validated by the config of the limit, which reports every invalid one
+		_, err := Build(Config{Name: "test", Limit: LimitConfig{
+			Algorithm: AlgorithmGradient, InitialLimit: 50, MinLimit: 20, MaxLimit: 10,
+			Gradient: &GradientConfig{Smoothing: &smoothing, RTTTolerance: &tolerance, ProbeInterval: -2},
+		}}, Options{})
+		asrt.EqualError(err, "invalid limiter config: limit: invalid GradientLimit config: "+
+			"minLimit 20 must be <= maxLimit 10; smoothing must be in (0, 1], got 1.5; rttTolerance must be >= 1, "+
+			"got 0.5; probeInterval must be >= 1 or ProbeDisabled, got -2")
+
+		_, err = Build(Config{Name: "test", Limit: LimitConfig{
+			Algorithm: AlgorithmGradient2,
+			Gradient2: &Gradient2Config{RTTTolerance: &tolerance, DriftRecovery: &DriftRecoveryConfig{Ratio: &tolerance}},
+		}}, Options{})
+		asrt.EqualError(err, "invalid limiter config: limit: invalid Gradient2Limit config: "+
+			"rttTolerance must be >= 1, got 0.5; driftRecovery.ratio must be > 1, got 0.5")
+
+		backOffRatio := 1.5
+		_, err = Build(Config{Name: "test", Limit: LimitConfig{
+			Algorithm: AlgorithmAIMD, AIMD: &AIMDConfig{BackOffRatio: &backOffRatio, IncreaseBy: -1},
+		}}, Options{})
+		asrt.EqualError(err, "invalid limiter config: limit: invalid AIMDLimit config: "+
+			"backOffRatio must be in (0, 1), got 1.5; increaseBy must be >= 1, got -1")
+
+		_, err = Build(Config{Name: "test", Limit: LimitConfig{
+			Algorithm: AlgorithmVegas,
+			Windowed:  &WindowedConfig{MinWindowTime: Duration(time.Millisecond), WindowSize: 5},
+		}}, Options{})
+		asrt.EqualError(err, "invalid limiter config: limit.windowed: invalid WindowedLimit config: "+
+			"minWindowTime must be >= 100ms, got 1ms; windowSize must be >= 10, got 5")
+	})
+}
@@ -0,0 +1,331 @@
+package stack
+
+import (
+	"fmt"
+
+	"github.com/platinummonkey/go-concurrency-limits/limiter"
+)
+
+// Limit algorithms supported by LimitConfig.Algorithm.
+const (
+	AlgorithmVegas     = "vegas"
+	AlgorithmGradient  = "gradient"
+	AlgorithmGradient2 = "gradient2"
+	AlgorithmAIMD      = "aimd"
+	AlgorithmFixed     = "fixed"
+	AlgorithmSettable  = "settable"
+)
+
+// Strategies supported by StrategyConfig.Type.
+const (
+	StrategySimple          = "simple"
+	StrategyPrecise         = "precise"
+	StrategyLookupPartition = "lookupPartition"
+)
+
+// Config describes a complete limiter stack.  The zero value of every optional field selects the default of the
+// matching constructor.
+type Config struct {
+	// Name of the limiter, used to name the limit algorithm and its metrics.
+	Name     string         `yaml:"name" json:"name"`
+	Limit    LimitConfig    `yaml:"limit" json:"limit"`
+	Strategy StrategyConfig `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+	Limiter  LimiterConfig  `yaml:"limiter,omitempty" json:"limiter,omitempty"`
+	// Blocking wraps the limiter in a BlockingLimiter, it may not be combined with Queue.
+	Blocking *BlockingConfig `yaml:"blocking,omitempty" json:"blocking,omitempty"`
+	// Queue wraps the limiter in a QueueBlockingLimiter, it may not be combined with Blocking.
+	Queue   *QueueConfig  `yaml:"queue,omitempty" json:"queue,omitempty"`
+	Metrics MetricsConfig `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+}
+
+// LimitConfig configures the limit algorithm.  Only the section of the selected algorithm may be set.
+type LimitConfig struct {
+	// Algorithm is one of vegas, gradient, gradient2, aimd, fixed or settable.
+	Algorithm    string `yaml:"algorithm" json:"algorithm"`
+	InitialLimit int    `yaml:"initialLimit,omitempty" json:"initialLimit,omitempty"`
+	// MinLimit is supported by gradient and gradient2.
+	MinLimit int `yaml:"minLimit,omitempty" json:"minLimit,omitempty"`
+	// MaxLimit is supported by vegas, gradient and gradient2.
+	MaxLimit int `yaml:"maxLimit,omitempty" json:"maxLimit,omitempty"`
+
+	Vegas     *VegasConfig     `yaml:"vegas,omitempty" json:"vegas,omitempty"`
+	Gradient  *GradientConfig  `yaml:"gradient,omitempty" json:"gradient,omitempty"`
+	Gradient2 *Gradient2Config `yaml:"gradient2,omitempty" json:"gradient2,omitempty"`
+	AIMD      *AIMDConfig      `yaml:"aimd,omitempty" json:"aimd,omitempty"`
+	// Windowed wraps the algorithm in a WindowedLimit.
+	Windowed *WindowedConfig `yaml:"windowed,omitempty" json:"windowed,omitempty"`
+}
+
+// VegasConfig holds the parameters specific to VegasLimit.
+type VegasConfig struct {
+	Smoothing       *float64    `yaml:"smoothing,omitempty" json:"smoothing,omitempty"`
+	ProbeMultiplier int         `yaml:"probeMultiplier,omitempty" json:"probeMultiplier,omitempty"`
+	Idle            *IdleConfig `yaml:"idle,omitempty" json:"idle,omitempty"`
+}
+
+// GradientConfig holds the parameters specific to GradientLimit.
+type GradientConfig struct {
+	Smoothing *float64 `yaml:"smoothing,omitempty" json:"smoothing,omitempty"`
+	// QueueSize is a constant queue size, by default the queue size grows with the square root of the limit.
+	QueueSize    int      `yaml:"queueSize,omitempty" json:"queueSize,omitempty"`
+	RTTTolerance *float64 `yaml:"rttTolerance,omitempty" json:"rttTolerance,omitempty"`
+	// ProbeInterval is the number of updates between probes of the no load RTT, -1 disables probing.
+	ProbeInterval int `yaml:"probeInterval,omitempty" json:"probeInterval,omitempty"`
+}
+
+// Gradient2Config holds the parameters specific to Gradient2Limit.
+type Gradient2Config struct {
+	Smoothing  *float64 `yaml:"smoothing,omitempty" json:"smoothing,omitempty"`
+	QueueSize  int      `yaml:"queueSize,omitempty" json:"queueSize,omitempty"`
+	LongWindow int      `yaml:"longWindow,omitempty" json:"longWindow,omitempty"`
+	// RTTTolerance is how much the short term RTT may exceed the long term RTT before the limit is reduced.
+	RTTTolerance  *float64             `yaml:"rttTolerance,omitempty" json:"rttTolerance,omitempty"`
+	DriftRecovery *DriftRecoveryConfig `yaml:"driftRecovery,omitempty" json:"driftRecovery,omitempty"`
+	// UseAverageRTT uses the average RTT of the requests in a sample window instead of the minimum.
+	UseAverageRTT bool        `yaml:"useAverageRTT,omitempty" json:"useAverageRTT,omitempty"`
+	Idle          *IdleConfig `yaml:"idle,omitempty" json:"idle,omitempty"`
+}
+
+// DriftRecoveryConfig configures the decay of the long term RTT of a Gradient2Limit after prolonged load, see
+// limit.Gradient2DriftRecovery.
+type DriftRecoveryConfig struct {
+	Ratio *float64 `yaml:"ratio,omitempty" json:"ratio,omitempty"`
+	Decay *float64 `yaml:"decay,omitempty" json:"decay,omitempty"`
+}
+
+// AIMDConfig holds the parameters specific to AIMDLimit.
+type AIMDConfig struct {
+	BackOffRatio *float64    `yaml:"backOffRatio,omitempty" json:"backOffRatio,omitempty"`
+	IncreaseBy   int         `yaml:"increaseBy,omitempty" json:"increaseBy,omitempty"`
+	Idle         *IdleConfig `yaml:"idle,omitempty" json:"idle,omitempty"`
+}
+
+// IdleConfig configures how the limit adjusts itself once traffic stops, see limit.IdlePolicy.  The policy is
+// disabled unless a timeout is set.
+type IdleConfig struct {
+	// Timeout is how long the limit may go without an active sample before it is idle.
+	Timeout Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	// Decay is the half life of the distance between the limit and the initial limit while idle, 0 disables it.
+	Decay Duration `yaml:"decay,omitempty" json:"decay,omitempty"`
+	// Rebaseline resets the RTT measurements once idle.
+	Rebaseline bool `yaml:"rebaseline,omitempty" json:"rebaseline,omitempty"`
+}
+
+// WindowedConfig holds the parameters of a WindowedLimit.
+type WindowedConfig struct {
+	MinWindowTime   Duration `yaml:"minWindowTime,omitempty" json:"minWindowTime,omitempty"`
+	MaxWindowTime   Duration `yaml:"maxWindowTime,omitempty" json:"maxWindowTime,omitempty"`
+	WindowSize      int      `yaml:"windowSize,omitempty" json:"windowSize,omitempty"`
+	MinRTTThreshold Duration `yaml:"minRTTThreshold,omitempty" json:"minRTTThreshold,omitempty"`
+}
+
+// StrategyConfig configures the enforcement strategy.
+type StrategyConfig struct {
+	// Type is one of simple, precise or lookupPartition.  Defaults to simple.
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+	// Partitions are required by lookupPartition.  Requests are assigned to partitions by Options.LookupFunc.
+	Partitions []PartitionConfig `yaml:"partitions,omitempty" json:"partitions,omitempty"`
+}
+
+// PartitionConfig is a single partition of a lookupPartition strategy.
+type PartitionConfig struct {
+	Name    string  `yaml:"name" json:"name"`
+	Percent float64 `yaml:"percent" json:"percent"`
+}
+
+// LimiterConfig configures the sample windows of the DefaultLimiter.
+type LimiterConfig struct {
+	MinWindowTime   Duration `yaml:"minWindowTime,omitempty" json:"minWindowTime,omitempty"`
+	MaxWindowTime   Duration `yaml:"maxWindowTime,omitempty" json:"maxWindowTime,omitempty"`
+	MinRTTThreshold Duration `yaml:"minRTTThreshold,omitempty" json:"minRTTThreshold,omitempty"`
+	WindowSize      int      `yaml:"windowSize,omitempty" json:"windowSize,omitempty"`
+}
+
+// BlockingConfig configures a BlockingLimiter.
+type BlockingConfig struct {
+	// Timeout is the maximum time to block, zero blocks until the context is done.
+	Timeout Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// QueueConfig configures a QueueBlockingLimiter, see limiter.QueueLimiterConfig.
+type QueueConfig struct {
+	Ordering            string   `yaml:"ordering,omitempty" json:"ordering,omitempty"`
+	MaxBacklogSize      int      `yaml:"maxBacklogSize,omitempty" json:"maxBacklogSize,omitempty"`
+	MaxBacklogTimeout   Duration `yaml:"maxBacklogTimeout,omitempty" json:"maxBacklogTimeout,omitempty"`
+	BacklogEvictDoneCtx bool     `yaml:"backlogEvictDoneCtx,omitempty" json:"backlogEvictDoneCtx,omitempty"`
+	CoDelTarget         Duration `yaml:"codelTarget,omitempty" json:"codelTarget,omitempty"`
+	CoDelInterval       Duration `yaml:"codelInterval,omitempty" json:"codelInterval,omitempty"`
+}
+
+// MetricsConfig selects the metric registry.
+type MetricsConfig struct {
+	// Registry names one of Options.MetricRegistries, empty disables metrics.
+	Registry string   `yaml:"registry,omitempty" json:"registry,omitempty"`
+	Tags     []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// Validate will return a *ValidationError listing every invalid field, or nil if the config is valid.  The parameters
+// of the limit algorithm are validated by its config when the stack is built or reloaded, and reported against the
+// limit field.
+func (c *Config) Validate() error {
+	v := &validator{}
+	if c.Name == "" {
+		v.errorf("name", "is required")
+	}
+	c.Limit.validate(v)
+	c.Strategy.validate(v)
+	c.Limiter.validate(v)
+	if c.Blocking != nil {
+		if c.Blocking.Timeout < 0 {
+			v.errorf("blocking.timeout", "must be >= 0")
+		}
+		if c.Queue != nil {
+			v.errorf("queue", "may not be combined with blocking")
+		}
+	}
+	if c.Queue != nil {
+		c.Queue.validate(v)
+	}
+	return v.err()
+}
+
+// validate will check the structure of the limit section, the parameters of the algorithm are validated by the config
+// of the limit when it is built or updated.
+func (c *LimitConfig) validate(v *validator) {
+	switch c.Algorithm {
+	case AlgorithmVegas, AlgorithmGradient, AlgorithmGradient2, AlgorithmAIMD, AlgorithmFixed, AlgorithmSettable:
+	case "":
+		v.errorf("limit.algorithm", "is required")
+	default:
+		v.errorf("limit.algorithm", "unknown algorithm %q", c.Algorithm)
+	}
+
+	if c.InitialLimit < 0 {
+		v.errorf("limit.initialLimit", "must be >= 0")
+	}
+	if c.InitialLimit == 0 && (c.Algorithm == AlgorithmFixed || c.Algorithm == AlgorithmSettable) {
+		v.errorf("limit.initialLimit", "is required by the %s algorithm", c.Algorithm)
+	}
+	if c.MinLimit != 0 && c.Algorithm != AlgorithmGradient && c.Algorithm != AlgorithmGradient2 {
+		v.errorf("limit.minLimit", "is not supported by the %s algorithm", c.Algorithm)
+	}
+	if c.MaxLimit != 0 && c.Algorithm != AlgorithmVegas && c.Algorithm != AlgorithmGradient &&
+		c.Algorithm != AlgorithmGradient2 {
+		v.errorf("limit.maxLimit", "is not supported by the %s algorithm", c.Algorithm)
+	}
+
+	section := func(name string, set bool, algorithm string) bool {
+		if set && c.Algorithm != algorithm {
+			v.errorf("limit."+name, "is only valid with the %s algorithm", algorithm)
+			return false
+		}
+		return set
+	}
+	if section("vegas", c.Vegas != nil, AlgorithmVegas) {
+		validateSet(v, "limit.vegas.smoothing", c.Vegas.Smoothing, "must be in (0, 1]")
+	}
+	if section("gradient", c.Gradient != nil, AlgorithmGradient) {
+		validateSet(v, "limit.gradient.smoothing", c.Gradient.Smoothing, "must be in (0, 1]")
+		validateSet(v, "limit.gradient.rttTolerance", c.Gradient.RTTTolerance, "must be >= 1")
+		if c.Gradient.QueueSize < 0 {
+			v.errorf("limit.gradient.queueSize", "must be >= 0")
+		}
+	}
+	if section("gradient2", c.Gradient2 != nil, AlgorithmGradient2) {
+		validateSet(v, "limit.gradient2.smoothing", c.Gradient2.Smoothing, "must be in (0, 1]")
+		validateSet(v, "limit.gradient2.rttTolerance", c.Gradient2.RTTTolerance, "must be >= 1")
+		if r := c.Gradient2.DriftRecovery; r != nil {
+			validateSet(v, "limit.gradient2.driftRecovery.ratio", r.Ratio, "must be > 1")
+			validateSet(v, "limit.gradient2.driftRecovery.decay", r.Decay, "must be in (0, 1]")
+		}
+		if c.Gradient2.QueueSize < 0 {
+			v.errorf("limit.gradient2.queueSize", "must be >= 0")
+		}
+	}
+	if section("aimd", c.AIMD != nil, AlgorithmAIMD) {
+		validateSet(v, "limit.aimd.backOffRatio", c.AIMD.BackOffRatio, "must be in (0, 1)")
+	}
+}
+
+// validateSet will reject an explicit zero of an optional parameter, which the config of the limit would replace by
+// its default.  Any other value is validated by the config of the limit.
+func validateSet(v *validator, field string, value *float64, message string) {
+	if value != nil && *value == 0 {
+		v.errorf(field, "%s", message)
+	}
+}
+
+func (c *StrategyConfig) validate(v *validator) {
+	switch c.Type {
+	case "", StrategySimple, StrategyPrecise:
+		if len(c.Partitions) > 0 {
+			v.errorf("strategy.partitions", "are only valid with the %s strategy", StrategyLookupPartition)
+		}
+		return
+	case StrategyLookupPartition:
+	default:
+		v.errorf("strategy.type", "unknown strategy %q", c.Type)
+		return
+	}
+
+	if len(c.Partitions) == 0 {
+		v.errorf("strategy.partitions", "are required by the %s strategy", StrategyLookupPartition)
+		return
+	}
+	names := make(map[string]struct{}, len(c.Partitions))
+	sum := 0.0
+	for i, p := range c.Partitions {
+		field := fmt.Sprintf("strategy.partitions[%d]", i)
+		if p.Name == "" {
+			v.errorf(field+".name", "is required")
+		} else if _, ok := names[p.Name]; ok {
+			v.errorf(field+".name", "duplicate partition %q", p.Name)
+		}
+		names[p.Name] = struct{}{}
+		if p.Percent <= 0 || p.Percent > 1 {
+			v.errorf(field+".percent", "must be in (0, 1]")
+		}
+		sum += p.Percent
+	}
+	if sum > 1 {
+		v.errorf("strategy.partitions", "sum of percentages must be <= 1, got %g", sum)
+	}
+}
+
+func (c *LimiterConfig) validate(v *validator) {
+	if c.MinWindowTime < 0 {
+		v.errorf("limiter.minWindowTime", "must be >= 0")
+	}
+	if c.MaxWindowTime < 0 {
+		v.errorf("limiter.maxWindowTime", "must be >= 0")
+	}
+	if c.MinWindowTime > 0 && c.MaxWindowTime > 0 && c.MaxWindowTime < c.MinWindowTime {
+		v.errorf("limiter.maxWindowTime", "must be >= minWindowTime")
+	}
+	if c.MinRTTThreshold < 0 {
+		v.errorf("limiter.minRTTThreshold", "must be >= 0")
+	}
+	if c.WindowSize != 0 && c.WindowSize < 10 {
+		v.errorf("limiter.windowSize", "must be >= 10")
+	}
+}
+
+func (c *QueueConfig) validate(v *validator) {
+	switch limiter.QueueOrdering(c.Ordering) {
+	case "", limiter.OrderingFIFO, limiter.OrderingLIFO:
+	default:
+		v.errorf("queue.ordering", "must be %s or %s", limiter.OrderingFIFO, limiter.OrderingLIFO)
+	}
+	if c.MaxBacklogSize < 0 {
+		v.errorf("queue.maxBacklogSize", "must be >= 0")
+	}
+	if c.MaxBacklogTimeout < 0 {
+		v.errorf("queue.maxBacklogTimeout", "must be >= 0")
+	}
+	if c.CoDelTarget < 0 {
+		v.errorf("queue.codelTarget", "must be >= 0")
+	}
+	if c.CoDelInterval < 0 {
+		v.errorf("queue.codelInterval", "must be >= 0")
+	}
+}
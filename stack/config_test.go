@@ -0,0 +1,273 @@
+package stack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testYAML = `
+name: test
+limit:
+  algorithm: gradient2
+  initialLimit: 20
+  maxLimit: 200
+  gradient2:
+    smoothing: 0.5
+    longWindow: 50
+    rttTolerance: 1.5
+    driftRecovery:
+      ratio: 3
+      decay: 0.5
+    useAverageRTT: true
+strategy:
+  type: lookupPartition
+  partitions:
+    - name: live
+      percent: 0.8
+    - name: batch
+      percent: 0.2
+queue:
+  ordering: fifo
+  maxBacklogSize: 50
+  maxBacklogTimeout: 250ms
+  codelTarget: 5ms
+metrics:
+  tags: ["env:test"]
+`
+
+const testJSON = `{
+  "name": "test",
+  "limit": {"algorithm": "aimd", "initialLimit": 10,
+    "aimd": {"backOffRatio": 0.5, "idle": {"timeout": "1m", "decay": "5m"}}},
+  "limiter": {"minWindowTime": "500ms", "maxWindowTime": 2000000000},
+  "blocking": {"timeout": "1s"}
+}`
+
+// fields returns the offending fields of a validation error.
+func fields(err error) []string {
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		return nil
+	}
+	result := make([]string, 0, len(validationErr.Errors))
+	for _, e := range validationErr.Errors {
+		result = append(result, e.Field)
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("YAML", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		config, err := ParseYAML([]byte(testYAML))
+		asrt.NoError(err)
+		asrt.NoError(config.Validate())
+		asrt.Equal(AlgorithmGradient2, config.Limit.Algorithm)
+		asrt.Equal(0.5, *config.Limit.Gradient2.Smoothing)
+		asrt.Equal(50, config.Limit.Gradient2.LongWindow)
+		asrt.Equal(1.5, *config.Limit.Gradient2.RTTTolerance)
+		asrt.Equal(3.0, *config.Limit.Gradient2.DriftRecovery.Ratio)
+		asrt.Equal(0.5, *config.Limit.Gradient2.DriftRecovery.Decay)
+		asrt.True(config.Limit.Gradient2.UseAverageRTT)
+		asrt.Len(config.Strategy.Partitions, 2)
+		asrt.Equal(250*time.Millisecond, config.Queue.MaxBacklogTimeout.Duration())
+		asrt.Equal(5*time.Millisecond, config.Queue.CoDelTarget.Duration())
+		asrt.Equal([]string{"env:test"}, config.Metrics.Tags)
+	})
+
+	t.Run("JSON", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		config, err := ParseJSON([]byte(testJSON))
+		asrt.NoError(err)
+		asrt.NoError(config.Validate())
+		asrt.Equal(0.5, *config.Limit.AIMD.BackOffRatio)
+		asrt.Equal(IdleConfig{Timeout: Duration(time.Minute), Decay: Duration(5 * time.Minute)},
+			*config.Limit.AIMD.Idle)
+		asrt.Equal(500*time.Millisecond, config.Limiter.MinWindowTime.Duration())
+		asrt.Equal(2*time.Second, config.Limiter.MaxWindowTime.Duration())
+		asrt.Equal(time.Second, config.Blocking.Timeout.Duration())
+	})
+
+	t.Run("UnknownField", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		_, err := ParseYAML([]byte("name: test\nlimit:\n  algorithm: vegas\n  smoothing: 1\n"))
+		asrt.Error(err)
+		_, err = ParseJSON([]byte(`{"name": "test", "limt": {}}`))
+		asrt.Error(err)
+	})
+
+	t.Run("InvalidDuration", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		_, err := ParseJSON([]byte(`{"blocking": {"timeout": "soon"}}`))
+		asrt.Error(err)
+		_, err = ParseYAML([]byte("blocking:\n  timeout: [1]\n"))
+		asrt.Error(err)
+	})
+
+	t.Run("LoadFile", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		dir := t2.TempDir()
+		yamlPath := filepath.Join(dir, "limiter.yaml")
+		jsonPath := filepath.Join(dir, "limiter.json")
+		invalidPath := filepath.Join(dir, "invalid.yaml")
+		asrt.NoError(os.WriteFile(yamlPath, []byte(testYAML), 0o600))
+		asrt.NoError(os.WriteFile(jsonPath, []byte(testJSON), 0o600))
+		asrt.NoError(os.WriteFile(invalidPath, []byte("name: test\nlimit:\n  algorithm: bogus\n"), 0o600))
+
+		config, err := LoadFile(yamlPath)
+		asrt.NoError(err)
+		asrt.Equal(AlgorithmGradient2, config.Limit.Algorithm)
+		config, err = LoadFile(jsonPath)
+		asrt.NoError(err)
+		asrt.Equal(AlgorithmAIMD, config.Limit.Algorithm)
+		_, err = LoadFile(invalidPath)
+		asrt.Equal([]string{"limit.algorithm"}, fields(err))
+		_, err = LoadFile(filepath.Join(dir, "missing.yaml"))
+		asrt.Error(err)
+	})
+
+	t.Run("DurationRoundTrip", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		d := Duration(1500 * time.Millisecond)
+		data, err := d.MarshalJSON()
+		asrt.NoError(err)
+		asrt.Equal(`"1.5s"`, string(data))
+		value, err := d.MarshalYAML()
+		asrt.NoError(err)
+		asrt.Equal("1.5s", value)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	zero := 0.0
+
+	cases := []struct {
+		name   string
+		config Config
+		fields []string
+	}{
+		{
+			name:   "Empty",
+			config: Config{},
+			fields: []string{"name", "limit.algorithm"},
+		},
+		{
+			name:   "UnknownAlgorithm",
+			config: Config{Name: "test", Limit: LimitConfig{Algorithm: "bogus"}},
+			fields: []string{"limit.algorithm"},
+		},
+		{
+			name:   "UnsupportedMinLimit",
+			config: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas, MinLimit: 5}},
+			fields: []string{"limit.minLimit"},
+		},
+		{
+			name:   "FixedRequiresLimit",
+			config: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmFixed}},
+			fields: []string{"limit.initialLimit"},
+		},
+		{
+			name: "WrongSection",
+			config: Config{Name: "test", Limit: LimitConfig{
+				Algorithm: AlgorithmVegas, Gradient2: &Gradient2Config{}}},
+			fields: []string{"limit.gradient2"},
+		},
+		{
+			name: "ExplicitZero",
+			config: Config{Name: "test", Limit: LimitConfig{
+				Algorithm: AlgorithmGradient,
+				Gradient:  &GradientConfig{Smoothing: &zero, RTTTolerance: &zero, QueueSize: -1},
+			}},
+			fields: []string{"limit.gradient.smoothing", "limit.gradient.rttTolerance", "limit.gradient.queueSize"},
+		},
+		{
+			name: "Gradient2ExplicitZero",
+			config: Config{Name: "test", Limit: LimitConfig{
+				Algorithm: AlgorithmGradient2,
+				Gradient2: &Gradient2Config{RTTTolerance: &zero, DriftRecovery: &DriftRecoveryConfig{
+					Ratio: &zero, Decay: &zero}},
+			}},
+			fields: []string{
+				"limit.gradient2.rttTolerance",
+				"limit.gradient2.driftRecovery.ratio",
+				"limit.gradient2.driftRecovery.decay",
+			},
+		},
+		{
+			name: "Partitions",
+			config: Config{
+				Name:  "test",
+				Limit: LimitConfig{Algorithm: AlgorithmVegas},
+				Strategy: StrategyConfig{Type: StrategyLookupPartition, Partitions: []PartitionConfig{
+					{Name: "a", Percent: 0.7},
+					{Name: "a", Percent: 0.7},
+					{Percent: 0},
+				}},
+			},
+			fields: []string{
+				"strategy.partitions[1].name",
+				"strategy.partitions[2].name",
+				"strategy.partitions[2].percent",
+				"strategy.partitions",
+			},
+		},
+		{
+			name: "PartitionsRequired",
+			config: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas},
+				Strategy: StrategyConfig{Type: StrategyLookupPartition}},
+			fields: []string{"strategy.partitions"},
+		},
+		{
+			name: "UnknownStrategy",
+			config: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas},
+				Strategy: StrategyConfig{Type: "bogus"}},
+			fields: []string{"strategy.type"},
+		},
+		{
+			name: "Limiter",
+			config: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas},
+				Limiter: LimiterConfig{
+					MinWindowTime: Duration(2 * time.Second), MaxWindowTime: Duration(time.Second), WindowSize: 1}},
+			fields: []string{"limiter.maxWindowTime", "limiter.windowSize"},
+		},
+		{
+			name: "BlockingAndQueue",
+			config: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas},
+				Blocking: &BlockingConfig{}, Queue: &QueueConfig{Ordering: "random", MaxBacklogSize: -1, CoDelTarget: -1}},
+			fields: []string{"queue", "queue.ordering", "queue.maxBacklogSize", "queue.codelTarget"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t2 *testing.T) {
+			t2.Parallel()
+			asrt := assert.New(t2)
+			err := c.config.Validate()
+			asrt.Equal(c.fields, fields(err))
+		})
+	}
+
+	t.Run("ErrorMessage", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		config := Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmGradient2,
+			Gradient2: &Gradient2Config{Smoothing: &zero}}}
+		err := config.Validate()
+		asrt.EqualError(err, "invalid limiter config: limit.gradient2.smoothing: must be in (0, 1]")
+	})
+}
@@ -0,0 +1,32 @@
+// Package stack builds a complete limiter stack (limit algorithm, enforcement strategy and partitions, default
+// limiter, blocking or queue wrapper and metric registry) from a declarative YAML or JSON document, so that limits can
+// be tuned by configuration instead of code changes.
+//
+// Example document:
+//
+//	name: my-service
+//	limit:
+//	  algorithm: gradient2
+//	  initialLimit: 20
+//	  maxLimit: 200
+//	  gradient2:
+//	    smoothing: 0.2
+//	strategy:
+//	  type: lookupPartition
+//	  partitions:
+//	    - name: live
+//	      percent: 0.8
+//	    - name: batch
+//	      percent: 0.2
+//	queue:
+//	  ordering: fifo
+//	  maxBacklogSize: 50
+//	  maxBacklogTimeout: 250ms
+//	metrics:
+//	  registry: datadog
+//	  tags: ["env:prod"]
+//
+// Validation errors name the offending field by its path in the document, for example
+// "strategy.partitions[1].name: duplicate partition "live"".  The parameters of the limit algorithm are validated by
+// the config of the limit, i.e. limit.Gradient2LimitConfig, which also supplies the defaults of unset fields.
+package stack
@@ -0,0 +1,45 @@
+package stack
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is a validation error of a single field, identified by its path in the document.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationError holds every field error found while validating a Config.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return "invalid limiter config: " + strings.Join(messages, "; ")
+}
+
+// validator collects field errors.
+type validator struct {
+	errors []*FieldError
+}
+
+func (v *validator) errorf(field string, format string, args ...interface{}) {
+	v.errors = append(v.errors, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
@@ -0,0 +1,62 @@
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseYAML will decode a YAML document into a Config.  Unknown fields are an error.
+func ParseYAML(data []byte) (Config, error) {
+	config := Config{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("invalid limiter config: %w", err)
+	}
+	return config, nil
+}
+
+// ParseJSON will decode a JSON document into a Config.  Unknown fields are an error.
+func ParseJSON(data []byte) (Config, error) {
+	config := Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return Config{}, fmt.Errorf("invalid limiter config: %w", err)
+	}
+	return config, nil
+}
+
+// LoadFile will read and validate a Config from a file, files ending in .json are decoded as JSON and all others as
+// YAML.
+func LoadFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return parseFile(path, data)
+}
+
+// parseFile will decode and validate the contents of a config file according to its extension.
+func parseFile(path string, data []byte) (Config, error) {
+	var config Config
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		config, err = ParseJSON(data)
+	} else {
+		config, err = ParseYAML(data)
+	}
+	if err != nil {
+		return Config{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
@@ -0,0 +1,117 @@
+package stack
+
+import (
+	"reflect"
+
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+)
+
+// Config returns the config the stack was built with or last reloaded from.
+func (s *Stack) Config() Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.config
+}
+
+// Reload will atomically apply the limit algorithm parameters of config to the running stack without resetting the
+// learned limit or RTT baseline, so initialLimit is ignored by the adaptive algorithms.  A settable limit is set to
+// the new initialLimit.  If the limit is outside new bounds it is clamped and the strategy is updated immediately.
+//
+// Only the parameters of the limit section can be reloaded, any other difference from the current config is reported
+// as a *ValidationError and nothing is applied.
+func (s *Stack) Reload(config Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v := &validator{}
+	current := s.config
+	fixed := func(field string, a interface{}, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			v.errorf(field, "can not be changed without rebuilding the limiter")
+		}
+	}
+	fixed("name", current.Name, config.Name)
+	fixed("limit.algorithm", current.Limit.Algorithm, config.Limit.Algorithm)
+	fixed("limit.windowed", current.Limit.Windowed, config.Limit.Windowed)
+	if config.Limit.Algorithm == AlgorithmFixed {
+		fixed("limit.initialLimit", current.Limit.InitialLimit, config.Limit.InitialLimit)
+	}
+	fixed("strategy", current.Strategy, config.Strategy)
+	fixed("limiter", current.Limiter, config.Limiter)
+	fixed("blocking", current.Blocking, config.Blocking)
+	fixed("queue", current.Queue, config.Queue)
+	fixed("metrics", current.Metrics, config.Metrics)
+	if err := v.err(); err != nil {
+		return err
+	}
+
+	if err := s.update(config.Limit); err != nil {
+		return fieldError("limit", err)
+	}
+	s.config = config
+	s.Strategy.SetLimit(s.Limit.EstimatedLimit())
+	return nil
+}
+
+// update will apply the parameters of c to the limit algorithm, resolving unset fields to the defaults of the config
+// of the limit just as Build does.
+func (s *Stack) update(c LimitConfig) error {
+	switch l := s.algorithm.(type) {
+	case *limit.VegasLimit:
+		config := vegasConfig(c, s.clock)
+		config.ApplyDefaults()
+		return l.Update(limit.VegasLimitUpdate{
+			MaxLimit:        &config.MaxLimit,
+			Smoothing:       &config.Smoothing,
+			ProbeMultiplier: &config.ProbeMultiplier,
+			Idle:            changedIdle(l.Config().Idle, config.Idle),
+		})
+	case *limit.GradientLimit:
+		config := gradientConfig(c)
+		config.ApplyDefaults()
+		return l.Update(limit.GradientLimitUpdate{
+			MinLimit:      &config.MinLimit,
+			MaxLimit:      &config.MaxLimit,
+			Smoothing:     &config.Smoothing,
+			RTTTolerance:  &config.RTTTolerance,
+			ProbeInterval: &config.ProbeInterval,
+			QueueSizeFunc: config.QueueSizeFunc,
+		})
+	case *limit.Gradient2Limit:
+		config := gradient2Config(c, s.clock)
+		config.ApplyDefaults()
+		return l.Update(limit.Gradient2LimitUpdate{
+			MinLimit:      &config.MinLimit,
+			MaxLimit:      &config.MaxLimit,
+			Smoothing:     &config.Smoothing,
+			RTTTolerance:  &config.RTTTolerance,
+			DriftRecovery: &config.DriftRecovery,
+			UseAverageRTT: &config.UseAverageRTT,
+			Idle:          changedIdle(l.Config().Idle, config.Idle),
+			QueueSizeFunc: config.QueueSizeFunc,
+		})
+	case *limit.AIMDLimit:
+		config := aimdConfig(c, s.clock)
+		config.ApplyDefaults()
+		return l.Update(limit.AIMDLimitUpdate{
+			BackOffRatio: &config.BackOffRatio,
+			IncreaseBy:   &config.IncreaseBy,
+			Idle:         changedIdle(l.Config().Idle, config.Idle),
+		})
+	case *limit.SettableLimit:
+		l.SetLimit(c.InitialLimit)
+	}
+	return nil
+}
+
+// changedIdle returns the idle policy to update a limit with, or nil if it is unchanged so that a reload does not
+// restart the idle period of the limit.
+func changedIdle(current limit.IdlePolicy, policy limit.IdlePolicy) *limit.IdlePolicy {
+	if current == policy {
+		return nil
+	}
+	return &policy
+}
@@ -0,0 +1,152 @@
+package stack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+	"github.com/platinummonkey/go-concurrency-limits/limit"
+)
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ClampsMaxLimit", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		config := Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmGradient2, InitialLimit: 50}}
+		s, err := Build(config, Options{})
+		asrt.NoError(err)
+		s.Limit.OnSample(0, 10, 50, false)
+		longRTT := core.SnapshotOf(s.Limit).Attributes["longRTT"]
+
+		config.Limit.MaxLimit = 20
+		asrt.NoError(s.Reload(config))
+		asrt.Equal(20, s.Limit.EstimatedLimit())
+		// the strategy is updated without waiting for the next sample window
+		asrt.Equal(20, core.SnapshotOf(s.Strategy).Limit)
+		asrt.Equal(longRTT, core.SnapshotOf(s.Limit).Attributes["longRTT"])
+		asrt.Equal(20, s.Config().Limit.MaxLimit)
+	})
+
+	t.Run("Algorithms", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		smoothing := 0.5
+		tolerance := 3.0
+		reloads := []struct {
+			before Config
+			after  Config
+			check  func(s *Stack)
+		}{
+			{
+				before: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas}},
+				after: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas, MaxLimit: 10,
+					Vegas: &VegasConfig{Smoothing: &smoothing, Idle: &IdleConfig{Timeout: Duration(time.Minute),
+						Rebaseline: true}}}},
+				check: func(s *Stack) {
+					asrt.Equal(10, s.Limit.EstimatedLimit())
+					asrt.Equal(0.5, core.SnapshotOf(s.Limit).Attributes["smoothing"])
+					asrt.Equal(limit.IdlePolicy{Timeout: time.Minute, Rebaseline: true, Clock: core.SystemClockInstance},
+						s.Limit.(*limit.VegasLimit).Config().Idle)
+				},
+			},
+			{
+				before: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmGradient}},
+				after: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmGradient, MinLimit: 60,
+					MaxLimit: 100, Gradient: &GradientConfig{RTTTolerance: &tolerance, ProbeInterval: -1}}},
+				check: func(s *Stack) {
+					asrt.Equal(60, s.Limit.EstimatedLimit())
+					asrt.Equal(limit.ProbeDisabled, core.SnapshotOf(s.Limit).Attributes["resetRTTCounter"])
+				},
+			},
+			{
+				before: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmGradient2}},
+				after: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmGradient2,
+					Gradient2: &Gradient2Config{RTTTolerance: &tolerance, UseAverageRTT: true,
+						DriftRecovery: &DriftRecoveryConfig{Decay: &smoothing},
+						Idle:          &IdleConfig{Timeout: Duration(time.Second)}}}},
+				check: func(s *Stack) {
+					config := s.Limit.(*limit.Gradient2Limit).Config()
+					asrt.Equal(3.0, config.RTTTolerance)
+					asrt.Equal(limit.Gradient2DriftRecovery{Ratio: 2, Decay: 0.5}, config.DriftRecovery)
+					asrt.True(config.UseAverageRTT)
+					asrt.Equal(time.Second, config.Idle.Timeout)
+				},
+			},
+			{
+				before: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmAIMD}},
+				after: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmAIMD,
+					AIMD: &AIMDConfig{BackOffRatio: &smoothing, IncreaseBy: 2}}},
+				check: func(s *Stack) {
+					asrt.Equal(0.5, s.Limit.(*limit.AIMDLimit).BackOffRatio())
+					asrt.Equal(2, core.SnapshotOf(s.Limit).Attributes["increaseBy"])
+				},
+			},
+			{
+				before: Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmSettable, InitialLimit: 10}},
+				after:  Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmSettable, InitialLimit: 3}},
+				check: func(s *Stack) {
+					asrt.Equal(3, s.Limit.EstimatedLimit())
+					asrt.Equal(3, core.SnapshotOf(s.Strategy).Limit)
+				},
+			},
+		}
+		for _, r := range reloads {
+			s, err := Build(r.before, Options{})
+			asrt.NoError(err)
+			asrt.NoError(s.Reload(r.after), r.after.Limit.Algorithm)
+			r.check(s)
+		}
+	})
+
+	t.Run("Boundaries", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		zero := 0.0
+		one := 1.0
+		// a document that builds can be reloaded unchanged, and the same document is rejected by both
+		config := Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas, Vegas: &VegasConfig{Smoothing: &one}}}
+		s, err := Build(config, Options{})
+		asrt.NoError(err)
+		asrt.NoError(s.Reload(config))
+		asrt.Equal(1.0, core.SnapshotOf(s.Limit).Attributes["smoothing"])
+
+		config.Limit.Vegas.Smoothing = &zero
+		_, err = Build(config, Options{})
+		asrt.Equal([]string{"limit.vegas.smoothing"}, fields(err))
+		asrt.Equal([]string{"limit.vegas.smoothing"}, fields(s.Reload(config)))
+
+		config = Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmAIMD, AIMD: &AIMDConfig{BackOffRatio: &zero}}}
+		_, err = Build(config, Options{})
+		asrt.Equal([]string{"limit.aimd.backOffRatio"}, fields(err))
+		config.Limit.AIMD.BackOffRatio = &one
+		_, err = Build(config, Options{})
+		asrt.Equal([]string{"limit"}, fields(err))
+	})
+
+	t.Run("RequiresRebuild", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		config := Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmFixed, InitialLimit: 10}}
+		s, err := Build(config, Options{})
+		asrt.NoError(err)
+
+		changed := Config{
+			Name:     "other",
+			Limit:    LimitConfig{Algorithm: AlgorithmFixed, InitialLimit: 20},
+			Strategy: StrategyConfig{Type: StrategyPrecise},
+			Queue:    &QueueConfig{},
+		}
+		err = s.Reload(changed)
+		asrt.Equal([]string{"name", "limit.initialLimit", "strategy", "queue"}, fields(err))
+		asrt.Equal(10, s.Limit.EstimatedLimit())
+		asrt.Equal(config, s.Config())
+
+		changed = Config{Name: "test", Limit: LimitConfig{Algorithm: AlgorithmVegas}}
+		asrt.Equal([]string{"limit.algorithm"}, fields(s.Reload(changed)))
+		asrt.Equal([]string{"name"}, fields(s.Reload(Config{Limit: config.Limit})))
+	})
+}
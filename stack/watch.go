@@ -0,0 +1,124 @@
+package stack
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/platinummonkey/go-concurrency-limits/core"
+)
+
+// FileWatcher is a config source that polls a config file and hands every new valid version of it to a callback,
+// typically Stack.Reload.  Polling keeps it free of platform specific file notification and works with files that are
+// replaced by a rename, as done by Kubernetes config maps.
+type FileWatcher struct {
+	path     string
+	interval time.Duration
+	apply    func(config Config) error
+	onError  func(err error)
+	clock    core.Clock
+
+	mu   sync.Mutex
+	last []byte
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewFileWatcher will create a FileWatcher that checks path every interval (default 10 seconds) and calls apply with
+// the new config whenever the file content changes.  Read, parse, validation and apply errors are passed to onError,
+// which may be nil, and the previous config stays in effect.
+func NewFileWatcher(
+	path string,
+	interval time.Duration,
+	apply func(config Config) error,
+	onError func(err error),
+) *FileWatcher {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if onError == nil {
+		onError = func(err error) {}
+	}
+	return &FileWatcher{
+		path:     path,
+		interval: interval,
+		apply:    apply,
+		onError:  onError,
+		clock:    core.SystemClockInstance,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// SetClock will set the clock used to schedule checks, it must be called before Start.
+func (w *FileWatcher) SetClock(clock core.Clock) {
+	if clock == nil {
+		clock = core.SystemClockInstance
+	}
+	w.clock = clock
+}
+
+// Check will read the file and apply it if the content changed since the last check, returning whether it was
+// applied.  Content that failed to apply is not retried until it changes again.
+func (w *FileWatcher) Check() (bool, error) {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return false, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.last != nil && bytes.Equal(data, w.last) {
+		return false, nil
+	}
+	w.last = data
+
+	config, err := parseFile(w.path, data)
+	if err != nil {
+		return false, err
+	}
+	if err := w.apply(config); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Start will check the file immediately and then every interval in a background goroutine until Stop is called.
+func (w *FileWatcher) Start() {
+	w.startOnce.Do(func() {
+		go w.run()
+	})
+}
+
+// Stop will stop a started watcher and wait for it to exit.
+func (w *FileWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	started := true
+	w.startOnce.Do(func() {
+		started = false
+	})
+	if started {
+		<-w.done
+	}
+}
+
+func (w *FileWatcher) run() {
+	defer close(w.done)
+	for {
+		if _, err := w.Check(); err != nil {
+			w.onError(err)
+		}
+		timer := w.clock.NewTimer(w.interval)
+		select {
+		case <-w.stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package stack
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/platinummonkey/go-concurrency-limits/clock"
+)
+
+func TestFileWatcher(t *testing.T) {
+	t.Parallel()
+
+	write := func(t *testing.T, path string, maxLimit string) {
+		data := "name: test\nlimit:\n  algorithm: gradient2\n  initialLimit: 50\n  minLimit: 5\n  maxLimit: " +
+			maxLimit + "\n"
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	}
+
+	t.Run("Check", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		path := filepath.Join(t2.TempDir(), "limiter.yaml")
+		write(t2, path, "100")
+		config, err := LoadFile(path)
+		asrt.NoError(err)
+		s, err := Build(config, Options{})
+		asrt.NoError(err)
+
+		w := NewFileWatcher(path, time.Second, s.Reload, nil)
+		applied, err := w.Check()
+		asrt.NoError(err)
+		asrt.True(applied)
+		applied, err = w.Check()
+		asrt.NoError(err)
+		asrt.False(applied)
+
+		write(t2, path, "10")
+		applied, err = w.Check()
+		asrt.NoError(err)
+		asrt.True(applied)
+		asrt.Equal(10, s.Limit.EstimatedLimit())
+
+		// invalid content keeps the previous config and is reported once
+		write(t2, path, "-1")
+		applied, err = w.Check()
+		asrt.Equal([]string{"limit"}, fields(err))
+		asrt.False(applied)
+		applied, err = w.Check()
+		asrt.NoError(err)
+		asrt.False(applied)
+		asrt.Equal(10, s.Config().Limit.MaxLimit)
+
+		asrt.NoError(os.Remove(path))
+		_, err = w.Check()
+		asrt.Error(err)
+	})
+
+	t.Run("Start", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		path := filepath.Join(t2.TempDir(), "limiter.yaml")
+		write(t2, path, "100")
+
+		mu := sync.Mutex{}
+		applied := make([]int, 0)
+		errs := make([]error, 0)
+		fakeClock := clock.NewFakeClock(time.Unix(0, 0))
+		w := NewFileWatcher(path, time.Second, func(config Config) error {
+			mu.Lock()
+			defer mu.Unlock()
+			applied = append(applied, config.Limit.MaxLimit)
+			if config.Limit.MaxLimit == 30 {
+				return errors.New("rejected")
+			}
+			return nil
+		}, func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		})
+		w.SetClock(fakeClock)
+		w.Start()
+		fakeClock.BlockUntil(1)
+
+		write(t2, path, "20")
+		fakeClock.Advance(time.Second)
+		fakeClock.BlockUntil(1)
+		write(t2, path, "30")
+		fakeClock.Advance(time.Second)
+		fakeClock.BlockUntil(1)
+		w.Stop()
+		w.Stop()
+
+		mu.Lock()
+		defer mu.Unlock()
+		asrt.Equal([]int{100, 20, 30}, applied)
+		asrt.Len(errs, 1)
+	})
+
+	t.Run("StopWithoutStart", func(t2 *testing.T) {
+		t2.Parallel()
+		w := NewFileWatcher("missing.yaml", 0, func(Config) error { return nil }, nil)
+		w.Stop()
+		w.Start()
+	})
+}
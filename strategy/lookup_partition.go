@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 
 	"github.com/platinummonkey/go-concurrency-limits/core"
@@ -21,6 +22,7 @@ type LookupPartition struct {
 	MetricSampleListener core.MetricSampleListener
 	limit                int32
 	busy                 int32
+	rejected             uint64
 	mu                   sync.RWMutex
 }
 
@@ -77,26 +79,63 @@ func (p *LookupPartition) UpdateLimit(totalLimit int32) {
 // IsLimitExceeded will return true of the number of requests in flight >= limit
 // note: not thread safe.
 func (p *LookupPartition) IsLimitExceeded() bool {
+	return p.isLimitExceededN(1)
+}
+
+// isLimitExceededN will return true if acquiring weight more units would exceed the limit.
+func (p *LookupPartition) isLimitExceededN(weight int32) bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return p.busy >= p.limit
+	return p.busy+weight > p.limit
 }
 
 // Acquire from the worker pool
 // note: not to be used directly, not thread safe.
 func (p *LookupPartition) Acquire() {
+	p.acquireN(1)
+}
+
+func (p *LookupPartition) acquireN(weight int32) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.busy++
+	p.busy += weight
 	p.MetricSampleListener.AddSample(float64(p.busy))
 }
 
 // Release from the worker pool
 // note: not to be used directly, not thread safe.
 func (p *LookupPartition) Release() {
+	p.releaseN(1)
+}
+
+func (p *LookupPartition) releaseN(weight int32) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.busy--
+	p.busy -= weight
+}
+
+// Reject records a rejected acquisition attempt against this partition.
+// note: not to be used directly.
+func (p *LookupPartition) Reject() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rejected++
+}
+
+// Snapshot returns the current state of the partition.
+func (p *LookupPartition) Snapshot() core.Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return core.Snapshot{
+		Type:     "LookupPartition",
+		Name:     p.name,
+		Limit:    int(p.limit),
+		InFlight: int(p.busy),
+		Rejected: p.rejected,
+		Attributes: map[string]interface{}{
+			"percent": p.percent,
+		},
+	}
 }
 
 // Name will return the partition name, these are immutable.
@@ -123,9 +162,10 @@ type LookupPartitionStrategy struct {
 	unknownPartition *LookupPartition
 	lookupFunc       func(ctx context.Context) string
 
-	mu    sync.RWMutex
-	busy  int32
-	limit int32
+	mu       sync.RWMutex
+	busy     int32
+	limit    int32
+	rejected uint64
 }
 
 // NewLookupPartitionStrategyWithMetricRegistry will create a new LookupPartitionStrategy
@@ -195,6 +235,16 @@ func (s *LookupPartitionStrategy) RemovePartition(name string) (int, bool) {
 
 // TryAcquire a token from a partition
 func (s *LookupPartitionStrategy) TryAcquire(ctx context.Context) (token core.StrategyToken, ok bool) {
+	return s.TryAcquireN(ctx, 1)
+}
+
+// TryAcquireN a token worth weight units from a partition.  A request is only rejected if both the total limit and
+// the partition limit would be exceeded.
+func (s *LookupPartitionStrategy) TryAcquireN(ctx context.Context, weight int) (token core.StrategyToken, ok bool) {
+	if weight < 1 {
+		weight = 1
+	}
+	w := int32(weight)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	partitionName := s.lookupFunc(ctx)
@@ -202,21 +252,23 @@ func (s *LookupPartitionStrategy) TryAcquire(ctx context.Context) (token core.St
 	if !ok {
 		partition = s.unknownPartition
 	}
-	if s.busy >= s.limit && partition.IsLimitExceeded() {
-		return core.NewNotAcquiredStrategyToken(int(s.busy)), false
+	if s.busy > 0 && s.busy+w > s.limit && partition.isLimitExceededN(w) {
+		s.rejected++
+		partition.Reject()
+		return core.NewRejectedStrategyToken(int(s.busy), core.NewPartitionRejectionError(partition.name)), false
 	}
 	// otherwise we can acquire
-	s.busy++
-	partition.Acquire()
-	return core.NewAcquiredStrategyToken(int(s.busy), s.releasePartition(partition)), true
+	s.busy += w
+	partition.acquireN(w)
+	return core.NewAcquiredStrategyToken(int(s.busy), s.releasePartition(partition, w)), true
 }
 
-func (s *LookupPartitionStrategy) releasePartition(partition *LookupPartition) func() {
+func (s *LookupPartitionStrategy) releasePartition(partition *LookupPartition, weight int32) func() {
 	return func() {
 		s.mu.Lock()
 		defer s.mu.Unlock()
-		s.busy--
-		partition.Release()
+		s.busy -= weight
+		partition.releaseN(weight)
 	}
 }
 
@@ -272,6 +324,32 @@ func (s *LookupPartitionStrategy) BinLimit(key string) (int, error) {
 	return partition.Limit(), nil
 }
 
+// Snapshot returns the current state of the strategy including the snapshots of all partitions.  The unknown
+// partition is always reported last.
+func (s *LookupPartitionStrategy) Snapshot() core.Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.partitions))
+	for name := range s.partitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	partitions := make([]*core.Snapshot, 0, len(s.partitions)+1)
+	for _, name := range names {
+		p := s.partitions[name].Snapshot()
+		partitions = append(partitions, &p)
+	}
+	unknown := s.unknownPartition.Snapshot()
+	partitions = append(partitions, &unknown)
+	return core.Snapshot{
+		Type:       "LookupPartitionStrategy",
+		Limit:      int(s.limit),
+		InFlight:   int(s.busy),
+		Rejected:   s.rejected,
+		Partitions: partitions,
+	}
+}
+
 func (s *LookupPartitionStrategy) String() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
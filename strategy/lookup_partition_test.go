@@ -160,6 +160,7 @@ func TestLookupPartitionStrategy(t *testing.T) {
 		if token != nil {
 			asrt.False(token.IsAcquired())
 		}
+		asrt.Equal(core.NewPartitionRejectionError("batch"), token.(core.RejectingStrategyToken).Rejection())
 
 		// now try live
 		token, ok = strategy.TryAcquire(ctxLive)
@@ -343,4 +344,34 @@ func TestLookupPartitionStrategy(t *testing.T) {
 		_, err = strategy.BinLimit("test1")
 		asrt.Error(err)
 	})
+
+	t.Run("Snapshot", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		strategy, err := NewLookupPartitionStrategyWithMetricRegistry(
+			makeTestLookupPartitions(),
+			nil,
+			1,
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+
+		ctx := context.WithValue(context.Background(), matchers.LookupPartitionContextKey, "batch")
+		token, ok := strategy.TryAcquire(ctx)
+		asrt.True(ok)
+		_, ok = strategy.TryAcquire(ctx)
+		asrt.False(ok)
+
+		snapshot := strategy.Snapshot()
+		asrt.Equal("LookupPartitionStrategy", snapshot.Type)
+		asrt.Equal(1, snapshot.Limit)
+		asrt.Equal(1, snapshot.InFlight)
+		asrt.Equal(uint64(1), snapshot.Rejected)
+		asrt.Len(snapshot.Partitions, 3)
+		asrt.Equal("batch", snapshot.Partitions[0].Name)
+		asrt.Equal(1, snapshot.Partitions[0].InFlight)
+		asrt.Equal(uint64(1), snapshot.Partitions[0].Rejected)
+		asrt.Equal("<unknown>", snapshot.Partitions[2].Name)
+		token.Release()
+	})
 }
@@ -15,6 +15,7 @@ type PreciseStrategy struct {
 	mu             sync.Mutex
 	inFlight       int32
 	limit          int32
+	rejected       uint64
 	metricListener core.MetricSampleListener
 }
 
@@ -42,21 +43,36 @@ func NewPreciseStrategyWithMetricRegistry(limit int, registry core.MetricRegistr
 // context Context of the request for partitioned limits.
 // returns not ok if limit is exceeded, or a StrategyToken that must be released when the operation completes.
 func (s *PreciseStrategy) TryAcquire(ctx context.Context) (token core.StrategyToken, ok bool) {
+	return s.TryAcquireN(ctx, 1)
+}
+
+// TryAcquireN will try to acquire a token worth weight units from the limiter.  A weight larger than the limit is
+// only admitted when nothing else is in flight so heavy requests are never starved indefinitely.
+// context Context of the request for partitioned limits.
+// returns not ok if limit is exceeded, or a StrategyToken that must be released when the operation completes.
+func (s *PreciseStrategy) TryAcquireN(ctx context.Context, weight int) (token core.StrategyToken, ok bool) {
+	if weight < 1 {
+		weight = 1
+	}
+	w := int32(weight)
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.inFlight >= s.limit {
+	if s.inFlight > 0 && s.inFlight+w > s.limit {
 		s.metricListener.AddSample(float64(s.inFlight))
+		s.rejected++
 		return core.NewNotAcquiredStrategyToken(int(s.inFlight)), false
 	}
-	s.inFlight++
+	s.inFlight += w
 	s.metricListener.AddSample(float64(s.inFlight))
-	return core.NewAcquiredStrategyToken(int(s.inFlight), s.releaseHandler), true
+	return core.NewAcquiredStrategyToken(int(s.inFlight), s.releaseHandler(w)), true
 }
 
-func (s *PreciseStrategy) releaseHandler() {
-	s.mu.Lock()
-	s.inFlight--
-	s.mu.Unlock()
+func (s *PreciseStrategy) releaseHandler(weight int32) func() {
+	return func() {
+		s.mu.Lock()
+		s.inFlight -= weight
+		s.mu.Unlock()
+	}
 }
 
 // SetLimit will update the strategy with a new limit.
@@ -83,6 +99,18 @@ func (s *PreciseStrategy) GetBusyCount() int {
 	return int(s.inFlight)
 }
 
+// Snapshot returns the current state of the strategy.
+func (s *PreciseStrategy) Snapshot() core.Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return core.Snapshot{
+		Type:     "PreciseStrategy",
+		Limit:    int(s.limit),
+		InFlight: int(s.inFlight),
+		Rejected: s.rejected,
+	}
+}
+
 func (s *PreciseStrategy) String() string {
 	s.mu.Lock()
 	defer s.mu.Unlock()
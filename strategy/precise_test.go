@@ -112,4 +112,36 @@ func TestPreciseStrategy(t *testing.T) {
 		asrt.True(token.IsAcquired(), "expected acquired token")
 		asrt.Equal(1, strategy.GetBusyCount(), "expected 1 resource taken")
 	})
+
+	t.Run("TryAcquireN", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		strategy := NewPreciseStrategy(10)
+
+		token, ok := strategy.TryAcquireN(context.Background(), 6)
+		asrt.True(ok)
+		asrt.Equal(6, token.InFlightCount())
+		asrt.Equal(6, strategy.GetBusyCount())
+
+		// 6 + 5 would exceed the limit of 10
+		_, ok = strategy.TryAcquireN(context.Background(), 5)
+		asrt.False(ok)
+
+		token2, ok := strategy.TryAcquireN(context.Background(), 4)
+		asrt.True(ok)
+		asrt.Equal(10, token2.InFlightCount())
+
+		token.Release()
+		token2.Release()
+		asrt.Equal(0, strategy.GetBusyCount())
+
+		// a weight larger than the limit is admitted only when idle
+		token, ok = strategy.TryAcquireN(context.Background(), 20)
+		asrt.True(ok)
+		asrt.Equal(20, strategy.GetBusyCount())
+		_, ok = strategy.TryAcquire(context.Background())
+		asrt.False(ok)
+		token.Release()
+		asrt.Equal(0, strategy.GetBusyCount())
+	})
 }
@@ -18,6 +18,7 @@ type PredicatePartition struct {
 	predicate            func(ctx context.Context) bool
 	limit                int32
 	busy                 int32
+	rejected             uint64
 
 	mu sync.RWMutex
 }
@@ -72,26 +73,63 @@ func (p *PredicatePartition) UpdateLimit(totalLimit int32) {
 // IsLimitExceeded will return true of the number of requests in flight >= limit
 // note: not thread safe.
 func (p *PredicatePartition) IsLimitExceeded() bool {
+	return p.isLimitExceededN(1)
+}
+
+// isLimitExceededN will return true if acquiring weight more units would exceed the limit.
+func (p *PredicatePartition) isLimitExceededN(weight int32) bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
-	return p.busy >= p.limit
+	return p.busy+weight > p.limit
 }
 
 // Acquire from the worker pool
 // note: not to be used directly, not thread safe.
 func (p *PredicatePartition) Acquire() {
+	p.acquireN(1)
+}
+
+func (p *PredicatePartition) acquireN(weight int32) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.busy++
+	p.busy += weight
 	p.MetricSampleListener.AddSample(float64(p.busy))
 }
 
 // Release from the worker pool
 // note: not to be used directly, not thread safe.
 func (p *PredicatePartition) Release() {
+	p.releaseN(1)
+}
+
+func (p *PredicatePartition) releaseN(weight int32) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.busy--
+	p.busy -= weight
+}
+
+// Reject records a rejected acquisition attempt against this partition.
+// note: not to be used directly.
+func (p *PredicatePartition) Reject() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rejected++
+}
+
+// Snapshot returns the current state of the partition.
+func (p *PredicatePartition) Snapshot() core.Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return core.Snapshot{
+		Type:     "PredicatePartition",
+		Name:     p.name,
+		Limit:    int(p.limit),
+		InFlight: int(p.busy),
+		Rejected: p.rejected,
+		Attributes: map[string]interface{}{
+			"percent": p.percent,
+		},
+	}
 }
 
 // Name will return the partition name, these are immutable.
@@ -122,9 +160,10 @@ func (p *PredicatePartition) String() string {
 type PredicatePartitionStrategy struct {
 	partitions []*PredicatePartition
 
-	mu    sync.RWMutex
-	busy  int32
-	limit int32
+	mu       sync.RWMutex
+	busy     int32
+	limit    int32
+	rejected uint64
 }
 
 // NewPredicatePartitionStrategyWithMetricRegistry will create a new PredicatePartitionStrategy
@@ -196,28 +235,41 @@ func (s *PredicatePartitionStrategy) RemovePartitionsMatching(matcher context.Co
 
 // TryAcquire a token from a partition
 func (s *PredicatePartitionStrategy) TryAcquire(ctx context.Context) (core.StrategyToken, bool) {
+	return s.TryAcquireN(ctx, 1)
+}
+
+// TryAcquireN a token worth weight units from the first partition matching the context.  A request is only rejected
+// if both the total limit and the partition limit would be exceeded.
+func (s *PredicatePartitionStrategy) TryAcquireN(ctx context.Context, weight int) (core.StrategyToken, bool) {
+	if weight < 1 {
+		weight = 1
+	}
+	w := int32(weight)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	for _, p := range s.partitions {
 		if p.predicate(ctx) {
-			if s.busy >= s.limit && p.IsLimitExceeded() {
+			if s.busy > 0 && s.busy+w > s.limit && p.isLimitExceededN(w) {
 				// limit exceeded on this partition
-				return core.NewNotAcquiredStrategyToken(int(s.busy)), false
+				s.rejected++
+				p.Reject()
+				return core.NewRejectedStrategyToken(int(s.busy), core.NewPartitionRejectionError(p.name)), false
 			}
-			s.busy++
-			p.Acquire()
-			return core.NewAcquiredStrategyToken(int(s.busy), s.releasePartition(p)), true
+			s.busy += w
+			p.acquireN(w)
+			return core.NewAcquiredStrategyToken(int(s.busy), s.releasePartition(p, w)), true
 		}
 	}
+	s.rejected++
 	return core.NewNotAcquiredStrategyToken(int(s.busy)), false
 }
 
-func (s *PredicatePartitionStrategy) releasePartition(partition *PredicatePartition) func() {
+func (s *PredicatePartitionStrategy) releasePartition(partition *PredicatePartition, weight int32) func() {
 	return func() {
 		s.mu.Lock()
 		defer s.mu.Unlock()
-		s.busy--
-		partition.Release()
+		s.busy -= weight
+		partition.releaseN(weight)
 	}
 }
 
@@ -273,6 +325,24 @@ func (s *PredicatePartitionStrategy) BinLimit(idx int) (int, error) {
 	return partition.Limit(), nil
 }
 
+// Snapshot returns the current state of the strategy including the snapshots of all partitions.
+func (s *PredicatePartitionStrategy) Snapshot() core.Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	partitions := make([]*core.Snapshot, 0, len(s.partitions))
+	for _, p := range s.partitions {
+		ps := p.Snapshot()
+		partitions = append(partitions, &ps)
+	}
+	return core.Snapshot{
+		Type:       "PredicatePartitionStrategy",
+		Limit:      int(s.limit),
+		InFlight:   int(s.busy),
+		Rejected:   s.rejected,
+		Partitions: partitions,
+	}
+}
+
 func (s *PredicatePartitionStrategy) String() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
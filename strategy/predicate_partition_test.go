@@ -164,6 +164,7 @@ func TestPredicatePartition(t *testing.T) {
 		if token != nil {
 			asrt.False(token.IsAcquired())
 		}
+		asrt.Equal(core.NewPartitionRejectionError("batch"), token.(core.RejectingStrategyToken).Rejection())
 
 		// now try live
 		token, ok = strategy.TryAcquire(ctxLive)
@@ -344,4 +345,39 @@ func TestPredicatePartition(t *testing.T) {
 		asrt.False(ok)
 		asrt.False(token.IsAcquired())
 	})
+
+	t.Run("TryAcquireN", func(t2 *testing.T) {
+		t2.Parallel()
+		asrt := assert.New(t2)
+		strategy, err := NewPredicatePartitionStrategyWithMetricRegistry(
+			makeTestPartitions(),
+			10,
+			core.EmptyMetricRegistryInstance,
+		)
+		asrt.NoError(err)
+		batchCtx := context.WithValue(context.Background(), matchers.StringPredicateContextKey, "batch")
+		liveCtx := context.WithValue(context.Background(), matchers.StringPredicateContextKey, "live")
+
+		// batch may borrow excess capacity
+		batchToken, ok := strategy.TryAcquireN(batchCtx, 8)
+		asrt.True(ok)
+		asrt.Equal(8, strategy.BusyCount())
+		busy, _ := strategy.BinBusyCount(0)
+		asrt.Equal(8, busy)
+
+		// total would be exceeded but live is still within its guaranteed 7
+		liveToken, ok := strategy.TryAcquireN(liveCtx, 7)
+		asrt.True(ok)
+		asrt.Equal(15, strategy.BusyCount())
+
+		// both the total and the batch partition limits are exceeded
+		_, ok = strategy.TryAcquireN(batchCtx, 1)
+		asrt.False(ok)
+
+		batchToken.Release()
+		liveToken.Release()
+		asrt.Equal(0, strategy.BusyCount())
+		busy, _ = strategy.BinBusyCount(1)
+		asrt.Equal(0, busy)
+	})
 }
@@ -13,6 +13,7 @@ import (
 type SimpleStrategy struct {
 	inFlight       *int32
 	limit          *int32
+	rejected       uint64
 	metricListener core.MetricSampleListener
 }
 
@@ -43,16 +44,36 @@ func NewSimpleStrategyWithMetricRegistry(limit int, registry core.MetricRegistry
 // context Context of the request for partitioned limits.
 // returns not ok if limit is exceeded, or a StrategyToken that must be released when the operation completes.
 func (s *SimpleStrategy) TryAcquire(ctx context.Context) (token core.StrategyToken, ok bool) {
-	inFlight := atomic.LoadInt32(s.inFlight)
-	if inFlight >= atomic.LoadInt32(s.limit) {
-		s.metricListener.AddSample(float64(inFlight))
-		return core.NewNotAcquiredStrategyToken(int(inFlight)), false
+	return s.TryAcquireN(ctx, 1)
+}
+
+// TryAcquireN will try to acquire a token worth weight units from the limiter.  A weight larger than the limit is
+// only admitted when nothing else is in flight so heavy requests are never starved indefinitely.
+// context Context of the request for partitioned limits.
+// returns not ok if limit is exceeded, or a StrategyToken that must be released when the operation completes.
+func (s *SimpleStrategy) TryAcquireN(ctx context.Context, weight int) (token core.StrategyToken, ok bool) {
+	if weight < 1 {
+		weight = 1
+	}
+	w := int32(weight)
+	var inFlight int32
+	for {
+		current := atomic.LoadInt32(s.inFlight)
+		if current > 0 && current+w > atomic.LoadInt32(s.limit) {
+			s.metricListener.AddSample(float64(current))
+			atomic.AddUint64(&s.rejected, 1)
+			return core.NewNotAcquiredStrategyToken(int(current)), false
+		}
+		// compare and swap so concurrent callers can never push the count over the limit
+		if atomic.CompareAndSwapInt32(s.inFlight, current, current+w) {
+			inFlight = current + w
+			break
+		}
 	}
-	inFlight = atomic.AddInt32(s.inFlight, 1)
 	s.metricListener.AddSample(float64(inFlight))
 	f := func(ref *int32) func() {
 		return func() {
-			atomic.AddInt32(ref, -1)
+			atomic.AddInt32(ref, -w)
 		}
 	}
 	return core.NewAcquiredStrategyToken(int(inFlight), f(s.inFlight)), true
@@ -76,6 +97,16 @@ func (s *SimpleStrategy) GetBusyCount() int {
 	return int(atomic.LoadInt32(s.inFlight))
 }
 
+// Snapshot returns the current state of the strategy.
+func (s *SimpleStrategy) Snapshot() core.Snapshot {
+	return core.Snapshot{
+		Type:     "SimpleStrategy",
+		Limit:    s.GetLimit(),
+		InFlight: s.GetBusyCount(),
+		Rejected: atomic.LoadUint64(&s.rejected),
+	}
+}
+
 func (s *SimpleStrategy) String() string {
 	return fmt.Sprintf("SimpleStrategy{inFlight=%d, limit=%d}", atomic.LoadInt32(s.inFlight), s.limit)
 }